@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/internal/config"
+	"github.com/joaoleau/blob/pgtest"
+)
+
+func TestMain(m *testing.M) { pgtest.Main(m) }
+
+// testAPI is the full router on a freshly migrated database.
+type testAPI struct {
+	t      *testing.T
+	server *gin.Engine
+	db     *sqlx.DB
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	db, dbConfig := pgtest.New(t)
+	cfg := config.Default()
+	cfg.Database = dbConfig
+	cfg.Storage.Dir = t.TempDir()
+	cfg.Features.LinkPreviews = false
+
+	ctx, cancel := context.WithCancel(context.Background())
+	server := gin.New()
+	workers, err := SetupRouters(ctx, server, db, &cfg)
+	if err != nil {
+		cancel()
+		t.Fatalf("SetupRouters: %v", err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		workers.Wait()
+	})
+
+	return &testAPI{t: t, server: server, db: db}
+}
+
+// signUp creates a user with a live session and returns its bearer token.
+func (a *testAPI) signUp(username string) string {
+	a.t.Helper()
+
+	userID := uuid.NewString()
+	token := uuid.NewString()
+	if _, err := a.db.Exec(`INSERT INTO "User" (id, email, username) VALUES ($1, $2, $3)`,
+		userID, username+"@example.com", username); err != nil {
+		a.t.Fatalf("insert user: %v", err)
+	}
+	if _, err := a.db.Exec(`INSERT INTO "Session" (id, user_id, expires, session_token) VALUES ($1, $2, $3, $4)`,
+		uuid.NewString(), userID, time.Now().Add(time.Hour), token); err != nil {
+		a.t.Fatalf("insert session: %v", err)
+	}
+	return token
+}
+
+// do sends body as JSON and decodes the JSON answer into out, when set.
+func (a *testAPI) do(token, method, path string, body, out interface{}) int {
+	a.t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			a.t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	a.server.ServeHTTP(recorder, req)
+
+	if out != nil {
+		if err := json.Unmarshal(recorder.Body.Bytes(), out); err != nil {
+			a.t.Fatalf("%s %s: decode %q: %v", method, path, recorder.Body.String(), err)
+		}
+	}
+	return recorder.Code
+}
+
+type envelope[T any] struct {
+	Data T `json:"data"`
+	Meta struct {
+		RequestID  string `json:"request_id"`
+		Pagination *struct {
+			TotalCount int  `json:"total_count"`
+			HasMore    bool `json:"has_more"`
+		} `json:"pagination"`
+	} `json:"meta"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type blobBody struct {
+	ID            string `json:"id"`
+	Content       string `json:"content"`
+	LikesCount    int    `json:"likes_count"`
+	CommentsCount int    `json:"comments_count"`
+	LikedByMe     bool   `json:"liked_by_me"`
+}
+
+func TestAPIRequiresSession(t *testing.T) {
+	api := newTestAPI(t)
+	token := api.signUp("ana")
+
+	var legacy map[string]interface{}
+	if status := api.do("", http.MethodGet, "/api/blob", nil, &legacy); status != http.StatusUnauthorized {
+		t.Errorf("legacy status = %d", status)
+	}
+	if legacy["error"] != "Authorization header is missing" {
+		t.Errorf("legacy body = %v", legacy)
+	}
+
+	var versioned envelope[interface{}]
+	if status := api.do("expired", http.MethodGet, "/api/v1/blob", nil, &versioned); status != http.StatusUnauthorized {
+		t.Errorf("v1 status = %d", status)
+	}
+	if versioned.Error == nil || versioned.Error.Message != "Invalid or expired session" {
+		t.Errorf("v1 error = %+v", versioned.Error)
+	}
+
+	var secure envelope[map[string]interface{}]
+	if status := api.do(token, http.MethodGet, "/api/v1/secure", nil, &secure); status != http.StatusOK {
+		t.Errorf("secure status = %d", status)
+	}
+	if secure.Data["email"] != "ana@example.com" {
+		t.Errorf("secure data = %v", secure.Data)
+	}
+}
+
+func TestAPIBlobLikesAndComments(t *testing.T) {
+	api := newTestAPI(t)
+	ana := api.signUp("ana")
+	bob := api.signUp("bob")
+
+	var created envelope[blobBody]
+	if status := api.do(ana, http.MethodPost, "/api/v1/blob", gin.H{"content": "hello @bob #golang"}, &created); status != http.StatusCreated {
+		t.Fatalf("create status = %d, error = %+v", status, created.Error)
+	}
+	blobPath := "/api/v1/blob/" + created.Data.ID
+
+	var like envelope[blobBody]
+	if status := api.do(bob, http.MethodPost, blobPath+"/like", nil, &like); status != http.StatusCreated {
+		t.Errorf("like status = %d", status)
+	}
+	if status := api.do(bob, http.MethodPost, blobPath+"/like", nil, &like); status != http.StatusOK {
+		t.Errorf("repeated like status = %d", status)
+	}
+	if like.Data.LikesCount != 1 || !like.Data.LikedByMe {
+		t.Errorf("like state = %+v", like.Data)
+	}
+
+	if status := api.do(bob, http.MethodPost, blobPath+"/comment", gin.H{"content": "hi ana"}, nil); status != http.StatusCreated {
+		t.Errorf("comment status = %d", status)
+	}
+
+	var detail envelope[blobBody]
+	if status := api.do(ana, http.MethodGet, blobPath, nil, &detail); status != http.StatusOK {
+		t.Fatalf("detail status = %d", status)
+	}
+	if detail.Data.LikesCount != 1 || detail.Data.CommentsCount != 1 || detail.Data.LikedByMe {
+		t.Errorf("detail = %+v", detail.Data)
+	}
+
+	var comments envelope[[]map[string]interface{}]
+	if status := api.do(ana, http.MethodGet, blobPath+"/comment", nil, &comments); status != http.StatusOK {
+		t.Fatalf("comments status = %d", status)
+	}
+	if len(comments.Data) != 1 || comments.Meta.Pagination == nil || comments.Meta.Pagination.TotalCount != 1 {
+		t.Errorf("comments = %+v, pagination = %+v", comments.Data, comments.Meta.Pagination)
+	}
+
+	var legacy struct {
+		Comments   []map[string]interface{} `json:"comments"`
+		TotalCount int                      `json:"total_count"`
+	}
+	if status := api.do(ana, http.MethodGet, "/api/blob/"+created.Data.ID+"/comment", nil, &legacy); status != http.StatusOK {
+		t.Fatalf("legacy comments status = %d", status)
+	}
+	if len(legacy.Comments) != 1 || legacy.TotalCount != 1 {
+		t.Errorf("legacy comments = %+v", legacy)
+	}
+
+	var mentions envelope[[]map[string]interface{}]
+	if status := api.do(bob, http.MethodGet, "/api/v1/user/mentions", nil, &mentions); status != http.StatusOK {
+		t.Fatalf("mentions status = %d", status)
+	}
+	if len(mentions.Data) != 1 {
+		t.Errorf("bob's mentions = %+v", mentions.Data)
+	}
+}
+
+func TestAPIBlockedUserCannotInteract(t *testing.T) {
+	api := newTestAPI(t)
+	ana := api.signUp("ana")
+	bob := api.signUp("bob")
+
+	var created envelope[blobBody]
+	if status := api.do(ana, http.MethodPost, "/api/v1/blob", gin.H{"content": "not for bob"}, &created); status != http.StatusCreated {
+		t.Fatalf("create status = %d", status)
+	}
+	if status := api.do(ana, http.MethodPost, "/api/v1/user/bob/block", nil, nil); status >= 300 {
+		t.Fatalf("block status = %d", status)
+	}
+
+	blobPath := "/api/v1/blob/" + created.Data.ID
+	if status := api.do(bob, http.MethodPost, blobPath+"/comment", gin.H{"content": "hi"}, nil); status != http.StatusForbidden {
+		t.Errorf("comment status = %d, want 403", status)
+	}
+	if status := api.do(bob, http.MethodPost, blobPath+"/like", nil, nil); status != http.StatusForbidden {
+		t.Errorf("like status = %d, want 403", status)
+	}
+}
@@ -1,95 +1,316 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
-	"github.com/joaoleau/blob/db"
 	"github.com/joaoleau/blob/handlers"
+	"github.com/joaoleau/blob/internal/config"
+	"github.com/joaoleau/blob/internal/database"
+	"github.com/joaoleau/blob/internal/logging"
+	"github.com/joaoleau/blob/internal/telemetry"
+	"github.com/joaoleau/blob/metrics"
 	"github.com/joaoleau/blob/middleware"
+	"github.com/joaoleau/blob/moderation"
+	"github.com/joaoleau/blob/openapi"
+	"github.com/joaoleau/blob/preview"
 	"github.com/joaoleau/blob/repository"
+	"github.com/joaoleau/blob/response"
+	"github.com/joaoleau/blob/storage"
 	"github.com/joaoleau/blob/usecases"
 	"github.com/joho/godotenv"
+	"github.com/opentracing/opentracing-go"
 )
 
-func SetupRouters(server *gin.Engine, dbConnection *sqlx.DB) {
+const serviceName = "blob-api"
+
+// SetupRouters registers every route on server and starts the background
+// workers, which stop when ctx is done; wait on the returned group before
+// closing dbConnection. An invalid configuration is reported before any
+// worker starts.
+func SetupRouters(ctx context.Context, server *gin.Engine, dbConnection *sqlx.DB, cfg *config.Config) (*sync.WaitGroup, error) {
+	mediaStorage, err := storage.FromConfig(cfg.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("invalid media storage configuration: %w", err)
+	}
+	contentFilter, err := moderation.FilterFromConfig(cfg.Moderation)
+	if err != nil {
+		return nil, fmt.Errorf("invalid moderation filter configuration: %w", err)
+	}
+
+	var workers sync.WaitGroup
+	metrics.RegisterDB(dbConnection, cfg.Database.Name)
+	healthHandler := handlers.NewHealthHandler(dbConnection)
+
+	mentionRepository := repository.NewMentionRepository(dbConnection)
+	relationRepository := repository.NewRelationRepository(dbConnection)
+
+	mediaRepository := repository.NewMediaRepository(dbConnection)
+	mediaUseCase := usecases.NewMediaUseCase(&mediaRepository, mediaStorage)
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		mediaUseCase.RunJanitor(ctx, 10*time.Minute)
+	}()
+
+	previewFetcher := preview.NewFetcher(cfg.LinkPreviews.Timeout, int64(cfg.LinkPreviews.MaxBytes), cfg.LinkPreviews.AllowPrivate)
+	previewRepository := repository.NewLinkPreviewRepository(dbConnection)
+	previewUseCase := usecases.NewLinkPreviewUseCase(&previewRepository, previewFetcher)
+	if cfg.Features.LinkPreviews {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			previewUseCase.RunWorker(ctx, 15*time.Second)
+		}()
+	}
 
 	userRepository := repository.NewUserRepository(dbConnection)
-	userUseCase := usecases.NewUserUseCase(userRepository)
+	userUseCase := usecases.NewUserUseCase(userRepository, &mentionRepository, &relationRepository, mediaUseCase, previewUseCase)
 	userHandler := handlers.NewUserHandler(userUseCase)
+	mediaHandler := handlers.NewMediaHandler(mediaUseCase, userUseCase, int64(cfg.Media.MaxUploadBytes))
+
+	moderationRepository := repository.NewModerationRepository(dbConnection)
+	moderationUseCase := usecases.NewModerationUseCase(&moderationRepository, contentFilter, userUseCase)
+	moderationHandler := handlers.NewModerationHandler(moderationUseCase)
 	
 	blobRepository := repository.NewBlobRepository(dbConnection)
-	blobUseCase := usecases.NewBlobUseCase(blobRepository, userUseCase)
+	blobUseCase := usecases.NewBlobUseCase(&blobRepository, moderationUseCase, userUseCase, mediaUseCase, previewUseCase)
 	blobHandler := handlers.NewBlobHandler(blobUseCase)
 
-	likeRepository := repository.NewLikeRepository(dbConnection, &blobRepository)
-	likeUseCase := usecases.NewLikeUseCase(likeRepository, &blobUseCase)
-	likeHandler := handlers.NewLikeHandler(likeUseCase)
+	reactionRepository := repository.NewReactionRepository(dbConnection)
+	reactionUseCase := usecases.NewReactionUseCase(&reactionRepository, userUseCase)
+	reactionHandler := handlers.NewReactionHandler(reactionUseCase)
 
-	commentsRepository := repository.NewCommentRepository(dbConnection, &blobRepository)
-	commentsUseCase := usecases.NewCommentUseCase(commentsRepository, &blobUseCase)
+	commentsRepository := repository.NewCommentRepository(dbConnection)
+	commentsUseCase := usecases.NewCommentUseCase(&commentsRepository, &blobUseCase)
 	commentsHandler := handlers.NewCommentHandler(commentsUseCase)
 
+	bookmarkRepository := repository.NewBookmarkRepository(dbConnection)
+	bookmarkUseCase := usecases.NewBookmarkUseCase(&bookmarkRepository, userUseCase)
+	bookmarkHandler := handlers.NewBookmarkHandler(bookmarkUseCase)
 
-	protected := server.Group("/api")
-	protected.Use(middleware.AuthMiddleware(dbConnection))
 
+	// Handlers pass *gin.Context down as the context; the fallback makes it
+	// carry the request context, with its span and logger.
+	server.ContextWithFallback = true
+
+	// Recovery comes first so a panic in any later middleware, or in the
+	// static file server, still gets a JSON 500 instead of a dropped connection.
+	server.Use(middleware.RecoveryMiddleware())
+
+	// Local uploads are served by the API itself; S3 objects come from the bucket.
+	if local, ok := mediaStorage.(*storage.LocalStorage); ok && strings.HasPrefix(local.BaseURL, "/") {
+		server.Static(local.BaseURL, local.Dir)
+	}
 
-	protected.GET("/secure", func(c *gin.Context) {
-		email, exists := c.Get("email")
-		if !exists {
-			c.JSON(500, gin.H{"error": "Email not found in context"})
-			return
+	server.Use(middleware.TracingMiddleware(serviceName)...)
+	server.Use(middleware.RequestLoggerMiddleware())
+	server.Use(middleware.SecurityHeadersMiddleware(cfg.Security.HSTSMaxAge))
+	var exposedHeaders []string
+	if cfg.Auth.CSRF {
+		exposedHeaders = append(exposedHeaders, cfg.Auth.CSRFHeader)
+	}
+	server.Use(middleware.CORSMiddleware(cfg.CORS, exposedHeaders...))
+	server.Use(middleware.MetricsMiddleware())
+	server.Use(middleware.BodyLimitMiddleware(int64(cfg.HTTP.MaxBodyBytes), "/api/media", "/api/v1/media"))
+
+	// Registered before the rate limiter so probes, scrapes and docs are never throttled.
+	server.GET("/healthz", healthHandler.Live)
+	server.GET("/readyz", healthHandler.Ready)
+	server.GET("/metrics", gin.WrapH(metrics.Handler()))
+	server.GET("/openapi.json", gin.WrapH(openapi.Handler(cfg.Auth.SessionCookie)))
+	server.GET("/docs", gin.WrapH(openapi.UIHandler()))
+
+	if cfg.RateLimit.Enabled {
+		server.Use(middleware.RateLimitMiddleware(cfg.RateLimit.RequestsPerMinute, cfg.RateLimit.Burst))
+	}
+
+	// Every API route is served under /api/v1 and, for the clients written
+	// before versioning, under /api with the bare response bodies.
+	for _, prefix := range []string{response.LegacyPrefix, response.VersionPrefix} {
+		protected := server.Group(prefix)
+		if cfg.Auth.CSRF {
+			protected.Use(middleware.CSRFMiddleware(cfg.Auth.SessionCookie, cfg.Auth.CSRFCookie, cfg.Auth.CSRFHeader))
 		}
+		protected.Use(middleware.AuthMiddleware(dbConnection, cfg.Auth.SessionCookie))
+
+
+		protected.GET("/secure", func(c *gin.Context) {
+			email, exists := c.Get("email")
+			if !exists {
+				response.Error(c, http.StatusInternalServerError, "Email not found in context")
+				return
+			}
 
-		c.JSON(200, gin.H{
-			"message": "You have access to this route.",
-			"email":  email,
+			response.Message(c, http.StatusOK, "You have access to this route.", gin.H{"email": email})
 		})
-	})
  
-	protected.POST("/blob", blobHandler.RegisterBlob)
-	protected.DELETE("/blob/:blobId", blobHandler.DeleteBlob)
-	protected.GET("/blob/:blobId", blobHandler.GetBlobByID)
-	protected.GET("/blob", blobHandler.ListBlobs)
+		protected.POST("/blob", blobHandler.RegisterBlob)
+		protected.PUT("/blob/:blobId", blobHandler.UpdateBlob)
+		protected.DELETE("/blob/:blobId", blobHandler.DeleteBlob)
+		protected.GET("/blob/:blobId", blobHandler.GetBlobByID)
+		protected.GET("/blob", blobHandler.ListBlobs)
+		protected.GET("/blob/feed", blobHandler.ListFeed)
+		protected.GET("/blob/trending", blobHandler.ListTrending)
+		protected.POST("/blob/batch", blobHandler.BatchViewerState)
+		protected.POST("/blob/:blobId/reblog", blobHandler.Reblog)
+
+		protected.GET("/interest", blobHandler.ListInterests)
+
+		if cfg.Features.MediaUploads {
+			protected.POST("/media", mediaHandler.Upload)
+		}
+
+		protected.POST("/blob/:blobId/reaction", reactionHandler.AddReaction)
+		protected.GET("/blob/:blobId/reaction", reactionHandler.ListReactions)
+		protected.DELETE("/blob/:blobId/reaction/:type", reactionHandler.RemoveReaction)
+		protected.POST("/blob/:blobId/comment/:commentId/reaction", reactionHandler.AddReaction)
+		protected.GET("/blob/:blobId/comment/:commentId/reaction", reactionHandler.ListReactions)
+		protected.DELETE("/blob/:blobId/comment/:commentId/reaction/:type", reactionHandler.RemoveReaction)
+
+		protected.POST("/blob/:blobId/like", reactionHandler.AddLike)
+		protected.GET("/blob/:blobId/like", reactionHandler.ListReactions)
+		protected.GET("/blob/:blobId/likes", reactionHandler.ListLikes)
+		protected.DELETE("/blob/:blobId/like", reactionHandler.RemoveLike)
+
+		protected.POST("/blob/:blobId/bookmark", bookmarkHandler.AddBookmark)
+		protected.DELETE("/blob/:blobId/bookmark", bookmarkHandler.RemoveBookmark)
 
-	protected.GET("/interest", blobHandler.ListInterests)
+		protected.POST("/blob/:blobId/comment", commentsHandler.CreateComment)
+		protected.GET("/blob/:blobId/comment", commentsHandler.ListCommentsByBlobID)
+		protected.PUT("/blob/:blobId/comment/:commentId", commentsHandler.UpdateComment)
+		protected.DELETE("/blob/:blobId/comment/:commentId", commentsHandler.DeleteComment)
 
-	protected.POST("/blob/:blobId/like", likeHandler.AddLike)
-	protected.GET("/blob/:blobId/like", likeHandler.ListLike)
-	protected.DELETE("/blob/:blobId/like", likeHandler.RemoveLike)
+		protected.POST("/blob/:blobId/report", moderationHandler.ReportBlob)
+		protected.POST("/blob/:blobId/comment/:commentId/report", moderationHandler.ReportComment)
 
-	protected.POST("/blob/:blobId/comment", commentsHandler.CreateComment)
-	protected.GET("/blob/:blobId/comment", commentsHandler.ListCommentsByBlobID)
-	protected.DELETE("/blob/:blobId/comment/:commentId", commentsHandler.DeleteComment)
+		moderator := protected.Group("/moderation")
+		moderator.Use(middleware.ModeratorMiddleware(dbConnection))
+		moderator.GET("/reports", moderationHandler.ListReports)
+		moderator.POST("/reports/:reportId/assign", moderationHandler.AssignReport)
+		moderator.POST("/reports/:reportId/resolve", moderationHandler.ResolveReport)
+		moderator.GET("/audit-log", moderationHandler.ListAuditLog)
 
-	protected.GET("/user", userHandler.GetUserProfile)
-	protected.GET("/user/:username", userHandler.GetUserByUsername)
-	protected.PUT("/user", userHandler.UpdateUser)
+		protected.GET("/user", userHandler.GetUserProfile)
+		protected.GET("/user/mentions", userHandler.ListMentions)
+		protected.GET("/user/warnings", moderationHandler.ListWarnings)
+		protected.GET("/user/blocks", userHandler.ListBlockedUsers)
+		protected.GET("/user/mutes", userHandler.ListMutedUsers)
+		protected.GET("/user/bookmarks", bookmarkHandler.ListBookmarks)
+		protected.GET("/user/stats", userHandler.GetUserStats)
+		protected.POST("/user/:username/follow", userHandler.FollowUser)
+		protected.DELETE("/user/:username/follow", userHandler.UnfollowUser)
+		protected.POST("/user/:username/block", userHandler.BlockUser)
+		protected.DELETE("/user/:username/block", userHandler.UnblockUser)
+		protected.POST("/user/:username/mute", userHandler.MuteUser)
+		protected.DELETE("/user/:username/mute", userHandler.UnmuteUser)
+		protected.GET("/user/:username", userHandler.GetUserByUsername)
+		protected.GET("/user/:username/blobs", userHandler.ListUserBlobs)
+		protected.GET("/user/:username/comments", userHandler.ListUserComments)
+		protected.GET("/user/:username/likes", userHandler.ListUserLikes)
+		protected.PUT("/user", userHandler.UpdateUser)
+		protected.DELETE("/user", userHandler.DeleteUser)
+		protected.GET("/user/export", userHandler.ExportUserData)
+		protected.POST("/user/deletion/cancel", userHandler.CancelDeletion)
+	}
+
+	return &workers, nil
 }
 
 
+// run serves the API until a signal arrives or the server fails. It returns
+// instead of exiting so main can flush the traces before the process ends.
+func run(ctx context.Context, cfg *config.Config) error {
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	server := gin.New()
+	if err := server.SetTrustedProxies(cfg.HTTP.TrustedProxies); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	dbConnection, err := database.Connect(ctx, cfg.Database, cfg.Database.Name)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer dbConnection.Close()
+	
+	workers, err := SetupRouters(ctx, server, dbConnection, cfg)
+	if err != nil {
+		return err
+	}
+	// The workers stop with ctx and must be done before the pool closes.
+	defer func() {
+		stop()
+		workers.Wait()
+	}()
+	
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.HTTP.Port),
+		Handler:           server,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+		MaxHeaderBytes:    cfg.HTTP.MaxHeaderBytes,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		if cfg.HTTP.TLSCertFile != "" {
+			serveErr <- httpServer.ListenAndServeTLS(cfg.HTTP.TLSCertFile, cfg.HTTP.TLSKeyFile)
+		} else {
+			serveErr <- httpServer.ListenAndServe()
+		}
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("failed to run server: %w", err)
+	case <-ctx.Done():
+	}
+	// A second signal kills the process instead of waiting for the drain.
+	stop()
+
+	slog.Info("Shutting down, draining in-flight requests", slog.Duration("timeout", cfg.HTTP.ShutdownTimeout))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.HTTP.ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		slog.Error("Server did not drain before the deadline", logging.Err(err))
+	}
+	return nil
+}
+
 func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Println("No .env file found, using system environment variables.")
 	}
 
-	server := gin.Default()
-	dbConnection, err := db.ConnectDB()
+	cfg, err := config.Load(serviceName, os.Args[1:])
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to load configuration: %v", err)
 	}
-	defer dbConnection.Close()
-	
-	SetupRouters(server, dbConnection)
-	
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "80"
+	logging.Setup(cfg.Logging)
+
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg.Telemetry, serviceName)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
 	}
-	if err := server.Run(":" + port); err != nil {
-		log.Fatalf("Failed to run server: %v", err)
+	opentracing.SetGlobalTracer(metrics.Tracer(opentracing.GlobalTracer()))
+
+	err = run(context.Background(), cfg)
+	shutdownTracing(context.Background())
+	if err != nil {
+		log.Fatalf("API stopped: %v", err)
 	}
 }
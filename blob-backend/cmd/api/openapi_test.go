@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/internal/config"
+	"github.com/joaoleau/blob/openapi"
+	_ "github.com/lib/pq"
+)
+
+var pathParameter = regexp.MustCompile(`\{([^}]+)\}`)
+
+// TestOpenAPIMatchesRoutes fails when a route registered by SetupRouters is
+// missing from the OpenAPI document, or the document lists one that is not
+// registered.
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// sqlx.Open does not connect; no query runs while routes are registered.
+	db, err := sqlx.Open("postgres", "host=localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	cfg := config.Default()
+	ctx, cancel := context.WithCancel(context.Background())
+	server := gin.New()
+	workers, err := SetupRouters(ctx, server, db, &cfg)
+	cancel()
+	if err != nil {
+		t.Fatalf("SetupRouters: %v", err)
+	}
+	workers.Wait()
+
+	registered := map[string]bool{}
+	for _, route := range server.Routes() {
+		// Static files are served from a wildcard route, not part of the API.
+		if strings.Contains(route.Path, "*") {
+			continue
+		}
+		registered[route.Method+" "+route.Path] = true
+	}
+
+	doc := openapi.Build(cfg.Auth.SessionCookie)
+	documented := map[string]bool{}
+	for _, operation := range doc.Operations() {
+		documented[operation] = true
+	}
+
+	for route := range registered {
+		if !documented[route] {
+			t.Errorf("route %s is not in the OpenAPI document", route)
+		}
+	}
+	for operation := range documented {
+		if !registered[operation] {
+			t.Errorf("OpenAPI operation %s is not a registered route", operation)
+		}
+	}
+
+	for path, methods := range doc.Paths {
+		var want []string
+		for _, match := range pathParameter.FindAllStringSubmatch(path, -1) {
+			want = append(want, match[1])
+		}
+		for method, operation := range methods {
+			var declared []string
+			for _, parameter := range operation.Parameters {
+				if parameter.In == "path" {
+					declared = append(declared, parameter.Name)
+				}
+			}
+			if strings.Join(declared, ",") != strings.Join(want, ",") {
+				t.Errorf("%s %s declares path parameters %v, want %v", strings.ToUpper(method), path, declared, want)
+			}
+		}
+	}
+}
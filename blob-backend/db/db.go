@@ -1,41 +0,0 @@
-package db
-
-import (
-	"fmt"
-	"os"
-	"github.com/joho/godotenv"
-	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
-)
-
-
-
-func ConnectDB() (*sqlx.DB, error) {
-	if err := godotenv.Load(); err != nil {
-		fmt.Errorf("Error loading .env file: %v", err)
-	}
-
-	var (
-		host     = os.Getenv("DB_HOST")
-		port     = os.Getenv("DB_PORT")
-		user     = os.Getenv("DB_USER")
-		password = os.Getenv("DB_PASSWD")
-		dbname   = os.Getenv("DB_DATABASE")
-	)
-
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
-
-	db, err := sqlx.Open("postgres", psqlInfo)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
-	}
-	
-	err = db.Ping()
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
-	}
-
-	fmt.Println("Connected to " + dbname)
-	return db, nil
-}
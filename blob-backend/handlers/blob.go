@@ -1,12 +1,16 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/moderation"
+	"github.com/joaoleau/blob/response"
 	"github.com/joaoleau/blob/usecases"
+	"github.com/pkg/errors"
 )
 
 type BlobHandler struct {
@@ -20,37 +24,165 @@ func NewBlobHandler(useCase usecases.BlobUseCase) BlobHandler {
 }
 
 func (h *BlobHandler) RegisterBlob(ctx *gin.Context) {
-	var blob models.BlobWithInterests
-	if err := ctx.ShouldBindJSON(&blob); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input data."})
+	var request models.CreateBlobRequest
+	if !bindRequest(ctx, &request) {
 		return
 	}
+	blob := models.BlobWithInterests{Content: request.Content, Interests: request.Interests, MediaIDs: request.MediaIDs}
 
 	createdBlob, err := h.blobUseCase.RegisterBlob(ctx, &blob)
+	if errors.Is(err, moderation.ErrRejected) {
+		response.Error(ctx, http.StatusUnprocessableEntity, "Content rejected by moderation filter.")
+		return
+	}
+	if errors.Is(err, usecases.ErrMediaUnavailable) {
+		response.Error(ctx, http.StatusBadRequest, "One or more attachments were not found or are already in use.")
+		return
+	}
+	if err != nil {
+		internalError(ctx, err, "Failed to create blob.")
+		return
+	}
+
+	if createdBlob.HeldForReview {
+		response.JSON(ctx, http.StatusAccepted, createdBlob)
+		return
+	}
+
+	response.JSON(ctx, http.StatusCreated, createdBlob)
+}
+
+
+// Reblog answers 201 with the new blob; a quote held by the content filter
+// answers 202 like any other blob.
+func (h *BlobHandler) Reblog(ctx *gin.Context) {
+	blobUUID, err := uuid.Parse(ctx.Param("blobId"))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid blob ID. Must be in UUID format.")
+		return
+	}
+
+	var request models.ReblogRequest
+	if ctx.Request.ContentLength != 0 && !bindRequest(ctx, &request) {
+		return
+	}
+
+	reblog, err := h.blobUseCase.Reblog(ctx, blobUUID, request.Content)
+	if errors.Is(err, usecases.ErrBlocked) {
+		response.Error(ctx, http.StatusForbidden, "You cannot interact with this blob.")
+		return
+	}
+	if errors.Is(err, usecases.ErrAlreadyReblogged) {
+		response.Error(ctx, http.StatusConflict, "You already reblogged this blob.")
+		return
+	}
+	if errors.Is(err, moderation.ErrRejected) {
+		response.Error(ctx, http.StatusUnprocessableEntity, "Content rejected by moderation filter.")
+		return
+	}
+	if err != nil {
+		internalError(ctx, err, "Failed to reblog blob.")
+		return
+	}
+	if reblog == nil {
+		response.Error(ctx, http.StatusNotFound, "Blob not found.")
+		return
+	}
+
+	if reblog.HeldForReview {
+		response.JSON(ctx, http.StatusAccepted, reblog)
+		return
+	}
+
+	response.JSON(ctx, http.StatusCreated, reblog)
+}
+
+func (h *BlobHandler) ListFeed(ctx *gin.Context) {
+	page, size := parsePagination(ctx)
+
+	feed, err := h.blobUseCase.ListFeed(ctx, page, size)
+	if err != nil {
+		internalError(ctx, err, "Failed to retrieve feed.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, feed)
+}
+
+func (h *BlobHandler) ListTrending(ctx *gin.Context) {
+	page, size := parsePagination(ctx)
+
+	trending, err := h.blobUseCase.ListTrending(ctx, page, size)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create blob."})
+		internalError(ctx, err, "Failed to retrieve trending blobs.")
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, createdBlob)
+	response.JSON(ctx, http.StatusOK, trending)
 }
 
+func (h *BlobHandler) UpdateBlob(ctx *gin.Context) {
+	blobID := ctx.Param("blobId")
+
+	blobUUID, err := uuid.Parse(blobID)
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid blob ID. Must be in UUID format.")
+		return
+	}
+
+	var request models.UpdateBlobRequest
+	if !bindRequest(ctx, &request) {
+		return
+	}
+	blob := models.BlobWithInterests{ID: blobUUID, Content: request.Content, Interests: request.Interests, MediaIDs: request.MediaIDs}
+
+	updatedBlob, err := h.blobUseCase.UpdateBlob(ctx, &blob)
+	if errors.Is(err, moderation.ErrRejected) {
+		response.Error(ctx, http.StatusUnprocessableEntity, "Content rejected by moderation filter.")
+		return
+	}
+	if errors.Is(err, usecases.ErrMediaUnavailable) {
+		response.Error(ctx, http.StatusBadRequest, "One or more attachments were not found or are already in use.")
+		return
+	}
+	if err != nil {
+		internalError(ctx, err, "Failed to update blob.")
+		return
+	}
+
+	if updatedBlob == nil {
+		response.Error(ctx, http.StatusNotFound, "Blob not found.")
+		return
+	}
+
+	if updatedBlob.HeldForReview {
+		response.JSON(ctx, http.StatusAccepted, updatedBlob)
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, updatedBlob)
+}
 
 func (h *BlobHandler) DeleteBlob(ctx *gin.Context) {
 	blobID := ctx.Param("blobId")
 
 	blobUUID, err := uuid.Parse(blobID)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid blob ID. Must be in UUID format."})
+		response.Error(ctx, http.StatusBadRequest, "Invalid blob ID. Must be in UUID format.")
 		return
 	}
 
-	if err := h.blobUseCase.DeleteBlob(ctx, blobUUID); err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete blob."})
+	err = h.blobUseCase.DeleteBlob(ctx, blobUUID)
+	if errors.Is(err, usecases.ErrBlobNotFound) {
+		response.Error(ctx, http.StatusNotFound, "Blob not found.")
+		return
+	}
+	if err != nil {
+		internalError(ctx, err, "Failed to delete blob.")
 		return
 	}
 
-	ctx.JSON(http.StatusNoContent, nil)
+	response.NoContent(ctx)
 }
 
 
@@ -59,40 +191,61 @@ func (h *BlobHandler) GetBlobByID(ctx *gin.Context) {
 
 	blobUUID, err := uuid.Parse(blobID)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid blob ID. Must be in UUID format."})
+		response.Error(ctx, http.StatusBadRequest, "Invalid blob ID. Must be in UUID format.")
 		return
 	}
 
 	blob, err := h.blobUseCase.GetBlobByID(ctx, blobUUID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve blob."})
+		internalError(ctx, err, "Failed to retrieve blob.")
 		return
 	}
 
 	if blob == nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "Blob not found."})
+		response.Error(ctx, http.StatusNotFound, "Blob not found.")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, blob)
+	blob.Links = models.BlobLinks{
+		Comments: fmt.Sprintf("%s/blob/%s/comment?page=1&size=%d", response.BasePath(ctx), blob.ID, usecases.BlobDetailListSize),
+		Likes:    fmt.Sprintf("%s/blob/%s/likes?page=1&size=%d", response.BasePath(ctx), blob.ID, usecases.BlobDetailListSize),
+	}
+	response.JSON(ctx, http.StatusOK, blob)
 }
 
 func (h *BlobHandler) ListBlobs(ctx *gin.Context) {
 	blobList, err := h.blobUseCase.ListBlobs(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve blobs."})
+		internalError(ctx, err, "Failed to retrieve blobs.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, blobList)
+}
+
+// BatchViewerState answers the viewer fields of several blobs at once so
+// clients can refresh them without reloading each blob.
+func (h *BlobHandler) BatchViewerState(ctx *gin.Context) {
+	var request models.BlobBatchRequest
+	if !bindRequest(ctx, &request) {
+		return
+	}
+
+	states, err := h.blobUseCase.ViewerStates(ctx, request.IDs)
+	if err != nil {
+		internalError(ctx, err, "Failed to retrieve blobs.")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, blobList)
+	response.JSON(ctx, http.StatusOK, gin.H{"blobs": states})
 }
 
 func (h *BlobHandler) ListInterests(ctx *gin.Context) {
 	interest, err := h.blobUseCase.ListInterests(ctx)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve interests."})
+		internalError(ctx, err, "Failed to retrieve interests.")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, interest)
+	response.JSON(ctx, http.StatusOK, interest)
 }
\ No newline at end of file
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/response"
+	"github.com/joaoleau/blob/usecases"
+	"github.com/pkg/errors"
+)
+
+type BookmarkHandler struct {
+	bookmarkUseCase *usecases.BookmarkUseCase
+}
+
+func NewBookmarkHandler(bookmarkUseCase *usecases.BookmarkUseCase) BookmarkHandler {
+	return BookmarkHandler{
+		bookmarkUseCase: bookmarkUseCase,
+	}
+}
+
+// AddBookmark answers 201 for a new bookmark and 200 when the blob was
+// already bookmarked.
+func (h *BookmarkHandler) AddBookmark(ctx *gin.Context) {
+	blobUUID, err := uuid.Parse(ctx.Param("blobId"))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid blob ID. Must be in UUID format.")
+		return
+	}
+
+	found, created, err := h.bookmarkUseCase.AddBookmark(ctx, blobUUID)
+	if errors.Is(err, usecases.ErrBlocked) {
+		response.Error(ctx, http.StatusForbidden, "You cannot interact with this blob.")
+		return
+	}
+	if err != nil {
+		internalError(ctx, err, "Failed to bookmark blob.")
+		return
+	}
+	if !found {
+		response.Error(ctx, http.StatusNotFound, "Blob not found.")
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	response.JSON(ctx, status, gin.H{"blob_id": blobUUID, "bookmarked": true})
+}
+
+func (h *BookmarkHandler) RemoveBookmark(ctx *gin.Context) {
+	blobUUID, err := uuid.Parse(ctx.Param("blobId"))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid blob ID. Must be in UUID format.")
+		return
+	}
+
+	removed, err := h.bookmarkUseCase.RemoveBookmark(ctx, blobUUID)
+	if err != nil {
+		internalError(ctx, err, "Failed to remove bookmark.")
+		return
+	}
+	if !removed {
+		response.Error(ctx, http.StatusNotFound, "Bookmark not found.")
+		return
+	}
+
+	response.NoContent(ctx)
+}
+
+func (h *BookmarkHandler) ListBookmarks(ctx *gin.Context) {
+	page, size := parsePagination(ctx)
+
+	bookmarks, err := h.bookmarkUseCase.ListBookmarks(ctx, page, size)
+	if err != nil {
+		internalError(ctx, err, "Failed to retrieve bookmarks.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, bookmarks)
+}
@@ -5,7 +5,10 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/moderation"
+	"github.com/joaoleau/blob/response"
 	"github.com/joaoleau/blob/usecases"
+	"github.com/pkg/errors"
 )
 
 type CommentHandler struct {
@@ -23,24 +26,82 @@ func (h *CommentHandler) CreateComment(c *gin.Context) {
 	
 	blobUUID, err := uuid.Parse(blobID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID. Must be in UUID format."})
+		response.Error(c, http.StatusBadRequest, "Invalid blob ID. Must be in UUID format.")
 		return
 	}
 	
-	var comment models.Comment
-	comment.BlobID = blobUUID
-	if err := c.ShouldBindJSON(&comment); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	var request models.CreateCommentRequest
+	if !bindRequest(c, &request) {
 		return
 	}
+	comment := models.Comment{BlobID: blobUUID, Content: request.Content}
 
 	newComment, err := h.commentUseCase.AddComment(c, &comment)
+	if errors.Is(err, usecases.ErrBlobNotFound) {
+		response.Error(c, http.StatusNotFound, "Blob not found.")
+		return
+	}
+	if errors.Is(err, usecases.ErrBlocked) {
+		response.Error(c, http.StatusForbidden, "You cannot interact with this blob.")
+		return
+	}
+	if errors.Is(err, moderation.ErrRejected) {
+		response.Error(c, http.StatusUnprocessableEntity, "Content rejected by moderation filter.")
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err, "Failed to create comment.")
 		return
 	}
 
-	c.JSON(http.StatusCreated, newComment)
+	if newComment.HeldForReview {
+		response.JSON(c, http.StatusAccepted, newComment)
+		return
+	}
+
+	response.JSON(c, http.StatusCreated, newComment)
+}
+
+func (h *CommentHandler) UpdateComment(c *gin.Context) {
+	blobUUID, err := uuid.Parse(c.Param("blobId"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid blob ID. Must be in UUID format.")
+		return
+	}
+
+	commentUUID, err := uuid.Parse(c.Param("commentId"))
+	if err != nil {
+		response.Error(c, http.StatusBadRequest, "Invalid comment ID. Must be in UUID format.")
+		return
+	}
+
+	var request models.UpdateCommentRequest
+	if !bindRequest(c, &request) {
+		return
+	}
+	comment := models.Comment{ID: commentUUID, BlobID: blobUUID, Content: request.Content}
+
+	updatedComment, err := h.commentUseCase.UpdateComment(c, &comment)
+	if errors.Is(err, moderation.ErrRejected) {
+		response.Error(c, http.StatusUnprocessableEntity, "Content rejected by moderation filter.")
+		return
+	}
+	if err != nil {
+		internalError(c, err, "Failed to update comment.")
+		return
+	}
+
+	if updatedComment == nil {
+		response.Error(c, http.StatusNotFound, "Comment not found.")
+		return
+	}
+
+	if updatedComment.HeldForReview {
+		response.JSON(c, http.StatusAccepted, updatedComment)
+		return
+	}
+
+	response.JSON(c, http.StatusOK, updatedComment)
 }
 
 func (h *CommentHandler) DeleteComment(c *gin.Context) {
@@ -48,16 +109,16 @@ func (h *CommentHandler) DeleteComment(c *gin.Context) {
 
 	commentUUID, err := uuid.Parse(commentID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid comment ID. Must be in UUID format."})
+		response.Error(c, http.StatusBadRequest, "Invalid comment ID. Must be in UUID format.")
 		return
 	}
 
 	if err := h.commentUseCase.RemoveComment(c, commentUUID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err, "Failed to delete comment.")
 		return
 	}
 
-	c.JSON(http.StatusNoContent, nil)
+	response.NoContent(c)
 }
 
 
@@ -66,29 +127,37 @@ func (h *CommentHandler) ListCommentsByBlobID(c *gin.Context) {
 	
 	blobUUID, err := uuid.Parse(blobID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid blob ID. Must be in UUID format."})
+		response.Error(c, http.StatusBadRequest, "Invalid blob ID. Must be in UUID format.")
 		return
 	}
 
-	comments, err := h.commentUseCase.ListCommentsByBlobID(c, blobUUID)
+	page, size := parsePagination(c)
+	comments, err := h.commentUseCase.ListCommentsByBlobID(c, blobUUID, page, size)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		internalError(c, err, "Failed to retrieve comments.")
+		return
+	}
+
+	// The versioned API answers the page of comments alone; clients read the
+	// signed-in user from GET /api/v1/user.
+	if response.Versioned(c) {
+		response.JSON(c, http.StatusOK, comments)
 		return
 	}
 
 	email, exists := c.Get("email")
 	if !exists {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user email not found in context"})
+		response.Error(c, http.StatusBadRequest, "Email not found in context")
 		return
 	}
 
 	user, err := h.commentUseCase.BlobUseCase.UserUseCase.GetUserByEmail(c, email.(string))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch user"})
+		internalError(c, err, "Failed to fetch user.")
 		return
 	}
 
-	response := gin.H{
+	body := gin.H{
 		"user_logon": map[string]interface{}{
 			"username":    user.Username,
 			"avatar_icon": user.AvatarIcon,
@@ -96,8 +165,13 @@ func (h *CommentHandler) ListCommentsByBlobID(c *gin.Context) {
 			"id": user.ID,
 			"email": user.Email,
 		},
-		"content": comments,
+		"content":     comments.Comments,
+		"total_count": comments.TotalCount,
+		"total_pages": comments.TotalPages,
+		"page":        comments.Page,
+		"size":        comments.Size,
+		"has_more":    comments.HasMore,
 	}
 
-	c.JSON(http.StatusOK, response)
+	response.JSON(c, http.StatusOK, body)
 }
\ No newline at end of file
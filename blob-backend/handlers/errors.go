@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaoleau/blob/internal/logging"
+	"github.com/joaoleau/blob/response"
+)
+
+// internalError logs err, with its chain of wrapped messages, on the request
+// logger and answers 500 with message.
+func internalError(ctx *gin.Context, err error, message string) {
+	logging.FromContext(ctx).ErrorContext(ctx, message, logging.Err(err))
+	response.Error(ctx, http.StatusInternalServerError, message)
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/internal/database"
+)
+
+type HealthHandler struct {
+	db *sqlx.DB
+}
+
+func NewHealthHandler(db *sqlx.DB) HealthHandler {
+	return HealthHandler{db: db}
+}
+
+// Live answers as long as the process serves HTTP; it never touches the
+// database so a database outage does not get the API restarted.
+func (h *HealthHandler) Live(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Ready reports whether the API can serve traffic: the database answers and
+// the migration runner has brought the schema to the version this build
+// expects.
+func (h *HealthHandler) Ready(ctx *gin.Context) {
+	if err := database.Check(ctx, h.db); err != nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "Database is unreachable."})
+		return
+	}
+
+	version, err := database.AppliedSchemaVersion(ctx, h.db)
+	if err != nil {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "Schema version is unknown."})
+		return
+	}
+	if version < database.SchemaVersion {
+		ctx.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":           "unavailable",
+			"error":            "Migrations are pending.",
+			"schema_version":   version,
+			"expected_version": database.SchemaVersion,
+		})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "schema_version": version})
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaoleau/blob/media"
+	"github.com/joaoleau/blob/response"
+	"github.com/joaoleau/blob/usecases"
+	"github.com/pkg/errors"
+)
+
+// multipartOverhead leaves room for the form boundaries and headers around
+// the uploaded file.
+const multipartOverhead = 1 << 20
+
+type MediaHandler struct {
+	mediaUseCase *usecases.MediaUseCase
+	userUseCase  *usecases.UserUseCase
+	maxBytes     int64
+}
+
+func NewMediaHandler(mediaUseCase *usecases.MediaUseCase, userUseCase *usecases.UserUseCase, maxBytes int64) MediaHandler {
+	return MediaHandler{
+		mediaUseCase: mediaUseCase,
+		userUseCase:  userUseCase,
+		maxBytes:     maxBytes,
+	}
+}
+
+// Upload accepts an image in the "file" field of a multipart form. The
+// returned id goes into media_ids when creating or editing a blob.
+func (h *MediaHandler) Upload(ctx *gin.Context) {
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, h.maxBytes+multipartOverhead)
+
+	file, _, err := ctx.Request.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			response.Fail(ctx, http.StatusRequestEntityTooLarge, response.ErrorBody{Message: "File too large.", MaxBytes: h.maxBytes})
+			return
+		}
+		response.Error(ctx, http.StatusBadRequest, "A file is required in the \"file\" field.")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, h.maxBytes+1))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Failed to read file.")
+		return
+	}
+	if int64(len(data)) > h.maxBytes {
+		response.Fail(ctx, http.StatusRequestEntityTooLarge, response.ErrorBody{Message: "File too large.", MaxBytes: h.maxBytes})
+		return
+	}
+
+	user, err := h.userUseCase.CurrentUser(ctx)
+	if err != nil {
+		internalError(ctx, err, "Failed to fetch user.")
+		return
+	}
+
+	uploaded, err := h.mediaUseCase.Upload(ctx, user.ID, data)
+	switch {
+	case errors.Is(err, media.ErrUnsupportedType):
+		response.Fail(ctx, http.StatusUnsupportedMediaType, response.ErrorBody{Message: "Unsupported file type.", Allowed: media.AllowedTypes})
+		return
+	case errors.Is(err, media.ErrTooLarge):
+		response.Error(ctx, http.StatusRequestEntityTooLarge, "Image dimensions too large.")
+		return
+	case errors.Is(err, media.ErrInvalidImage):
+		response.Error(ctx, http.StatusUnprocessableEntity, "The file is not a valid image.")
+		return
+	case err != nil:
+		internalError(ctx, err, "Failed to upload file.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusCreated, uploaded)
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/response"
+	"github.com/joaoleau/blob/usecases"
+	"github.com/pkg/errors"
+)
+
+type ModerationHandler struct {
+	moderationUseCase *usecases.ModerationUseCase
+}
+
+func NewModerationHandler(useCase *usecases.ModerationUseCase) ModerationHandler {
+	return ModerationHandler{
+		moderationUseCase: useCase,
+	}
+}
+
+func (h *ModerationHandler) ReportBlob(ctx *gin.Context) {
+	blobUUID, err := uuid.Parse(ctx.Param("blobId"))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid blob ID. Must be in UUID format.")
+		return
+	}
+
+	h.report(ctx, blobUUID, nil)
+}
+
+func (h *ModerationHandler) ReportComment(ctx *gin.Context) {
+	blobUUID, err := uuid.Parse(ctx.Param("blobId"))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid blob ID. Must be in UUID format.")
+		return
+	}
+
+	commentUUID, err := uuid.Parse(ctx.Param("commentId"))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid comment ID. Must be in UUID format.")
+		return
+	}
+
+	h.report(ctx, blobUUID, &commentUUID)
+}
+
+func (h *ModerationHandler) report(ctx *gin.Context, blobID uuid.UUID, commentID *uuid.UUID) {
+	var request models.ReportRequest
+	if !bindRequest(ctx, &request) {
+		return
+	}
+
+	report, err := h.moderationUseCase.ReportContent(ctx, blobID, commentID, request)
+	switch {
+	case errors.Is(err, usecases.ErrInvalidReportReason):
+		response.Fail(ctx, http.StatusBadRequest, response.ErrorBody{Message: "Invalid report reason.", Allowed: models.ReportReasons})
+		return
+	case errors.Is(err, usecases.ErrAlreadyReported):
+		response.Error(ctx, http.StatusConflict, "You already reported this content.")
+		return
+	case err != nil:
+		internalError(ctx, err, "Failed to report content.")
+		return
+	}
+
+	if report == nil {
+		response.Error(ctx, http.StatusNotFound, "Content not found.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusCreated, report)
+}
+
+func (h *ModerationHandler) ListReports(ctx *gin.Context) {
+	page, size := parsePagination(ctx)
+
+	reports, err := h.moderationUseCase.ListReports(ctx, ctx.Query("status"), page, size)
+	if err != nil {
+		internalError(ctx, err, "Failed to retrieve reports.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, reports)
+}
+
+func (h *ModerationHandler) AssignReport(ctx *gin.Context) {
+	reportUUID, err := uuid.Parse(ctx.Param("reportId"))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid report ID. Must be in UUID format.")
+		return
+	}
+
+	var request models.AssignReportRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			response.Error(ctx, http.StatusBadRequest, "Invalid input data.")
+			return
+		}
+	}
+	if !validateRequest(ctx, &request) {
+		return
+	}
+
+	report, err := h.moderationUseCase.AssignReport(ctx, reportUUID, request.AssigneeID)
+	if errors.Is(err, usecases.ErrAssigneeNotModerator) {
+		response.Error(ctx, http.StatusBadRequest, "Assignee must be a moderator.")
+		return
+	}
+	if err != nil {
+		internalError(ctx, err, "Failed to assign report.")
+		return
+	}
+
+	if report == nil {
+		response.Error(ctx, http.StatusNotFound, "Open report not found.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, report)
+}
+
+func (h *ModerationHandler) ResolveReport(ctx *gin.Context) {
+	reportUUID, err := uuid.Parse(ctx.Param("reportId"))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid report ID. Must be in UUID format.")
+		return
+	}
+
+	var request models.ResolveReportRequest
+	if !bindRequest(ctx, &request) {
+		return
+	}
+
+	report, err := h.moderationUseCase.ResolveReport(ctx, reportUUID, request)
+	if errors.Is(err, usecases.ErrInvalidAction) {
+		response.Error(ctx, http.StatusBadRequest, "Invalid moderation action.")
+		return
+	}
+	if err != nil {
+		internalError(ctx, err, "Failed to resolve report.")
+		return
+	}
+
+	if report == nil {
+		response.Error(ctx, http.StatusNotFound, "Open report not found.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, report)
+}
+
+func (h *ModerationHandler) ListAuditLog(ctx *gin.Context) {
+	page, size := parsePagination(ctx)
+
+	entries, err := h.moderationUseCase.ListAuditLog(ctx, page, size)
+	if err != nil {
+		internalError(ctx, err, "Failed to retrieve audit log.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, entries)
+}
+
+// ListWarnings returns the warnings moderators gave the caller.
+func (h *ModerationHandler) ListWarnings(ctx *gin.Context) {
+	page, size := parsePagination(ctx)
+
+	warnings, err := h.moderationUseCase.ListWarnings(ctx, page, size)
+	if err != nil {
+		internalError(ctx, err, "Failed to retrieve warnings.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, warnings)
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/response"
+	"github.com/joaoleau/blob/usecases"
+	"github.com/pkg/errors"
+)
+
+type ReactionHandler struct {
+	reactionUseCase *usecases.ReactionUseCase
+}
+
+func NewReactionHandler(reactionUseCase *usecases.ReactionUseCase) ReactionHandler {
+	return ReactionHandler{
+		reactionUseCase: reactionUseCase,
+	}
+}
+
+// AddReaction handles both blob and comment reactions; the comment routes
+// carry a :commentId.
+func (h *ReactionHandler) AddReaction(ctx *gin.Context) {
+	blobID, commentID, ok := reactionTarget(ctx)
+	if !ok {
+		return
+	}
+
+	var request models.ReactionRequest
+	if !bindRequest(ctx, &request) {
+		return
+	}
+
+	h.react(ctx, blobID, commentID, request.Type)
+}
+
+func (h *ReactionHandler) RemoveReaction(ctx *gin.Context) {
+	blobID, commentID, ok := reactionTarget(ctx)
+	if !ok {
+		return
+	}
+
+	h.unreact(ctx, blobID, commentID, ctx.Param("type"))
+}
+
+func (h *ReactionHandler) ListReactions(ctx *gin.Context) {
+	blobID, commentID, ok := reactionTarget(ctx)
+	if !ok {
+		return
+	}
+
+	groups, err := h.reactionUseCase.ListReactions(ctx, blobID, commentID)
+	if err != nil {
+		internalError(ctx, err, "Failed to retrieve reactions.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, groups)
+}
+
+func (h *ReactionHandler) ListLikes(ctx *gin.Context) {
+	blobID, _, ok := reactionTarget(ctx)
+	if !ok {
+		return
+	}
+
+	page, size := parsePagination(ctx)
+	likes, err := h.reactionUseCase.ListLikes(ctx, blobID, page, size)
+	if err != nil {
+		internalError(ctx, err, "Failed to retrieve likes.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, likes)
+}
+
+// AddLike keeps the like routes working on top of reactions. Liking is
+// idempotent: 201 for a new like, 200 when the blob was already liked.
+func (h *ReactionHandler) AddLike(ctx *gin.Context) {
+	blobID, _, ok := reactionTarget(ctx)
+	if !ok {
+		return
+	}
+
+	state, created, err := h.reactionUseCase.Like(ctx, blobID)
+	if errors.Is(err, usecases.ErrBlocked) {
+		response.Error(ctx, http.StatusForbidden, "You cannot interact with this blob.")
+		return
+	}
+	if err != nil {
+		internalError(ctx, err, "Failed to like blob.")
+		return
+	}
+	if state == nil {
+		response.Error(ctx, http.StatusNotFound, "Blob not found.")
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	response.JSON(ctx, status, state)
+}
+
+// RemoveLike answers 200 with the updated state whether or not the user had
+// liked the blob, and 404 only when the blob itself is gone.
+func (h *ReactionHandler) RemoveLike(ctx *gin.Context) {
+	blobID, _, ok := reactionTarget(ctx)
+	if !ok {
+		return
+	}
+
+	state, err := h.reactionUseCase.Unlike(ctx, blobID)
+	if err != nil {
+		internalError(ctx, err, "Failed to remove like.")
+		return
+	}
+	if state == nil {
+		response.Error(ctx, http.StatusNotFound, "Blob not found.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, state)
+}
+
+func (h *ReactionHandler) react(ctx *gin.Context, blobID uuid.UUID, commentID *uuid.UUID, reactionType string) {
+	reaction, err := h.reactionUseCase.React(ctx, blobID, commentID, reactionType)
+	switch {
+	case errors.Is(err, usecases.ErrInvalidReaction):
+		response.Fail(ctx, http.StatusBadRequest, response.ErrorBody{Message: "Invalid reaction type.", Allowed: models.ReactionTypes})
+		return
+	case errors.Is(err, usecases.ErrAlreadyReacted):
+		response.Error(ctx, http.StatusConflict, "You already reacted with this type.")
+		return
+	case errors.Is(err, usecases.ErrBlocked):
+		response.Error(ctx, http.StatusForbidden, "You cannot interact with this blob.")
+		return
+	case err != nil:
+		internalError(ctx, err, "Failed to add reaction.")
+		return
+	}
+	if reaction == nil {
+		response.Error(ctx, http.StatusNotFound, "Content not found.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusCreated, reaction)
+}
+
+func (h *ReactionHandler) unreact(ctx *gin.Context, blobID uuid.UUID, commentID *uuid.UUID, reactionType string) {
+	if _, err := h.reactionUseCase.Unreact(ctx, blobID, commentID, reactionType); err != nil {
+		if errors.Is(err, usecases.ErrInvalidReaction) {
+			response.Fail(ctx, http.StatusBadRequest, response.ErrorBody{Message: "Invalid reaction type.", Allowed: models.ReactionTypes})
+			return
+		}
+		internalError(ctx, err, "Failed to remove reaction.")
+		return
+	}
+
+	response.NoContent(ctx)
+}
+
+// reactionTarget parses :blobId and, on comment routes, :commentId, answering
+// 400 itself when either is not a UUID.
+func reactionTarget(ctx *gin.Context) (uuid.UUID, *uuid.UUID, bool) {
+	blobID, err := uuid.Parse(ctx.Param("blobId"))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid blob ID. Must be in UUID format.")
+		return uuid.Nil, nil, false
+	}
+
+	if ctx.Param("commentId") == "" {
+		return blobID, nil, true
+	}
+
+	commentID, err := uuid.Parse(ctx.Param("commentId"))
+	if err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid comment ID. Must be in UUID format.")
+		return uuid.Nil, nil, false
+	}
+	return blobID, &commentID, true
+}
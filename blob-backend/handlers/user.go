@@ -1,10 +1,17 @@
 package handlers
 
 import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"github.com/joaoleau/blob/models"
 	"github.com/gin-gonic/gin"
+	"github.com/joaoleau/blob/response"
 	"github.com/joaoleau/blob/usecases"
+	"github.com/pkg/errors"
 )
 
 type UserHandler struct {
@@ -20,59 +27,319 @@ func NewUserHandler(useCase *usecases.UserUseCase) UserHandler {
 func (h *UserHandler) GetUserByUsername(ctx *gin.Context) {
 	username := ctx.Param("username")
 
-	userWithBlobs, err := h.userUseCase.GetUserByUsername(ctx, username)
+	profile, err := h.userUseCase.GetProfile(ctx, username)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve user."})
+		h.handleProfileError(ctx, username, err, "Failed to retrieve user.")
 		return
 	}
 
-	if userWithBlobs == nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "User not found."})
+	response.JSON(ctx, http.StatusOK, profile)
+}
+
+func (h *UserHandler) ListUserBlobs(ctx *gin.Context) {
+	username := ctx.Param("username")
+	page, size := parsePagination(ctx)
+
+	blobs, err := h.userUseCase.ListUserBlobs(ctx, username, page, size)
+	if err != nil {
+		h.handleProfileError(ctx, username, err, "Failed to retrieve blobs.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, blobs)
+}
+
+func (h *UserHandler) ListUserComments(ctx *gin.Context) {
+	username := ctx.Param("username")
+	page, size := parsePagination(ctx)
+
+	comments, err := h.userUseCase.ListUserComments(ctx, username, page, size)
+	if err != nil {
+		h.handleProfileError(ctx, username, err, "Failed to retrieve comments.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, comments)
+}
+
+func (h *UserHandler) ListUserLikes(ctx *gin.Context) {
+	username := ctx.Param("username")
+	page, size := parsePagination(ctx)
+
+	likes, err := h.userUseCase.ListUserLikes(ctx, username, page, size)
+	if err != nil {
+		h.handleProfileError(ctx, username, err, "Failed to retrieve likes.")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, userWithBlobs)
+	response.JSON(ctx, http.StatusOK, likes)
+}
+
+// handleProfileError answers a failed profile lookup. Unknown usernames that
+// belonged to a renamed user redirect to the same path under the new name.
+func (h *UserHandler) handleProfileError(ctx *gin.Context, username string, err error, message string) {
+	switch {
+	case errors.Is(err, usecases.ErrUserNotFound):
+		current, err := h.userUseCase.RenamedUsername(ctx, username)
+		if err != nil || current == "" {
+			response.Error(ctx, http.StatusNotFound, "User not found.")
+			return
+		}
+		location := strings.Replace(ctx.Request.URL.Path, "/user/"+username, "/user/"+url.PathEscape(current), 1)
+		if ctx.Request.URL.RawQuery != "" {
+			location += "?" + ctx.Request.URL.RawQuery
+		}
+		ctx.Redirect(http.StatusMovedPermanently, location)
+	case errors.Is(err, usecases.ErrBlocked):
+		response.Error(ctx, http.StatusForbidden, "You cannot view this profile.")
+	case errors.Is(err, usecases.ErrPrivateProfile):
+		response.Error(ctx, http.StatusForbidden, "This profile is private.")
+	case errors.Is(err, usecases.ErrLikesHidden):
+		response.Error(ctx, http.StatusForbidden, "This user's likes are hidden.")
+	default:
+		internalError(ctx, err, message)
+	}
 }
 
 func (h *UserHandler) GetUserProfile(ctx *gin.Context) {
 	email, exists := ctx.Get("email")
 	if !exists {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Email não encontrado no contexto"})
+		response.Error(ctx, http.StatusBadRequest, "Email not found in context")
 		return
 	}
 
 	user, err := h.userUseCase.GetUserByEmail(ctx, email.(string))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Erro ao recuperar informações do usuário"})
+		internalError(ctx, err, "Failed to retrieve user.")
 		return
 	}
 
 	if user == nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "Usuário não encontrado"})
+		response.Error(ctx, http.StatusNotFound, "User not found.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, user)
+}
+
+func (h *UserHandler) ListMentions(ctx *gin.Context) {
+	email, exists := ctx.Get("email")
+	if !exists {
+		response.Error(ctx, http.StatusBadRequest, "Email not found in context")
+		return
+	}
+
+	page, size := parsePagination(ctx)
+
+	mentions, err := h.userUseCase.ListMentions(ctx, email.(string), page, size)
+	if err != nil {
+		internalError(ctx, err, "Failed to retrieve mentions.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, mentions)
+}
+
+func (h *UserHandler) GetUserStats(ctx *gin.Context) {
+	stats, err := h.userUseCase.Stats(ctx)
+	if err != nil {
+		internalError(ctx, err, "Failed to retrieve stats.")
+		return
+	}
+
+	response.JSON(ctx, http.StatusOK, stats)
+}
+
+func (h *UserHandler) FollowUser(ctx *gin.Context) {
+	h.addRelation(ctx, models.RelationFollow)
+}
+
+func (h *UserHandler) UnfollowUser(ctx *gin.Context) {
+	h.removeRelation(ctx, models.RelationFollow)
+}
+
+func (h *UserHandler) BlockUser(ctx *gin.Context) {
+	h.addRelation(ctx, models.RelationBlock)
+}
+
+func (h *UserHandler) UnblockUser(ctx *gin.Context) {
+	h.removeRelation(ctx, models.RelationBlock)
+}
+
+func (h *UserHandler) MuteUser(ctx *gin.Context) {
+	h.addRelation(ctx, models.RelationMute)
+}
+
+func (h *UserHandler) UnmuteUser(ctx *gin.Context) {
+	h.removeRelation(ctx, models.RelationMute)
+}
+
+func (h *UserHandler) ListBlockedUsers(ctx *gin.Context) {
+	h.listRelations(ctx, models.RelationBlock)
+}
+
+func (h *UserHandler) ListMutedUsers(ctx *gin.Context) {
+	h.listRelations(ctx, models.RelationMute)
+}
+
+func (h *UserHandler) addRelation(ctx *gin.Context, relation string) {
+	err := h.userUseCase.AddRelation(ctx, relation, ctx.Param("username"))
+	if !h.handleRelationError(ctx, err) {
+		return
+	}
+
+	response.NoContent(ctx)
+}
+
+func (h *UserHandler) removeRelation(ctx *gin.Context, relation string) {
+	err := h.userUseCase.RemoveRelation(ctx, relation, ctx.Param("username"))
+	if !h.handleRelationError(ctx, err) {
+		return
+	}
+
+	response.NoContent(ctx)
+}
+
+func (h *UserHandler) handleRelationError(ctx *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, usecases.ErrUserNotFound):
+		response.Error(ctx, http.StatusNotFound, "User not found.")
+	case errors.Is(err, usecases.ErrSelfRelation):
+		response.Error(ctx, http.StatusBadRequest, "You cannot follow, block or mute yourself.")
+	case errors.Is(err, usecases.ErrBlocked):
+		response.Error(ctx, http.StatusForbidden, "You cannot follow this user.")
+	default:
+		internalError(ctx, err, "Failed to update user relation.")
+	}
+	return false
+}
+
+func (h *UserHandler) listRelations(ctx *gin.Context, relation string) {
+	users, err := h.userUseCase.ListRelations(ctx, relation)
+	if err != nil {
+		internalError(ctx, err, "Failed to retrieve users.")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, user)
+	response.JSON(ctx, http.StatusOK, users)
+}
+
+// ExportUserData returns everything stored about the caller as JSON, or as a
+// ZIP archive with one file per section when called with ?format=zip.
+func (h *UserHandler) ExportUserData(ctx *gin.Context) {
+	export, err := h.userUseCase.ExportData(ctx)
+	if err != nil {
+		internalError(ctx, err, "Failed to export user data.")
+		return
+	}
+
+	filename := fmt.Sprintf("blob-export-%s", export.ExportedAt.Format("20060102-150405"))
+
+	if ctx.Query("format") != "zip" {
+		ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, filename))
+		// The export is a file download, not an API response, so it is
+		// written the same way on every version.
+		ctx.JSON(http.StatusOK, export)
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, filename))
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Status(http.StatusOK)
+
+	archive := zip.NewWriter(ctx.Writer)
+	sections := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", export.Profile},
+		{"blobs.json", export.Blobs},
+		{"comments.json", export.Comments},
+		{"reactions.json", export.Reactions},
+		{"sessions.json", export.Sessions},
+	}
+	for _, section := range sections {
+		file, err := archive.Create(section.name)
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(section.data); err != nil {
+			ctx.Error(err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		ctx.Error(err)
+	}
+}
+
+func (h *UserHandler) DeleteUser(ctx *gin.Context) {
+	scheduledAt, err := h.userUseCase.RequestDeletion(ctx)
+	if err != nil {
+		internalError(ctx, err, "Failed to delete account.")
+		return
+	}
+
+	response.Message(ctx, http.StatusAccepted, "Account scheduled for deletion. Sign in and cancel before the deletion date to keep it.", gin.H{
+		"deletion_scheduled_at": scheduledAt,
+	})
+}
+
+func (h *UserHandler) CancelDeletion(ctx *gin.Context) {
+	cancelled, err := h.userUseCase.CancelDeletion(ctx)
+	if err != nil {
+		internalError(ctx, err, "Failed to cancel account deletion.")
+		return
+	}
+
+	if !cancelled {
+		response.Error(ctx, http.StatusNotFound, "No pending account deletion.")
+		return
+	}
+
+	response.Message(ctx, http.StatusOK, "Account deletion cancelled.", nil)
 }
 
 func (h *UserHandler) UpdateUser(ctx *gin.Context) {
 	email, exists := ctx.Get("email")
 	if !exists {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Email não encontrado no contexto"})
+		response.Error(ctx, http.StatusBadRequest, "Email not found in context")
 		return
 	}
 	
-	var userData models.User
-	if err := ctx.ShouldBindJSON(&userData); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+	var request models.UpdateUserRequest
+	if !bindRequest(ctx, &request) {
 		return
 	}
+	userData := models.User{
+		Name:           request.Name,
+		Email:          request.Email,
+		Username:       request.Username,
+		Bio:            request.Bio,
+		Image:          request.Image,
+		AvatarIcon:     request.AvatarIcon,
+		AvatarColor:    request.AvatarColor,
+		PrivateProfile: request.PrivateProfile,
+		HideLikes:      request.HideLikes,
+	}
 
 	err := h.userUseCase.UpdateUser(ctx, email.(string), userData)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	switch {
+	case errors.Is(err, usecases.ErrInvalidUsername):
+		response.Error(ctx, http.StatusBadRequest, "Usernames must be 3 to 50 letters, digits, dots or underscores and cannot start or end with a dot.")
+		return
+	case errors.Is(err, usecases.ErrUsernameTaken):
+		response.Error(ctx, http.StatusConflict, "Username already taken.")
+		return
+	case err != nil:
+		internalError(ctx, err, "Failed to update user.")
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "User updated successfully"})
+	response.Message(ctx, http.StatusOK, "User updated successfully", nil)
 }
\ No newline at end of file
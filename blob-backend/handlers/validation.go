@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaoleau/blob/response"
+	"github.com/joaoleau/blob/validation"
+	"github.com/pkg/errors"
+)
+
+// bindRequest binds the JSON body into request and validates it, answering
+// 400 itself when either step fails.
+func bindRequest(ctx *gin.Context, request interface{}) bool {
+	if err := ctx.ShouldBindJSON(request); err != nil {
+		response.Error(ctx, http.StatusBadRequest, "Invalid input data.")
+		return false
+	}
+	return validateRequest(ctx, request)
+}
+
+// validateRequest answers 400 with every invalid field of request.
+func validateRequest(ctx *gin.Context, request interface{}) bool {
+	err := validation.Struct(request)
+	if err == nil {
+		return true
+	}
+
+	var fields validation.Errors
+	if errors.As(err, &fields) {
+		response.Fail(ctx, http.StatusBadRequest, response.ErrorBody{Message: "Invalid input data.", Fields: fields})
+		return false
+	}
+
+	response.Error(ctx, http.StatusBadRequest, "Invalid input data.")
+	return false
+}
@@ -0,0 +1,115 @@
+package i18n
+
+var ptBR = map[string]string{
+	// Authentication and request handling.
+	"Authorization header is missing":     "Cabeçalho Authorization ausente.",
+	"Invalid authorization header format": "Formato do cabeçalho Authorization inválido.",
+	"Invalid or expired session":          "Sessão inválida ou expirada.",
+	"Session has expired":                 "A sessão expirou.",
+	"Account has been suspended":          "A conta foi suspensa.",
+	"Email not found in context":          "Email não encontrado no contexto.",
+	"Moderator access required":           "Acesso de moderador necessário.",
+	"Missing or invalid CSRF token":       "Token CSRF ausente ou inválido.",
+	"Too many requests":                   "Muitas requisições.",
+	"Request body too large.":             "Corpo da requisição muito grande.",
+	"Failed to generate CSRF token":       "Falha ao gerar o token CSRF.",
+	"Internal server error.":              "Erro interno do servidor.",
+	"You have access to this route.":      "Você tem acesso a esta rota.",
+	"Invalid input data.":                 "Dados de entrada inválidos.",
+
+	// Validation of request fields.
+	"is required":                                 "é obrigatório",
+	"must have at most %s items":                  "deve ter no máximo %s itens",
+	"must be at most %s characters":               "deve ter no máximo %s caracteres",
+	"must be a valid email address":               "deve ser um endereço de email válido",
+	"must be a valid URL":                         "deve ser uma URL válida",
+	"must be a valid UUID":                        "deve ser um UUID válido",
+	"must be one of: %s":                          "deve ser um de: %s",
+	"must not be blank and at most %s characters": "não pode estar em branco e deve ter no máximo %s caracteres",
+	"must be at most %s distinct, non-blank interests of up to %d characters":                 "deve ter no máximo %s interesses distintos, não vazios, de até %d caracteres",
+	"must be 3 to 50 letters, digits, dots or underscores and cannot start or end with a dot": "deve ter de 3 a 50 letras, dígitos, pontos ou sublinhados e não pode começar nem terminar com ponto",
+	"is invalid": "é inválido",
+
+	// Blobs, comments and reactions.
+	"Invalid blob ID. Must be in UUID format.":    "ID de blob inválido. Deve estar no formato UUID.",
+	"Invalid comment ID. Must be in UUID format.": "ID de comentário inválido. Deve estar no formato UUID.",
+	"Blob not found.":                        "Blob não encontrado.",
+	"Comment not found.":                     "Comentário não encontrado.",
+	"Content not found.":                     "Conteúdo não encontrado.",
+	"Content rejected by moderation filter.": "Conteúdo rejeitado pelo filtro de moderação.",
+	"One or more attachments were not found or are already in use.": "Um ou mais anexos não foram encontrados ou já estão em uso.",
+	"You cannot interact with this blob.":                           "Você não pode interagir com este blob.",
+	"You already reblogged this blob.":                              "Você já reblogou este blob.",
+	"You already reacted with this type.":                           "Você já reagiu com este tipo.",
+	"Invalid reaction type.":                                        "Tipo de reação inválido.",
+	"Failed to create blob.":                                        "Falha ao criar o blob.",
+	"Failed to update blob.":                                        "Falha ao atualizar o blob.",
+	"Failed to delete blob.":                                        "Falha ao excluir o blob.",
+	"Failed to reblog blob.":                                        "Falha ao reblogar o blob.",
+	"Failed to retrieve blob.":                                      "Falha ao buscar o blob.",
+	"Failed to retrieve blobs.":                                     "Falha ao buscar os blobs.",
+	"Failed to retrieve feed.":                                      "Falha ao buscar o feed.",
+	"Failed to retrieve trending blobs.":                            "Falha ao buscar os blobs em alta.",
+	"Failed to retrieve interests.":                                 "Falha ao buscar os interesses.",
+	"Failed to retrieve comments.":                                  "Falha ao buscar os comentários.",
+	"Failed to create comment.":                                     "Falha ao criar o comentário.",
+	"Failed to update comment.":                                     "Falha ao atualizar o comentário.",
+	"Failed to delete comment.":                                     "Falha ao excluir o comentário.",
+	"Failed to add reaction.":                                       "Falha ao adicionar a reação.",
+	"Failed to remove reaction.":                                    "Falha ao remover a reação.",
+	"Failed to retrieve reactions.":                                 "Falha ao buscar as reações.",
+	"Failed to like blob.":                                          "Falha ao curtir o blob.",
+	"Failed to remove like.":                                        "Falha ao remover a curtida.",
+	"Failed to retrieve likes.":                                     "Falha ao buscar as curtidas.",
+
+	// Bookmarks and media.
+	"Bookmark not found.":                       "Favorito não encontrado.",
+	"Failed to bookmark blob.":                  "Falha ao favoritar o blob.",
+	"Failed to remove bookmark.":                "Falha ao remover o favorito.",
+	"Failed to retrieve bookmarks.":             "Falha ao buscar os favoritos.",
+	"A file is required in the \"file\" field.": "Um arquivo é obrigatório no campo \"file\".",
+	"File too large.":                           "Arquivo muito grande.",
+	"Failed to read file.":                      "Falha ao ler o arquivo.",
+	"Unsupported file type.":                    "Tipo de arquivo não suportado.",
+	"Image dimensions too large.":               "Dimensões da imagem muito grandes.",
+	"The file is not a valid image.":            "O arquivo não é uma imagem válida.",
+	"Failed to upload file.":                    "Falha ao enviar o arquivo.",
+
+	// Moderation.
+	"Invalid report ID. Must be in UUID format.": "ID de denúncia inválido. Deve estar no formato UUID.",
+	"Invalid report reason.":                     "Motivo de denúncia inválido.",
+	"You already reported this content.":         "Você já denunciou este conteúdo.",
+	"Open report not found.":                     "Denúncia aberta não encontrada.",
+	"Invalid moderation action.":                 "Ação de moderação inválida.",
+	"Assignee must be a moderator.":              "O responsável deve ser um moderador.",
+	"Failed to report content.":                  "Falha ao denunciar o conteúdo.",
+	"Failed to retrieve reports.":                "Falha ao buscar as denúncias.",
+	"Failed to assign report.":                   "Falha ao atribuir a denúncia.",
+	"Failed to resolve report.":                  "Falha ao resolver a denúncia.",
+	"Failed to retrieve audit log.":              "Falha ao buscar o registro de auditoria.",
+	"Failed to retrieve warnings.":               "Falha ao buscar as advertências.",
+
+	// Users.
+	"User not found.":                            "Usuário não encontrado.",
+	"Failed to fetch user.":                      "Falha ao buscar o usuário.",
+	"Failed to retrieve user.":                   "Falha ao buscar o usuário.",
+	"Failed to update user.":                     "Falha ao atualizar o usuário.",
+	"Failed to retrieve users.":                  "Falha ao buscar os usuários.",
+	"Failed to retrieve mentions.":               "Falha ao buscar as menções.",
+	"Failed to retrieve stats.":                  "Falha ao buscar as estatísticas.",
+	"Failed to update user relation.":            "Falha ao atualizar a relação com o usuário.",
+	"Failed to export user data.":                "Falha ao exportar os dados do usuário.",
+	"Failed to delete account.":                  "Falha ao excluir a conta.",
+	"Failed to cancel account deletion.":         "Falha ao cancelar a exclusão da conta.",
+	"You cannot view this profile.":              "Você não pode ver este perfil.",
+	"This profile is private.":                   "Este perfil é privado.",
+	"This user's likes are hidden.":              "As curtidas deste usuário estão ocultas.",
+	"You cannot follow this user.":               "Você não pode seguir este usuário.",
+	"You cannot follow, block or mute yourself.": "Você não pode seguir, bloquear ou silenciar a si mesmo.",
+	"Username already taken.":                    "Nome de usuário já em uso.",
+	"Usernames must be 3 to 50 letters, digits, dots or underscores and cannot start or end with a dot.": "Nomes de usuário devem ter de 3 a 50 letras, dígitos, pontos ou sublinhados e não podem começar nem terminar com ponto.",
+	"User updated successfully":    "Usuário atualizado com sucesso.",
+	"No pending account deletion.": "Nenhuma exclusão de conta pendente.",
+	"Account deletion cancelled.":  "Exclusão da conta cancelada.",
+	"Account scheduled for deletion. Sign in and cancel before the deletion date to keep it.": "Conta agendada para exclusão. Entre e cancele antes da data de exclusão para mantê-la.",
+}
@@ -0,0 +1,49 @@
+// Package i18n translates the messages the API shows to users. Messages are
+// written in English in the code and double as catalog keys; a message with
+// no translation is answered in English.
+package i18n
+
+import (
+	"fmt"
+
+	"golang.org/x/text/language"
+)
+
+var (
+	BrazilianPortuguese = language.BrazilianPortuguese
+	English             = language.English
+
+	// supported lists the languages with a catalog, the default first.
+	supported = []language.Tag{English, BrazilianPortuguese}
+	matcher   = language.NewMatcher(supported)
+
+	catalogs = map[language.Tag]map[string]string{
+		BrazilianPortuguese: ptBR,
+	}
+)
+
+// Language picks the supported language that best matches an
+// Accept-Language header, English when none does.
+func Language(acceptLanguage string) language.Tag {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return English
+	}
+	_, index, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return English
+	}
+	return supported[index]
+}
+
+// T translates message into lang. With args, message is a fmt format and is
+// formatted after translation.
+func T(lang language.Tag, message string, args ...interface{}) string {
+	if translated, ok := catalogs[lang][message]; ok {
+		message = translated
+	}
+	if len(args) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, args...)
+}
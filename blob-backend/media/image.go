@@ -0,0 +1,150 @@
+// Package media validates uploaded images and re-encodes them. Decoding and
+// encoding again drops every metadata block, EXIF included, so location and
+// camera details never reach storage. The EXIF orientation is applied to the
+// pixels first so photos stay upright.
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/gabriel-vasile/mimetype"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+var (
+	ErrUnsupportedType = errors.New("unsupported media type")
+	ErrTooLarge        = errors.New("media too large")
+	ErrInvalidImage    = errors.New("invalid image")
+)
+
+// AllowedTypes are the sniffed MIME types accepted for upload.
+var AllowedTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+const (
+	// MaxPixels guards against decompression bombs: small files that decode
+	// into huge bitmaps.
+	MaxPixels = 40_000_000
+	// MaxDimension is the longest edge kept for the full-size image.
+	MaxDimension = 2048
+	// ThumbnailDimension is the longest edge of the thumbnail.
+	ThumbnailDimension = 320
+
+	jpegQuality = 85
+)
+
+// Image is an encoded rendition ready to be stored.
+type Image struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+	Width       int
+	Height      int
+}
+
+// Process sniffs data, decodes it and returns a re-encoded full-size image,
+// scaled down to MaxDimension, and a thumbnail. Animated GIFs keep only
+// their first frame.
+func Process(data []byte) (*Image, *Image, error) {
+	contentType := mimetype.Detect(data).String()
+	if !isAllowed(contentType) {
+		return nil, nil, ErrUnsupportedType
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, ErrInvalidImage
+	}
+	if config.Width*config.Height > MaxPixels {
+		return nil, nil, ErrTooLarge
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, ErrInvalidImage
+	}
+	if contentType == "image/jpeg" {
+		decoded = orient(decoded, jpegOrientation(data))
+	}
+
+	// Photos stay JPEG; everything else may carry transparency, so it is
+	// stored as PNG.
+	outputType := "image/png"
+	if contentType == "image/jpeg" {
+		outputType = "image/jpeg"
+	}
+
+	full, err := encode(scale(decoded, MaxDimension), outputType)
+	if err != nil {
+		return nil, nil, err
+	}
+	thumbnail, err := encode(scale(decoded, ThumbnailDimension), outputType)
+	if err != nil {
+		return nil, nil, err
+	}
+	return full, thumbnail, nil
+}
+
+// scale fits img into a max x max box, keeping its aspect ratio. Images that
+// already fit are only copied into a fresh RGBA bitmap.
+func scale(img image.Image, max int) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > max || height > max {
+		if width >= height {
+			height = height * max / width
+			width = max
+		} else {
+			width = width * max / height
+			height = max
+		}
+	}
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+func encode(img image.Image, contentType string) (*Image, error) {
+	var buf bytes.Buffer
+	extension := ".png"
+
+	var err error
+	if contentType == "image/jpeg" {
+		extension = ".jpg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Image{
+		Data:        buf.Bytes(),
+		ContentType: contentType,
+		Extension:   extension,
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+	}, nil
+}
+
+func isAllowed(contentType string) bool {
+	for _, allowed := range AllowedTypes {
+		if contentType == allowed {
+			return true
+		}
+	}
+	return false
+}
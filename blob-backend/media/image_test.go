@@ -0,0 +1,208 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+var (
+	red  = color.RGBA{R: 255, A: 255}
+	blue = color.RGBA{B: 255, A: 255}
+)
+
+// halves returns a width x height image, red on the left half and blue on
+// the right one, so orientation changes are visible.
+func halves(width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if x < width/2 {
+				img.SetRGBA(x, y, red)
+			} else {
+				img.SetRGBA(x, y, blue)
+			}
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encodeJPEG encodes img and, when orientation is set, inserts an EXIF
+// segment carrying it right after the start of image marker.
+func encodeJPEG(t *testing.T, img image.Image, orientation int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if orientation == 0 {
+		return data
+	}
+
+	var exif bytes.Buffer
+	exif.WriteString("Exif\x00\x00MM\x00\x2a")
+	binary.Write(&exif, binary.BigEndian, uint32(8))
+	binary.Write(&exif, binary.BigEndian, uint16(1))
+	binary.Write(&exif, binary.BigEndian, []uint16{exifOrientationTag, 3})
+	binary.Write(&exif, binary.BigEndian, uint32(1))
+	binary.Write(&exif, binary.BigEndian, []uint16{uint16(orientation), 0})
+	binary.Write(&exif, binary.BigEndian, uint32(0))
+
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(exif.Len()+2))
+	segment = append(segment, exif.Bytes()...)
+
+	out := append([]byte{}, data[:2]...)
+	out = append(out, segment...)
+	return append(out, data[2:]...)
+}
+
+// withDimensions rewrites the IHDR of a PNG to claim width x height.
+func withDimensions(data []byte, width, height uint32) []byte {
+	out := append([]byte{}, data...)
+	// Signature (8), chunk length (4), "IHDR" (4), then width and height.
+	binary.BigEndian.PutUint32(out[16:], width)
+	binary.BigEndian.PutUint32(out[20:], height)
+	binary.BigEndian.PutUint32(out[29:], crc32.ChecksumIEEE(out[12:29]))
+	return out
+}
+
+func isRed(c color.Color) bool {
+	r, _, b, _ := c.RGBA()
+	return r > b
+}
+
+func TestProcessRejects(t *testing.T) {
+	small := encodePNG(t, halves(2, 2))
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"text", []byte("just some text"), ErrUnsupportedType},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), ErrUnsupportedType},
+		{"truncated png", small[:20], ErrInvalidImage},
+		{"decompression bomb", withDimensions(small, 10000, 10000), ErrTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := Process(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("Process = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessScalesDown(t *testing.T) {
+	full, thumbnail, err := Process(encodePNG(t, halves(3000, 1500)))
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+
+	if full.Width != MaxDimension || full.Height != MaxDimension/2 {
+		t.Errorf("full = %dx%d", full.Width, full.Height)
+	}
+	if thumbnail.Width != ThumbnailDimension || thumbnail.Height != ThumbnailDimension/2 {
+		t.Errorf("thumbnail = %dx%d", thumbnail.Width, thumbnail.Height)
+	}
+	if full.ContentType != "image/png" || full.Extension != ".png" {
+		t.Errorf("full = %s %s, want a PNG", full.ContentType, full.Extension)
+	}
+}
+
+func TestProcessDropsEXIF(t *testing.T) {
+	data := encodeJPEG(t, halves(40, 20), 1)
+	if !bytes.Contains(data, []byte("Exif")) {
+		t.Fatal("test image has no EXIF")
+	}
+
+	full, thumbnail, err := Process(data)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	for _, img := range []*Image{full, thumbnail} {
+		if img.ContentType != "image/jpeg" || img.Extension != ".jpg" {
+			t.Errorf("output = %s %s, want a JPEG", img.ContentType, img.Extension)
+		}
+		if bytes.Contains(img.Data, []byte("Exif")) {
+			t.Errorf("EXIF survived re-encoding")
+		}
+	}
+}
+
+func TestProcessAppliesOrientation(t *testing.T) {
+	// The stored image is 40x20, red on the left and blue on the right.
+	tests := []struct {
+		orientation   int
+		width, height int
+		topLeftRed    bool
+	}{
+		{0, 40, 20, true},
+		{1, 40, 20, true},
+		{2, 40, 20, false},
+		{3, 40, 20, false},
+		{4, 40, 20, true},
+		{5, 20, 40, true},
+		{6, 20, 40, true},
+		{7, 20, 40, false},
+		{8, 20, 40, false},
+	}
+	for _, tt := range tests {
+		full, _, err := Process(encodeJPEG(t, halves(40, 20), tt.orientation))
+		if err != nil {
+			t.Fatalf("orientation %d: Process: %v", tt.orientation, err)
+		}
+		if full.Width != tt.width || full.Height != tt.height {
+			t.Errorf("orientation %d: size = %dx%d, want %dx%d", tt.orientation, full.Width, full.Height, tt.width, tt.height)
+			continue
+		}
+
+		img, err := jpeg.Decode(bytes.NewReader(full.Data))
+		if err != nil {
+			t.Fatalf("orientation %d: decode: %v", tt.orientation, err)
+		}
+		topLeft := img.At(2, 2)
+		bottomRight := img.At(tt.width-3, tt.height-3)
+		if isRed(topLeft) != tt.topLeftRed || isRed(bottomRight) == tt.topLeftRed {
+			t.Errorf("orientation %d: top left red = %v, bottom right red = %v", tt.orientation, isRed(topLeft), isRed(bottomRight))
+		}
+	}
+}
+
+func TestJPEGOrientationIgnoresBrokenEXIF(t *testing.T) {
+	data := encodeJPEG(t, halves(4, 4), 6)
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{"valid", data, 6},
+		{"not a jpeg", encodePNG(t, halves(4, 4)), 1},
+		{"truncated segment", data[:12], 1},
+		{"no exif", encodeJPEG(t, halves(4, 4), 0), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
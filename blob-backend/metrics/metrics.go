@@ -0,0 +1,74 @@
+// Package metrics defines the Prometheus metrics exported on /metrics.
+package metrics
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "blob"
+
+var (
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of HTTP requests by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	RepositoryQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "repository_query_duration_seconds",
+		Help:      "Duration of repository calls by repository and method.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"repository", "method"})
+
+	BlobsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "blobs_created_total",
+		Help:      "Blobs created, reblogs included.",
+	})
+
+	CommentsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "comments_created_total",
+		Help:      "Comments created.",
+	})
+
+	// Reactions counts new reactions by type; likes are type "like".
+	Reactions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reactions_total",
+		Help:      "Reactions added to blobs and comments by type.",
+	}, []string{"type"})
+)
+
+var (
+	dbCollectorsMu sync.Mutex
+	dbCollectors   = map[string]prometheus.Collector{}
+)
+
+// RegisterDB exports the connection pool statistics of db. Registering a
+// name again replaces the earlier pool, as happens when tests set up the
+// routers more than once in the same process.
+func RegisterDB(db *sqlx.DB, name string) {
+	dbCollectorsMu.Lock()
+	defer dbCollectorsMu.Unlock()
+
+	if previous, ok := dbCollectors[name]; ok {
+		prometheus.Unregister(previous)
+	}
+	collector := collectors.NewDBStatsCollector(db.DB, name)
+	prometheus.MustRegister(collector)
+	dbCollectors[name] = collector
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// Tracer wraps next and records the duration of every repository span, named
+// "XRepo.Method" by convention, in RepositoryQueryDuration. Spans are still
+// handed to next, so it can sit in front of a real tracer.
+func Tracer(next opentracing.Tracer) opentracing.Tracer {
+	return &tracer{Tracer: next}
+}
+
+type tracer struct {
+	opentracing.Tracer
+}
+
+func (t *tracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	span := t.Tracer.StartSpan(operationName, opts...)
+
+	repository, method, ok := strings.Cut(operationName, ".")
+	if !ok || !strings.HasSuffix(repository, "Repo") {
+		return span
+	}
+
+	options := opentracing.StartSpanOptions{}
+	for _, opt := range opts {
+		opt.Apply(&options)
+	}
+	start := options.StartTime
+	if start.IsZero() {
+		start = time.Now()
+	}
+
+	return &timedSpan{
+		Span:     span,
+		tracer:   t,
+		start:    start,
+		observer: RepositoryQueryDuration.WithLabelValues(repository, method),
+	}
+}
+
+// ContextWithSpanHook forwards to next, so a tracer that also keeps its own
+// span in the context, like the OpenTelemetry bridge, sees repository spans.
+func (t *tracer) ContextWithSpanHook(ctx context.Context, span opentracing.Span) context.Context {
+	hook, ok := t.Tracer.(opentracing.TracerContextWithSpanExtension)
+	if !ok {
+		return ctx
+	}
+	if timed, ok := span.(*timedSpan); ok {
+		span = timed.Span
+	}
+	return hook.ContextWithSpanHook(ctx, span)
+}
+
+type timedSpan struct {
+	opentracing.Span
+	tracer   *tracer
+	start    time.Time
+	observer interface{ Observe(float64) }
+}
+
+func (s *timedSpan) Finish() {
+	s.FinishWithOptions(opentracing.FinishOptions{})
+}
+
+func (s *timedSpan) FinishWithOptions(opts opentracing.FinishOptions) {
+	finish := opts.FinishTime
+	if finish.IsZero() {
+		finish = time.Now()
+	}
+	s.observer.Observe(finish.Sub(s.start).Seconds())
+	s.Span.FinishWithOptions(opts)
+}
+
+func (s *timedSpan) Tracer() opentracing.Tracer {
+	return s.tracer
+}
@@ -7,42 +7,59 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/response"
 )
 
 type SessionDetails struct {
 		Email				 string 	 `db:"email"`
     Expires      time.Time `db:"expires"`
+    BannedAt     *time.Time `db:"banned_at"`
 }
 
-func AuthMiddleware(db *sqlx.DB) gin.HandlerFunc {
+// AuthMiddleware reads the session token from the Authorization bearer
+// header or, when sessionCookie is set, from that cookie.
+func AuthMiddleware(db *sqlx.DB, sessionCookie string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is missing"})
+		var sessionToken string
+		if authHeader == "" && sessionCookie != "" {
+			sessionToken, _ = c.Cookie(sessionCookie)
+		}
+		if authHeader == "" && sessionToken == "" {
+			response.Error(c, http.StatusUnauthorized, "Authorization header is missing")
 			c.Abort()
 			return
 		}
 
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
-			c.Abort()
-			return
+		if sessionToken == "" {
+			parts := strings.Split(authHeader, " ")
+			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+				response.Error(c, http.StatusUnauthorized, "Invalid authorization header format")
+				c.Abort()
+				return
+			}
+
+			sessionToken = parts[1]
 		}
 
-		sessionToken := parts[1]
 		var session SessionDetails
 		
-		query := `SELECT u.email, s.expires FROM "Session" s JOIN "User" u ON s.user_id = u.id WHERE session_token = $1`
+		query := `SELECT u.email, s.expires, u.banned_at FROM "Session" s JOIN "User" u ON s.user_id = u.id WHERE session_token = $1`
 		err := db.Get(&session, query, sessionToken)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired session"})
+			response.Error(c, http.StatusUnauthorized, "Invalid or expired session")
 			c.Abort()
 			return
 		}
 
 		if time.Now().After(session.Expires) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has expired"})
+			response.Error(c, http.StatusUnauthorized, "Session has expired")
+			c.Abort()
+			return
+		}
+
+		if session.BannedAt != nil {
+			response.Error(c, http.StatusForbidden, "Account has been suspended")
 			c.Abort()
 			return
 		}
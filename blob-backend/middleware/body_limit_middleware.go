@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaoleau/blob/response"
+)
+
+// BodyLimitMiddleware rejects request bodies larger than limit bytes. Routes
+// listed in exempt enforce their own limit, like media uploads.
+func BodyLimitMiddleware(limit int64, exempt ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(exempt))
+	for _, route := range exempt {
+		skip[route] = true
+	}
+
+	return func(c *gin.Context) {
+		if skip[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			response.Abort(c, http.StatusRequestEntityTooLarge, response.ErrorBody{Message: "Request body too large.", MaxBytes: limit})
+			return
+		}
+		// Chunked bodies have no length up front; reading past the limit fails.
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		c.Next()
+	}
+}
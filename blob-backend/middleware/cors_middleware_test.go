@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaoleau/blob/internal/config"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Default().CORS
+	cfg.AllowedOrigins = []string{"https://app.example"}
+	cfg.AllowCredentials = true
+	cfg.MaxAge = time.Hour
+
+	server := gin.New()
+	server.Use(CORSMiddleware(cfg, "X-CSRF-Token"))
+	server.GET("/api/blob", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name        string
+		method      string
+		origin      string
+		preflight   bool
+		wantStatus  int
+		wantOrigin  string
+		wantMethods string
+		wantExpose  string
+	}{
+		{"allowed preflight", http.MethodOptions, "https://app.example", true, http.StatusNoContent, "https://app.example", "GET, POST, PUT, PATCH, DELETE", ""},
+		{"rejected preflight", http.MethodOptions, "https://evil.example", true, http.StatusForbidden, "", "", ""},
+		{"allowed request", http.MethodGet, "https://app.example", false, http.StatusOK, "https://app.example", "", "X-Request-ID, Retry-After, X-CSRF-Token"},
+		{"rejected request", http.MethodGet, "https://evil.example", false, http.StatusOK, "", "", ""},
+		{"same origin", http.MethodGet, "", false, http.StatusOK, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/blob", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			header := recorder.Header()
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if got := header.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := header.Get("Access-Control-Allow-Methods"); got != tt.wantMethods {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, tt.wantMethods)
+			}
+			if got := header.Get("Access-Control-Expose-Headers"); got != tt.wantExpose {
+				t.Errorf("Access-Control-Expose-Headers = %q, want %q", got, tt.wantExpose)
+			}
+			wantCredentials := ""
+			if tt.wantOrigin != "" {
+				wantCredentials = "true"
+			}
+			if got := header.Get("Access-Control-Allow-Credentials"); got != wantCredentials {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, wantCredentials)
+			}
+			if tt.preflight && tt.wantStatus == http.StatusNoContent && header.Get("Access-Control-Max-Age") != "3600" {
+				t.Errorf("Access-Control-Max-Age = %q", header.Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+}
+
+func TestCORSWildcardWithoutCredentials(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := config.Default().CORS
+	cfg.AllowedOrigins = []string{"*"}
+
+	server := gin.New()
+	server.Use(CORSMiddleware(cfg))
+	server.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Origin", "https://anywhere.example")
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+
+	if got := recorder.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want *", got)
+	}
+	if got := recorder.Header().Get("Access-Control-Expose-Headers"); got != "X-Request-ID, Retry-After" {
+		t.Errorf("Access-Control-Expose-Headers = %q", got)
+	}
+}
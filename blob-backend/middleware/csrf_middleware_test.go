@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	server := gin.New()
+	server.Use(CSRFMiddleware("session", "csrf_token", "X-CSRF-Token"))
+	server.Any("/api/blob", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name          string
+		method        string
+		cookies       map[string]string
+		headers       map[string]string
+		wantStatus    int
+		wantNewCookie bool
+	}{
+		{"safe request gets a token", http.MethodGet, nil, nil, http.StatusOK, true},
+		{"unsafe without a session", http.MethodPost, nil, nil, http.StatusOK, true},
+		{"bearer token", http.MethodPost, map[string]string{"session": "s"}, map[string]string{"Authorization": "Bearer s"}, http.StatusOK, true},
+		{"session without token", http.MethodPost, map[string]string{"session": "s", "csrf_token": "t"}, nil, http.StatusForbidden, false},
+		{"session with wrong token", http.MethodDelete, map[string]string{"session": "s", "csrf_token": "t"}, map[string]string{"X-CSRF-Token": "other"}, http.StatusForbidden, false},
+		{"session with matching token", http.MethodPut, map[string]string{"session": "s", "csrf_token": "t"}, map[string]string{"X-CSRF-Token": "t"}, http.StatusOK, false},
+		// A token issued by this very response cannot be echoed yet.
+		{"session before any token", http.MethodPost, map[string]string{"session": "s"}, nil, http.StatusForbidden, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/blob", nil)
+			for name, value := range tt.cookies {
+				req.AddCookie(&http.Cookie{Name: name, Value: value})
+			}
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+
+			var issued string
+			for _, cookie := range recorder.Result().Cookies() {
+				if cookie.Name == "csrf_token" {
+					issued = cookie.Value
+				}
+			}
+			if (issued != "") != tt.wantNewCookie {
+				t.Errorf("issued cookie = %q, want a new one: %v", issued, tt.wantNewCookie)
+			}
+
+			want := tt.cookies["csrf_token"]
+			if issued != "" {
+				want = issued
+			}
+			if got := recorder.Header().Get("X-CSRF-Token"); got == "" || got != want {
+				t.Errorf("X-CSRF-Token header = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/response"
+)
+
+// ModeratorMiddleware must run after AuthMiddleware; it only lets users with
+// the moderator or admin role through.
+func ModeratorMiddleware(db *sqlx.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email, exists := c.Get("email")
+		if !exists {
+			response.Error(c, http.StatusUnauthorized, "Email not found in context")
+			c.Abort()
+			return
+		}
+
+		var role string
+		query := `SELECT role FROM "User" WHERE email = $1`
+		if err := db.GetContext(c.Request.Context(), &role, query, email); err != nil {
+			response.Error(c, http.StatusForbidden, "Moderator access required")
+			c.Abort()
+			return
+		}
+
+		if role != "moderator" && role != "admin" {
+			response.Error(c, http.StatusForbidden, "Moderator access required")
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
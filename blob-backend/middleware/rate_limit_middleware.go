@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaoleau/blob/response"
+)
+
+// RateLimitMiddleware gives every client IP a token bucket that refills at
+// requestsPerMinute and holds up to burst requests. Requests over the limit
+// get 429 with a Retry-After header.
+func RateLimitMiddleware(requestsPerMinute, burst int) gin.HandlerFunc {
+	limiter := &rateLimiter{
+		rate:    float64(requestsPerMinute) / 60,
+		burst:   float64(burst),
+		buckets: make(map[string]*bucket),
+	}
+
+	return func(c *gin.Context) {
+		wait := limiter.take(c.ClientIP(), time.Now())
+		if wait > 0 {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			response.Error(c, http.StatusTooManyRequests, "Too many requests")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+type bucket struct {
+	tokens float64
+	seen   time.Time
+}
+
+type rateLimiter struct {
+	mu         sync.Mutex
+	rate       float64
+	burst      float64
+	buckets    map[string]*bucket
+	lastPruned time.Time
+}
+
+// take spends a token for key and returns zero, or how long the client has
+// to wait for the next one.
+func (l *rateLimiter) take(key string, now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.prune(now)
+
+	b := l.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: l.burst, seen: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.seen).Seconds()*l.rate)
+	b.seen = now
+	if b.tokens < 1 {
+		return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return 0
+}
+
+// prune drops, once a minute, the buckets that refilled completely; they
+// behave exactly like a missing bucket.
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPruned) < time.Minute {
+		return
+	}
+	l.lastPruned = now
+
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.seen) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
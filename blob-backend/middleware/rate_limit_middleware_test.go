@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRateLimitIgnoresSpoofedForwardedFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		proxies []string
+		want    []int
+	}{
+		// Without trusted proxies every request counts against the peer
+		// address, whatever X-Forwarded-For claims.
+		{"untrusted peer", nil, []int{http.StatusOK, http.StatusTooManyRequests, http.StatusTooManyRequests}},
+		// Behind a trusted proxy each forwarded client gets its own bucket.
+		{"trusted proxy", []string{"10.0.0.0/8"}, []int{http.StatusOK, http.StatusOK, http.StatusOK}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := gin.New()
+			if err := server.SetTrustedProxies(tt.proxies); err != nil {
+				t.Fatal(err)
+			}
+			server.Use(RateLimitMiddleware(1, 1))
+			server.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			for i, spoofed := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3"} {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.RemoteAddr = "10.1.2.3:4567"
+				req.Header.Set("X-Forwarded-For", spoofed)
+				req.Header.Set("X-Real-IP", spoofed)
+				recorder := httptest.NewRecorder()
+				server.ServeHTTP(recorder, req)
+
+				if recorder.Code != tt.want[i] {
+					t.Errorf("request %d from %s: status = %d, want %d", i+1, spoofed, recorder.Code, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaoleau/blob/internal/logging"
+	"github.com/joaoleau/blob/response"
+)
+
+// RecoveryMiddleware turns a panic in a handler into a JSON 500 and logs it,
+// with the stack, through the request logger.
+func RecoveryMiddleware() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		logging.FromContext(c).ErrorContext(c, "Recovered from panic",
+			slog.String("panic", fmt.Sprint(recovered)),
+			slog.String("stack", string(debug.Stack())),
+		)
+		response.Abort(c, http.StatusInternalServerError, response.ErrorBody{Message: "Internal server error."})
+	})
+}
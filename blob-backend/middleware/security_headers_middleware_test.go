@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		hstsMaxAge time.Duration
+		wantHSTS   string
+	}{
+		{24 * time.Hour, "max-age=86400; includeSubDomains"},
+		{0, ""},
+	}
+	for _, tt := range tests {
+		server := gin.New()
+		server.Use(SecurityHeadersMiddleware(tt.hstsMaxAge))
+		server.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+
+		header := recorder.Header()
+		if got := header.Get("Strict-Transport-Security"); got != tt.wantHSTS {
+			t.Errorf("hsts %v: Strict-Transport-Security = %q, want %q", tt.hstsMaxAge, got, tt.wantHSTS)
+		}
+		for name, want := range map[string]string{
+			"X-Content-Type-Options": "nosniff",
+			"X-Frame-Options":        "DENY",
+			"Referrer-Policy":        "no-referrer",
+		} {
+			if got := header.Get(name); got != want {
+				t.Errorf("%s = %q, want %q", name, got, want)
+			}
+		}
+	}
+}
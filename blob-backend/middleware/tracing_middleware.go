@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/joaoleau/blob/internal/telemetry"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+// TracingMiddleware starts a server span for every request, continuing the
+// trace of an incoming W3C traceparent header, and makes it the parent of the
+// opentracing spans started by the use cases and repositories. The engine
+// needs ContextWithFallback so handlers passing *gin.Context down still carry
+// the request context.
+func TracingMiddleware(service string) []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		otelgin.Middleware(service),
+		func(c *gin.Context) {
+			c.Request = c.Request.WithContext(telemetry.ContextWithOpenTracingSpan(c.Request.Context()))
+			c.Next()
+		},
+	}
+}
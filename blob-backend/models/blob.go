@@ -20,6 +20,23 @@ type BlobWithInterests struct {
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 	UserID    string    `json:"user_id" db:"user_id" validate:"required,uuid"`
 	Interests     []string  `json:"interests"`
+	Entities  []ContentEntity `json:"entities"`
+	HeldForReview bool    `json:"held_for_review,omitempty"`
+	MediaIDs  []uuid.UUID `json:"-"`
+	// MentionIDs are the users notified by the @mentions in Content; the
+	// repository stores them together with the blob.
+	MentionIDs []string   `json:"-"`
+	// Hashtags are the #tags in Content; the repository links the blob to
+	// their interests, creating missing ones, as it writes the blob.
+	Hashtags  []string    `json:"-"`
+	// HoldReport, when set, hides the blob as it is written and files this
+	// filter report in the same transaction.
+	HoldReport *Report    `json:"-"`
+	Media     []Media     `json:"media"`
+	LinkPreviews []LinkPreview `json:"link_previews"`
+	ReblogOfID *string    `json:"-" db:"reblog_of_id"`
+	IsReblog  bool        `json:"is_reblog" db:"is_reblog"`
+	ReblogOf  *ReblogOf   `json:"reblog_of,omitempty" db:"-"`
 }
 
 type BlobListWithDetails struct {
@@ -33,28 +50,54 @@ type BlobListWithDetails struct {
     UserCreatedAt time.Time `json:"user_created_at" db:"user_created_at"`
     LikesCount    int       `json:"likes_count" db:"likes_count"`
     CommentsCount int       `json:"comments_count" db:"comments_count"`
+    ReblogsCount  int       `json:"reblogs_count" db:"reblogs_count"`
+    ReactionCounts ReactionCounts `json:"reaction_counts" db:"reaction_counts"`
+    IsReblog      bool      `json:"is_reblog" db:"is_reblog"`
+    ReblogOf      *ReblogOf `json:"reblog_of,omitempty" db:"-"`
     Interests     []string  `json:"interests"`
+    Entities      []ContentEntity `json:"entities"`
+    Media         []Media   `json:"media"`
+    LinkPreviews  []LinkPreview `json:"link_previews"`
+    ViewerState
 }
 
+// BlobWithDetails is the blob detail response. It carries the first page of
+// comments and likes only; Links point at the endpoints for the rest.
 type BlobWithDetails struct {
-	ID           string    `json:"id"`
-	UserID       string    `json:"user_id"`
-	Content      string    `json:"content"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Username     string    `json:"username"`
-	AvatarIcon   string    `json:"avatar_icon"`
-	UserCreatedAt time.Time `json:"user_created_at"`
-	Comments     []Comment `json:"comments"`
-	Likes        []Like    `json:"likes"`
-	Interests    []Interest  `json:"interests"`
+	ID           string    `json:"id" db:"id"`
+	UserID       string    `json:"user_id" db:"user_id"`
+	Content      string    `json:"content" db:"content"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+	Username     string    `json:"username" db:"username"`
+	AvatarIcon   string    `json:"avatar_icon" db:"avatar_icon"`
+	UserCreatedAt time.Time `json:"user_created_at" db:"user_created_at"`
+	LikesCount   int       `json:"likes_count" db:"likes_count"`
+	CommentsCount int      `json:"comments_count" db:"comments_count"`
+	ReblogsCount int       `json:"reblogs_count" db:"reblogs_count"`
+	ReactionCounts ReactionCounts `json:"reaction_counts" db:"reaction_counts"`
+	IsReblog     bool      `json:"is_reblog" db:"is_reblog"`
+	ReblogOf     *ReblogOf `json:"reblog_of,omitempty" db:"-"`
+	Comments     []CommentWithUser `json:"comments" db:"-"`
+	Likes        []ReactionWithUser `json:"likes" db:"-"`
+	Interests    []Interest  `json:"interests" db:"-"`
+	Entities     []ContentEntity `json:"entities" db:"-"`
+	Media        []Media   `json:"media" db:"-"`
+	LinkPreviews []LinkPreview `json:"link_previews" db:"-"`
+	Links        BlobLinks `json:"links" db:"-"`
+	ViewerState
+}
+
+type BlobLinks struct {
+	Comments string `json:"comments"`
+	Likes    string `json:"likes"`
 }
 
 type BlobList struct {
-	TotalCount int     `json:"total_count"`
-	TotalPages int     `json:"total_pages"`
-	Page       int     `json:"page"`
-	Size       int     `json:"size"`
-	HasMore    bool    `json:"has_more"`
-	Users      []*BlobListWithDetails `json:"blobs"`
+	Pagination
+	Users []*BlobListWithDetails `json:"blobs"`
+}
+
+func (l BlobList) PageItems() interface{} {
+	return l.Users
 }
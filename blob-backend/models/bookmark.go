@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// Bookmark is a saved blob. Once the blob expires the bookmark keeps showing
+// a snapshot of it, marked as expired; likes, comments and media go away with
+// the original.
+type Bookmark struct {
+	BlobID        string          `json:"blob_id" db:"blob_id"`
+	UserID        string          `json:"user_id" db:"user_id"`
+	Username      string          `json:"username" db:"username"`
+	AvatarIcon    string          `json:"avatar_icon" db:"avatar_icon"`
+	Content       string          `json:"content" db:"content"`
+	Interests     []string        `json:"interests" db:"-"`
+	Entities      []ContentEntity `json:"entities" db:"-"`
+	BlobCreatedAt time.Time       `json:"blob_created_at" db:"blob_created_at"`
+	BookmarkedAt  time.Time       `json:"bookmarked_at" db:"bookmarked_at"`
+	Expired       bool            `json:"expired" db:"expired"`
+}
+
+type BookmarkList struct {
+	Pagination
+	Bookmarks []*Bookmark `json:"bookmarks"`
+}
+
+func (l BookmarkList) PageItems() interface{} {
+	return l.Bookmarks
+}
+
+// UserStats are the private counters shown to the owner of an account.
+type UserStats struct {
+	BlobsCount        int `json:"blobs_count" db:"blobs_count"`
+	LikesReceived     int `json:"likes_received" db:"likes_received"`
+	CommentsReceived  int `json:"comments_received" db:"comments_received"`
+	ReblogsReceived   int `json:"reblogs_received" db:"reblogs_received"`
+	BookmarksReceived int `json:"bookmarks_received" db:"bookmarks_received"`
+	BookmarksSaved    int `json:"bookmarks_saved" db:"bookmarks_saved"`
+}
@@ -12,6 +12,14 @@ type Comment struct {
 	UpdatedAt time.Time   	`json:"updated_at" db:"updated_at"`
 	UserID    string       	`json:"user_id" db:"user_id" validate:"required,uuid"`
 	BlobID    uuid.UUID    	`json:"blob_id" db:"blob_id" validate:"required,uuid"`
+	Entities  []ContentEntity `json:"entities"`
+	HeldForReview bool      `json:"held_for_review,omitempty"`
+	// MentionIDs are the users notified by the @mentions in Content; the
+	// repository stores them together with the comment.
+	MentionIDs []string     `json:"-"`
+	// HoldReport, when set, hides the comment as it is written and files
+	// this filter report in the same transaction.
+	HoldReport *Report      `json:"-"`
 }
 
 type CommentWithUser struct {
@@ -25,4 +33,7 @@ type CommentWithUser struct {
 	AvatarIcon    string    `json:"avatar_icon" db:"avatar_icon" default:"user"`
 	AvatarColor   string    `json:"avatar_color" db:"avatar_color" default:"cyan"`
 	BlobID    uuid.UUID    	`json:"blob_id" db:"blob_id" validate:"required,uuid"`
+	ReactionCounts ReactionCounts `json:"reaction_counts" db:"reaction_counts"`
+	Entities  []ContentEntity `json:"entities"`
+	HeldForReview bool      `json:"held_for_review,omitempty"`
 }
@@ -0,0 +1,18 @@
+package models
+
+const (
+	EntityHashtag = "hashtag"
+	EntityMention = "mention"
+)
+
+// ContentEntity marks a #tag or @username inside a blob or comment.
+// Start and End are rune offsets into the content (End is exclusive) and
+// include the leading '#' or '@'; Text holds the tag or username without it.
+// A rune is a Unicode code point, so an emoji outside the BMP counts once,
+// not twice as in JavaScript's UTF-16 string indices.
+type ContentEntity struct {
+	Type  string `json:"type"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Text  string `json:"text"`
+}
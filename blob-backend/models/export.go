@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+type UserExport struct {
+	ExportedAt time.Time  `json:"exported_at"`
+	Profile    *User      `json:"profile"`
+	Blobs      []Blob     `json:"blobs"`
+	Comments   []Comment  `json:"comments"`
+	Reactions  []Reaction `json:"reactions"`
+	Sessions   []Session  `json:"sessions"`
+}
@@ -12,14 +12,18 @@ type Like struct {
 	BlobID    uuid.UUID `json:"blob_id" db:"blob_id" validate:"required,uuid"`
 }
 
-type LikeWithUser struct {
-	ID        uuid.UUID `json:"id" db:"id" validate:"required,uuid"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UserID    string    `json:"user_id" db:"user_id" validate:"required,uuid"`
-	BlobID    uuid.UUID `json:"blob_id" db:"blob_id" validate:"required,uuid"`
-	Image         string    `json:"image,omitempty" db:"image"`
-	Username      string    `json:"username,omitempty" db:"username"`
-	AvatarIcon    string    `json:"avatar_icon" db:"avatar_icon" default:"user"`
-	AvatarColor   string    `json:"avatar_color" db:"avatar_color" default:"cyan"`
+// LikeState is what the like routes answer with after a change.
+type LikeState struct {
+	BlobID     uuid.UUID `json:"blob_id" db:"-"`
+	LikesCount int       `json:"likes_count" db:"likes_count"`
+	LikedByMe  bool      `json:"liked_by_me" db:"liked_by_me"`
 }
 
+type LikeList struct {
+	Pagination
+	Likes []ReactionWithUser `json:"likes"`
+}
+
+func (l LikeList) PageItems() interface{} {
+	return l.Likes
+}
@@ -0,0 +1,23 @@
+package models
+
+const (
+	LinkPreviewPending  = "pending"
+	LinkPreviewFetching = "fetching"
+	LinkPreviewReady    = "ready"
+	LinkPreviewFailed   = "failed"
+)
+
+// LinkPreview is the cached unfurl of a normalized URL.
+type LinkPreview struct {
+	URL         string `json:"url" db:"url"`
+	Title       string `json:"title,omitempty" db:"title"`
+	Description string `json:"description,omitempty" db:"description"`
+	ImageURL    string `json:"image_url,omitempty" db:"image_url"`
+	SiteName    string `json:"site_name,omitempty" db:"site_name"`
+}
+
+// LinkPreviewJob is a queued URL claimed by the preview worker.
+type LinkPreviewJob struct {
+	URL      string `db:"url"`
+	Attempts int    `db:"attempts"`
+}
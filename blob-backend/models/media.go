@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Media is an uploaded image. Uploads expire unless attached to a blob, and
+// expire again once that blob is deleted.
+type Media struct {
+	ID           uuid.UUID  `json:"id" db:"id"`
+	UserID       *string    `json:"user_id,omitempty" db:"user_id"`
+	BlobID       *uuid.UUID `json:"blob_id,omitempty" db:"blob_id"`
+	ContentType  string     `json:"content_type" db:"content_type"`
+	Size         int64      `json:"size" db:"size"`
+	Width        int        `json:"width" db:"width"`
+	Height       int        `json:"height" db:"height"`
+	StorageKey   string     `json:"-" db:"storage_key"`
+	ThumbnailKey string     `json:"-" db:"thumbnail_key"`
+	URL          string     `json:"url" db:"-"`
+	ThumbnailURL string     `json:"thumbnail_url" db:"-"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Mention struct {
+	ID              uuid.UUID  `json:"id" db:"id" validate:"required,uuid"`
+	BlobID          uuid.UUID  `json:"blob_id" db:"blob_id" validate:"required,uuid"`
+	CommentID       *uuid.UUID `json:"comment_id,omitempty" db:"comment_id"`
+	AuthorID        string     `json:"author_id" db:"author_id" validate:"required,uuid"`
+	MentionedUserID string     `json:"mentioned_user_id" db:"mentioned_user_id" validate:"required,uuid"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+}
+
+type MentionWithAuthor struct {
+	ID                uuid.UUID       `json:"id" db:"id"`
+	BlobID            uuid.UUID       `json:"blob_id" db:"blob_id"`
+	CommentID         *uuid.UUID      `json:"comment_id,omitempty" db:"comment_id"`
+	AuthorID          string          `json:"author_id" db:"author_id"`
+	AuthorUsername    string          `json:"author_username" db:"author_username"`
+	AuthorAvatarIcon  string          `json:"author_avatar_icon" db:"author_avatar_icon"`
+	AuthorAvatarColor string          `json:"author_avatar_color" db:"author_avatar_color"`
+	Content           string          `json:"content" db:"content"`
+	CreatedAt         time.Time       `json:"created_at" db:"created_at"`
+	Entities          []ContentEntity `json:"entities"`
+}
+
+type MentionList struct {
+	Pagination
+	Mentions []MentionWithAuthor `json:"mentions"`
+}
+
+func (l MentionList) PageItems() interface{} {
+	return l.Mentions
+}
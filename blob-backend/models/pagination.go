@@ -0,0 +1,22 @@
+package models
+
+// Pagination describes one page of a list response. The list types embed it
+// so its fields sit next to the items.
+type Pagination struct {
+	TotalCount int  `json:"total_count"`
+	TotalPages int  `json:"total_pages"`
+	Page       int  `json:"page"`
+	Size       int  `json:"size"`
+	HasMore    bool `json:"has_more"`
+}
+
+func (p Pagination) PageInfo() Pagination {
+	return p
+}
+
+// Paginated is implemented by the list responses: the versioned API moves
+// the page to the response meta and answers the items alone as data.
+type Paginated interface {
+	PageInfo() Pagination
+	PageItems() interface{}
+}
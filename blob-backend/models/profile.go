@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+)
+
+// PublicProfile is what other users see of an account: no email or other
+// private data, plus counters and the viewer's relation to the owner.
+type PublicProfile struct {
+	ID             string    `json:"id" db:"id"`
+	Username       string    `json:"username" db:"username"`
+	Name           string    `json:"name,omitempty" db:"name"`
+	Image          string    `json:"image,omitempty" db:"image"`
+	Bio            string    `json:"bio,omitempty" db:"bio"`
+	AvatarIcon     string    `json:"avatar_icon" db:"avatar_icon"`
+	AvatarColor    string    `json:"avatar_color" db:"avatar_color"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	PrivateProfile bool      `json:"private_profile" db:"private_profile"`
+	HideLikes      bool      `json:"hide_likes" db:"hide_likes"`
+	FollowersCount int       `json:"followers_count" db:"followers_count"`
+	FollowingCount int       `json:"following_count" db:"following_count"`
+	BlobsCount     int       `json:"blobs_count" db:"blobs_count"`
+	LikesCount     int       `json:"likes_count" db:"likes_count"`
+	FollowedByMe   bool      `json:"followed_by_me" db:"followed_by_me"`
+	FollowsMe      bool      `json:"follows_me" db:"follows_me"`
+	IsMe           bool      `json:"is_me" db:"-"`
+	BlockedMe      bool      `json:"-" db:"blocked_me"`
+}
+
+type CommentList struct {
+	Pagination
+	Comments []CommentWithUser `json:"comments"`
+}
+
+func (l CommentList) PageItems() interface{} {
+	return l.Comments
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReactionLike is the reaction behind the like endpoints, likes_count and the
+// liked blobs of a profile.
+const ReactionLike = "like"
+
+// ReactionTypes lists the reactions in the order clients show them.
+var ReactionTypes = []string{ReactionLike, "love", "laugh", "wow", "sad", "fire"}
+
+// Reaction is a user's reaction to a blob, or to one of its comments when
+// CommentID is set. A user has at most one reaction of each type per target.
+type Reaction struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	UserID    string     `json:"user_id" db:"user_id"`
+	BlobID    uuid.UUID  `json:"blob_id" db:"blob_id"`
+	CommentID *uuid.UUID `json:"comment_id,omitempty" db:"comment_id"`
+	Type      string     `json:"type" db:"type"`
+}
+
+type ReactionWithUser struct {
+	Reaction
+	Image       string `json:"image,omitempty" db:"image"`
+	Username    string `json:"username,omitempty" db:"username"`
+	AvatarIcon  string `json:"avatar_icon" db:"avatar_icon"`
+	AvatarColor string `json:"avatar_color" db:"avatar_color"`
+}
+
+// ReactionGroup is every reaction of one type on a target.
+type ReactionGroup struct {
+	Type        string             `json:"type"`
+	Count       int                `json:"count"`
+	ReactedByMe bool               `json:"reacted_by_me"`
+	Users       []ReactionWithUser `json:"users"`
+}
+
+// ReactionCounts maps a reaction type to how many users reacted with it. It
+// scans the JSON object built by the list queries.
+type ReactionCounts map[string]int
+
+func (c *ReactionCounts) Scan(src interface{}) error {
+	counts := ReactionCounts{}
+	switch value := src.(type) {
+	case nil:
+	case []byte:
+		if err := json.Unmarshal(value, &counts); err != nil {
+			return err
+		}
+	case string:
+		if err := json.Unmarshal([]byte(value), &counts); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("cannot scan %T into ReactionCounts", src)
+	}
+	*c = counts
+	return nil
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// ReblogOf is the blob a reblog points at. Once the original expires or is
+// deleted only Available is set, false, and clients show a "no longer
+// available" placeholder; the reblog itself stays until its own expiry.
+type ReblogOf struct {
+	Available  bool       `json:"available" db:"-"`
+	ID         string     `json:"id,omitempty" db:"id"`
+	UserID     string     `json:"user_id,omitempty" db:"user_id"`
+	Username   string     `json:"username,omitempty" db:"username"`
+	AvatarIcon string     `json:"avatar_icon,omitempty" db:"avatar_icon"`
+	Content    string     `json:"content,omitempty" db:"content"`
+	CreatedAt  *time.Time `json:"created_at,omitempty" db:"created_at"`
+}
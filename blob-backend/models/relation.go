@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	RelationBlock  = "block"
+	RelationMute   = "mute"
+	RelationFollow = "follow"
+)
+
+type RelatedUser struct {
+	ID          string    `json:"id" db:"id"`
+	Username    string    `json:"username" db:"username"`
+	AvatarIcon  string    `json:"avatar_icon" db:"avatar_icon"`
+	AvatarColor string    `json:"avatar_color" db:"avatar_color"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusAssigned  = "assigned"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+
+	ReportSourceUser   = "user"
+	ReportSourceFilter = "filter"
+
+	ModerationActionHide    = "hide"
+	ModerationActionDelete  = "delete"
+	ModerationActionWarn    = "warn"
+	ModerationActionBan     = "ban"
+	ModerationActionDismiss = "dismiss"
+	ModerationActionAssign  = "assign"
+)
+
+var ReportReasons = []string{"spam", "harassment", "hate", "violence", "nudity", "misinformation", "other"}
+
+type Report struct {
+	ID             uuid.UUID  `json:"id" db:"id" validate:"required,uuid"`
+	ReporterID     *string    `json:"reporter_id,omitempty" db:"reporter_id"`
+	BlobID         *uuid.UUID `json:"blob_id,omitempty" db:"blob_id"`
+	CommentID      *uuid.UUID `json:"comment_id,omitempty" db:"comment_id"`
+	TargetUserID   string     `json:"target_user_id" db:"target_user_id" validate:"required,uuid"`
+	Content        string     `json:"content" db:"content"`
+	Reason         string     `json:"reason" db:"reason" validate:"required"`
+	Details        string     `json:"details,omitempty" db:"details"`
+	Source         string     `json:"source" db:"source"`
+	Status         string     `json:"status" db:"status"`
+	AssigneeID     *string    `json:"assignee_id,omitempty" db:"assignee_id"`
+	Action         *string    `json:"action,omitempty" db:"action"`
+	ResolutionNote *string    `json:"resolution_note,omitempty" db:"resolution_note"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+	ResolvedAt     *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+}
+
+type ReportRequest struct {
+	Reason  string `json:"reason" validate:"required"`
+	Details string `json:"details" validate:"max=1000"`
+}
+
+type AssignReportRequest struct {
+	AssigneeID string `json:"assignee_id" validate:"omitempty,uuid"`
+}
+
+type ResolveReportRequest struct {
+	Action string `json:"action" validate:"required"`
+	Note   string `json:"note" validate:"max=1000"`
+}
+
+type ReportList struct {
+	Pagination
+	Reports []*Report `json:"reports"`
+}
+
+func (l ReportList) PageItems() interface{} {
+	return l.Reports
+}
+
+type AuditLogEntry struct {
+	ID          uuid.UUID  `json:"id" db:"id"`
+	ModeratorID *string    `json:"moderator_id,omitempty" db:"moderator_id"`
+	Action      string     `json:"action" db:"action"`
+	ReportID    *uuid.UUID `json:"report_id,omitempty" db:"report_id"`
+	TargetType  string     `json:"target_type" db:"target_type"`
+	TargetID    string     `json:"target_id" db:"target_id"`
+	Details     string     `json:"details,omitempty" db:"details"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// Warning is what a user sees after a moderator resolved a report against
+// them with the warn action. Reason is the report's reason and Note the
+// moderator's resolution note.
+type Warning struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	ReportID  *uuid.UUID `json:"report_id,omitempty" db:"report_id"`
+	Reason    string     `json:"reason" db:"reason"`
+	Note      string     `json:"note,omitempty" db:"note"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"github.com/google/uuid"
+)
+
+// Request DTOs for the create and update endpoints. Handlers bind the JSON
+// body into these and run validation.Struct before building the models.
+
+type CreateBlobRequest struct {
+	Content   string      `json:"content" validate:"content=1000"`
+	Interests []string    `json:"interests" validate:"interests=5"`
+	MediaIDs  []uuid.UUID `json:"media_ids" validate:"max=4"`
+}
+
+// UpdateBlobRequest replaces the attachments only when media_ids is present;
+// an empty list removes them all.
+type UpdateBlobRequest struct {
+	Content   string      `json:"content" validate:"omitempty,content=1000"`
+	Interests []string    `json:"interests" validate:"interests=5"`
+	MediaIDs  []uuid.UUID `json:"media_ids" validate:"max=4"`
+}
+
+// ReblogRequest reblogs a blob; with content it becomes a quote.
+type ReblogRequest struct {
+	Content string `json:"content" validate:"omitempty,content=1000"`
+}
+
+// BlobBatchRequest asks for the viewer state of up to 100 blobs at once.
+type BlobBatchRequest struct {
+	IDs []uuid.UUID `json:"ids" validate:"required,max=100"`
+}
+
+type ReactionRequest struct {
+	Type string `json:"type" validate:"required,reaction"`
+}
+
+type CreateCommentRequest struct {
+	Content string `json:"content" validate:"content=500"`
+}
+
+type UpdateCommentRequest struct {
+	Content string `json:"content" validate:"content=500"`
+}
+
+type UpdateUserRequest struct {
+	Name           string `json:"name" validate:"max=100"`
+	Email          string `json:"email" validate:"omitempty,email,max=255"`
+	Username       string `json:"username" validate:"omitempty,username"`
+	Bio            string `json:"bio" validate:"max=500"`
+	Image          string `json:"image" validate:"omitempty,url,max=255"`
+	AvatarIcon     string `json:"avatar_icon" validate:"omitempty,avatar_icon"`
+	AvatarColor    string `json:"avatar_color" validate:"omitempty,avatar_color"`
+	PrivateProfile *bool  `json:"private_profile"`
+	HideLikes      *bool  `json:"hide_likes"`
+}
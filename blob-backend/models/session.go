@@ -0,0 +1,13 @@
+package models
+
+import (
+	"time"
+)
+
+// Session is the part of a login session that is safe to show to its owner;
+// the session token itself is never returned.
+type Session struct {
+	ID        string    `json:"id" db:"id"`
+	Expires   time.Time `json:"expires" db:"expires"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
@@ -17,28 +17,16 @@ type User struct {
 	AvatarColor   string    `json:"avatar_color" db:"avatar_color" default:"cyan"`
 	CreatedAt     time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty" db:"deletion_scheduled_at"`
+	PrivateProfile *bool    `json:"private_profile,omitempty" db:"private_profile"`
+	HideLikes     *bool     `json:"hide_likes,omitempty" db:"hide_likes"`
 }
 
-type UserWithBlobs struct {
-	ID              string    `db:"id"`
-	Name            string    `db:"name"`
-	Email           string    `db:"email"`
-	EmailVerified   time.Time `db:"email_verified"`
-	Image           string    `db:"image"`
-	Username        string    `db:"username"`
-	Bio             string    `db:"bio"`
-	AvatarIcon      string    `db:"avatar_icon"`
-	AvatarColor     string    `db:"avatar_color"`
-	CreatedAt       time.Time `db:"created_at"`
-	UpdatedAt       time.Time `db:"updated_at"`
-	Blobs           []Blob    `db:"-"`
+type UserList struct {
+	Pagination
+	Users []*User `json:"users"`
 }
 
-type UserList struct {
-	TotalCount int     `json:"total_count"`
-	TotalPages int     `json:"total_pages"`
-	Page       int     `json:"page"`
-	Size       int     `json:"size"`
-	HasMore    bool    `json:"has_more"`
-	Users      []*User `json:"users"`
+func (l UserList) PageItems() interface{} {
+	return l.Users
 }
\ No newline at end of file
@@ -0,0 +1,26 @@
+package models
+
+// ViewerState describes a blob from the point of view of the current user.
+// It is embedded in the blob responses so its fields sit next to the counts.
+type ViewerState struct {
+	LikedByMe     bool            `json:"liked_by_me" db:"liked_by_me"`
+	CommentedByMe bool            `json:"commented_by_me" db:"commented_by_me"`
+	IsAuthor      bool            `json:"is_author" db:"is_author"`
+	Bookmarked    bool            `json:"bookmarked" db:"bookmarked"`
+	Permissions   BlobPermissions `json:"permissions" db:"permissions"`
+}
+
+// BlobPermissions tells clients which actions the API will accept. Users
+// blocked by the author of a blob can no longer interact with it.
+type BlobPermissions struct {
+	CanEdit    bool `json:"can_edit" db:"can_edit"`
+	CanDelete  bool `json:"can_delete" db:"can_delete"`
+	CanComment bool `json:"can_comment" db:"can_comment"`
+	CanReact   bool `json:"can_react" db:"can_react"`
+	CanReblog  bool `json:"can_reblog" db:"can_reblog"`
+}
+
+type BlobViewerState struct {
+	ID string `json:"id" db:"id"`
+	ViewerState
+}
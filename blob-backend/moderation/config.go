@@ -0,0 +1,49 @@
+package moderation
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/joaoleau/blob/internal/config"
+)
+
+// FilterFromConfig builds the content filter from its settings. Empty
+// settings contribute no rule, so the defaults allow everything.
+func FilterFromConfig(cfg config.Moderation) (*Filter, error) {
+	var rules []Rule
+
+	if len(cfg.RejectWords) > 0 {
+		rules = append(rules, WordListRule{Words: cfg.RejectWords, Action: Reject})
+	}
+	if len(cfg.HoldWords) > 0 {
+		rules = append(rules, WordListRule{Words: cfg.HoldWords, Action: Hold})
+	}
+
+	for _, p := range []struct {
+		key     string
+		pattern string
+		action  Action
+	}{
+		{"reject_pattern", cfg.RejectPattern, Reject},
+		{"hold_pattern", cfg.HoldPattern, Hold},
+	} {
+		if p.pattern == "" {
+			continue
+		}
+		pattern, err := regexp.Compile(p.pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid moderation.%s: %w", p.key, err)
+		}
+		rules = append(rules, RegexRule{Pattern: pattern, Action: p.action})
+	}
+
+	if cfg.MaxLinks >= 0 {
+		action, err := ParseAction(cfg.LinkAction)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, LinkLimitRule{Max: cfg.MaxLinks, Action: action})
+	}
+
+	return NewFilter(rules...), nil
+}
@@ -0,0 +1,139 @@
+package moderation
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// ErrRejected is returned by the create and edit paths when a rule rejects
+// the submitted content outright.
+var ErrRejected = errors.New("content rejected by moderation filter")
+
+type Action int
+
+const (
+	Allow Action = iota
+	Hold
+	Reject
+)
+
+func (a Action) String() string {
+	switch a {
+	case Hold:
+		return "hold"
+	case Reject:
+		return "reject"
+	default:
+		return "allow"
+	}
+}
+
+// ParseAction maps "hold" and "reject" to their actions; anything else is
+// an error so a typo in the configuration does not silently allow content.
+func ParseAction(value string) (Action, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "hold":
+		return Hold, nil
+	case "reject":
+		return Reject, nil
+	}
+	return Allow, fmt.Errorf("unknown moderation action %q", value)
+}
+
+type Verdict struct {
+	Action Action
+	Rule   string
+	Reason string
+}
+
+// Rule inspects content and returns Allow when it has nothing to say.
+type Rule interface {
+	Name() string
+	Check(content string) Verdict
+}
+
+// Filter runs every rule and keeps the strictest verdict, so a Reject from
+// any rule wins over a Hold from another.
+type Filter struct {
+	rules []Rule
+}
+
+func NewFilter(rules ...Rule) *Filter {
+	return &Filter{rules: rules}
+}
+
+func (f *Filter) Check(content string) Verdict {
+	verdict := Verdict{Action: Allow}
+	if f == nil {
+		return verdict
+	}
+
+	for _, rule := range f.rules {
+		if v := rule.Check(content); v.Action > verdict.Action {
+			verdict = v
+		}
+	}
+	return verdict
+}
+
+// WordListRule matches whole words, case-insensitively.
+type WordListRule struct {
+	Words  []string
+	Action Action
+}
+
+func (r WordListRule) Name() string { return "word_list" }
+
+func (r WordListRule) Check(content string) Verdict {
+	if len(r.Words) == 0 {
+		return Verdict{Action: Allow}
+	}
+
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(content), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	}) {
+		words[word] = true
+	}
+
+	for _, blocked := range r.Words {
+		if words[strings.ToLower(blocked)] {
+			return Verdict{Action: r.Action, Rule: r.Name(), Reason: "contains a blocked word"}
+		}
+	}
+	return Verdict{Action: Allow}
+}
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`)
+
+// LinkLimitRule fires when the content has more than Max links.
+type LinkLimitRule struct {
+	Max    int
+	Action Action
+}
+
+func (r LinkLimitRule) Name() string { return "link_limit" }
+
+func (r LinkLimitRule) Check(content string) Verdict {
+	if links := len(linkPattern.FindAllString(content, -1)); links > r.Max {
+		return Verdict{Action: r.Action, Rule: r.Name(), Reason: fmt.Sprintf("contains %d links, at most %d allowed", links, r.Max)}
+	}
+	return Verdict{Action: Allow}
+}
+
+type RegexRule struct {
+	Pattern *regexp.Regexp
+	Action  Action
+}
+
+func (r RegexRule) Name() string { return "regex" }
+
+func (r RegexRule) Check(content string) Verdict {
+	if r.Pattern != nil && r.Pattern.MatchString(content) {
+		return Verdict{Action: r.Action, Rule: r.Name(), Reason: "matches a blocked pattern"}
+	}
+	return Verdict{Action: Allow}
+}
@@ -0,0 +1,311 @@
+// Package openapi describes the HTTP API as an OpenAPI 3 document. The
+// operations are listed in routes.go; their request and response schemas are
+// generated from the models, so the document follows the DTOs as they change.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	envelope "github.com/joaoleau/blob/response"
+)
+
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Tags       []Tag                           `json:"tags"`
+	Paths      map[string]map[string]Operation `json:"paths"`
+	Components Components                      `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+type Parameter struct {
+	Name        string `json:"name"`
+	In          string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+	Schema      Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]Schema         `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// endpoint is one row of the route table in routes.go. Path parameters are
+// taken from the {name} segments of path. Routes under /api are documented
+// twice: as deprecated, and under /api/v1 with their bodies in the envelope.
+// v1 replaces responses there when the data differs from the bare body, and
+// download marks routes whose successful body is a file, never enveloped.
+type endpoint struct {
+	method    string
+	path      string
+	id        string
+	summary   string
+	tag       string
+	public    bool
+	download  bool
+	query     []Parameter
+	body      *RequestBody
+	responses []response
+	v1        []response
+
+	deprecated bool
+	enveloped  bool
+}
+
+type response struct {
+	status      int
+	description string
+	content     map[string]MediaType
+}
+
+var pathParameter = regexp.MustCompile(`\{([^}]+)\}`)
+
+// Build returns the document for every route the API registers.
+// sessionCookie is the cookie sessions are read from, if any.
+func Build(sessionCookie string) *Document {
+	g := newGenerator()
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "Blob API",
+			Version:     "1.0.0",
+			Description: "Short-lived posts (blobs) with comments, reactions, bookmarks and moderation.",
+		},
+		Tags:  tags,
+		Paths: map[string]map[string]Operation{},
+		Components: Components{
+			SecuritySchemes: map[string]SecurityScheme{
+				"bearer": {Type: "http", Scheme: "bearer"},
+			},
+		},
+	}
+	security := []map[string][]string{{"bearer": {}}}
+	if sessionCookie != "" {
+		doc.Components.SecuritySchemes["session"] = SecurityScheme{Type: "apiKey", In: "cookie", Name: sessionCookie}
+		security = append(security, map[string][]string{"session": {}})
+	}
+	g.of(Error{})
+	g.of(envelope.Meta{})
+	g.components["ErrorEnvelope"] = Schema{
+		"type": "object",
+		"properties": Schema{
+			"data":  null(),
+			"meta":  ref("Meta"),
+			"error": g.of(envelope.ErrorBody{}),
+		},
+		"required": []string{"data", "meta", "error"},
+	}
+
+	for _, e := range endpoints(g) {
+		if !strings.HasPrefix(e.path, envelope.LegacyPrefix+"/") {
+			doc.add(g, e, security)
+			continue
+		}
+
+		versioned := e
+		versioned.path = envelope.VersionPrefix + strings.TrimPrefix(e.path, envelope.LegacyPrefix)
+		versioned.id = e.id + "V1"
+		versioned.enveloped = true
+		if e.v1 != nil {
+			versioned.responses = e.v1
+		}
+		doc.add(g, versioned, security)
+
+		e.deprecated = true
+		doc.add(g, e, security)
+	}
+
+	doc.Components.Schemas = g.components
+	return doc
+}
+
+// Operations lists the method and path of every documented operation, in
+// the gin route syntax, sorted.
+func (d *Document) Operations() []string {
+	var operations []string
+	for path, methods := range d.Paths {
+		for method := range methods {
+			operations = append(operations, strings.ToUpper(method)+" "+pathParameter.ReplaceAllString(path, ":$1"))
+		}
+	}
+	sort.Strings(operations)
+	return operations
+}
+
+// add documents e. Operations that are not public get security and the
+// 401 and 500 every authenticated route can answer.
+func (d *Document) add(g *generator, e endpoint, security []map[string][]string) {
+	operation := Operation{
+		OperationID: e.id,
+		Summary:     e.summary,
+		Tags:        []string{e.tag},
+		Parameters:  append(pathParameters(e.path), e.query...),
+		RequestBody: e.body,
+		Responses:   map[string]Response{},
+		Deprecated:  e.deprecated,
+	}
+
+	responses := e.responses
+	if !e.public {
+		operation.Security = security
+		responses = append(responses[:len(responses):len(responses)],
+			failure(http.StatusUnauthorized, "Missing, invalid or expired session."),
+			failure(http.StatusInternalServerError, ""),
+		)
+	}
+	for _, r := range responses {
+		if e.enveloped && !(e.download && r.status < http.StatusMultipleChoices) {
+			r = g.enveloped(r)
+		}
+		operation.Responses[strconv.Itoa(r.status)] = r.response()
+	}
+
+	if d.Paths[e.path] == nil {
+		d.Paths[e.path] = map[string]Operation{}
+	}
+	d.Paths[e.path][strings.ToLower(e.method)] = operation
+}
+
+// enveloped returns r with its JSON body moved into the /api/v1 envelope: a
+// paginated list becomes its items, with the page in meta, and an Error the
+// error member.
+func (g *generator) enveloped(r response) response {
+	media, ok := r.content["application/json"]
+	if !ok {
+		return r
+	}
+	if media.Schema["$ref"] == componentPrefix+"Error" {
+		r.content = map[string]MediaType{"application/json": {Schema: ref("ErrorEnvelope")}}
+		return r
+	}
+
+	data := media.Schema
+	if name, isRef := data["$ref"].(string); isRef {
+		if items, paginated := g.pageItems[strings.TrimPrefix(name, componentPrefix)]; paginated {
+			data = items
+		}
+	}
+	r.content = map[string]MediaType{"application/json": {Schema: Schema{
+		"type": "object",
+		"properties": Schema{
+			"data":  data,
+			"meta":  ref("Meta"),
+			"error": null(),
+		},
+		"required": []string{"data", "meta", "error"},
+	}}}
+	return r
+}
+
+func (r response) response() Response {
+	description := r.description
+	if description == "" {
+		description = http.StatusText(r.status)
+	}
+	return Response{Description: description, Content: r.content}
+}
+
+func pathParameters(path string) []Parameter {
+	var parameters []Parameter
+	for _, match := range pathParameter.FindAllStringSubmatch(path, -1) {
+		name := match[1]
+		schema := Schema{"type": "string"}
+		if known, ok := pathParameterSchemas[name]; ok {
+			schema = known
+		}
+		parameters = append(parameters, Parameter{Name: name, In: "path", Required: true, Schema: schema})
+	}
+	return parameters
+}
+
+// Handler serves the document as JSON. It is built on the first request.
+func Handler(sessionCookie string) http.Handler {
+	var (
+		once     sync.Once
+		document []byte
+		err      error
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			document, err = json.Marshal(Build(sessionCookie))
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(document)
+	})
+}
+
+// uiPage renders /openapi.json with Redoc.
+const uiPage = `<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>Blob API</title>
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+</head>
+<body>
+  <redoc spec-url="/openapi.json"></redoc>
+  <script src="https://cdn.redoc.ly/redoc/v2.1.5/bundles/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+// UIHandler serves a Redoc page for the document.
+func UIHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(uiPage))
+	})
+}
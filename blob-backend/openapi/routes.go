@@ -0,0 +1,362 @@
+package openapi
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/validation"
+)
+
+// Error is the body of every error response on the unversioned routes.
+// Fields lists the invalid fields of a 400, Allowed the accepted values when
+// one was not and MaxBytes the limit a 413 exceeded.
+type Error struct {
+	Error    string            `json:"error"`
+	Fields   validation.Errors `json:"fields,omitempty"`
+	Allowed  []string          `json:"allowed,omitempty"`
+	MaxBytes int64             `json:"max_bytes,omitempty"`
+}
+
+var tags = []Tag{
+	{Name: "Blobs", Description: "Posting, editing and listing blobs."},
+	{Name: "Comments"},
+	{Name: "Reactions", Description: "Reactions on blobs and comments; likes are the \"like\" reaction."},
+	{Name: "Bookmarks"},
+	{Name: "Media", Description: "Image uploads attached to blobs through media_ids."},
+	{Name: "Users", Description: "Profiles, relations and account management."},
+	{Name: "Moderation", Description: "Reports from users and the content filter. Listing and acting on them requires the moderator or admin role."},
+	{Name: "Operations", Description: "Health checks, metrics and this document."},
+}
+
+var pathParameterSchemas = map[string]Schema{
+	"blobId":    {"type": "string", "format": "uuid"},
+	"commentId": {"type": "string", "format": "uuid"},
+	"reportId":  {"type": "string", "format": "uuid"},
+	"username":  {"type": "string", "pattern": validation.UsernamePattern},
+	"type":      enum(models.ReactionTypes...),
+}
+
+var pagination = []Parameter{
+	{Name: "page", In: "query", Description: "Page number, starting at 1.", Schema: Schema{"type": "integer", "minimum": 1, "default": 1}},
+	{Name: "size", In: "query", Description: "Items per page.", Schema: Schema{"type": "integer", "minimum": 1, "maximum": 100, "default": 20}},
+}
+
+func endpoints(g *generator) []endpoint {
+	blob := g.of(models.BlobWithInterests{})
+	comment := g.of(models.Comment{})
+	report := g.of(models.Report{})
+	likeState := g.of(models.LikeState{})
+	blobList := g.of(models.BlobList{})
+	message := object(Schema{"message": Schema{"type": "string"}})
+
+	return []endpoint{
+		{method: "GET", path: "/healthz", id: "live", summary: "Liveness probe", tag: "Operations", public: true,
+			responses: []response{ok(http.StatusOK, object(Schema{"status": enum("ok")}))}},
+		{method: "GET", path: "/readyz", id: "ready", summary: "Readiness probe: database reachable and migrations applied", tag: "Operations", public: true,
+			responses: []response{
+				ok(http.StatusOK, object(Schema{"status": enum("ok"), "schema_version": Schema{"type": "integer"}})),
+				ok(http.StatusServiceUnavailable, object(Schema{
+					"status":           enum("unavailable"),
+					"error":            Schema{"type": "string"},
+					"schema_version":   Schema{"type": "integer"},
+					"expected_version": Schema{"type": "integer"},
+				})),
+			}},
+		{method: "GET", path: "/metrics", id: "metrics", summary: "Prometheus metrics", tag: "Operations", public: true,
+			responses: []response{content(http.StatusOK, "text/plain", Schema{"type": "string"})}},
+		{method: "GET", path: "/openapi.json", id: "openapi", summary: "This document", tag: "Operations", public: true,
+			responses: []response{ok(http.StatusOK, Schema{"type": "object"})}},
+		{method: "GET", path: "/docs", id: "docs", summary: "API reference rendered from this document", tag: "Operations", public: true,
+			responses: []response{content(http.StatusOK, "text/html", Schema{"type": "string"})}},
+
+		{method: "GET", path: "/api/secure", id: "secure", summary: "Check that the session is valid", tag: "Users",
+			responses: []response{ok(http.StatusOK, object(Schema{"message": Schema{"type": "string"}, "email": Schema{"type": "string", "format": "email"}}))}},
+
+		{method: "POST", path: "/api/blob", id: "createBlob", summary: "Post a blob", tag: "Blobs",
+			body: jsonBody(g.of(models.CreateBlobRequest{})),
+			responses: []response{
+				ok(http.StatusCreated, blob),
+				described(ok(http.StatusAccepted, blob), "Held for review by the content filter."),
+				failure(http.StatusBadRequest, "Invalid input, or attachments not found or already in use."),
+				failure(http.StatusUnprocessableEntity, "Rejected by the content filter."),
+			}},
+		{method: "GET", path: "/api/blob", id: "listBlobs", summary: "List every blob", tag: "Blobs",
+			responses: []response{ok(http.StatusOK, arrayOf(g.of(models.BlobListWithDetails{})))}},
+		{method: "GET", path: "/api/blob/feed", id: "listFeed", summary: "Blobs from followed users", tag: "Blobs", query: pagination,
+			responses: []response{ok(http.StatusOK, blobList)}},
+		{method: "GET", path: "/api/blob/trending", id: "listTrending", summary: "Blobs ranked by recent activity", tag: "Blobs", query: pagination,
+			responses: []response{ok(http.StatusOK, blobList)}},
+		{method: "POST", path: "/api/blob/batch", id: "batchViewerState", summary: "Viewer state of up to 100 blobs", tag: "Blobs",
+			body: jsonBody(g.of(models.BlobBatchRequest{})),
+			responses: []response{
+				ok(http.StatusOK, object(Schema{"blobs": arrayOf(g.of(models.BlobViewerState{}))})),
+				failure(http.StatusBadRequest, ""),
+			}},
+		{method: "GET", path: "/api/blob/{blobId}", id: "getBlob", summary: "A blob with the first page of its comments and likes", tag: "Blobs",
+			responses: []response{
+				ok(http.StatusOK, g.of(models.BlobWithDetails{})),
+				failure(http.StatusBadRequest, ""),
+				failure(http.StatusNotFound, ""),
+			}},
+		{method: "PUT", path: "/api/blob/{blobId}", id: "updateBlob", summary: "Edit a blob", tag: "Blobs",
+			body: jsonBody(g.of(models.UpdateBlobRequest{})),
+			responses: []response{
+				ok(http.StatusOK, blob),
+				described(ok(http.StatusAccepted, blob), "Held for review by the content filter."),
+				failure(http.StatusBadRequest, "Invalid input, or attachments not found or already in use."),
+				failure(http.StatusNotFound, ""),
+				failure(http.StatusUnprocessableEntity, "Rejected by the content filter."),
+			}},
+		{method: "DELETE", path: "/api/blob/{blobId}", id: "deleteBlob", summary: "Delete a blob", tag: "Blobs",
+			responses: []response{empty(http.StatusNoContent), failure(http.StatusBadRequest, ""), failure(http.StatusNotFound, "Missing or written by someone else.")}},
+		{method: "POST", path: "/api/blob/{blobId}/reblog", id: "reblog", summary: "Reblog a blob, or quote it with content", tag: "Blobs",
+			body: optional(jsonBody(g.of(models.ReblogRequest{}))),
+			responses: []response{
+				ok(http.StatusCreated, blob),
+				described(ok(http.StatusAccepted, blob), "Quote held for review by the content filter."),
+				failure(http.StatusBadRequest, ""),
+				failure(http.StatusForbidden, "Blocked by the author."),
+				failure(http.StatusNotFound, ""),
+				failure(http.StatusConflict, "Already reblogged."),
+				failure(http.StatusUnprocessableEntity, "Rejected by the content filter."),
+			}},
+		{method: "GET", path: "/api/interest", id: "listInterests", summary: "List interests", tag: "Blobs",
+			responses: []response{ok(http.StatusOK, arrayOf(g.of(models.Interest{})))}},
+
+		{method: "POST", path: "/api/media", id: "uploadMedia", summary: "Upload an image", tag: "Media",
+			body: &RequestBody{Required: true, Content: map[string]MediaType{
+				"multipart/form-data": {Schema: Schema{
+					"type":       "object",
+					"properties": Schema{"file": Schema{"type": "string", "format": "binary"}},
+					"required":   []string{"file"},
+				}},
+			}},
+			responses: []response{
+				ok(http.StatusCreated, g.of(models.Media{})),
+				failure(http.StatusBadRequest, "No file in the \"file\" field."),
+				failure(http.StatusRequestEntityTooLarge, "File or image dimensions too large."),
+				failure(http.StatusUnsupportedMediaType, ""),
+				failure(http.StatusUnprocessableEntity, "Not a valid image."),
+			}},
+
+		{method: "POST", path: "/api/blob/{blobId}/comment", id: "createComment", summary: "Comment on a blob", tag: "Comments",
+			body: jsonBody(g.of(models.CreateCommentRequest{})),
+			responses: []response{
+				ok(http.StatusCreated, comment),
+				described(ok(http.StatusAccepted, comment), "Held for review by the content filter."),
+				failure(http.StatusBadRequest, ""),
+				failure(http.StatusForbidden, "Blocked by the author."),
+				failure(http.StatusNotFound, ""),
+				failure(http.StatusUnprocessableEntity, "Rejected by the content filter."),
+			}},
+		{method: "GET", path: "/api/blob/{blobId}/comment", id: "listComments", summary: "Comments on a blob", tag: "Comments", query: pagination,
+			responses: []response{
+				ok(http.StatusOK, object(Schema{
+					"user_logon": object(Schema{
+						"id":           Schema{"type": "string"},
+						"username":     Schema{"type": "string"},
+						"email":        Schema{"type": "string", "format": "email"},
+						"avatar_icon":  Schema{"type": "string"},
+						"avatar_color": Schema{"type": "string"},
+					}),
+					"content":     arrayOf(g.of(models.CommentWithUser{})),
+					"total_count": Schema{"type": "integer"},
+					"total_pages": Schema{"type": "integer"},
+					"page":        Schema{"type": "integer"},
+					"size":        Schema{"type": "integer"},
+					"has_more":    Schema{"type": "boolean"},
+				})),
+				failure(http.StatusBadRequest, ""),
+			},
+			v1: []response{ok(http.StatusOK, g.of(models.CommentList{})), failure(http.StatusBadRequest, "")}},
+		{method: "PUT", path: "/api/blob/{blobId}/comment/{commentId}", id: "updateComment", summary: "Edit a comment", tag: "Comments",
+			body: jsonBody(g.of(models.UpdateCommentRequest{})),
+			responses: []response{
+				ok(http.StatusOK, comment),
+				described(ok(http.StatusAccepted, comment), "Held for review by the content filter."),
+				failure(http.StatusBadRequest, ""),
+				failure(http.StatusNotFound, ""),
+				failure(http.StatusUnprocessableEntity, "Rejected by the content filter."),
+			}},
+		{method: "DELETE", path: "/api/blob/{blobId}/comment/{commentId}", id: "deleteComment", summary: "Delete a comment", tag: "Comments",
+			responses: []response{empty(http.StatusNoContent), failure(http.StatusBadRequest, "")}},
+
+		react("/api/blob/{blobId}/reaction", "addBlobReaction", "React to a blob", g),
+		{method: "GET", path: "/api/blob/{blobId}/reaction", id: "listBlobReactions", summary: "Reactions on a blob, grouped by type", tag: "Reactions",
+			responses: []response{ok(http.StatusOK, arrayOf(g.of(models.ReactionGroup{}))), failure(http.StatusBadRequest, "")}},
+		unreact("/api/blob/{blobId}/reaction/{type}", "removeBlobReaction", "Remove a reaction from a blob"),
+		react("/api/blob/{blobId}/comment/{commentId}/reaction", "addCommentReaction", "React to a comment", g),
+		{method: "GET", path: "/api/blob/{blobId}/comment/{commentId}/reaction", id: "listCommentReactions", summary: "Reactions on a comment, grouped by type", tag: "Reactions",
+			responses: []response{ok(http.StatusOK, arrayOf(g.of(models.ReactionGroup{}))), failure(http.StatusBadRequest, "")}},
+		unreact("/api/blob/{blobId}/comment/{commentId}/reaction/{type}", "removeCommentReaction", "Remove a reaction from a comment"),
+		{method: "POST", path: "/api/blob/{blobId}/like", id: "likeBlob", summary: "Like a blob", tag: "Reactions",
+			responses: []response{
+				ok(http.StatusCreated, likeState),
+				described(ok(http.StatusOK, likeState), "Already liked."),
+				failure(http.StatusBadRequest, ""),
+				failure(http.StatusForbidden, "Blocked by the author."),
+				failure(http.StatusNotFound, ""),
+			}},
+		{method: "GET", path: "/api/blob/{blobId}/like", id: "listBlobLikeReactions", summary: "Reactions on a blob, grouped by type (alias of /reaction)", tag: "Reactions",
+			responses: []response{ok(http.StatusOK, arrayOf(g.of(models.ReactionGroup{}))), failure(http.StatusBadRequest, "")}},
+		{method: "GET", path: "/api/blob/{blobId}/likes", id: "listLikes", summary: "Users who liked a blob", tag: "Reactions", query: pagination,
+			responses: []response{ok(http.StatusOK, g.of(models.LikeList{})), failure(http.StatusBadRequest, "")}},
+		{method: "DELETE", path: "/api/blob/{blobId}/like", id: "unlikeBlob", summary: "Remove a like", tag: "Reactions",
+			responses: []response{ok(http.StatusOK, likeState), failure(http.StatusBadRequest, ""), failure(http.StatusNotFound, "")}},
+
+		{method: "POST", path: "/api/blob/{blobId}/bookmark", id: "addBookmark", summary: "Bookmark a blob", tag: "Bookmarks",
+			responses: []response{
+				ok(http.StatusCreated, object(Schema{"blob_id": Schema{"type": "string", "format": "uuid"}, "bookmarked": Schema{"type": "boolean"}})),
+				described(ok(http.StatusOK, object(Schema{"blob_id": Schema{"type": "string", "format": "uuid"}, "bookmarked": Schema{"type": "boolean"}})), "Already bookmarked."),
+				failure(http.StatusBadRequest, ""),
+				failure(http.StatusForbidden, "Blocked by the author."),
+				failure(http.StatusNotFound, ""),
+			}},
+		{method: "DELETE", path: "/api/blob/{blobId}/bookmark", id: "removeBookmark", summary: "Remove a bookmark", tag: "Bookmarks",
+			responses: []response{empty(http.StatusNoContent), failure(http.StatusBadRequest, ""), failure(http.StatusNotFound, "")}},
+		{method: "GET", path: "/api/user/bookmarks", id: "listBookmarks", summary: "The caller's bookmarks", tag: "Bookmarks", query: pagination,
+			responses: []response{ok(http.StatusOK, g.of(models.BookmarkList{}))}},
+
+		reportContent("/api/blob/{blobId}/report", "reportBlob", "Report a blob", g),
+		reportContent("/api/blob/{blobId}/comment/{commentId}/report", "reportComment", "Report a comment", g),
+		{method: "GET", path: "/api/moderation/reports", id: "listReports", summary: "Reports, newest first", tag: "Moderation",
+			query:     append([]Parameter{{Name: "status", In: "query", Schema: enum(models.ReportStatusOpen, models.ReportStatusAssigned, models.ReportStatusResolved, models.ReportStatusDismissed)}}, pagination...),
+			responses: []response{ok(http.StatusOK, g.of(models.ReportList{})), failure(http.StatusForbidden, "Moderator access required.")}},
+		{method: "POST", path: "/api/moderation/reports/{reportId}/assign", id: "assignReport", summary: "Assign a report, to the caller when assignee_id is empty", tag: "Moderation",
+			body: optional(jsonBody(g.of(models.AssignReportRequest{}))),
+			responses: []response{
+				ok(http.StatusOK, report),
+				failure(http.StatusBadRequest, "Invalid input, or the assignee is not a moderator."),
+				failure(http.StatusForbidden, "Moderator access required."),
+				failure(http.StatusNotFound, "No open report with this ID."),
+			}},
+		{method: "POST", path: "/api/moderation/reports/{reportId}/resolve", id: "resolveReport", summary: "Act on a report and close it", tag: "Moderation",
+			body: jsonBody(g.of(models.ResolveReportRequest{})),
+			responses: []response{
+				ok(http.StatusOK, report),
+				failure(http.StatusBadRequest, "Invalid input, or the assignee is not a moderator."),
+				failure(http.StatusForbidden, "Moderator access required."),
+				failure(http.StatusNotFound, "No open report with this ID."),
+			}},
+		{method: "GET", path: "/api/moderation/audit-log", id: "listAuditLog", summary: "Moderation actions, newest first", tag: "Moderation", query: pagination,
+			responses: []response{ok(http.StatusOK, arrayOf(g.of(models.AuditLogEntry{}))), failure(http.StatusForbidden, "Moderator access required.")}},
+
+		{method: "GET", path: "/api/user", id: "getCurrentUser", summary: "The caller's account", tag: "Users",
+			responses: []response{ok(http.StatusOK, g.of(models.User{})), failure(http.StatusNotFound, "")}},
+		{method: "PUT", path: "/api/user", id: "updateCurrentUser", summary: "Update the caller's account", tag: "Users",
+			body:      jsonBody(g.of(models.UpdateUserRequest{})),
+			responses: []response{ok(http.StatusOK, message), failure(http.StatusBadRequest, ""), failure(http.StatusConflict, "Username already taken.")}},
+		{method: "DELETE", path: "/api/user", id: "deleteCurrentUser", summary: "Schedule the caller's account for deletion", tag: "Users",
+			responses: []response{ok(http.StatusAccepted, object(Schema{"message": Schema{"type": "string"}, "deletion_scheduled_at": Schema{"type": "string", "format": "date-time"}}))}},
+		{method: "POST", path: "/api/user/deletion/cancel", id: "cancelDeletion", summary: "Cancel a scheduled account deletion", tag: "Users",
+			responses: []response{ok(http.StatusOK, message), failure(http.StatusNotFound, "No pending account deletion.")}},
+		{method: "GET", path: "/api/user/export", id: "exportUserData", summary: "Everything stored about the caller", tag: "Users", download: true,
+			query: []Parameter{{Name: "format", In: "query", Description: "zip returns one JSON file per section.", Schema: enum("json", "zip")}},
+			responses: []response{{status: http.StatusOK, content: map[string]MediaType{
+				"application/json": {Schema: g.of(models.UserExport{})},
+				"application/zip":  {Schema: Schema{"type": "string", "format": "binary"}},
+			}}}},
+		{method: "GET", path: "/api/user/stats", id: "getUserStats", summary: "The caller's private counters", tag: "Users",
+			responses: []response{ok(http.StatusOK, g.of(models.UserStats{}))}},
+		{method: "GET", path: "/api/user/mentions", id: "listMentions", summary: "Blobs and comments mentioning the caller", tag: "Users", query: pagination,
+			responses: []response{ok(http.StatusOK, g.of(models.MentionList{}))}},
+		{method: "GET", path: "/api/user/warnings", id: "listWarnings", summary: "Warnings moderators gave the caller, newest first", tag: "Users", query: pagination,
+			responses: []response{ok(http.StatusOK, arrayOf(g.of(models.Warning{})))}},
+		{method: "GET", path: "/api/user/blocks", id: "listBlockedUsers", summary: "Users the caller blocked", tag: "Users",
+			responses: []response{ok(http.StatusOK, arrayOf(g.of(models.RelatedUser{})))}},
+		{method: "GET", path: "/api/user/mutes", id: "listMutedUsers", summary: "Users the caller muted", tag: "Users",
+			responses: []response{ok(http.StatusOK, arrayOf(g.of(models.RelatedUser{})))}},
+		profile("", "getProfile", "A user's public profile", nil, g.of(models.PublicProfile{})),
+		profile("/blobs", "listUserBlobs", "A user's blobs", pagination, blobList),
+		profile("/comments", "listUserComments", "A user's comments", pagination, g.of(models.CommentList{})),
+		profile("/likes", "listUserLikes", "Blobs a user liked", pagination, blobList),
+		relation("POST", "follow", "followUser", "Follow a user"),
+		relation("DELETE", "follow", "unfollowUser", "Unfollow a user"),
+		relation("POST", "block", "blockUser", "Block a user"),
+		relation("DELETE", "block", "unblockUser", "Unblock a user"),
+		relation("POST", "mute", "muteUser", "Mute a user"),
+		relation("DELETE", "mute", "unmuteUser", "Unmute a user"),
+	}
+}
+
+func react(path, id, summary string, g *generator) endpoint {
+	return endpoint{method: "POST", path: path, id: id, summary: summary, tag: "Reactions",
+		body: jsonBody(g.of(models.ReactionRequest{})),
+		responses: []response{
+			ok(http.StatusCreated, g.of(models.Reaction{})),
+			failure(http.StatusBadRequest, "Invalid ID or reaction type."),
+			failure(http.StatusForbidden, "Blocked by the author."),
+			failure(http.StatusNotFound, ""),
+			failure(http.StatusConflict, "Already reacted with this type."),
+		}}
+}
+
+func unreact(path, id, summary string) endpoint {
+	return endpoint{method: "DELETE", path: path, id: id, summary: summary, tag: "Reactions",
+		responses: []response{empty(http.StatusNoContent), failure(http.StatusBadRequest, "Invalid ID or reaction type.")}}
+}
+
+func reportContent(path, id, summary string, g *generator) endpoint {
+	request := g.of(models.ReportRequest{})
+	return endpoint{method: "POST", path: path, id: id, summary: summary, tag: "Moderation",
+		body: jsonBody(request),
+		responses: []response{
+			ok(http.StatusCreated, g.of(models.Report{})),
+			failure(http.StatusBadRequest, "Invalid ID or reason; reasons are "+strings.Join(models.ReportReasons, ", ")+"."),
+			failure(http.StatusNotFound, ""),
+			failure(http.StatusConflict, "Already reported."),
+		}}
+}
+
+// profile describes a route under /api/user/{username}, which redirects when
+// the username belonged to a renamed user.
+func profile(suffix, id, summary string, query []Parameter, body Schema) endpoint {
+	return endpoint{method: "GET", path: "/api/user/{username}" + suffix, id: id, summary: summary, tag: "Users", query: query,
+		responses: []response{
+			ok(http.StatusOK, body),
+			{status: http.StatusMovedPermanently, description: "The user was renamed; Location has the new path."},
+			failure(http.StatusForbidden, "Blocked by the user, private profile or hidden likes."),
+			failure(http.StatusNotFound, ""),
+		}}
+}
+
+func relation(method, relation, id, summary string) endpoint {
+	return endpoint{method: method, path: "/api/user/{username}/" + relation, id: id, summary: summary, tag: "Users",
+		responses: []response{
+			empty(http.StatusNoContent),
+			failure(http.StatusBadRequest, "The target is the caller."),
+			failure(http.StatusForbidden, "Blocked by the user."),
+			failure(http.StatusNotFound, ""),
+		}}
+}
+
+func jsonBody(schema Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: schema}}}
+}
+
+func optional(body *RequestBody) *RequestBody {
+	body.Required = false
+	return body
+}
+
+func ok(status int, schema Schema) response {
+	return content(status, "application/json", schema)
+}
+
+func content(status int, mediaType string, schema Schema) response {
+	return response{status: status, content: map[string]MediaType{mediaType: {Schema: schema}}}
+}
+
+func empty(status int) response {
+	return response{status: status}
+}
+
+func failure(status int, description string) response {
+	return described(ok(status, ref("Error")), description)
+}
+
+func described(r response, description string) response {
+	r.description = description
+	return r
+}
@@ -0,0 +1,194 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/validation"
+)
+
+// Schema is a JSON Schema object as embedded in OpenAPI 3.0.
+type Schema map[string]interface{}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	uuidType      = reflect.TypeOf(uuid.UUID{})
+	paginatedType = reflect.TypeOf((*models.Paginated)(nil)).Elem()
+)
+
+// generator builds schemas from Go types. Named structs become components
+// so every model is described once and referenced everywhere else.
+// pageItems holds the schema of the items of each paginated list component,
+// which the versioned API answers without the list around them.
+type generator struct {
+	components map[string]Schema
+	pageItems  map[string]Schema
+}
+
+func newGenerator() *generator {
+	return &generator{components: map[string]Schema{}, pageItems: map[string]Schema{}}
+}
+
+// of returns the schema of the type of value.
+func (g *generator) of(value interface{}) Schema {
+	return g.schema(reflect.TypeOf(value))
+}
+
+func (g *generator) schema(t reflect.Type) Schema {
+	switch t {
+	case timeType:
+		return Schema{"type": "string", "format": "date-time"}
+	case uuidType:
+		return Schema{"type": "string", "format": "uuid"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int64:
+		return Schema{"type": "integer", "format": "int64"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, seen := g.components[t.Name()]; !seen {
+			// Reserve the name first so self-referencing types terminate.
+			g.components[t.Name()] = nil
+			g.components[t.Name()] = g.object(t)
+			if t.Implements(paginatedType) {
+				items := reflect.Zero(t).Interface().(models.Paginated).PageItems()
+				g.pageItems[t.Name()] = g.schema(reflect.TypeOf(items))
+			}
+		}
+		return ref(t.Name())
+	}
+	return Schema{}
+}
+
+// object describes a struct the way encoding/json writes it: fields named by
+// their json tag, embedded structs flattened and "-" fields left out. The
+// validate tags of request DTOs become required fields and constraints.
+func (g *generator) object(t reflect.Type) Schema {
+	properties := Schema{}
+	var required []string
+	g.fields(t, properties, &required)
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (g *generator) fields(t reflect.Type, properties Schema, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			g.fields(field.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := g.schema(field.Type)
+		if _, isRef := property["$ref"]; !isRef {
+			if value, ok := field.Tag.Lookup("default"); ok {
+				property["default"] = value
+			}
+			if constrain(property, field.Tag.Get("validate")) {
+				*required = append(*required, name)
+			}
+		}
+		properties[name] = property
+	}
+}
+
+// constrain adds the rules of a validate tag to property and reports whether
+// the field is required.
+func constrain(property Schema, rules string) bool {
+	required := false
+	optional := strings.Contains(rules, "omitempty")
+	for _, rule := range strings.Split(rules, ",") {
+		rule, param, _ := strings.Cut(rule, "=")
+		limit, _ := strconv.Atoi(param)
+		switch rule {
+		case "required":
+			required = true
+		case "max", "content", "interests":
+			if property["type"] == "array" {
+				property["maxItems"] = limit
+			} else {
+				property["maxLength"] = limit
+			}
+			// content also rejects blank text.
+			if rule == "content" {
+				property["minLength"] = 1
+				required = required || !optional
+			}
+		case "email":
+			property["format"] = "email"
+		case "url":
+			property["format"] = "uri"
+		case "uuid":
+			property["format"] = "uuid"
+		case "username":
+			property["pattern"] = validation.UsernamePattern
+		case "avatar_icon":
+			property["enum"] = validation.AvatarIcons
+		case "avatar_color":
+			property["enum"] = validation.AvatarColors
+		case "reaction":
+			property["enum"] = models.ReactionTypes
+		}
+	}
+	return required
+}
+
+// componentPrefix starts the $ref of every component schema.
+const componentPrefix = "#/components/schemas/"
+
+func ref(name string) Schema {
+	return Schema{"$ref": componentPrefix + name}
+}
+
+func object(properties Schema) Schema {
+	return Schema{"type": "object", "properties": properties}
+}
+
+func arrayOf(items Schema) Schema {
+	return Schema{"type": "array", "items": items}
+}
+
+// null is the schema of a member that is always null.
+func null() Schema {
+	return Schema{"nullable": true, "enum": []interface{}{nil}}
+}
+
+func enum(values ...string) Schema {
+	return Schema{"type": "string", "enum": values}
+}
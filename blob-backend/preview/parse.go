@@ -0,0 +1,123 @@
+package preview
+
+import (
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	maxTitleLength       = 300
+	maxDescriptionLength = 1000
+)
+
+// Metadata is what a page says about itself. OpenGraph tags win over Twitter
+// cards, which win over the plain <title> and description.
+type Metadata struct {
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+// Parse reads the document head from r. base resolves relative image URLs.
+func Parse(r io.Reader, base *url.URL) *Metadata {
+	tags := make(map[string]string)
+	var title strings.Builder
+	inTitle := false
+
+	tokenizer := html.NewTokenizer(r)
+	for {
+		tokenType := tokenizer.Next()
+		switch tokenType {
+		case html.ErrorToken:
+			return buildMetadata(tags, title.String(), base)
+		case html.StartTagToken, html.SelfClosingTagToken:
+			name, hasAttr := tokenizer.TagName()
+			switch atom.Lookup(name) {
+			case atom.Body:
+				return buildMetadata(tags, title.String(), base)
+			case atom.Title:
+				inTitle = tokenType == html.StartTagToken
+			case atom.Meta:
+				if hasAttr {
+					readMeta(tokenizer, tags)
+				}
+			}
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch atom.Lookup(name) {
+			case atom.Head:
+				return buildMetadata(tags, title.String(), base)
+			case atom.Title:
+				inTitle = false
+			}
+		case html.TextToken:
+			if inTitle {
+				title.Write(tokenizer.Text())
+			}
+		}
+	}
+}
+
+func readMeta(tokenizer *html.Tokenizer, tags map[string]string) {
+	var key, content string
+	for {
+		name, value, more := tokenizer.TagAttr()
+		switch string(name) {
+		case "property", "name":
+			if key == "" {
+				key = strings.ToLower(strings.TrimSpace(string(value)))
+			}
+		case "content":
+			content = strings.TrimSpace(string(value))
+		}
+		if !more {
+			break
+		}
+	}
+	if key != "" && content != "" {
+		if _, exists := tags[key]; !exists {
+			tags[key] = content
+		}
+	}
+}
+
+func buildMetadata(tags map[string]string, title string, base *url.URL) *Metadata {
+	first := func(keys ...string) string {
+		for _, key := range keys {
+			if value := tags[key]; value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	metadata := &Metadata{
+		Title:       first("og:title", "twitter:title"),
+		Description: first("og:description", "twitter:description", "description"),
+		ImageURL:    resolveReference(base, first("og:image:secure_url", "og:image", "og:image:url", "twitter:image", "twitter:image:src")),
+		SiteName:    first("og:site_name", "application-name"),
+	}
+	if metadata.Title == "" {
+		metadata.Title = strings.Join(strings.Fields(title), " ")
+	}
+	if metadata.SiteName == "" && base != nil {
+		metadata.SiteName = strings.TrimPrefix(base.Hostname(), "www.")
+	}
+
+	metadata.Title = truncate(metadata.Title, maxTitleLength)
+	metadata.Description = truncate(metadata.Description, maxDescriptionLength)
+	return metadata
+}
+
+func truncate(value string, max int) string {
+	if utf8.RuneCountInString(value) <= max {
+		return value
+	}
+	return string([]rune(value)[:max-1]) + "…"
+}
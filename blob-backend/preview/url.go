@@ -0,0 +1,101 @@
+// Package preview unfurls links found in blobs: it extracts and normalizes
+// URLs, fetches pages without reaching private networks and reads their
+// OpenGraph and Twitter card metadata.
+package preview
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// MaxURLsPerContent limits how many links of a single blob are unfurled.
+const MaxURLsPerContent = 3
+
+var ErrInvalidURL = errors.New("invalid url")
+
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+`)
+
+// ExtractURLs returns the distinct normalized URLs in content, in order of
+// appearance and at most MaxURLsPerContent of them.
+func ExtractURLs(content string) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, match := range urlPattern.FindAllString(content, -1) {
+		// Punctuation right after a link usually belongs to the sentence.
+		match = strings.TrimRight(match, ".,;:!?)]}'")
+		normalized, err := Normalize(match)
+		if err != nil || seen[normalized] {
+			continue
+		}
+		seen[normalized] = true
+		urls = append(urls, normalized)
+		if len(urls) == MaxURLsPerContent {
+			break
+		}
+	}
+	return urls
+}
+
+// Normalize canonicalizes an http(s) URL so the same page shares one cache
+// entry: lower-case scheme and host, no default port, no fragment, no
+// tracking parameters and sorted query parameters.
+func Normalize(raw string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", ErrInvalidURL
+	}
+
+	parsed.Scheme = strings.ToLower(parsed.Scheme)
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return "", ErrInvalidURL
+	}
+	if parsed.User != nil || parsed.Hostname() == "" {
+		return "", ErrInvalidURL
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(parsed.Hostname()), ".")
+	port := parsed.Port()
+	if (parsed.Scheme == "http" && port == "80") || (parsed.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+	}
+	parsed.Host = host
+
+	if parsed.Path == "" {
+		parsed.Path = "/"
+	}
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+
+	query := parsed.Query()
+	for key := range query {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "utm_") || lower == "fbclid" || lower == "gclid" {
+			query.Del(key)
+		}
+	}
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, url.QueryEscape(key)+"="+url.QueryEscape(value))
+		}
+	}
+	parsed.RawQuery = strings.Join(parts, "&")
+	parsed.ForceQuery = false
+
+	return parsed.String(), nil
+}
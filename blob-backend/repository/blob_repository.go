@@ -7,10 +7,18 @@ import (
 	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/joaoleau/blob/models"
+	"github.com/lib/pq"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 )
 
+// ErrAlreadyReblogged is returned when a user reblogs the same blob twice
+// without a quote.
+var ErrAlreadyReblogged = errors.New("blob already reblogged")
+
+// ErrBlobNotFound is returned when content is added to a blob that is gone.
+var ErrBlobNotFound = errors.New("blob not found")
+
 type BlobRepo struct {
 	db *sqlx.DB
 }
@@ -19,29 +27,158 @@ func NewBlobRepository(db *sqlx.DB) BlobRepo {
 	return BlobRepo {db: db}
 }
 
+// Create inserts the blob with its interests and those of blob.Hashtags, the
+// mentions of blob.MentionIDs, the attachments of blob.MediaIDs and, for held blobs, the
+// filter report in one transaction.
 func (r *BlobRepo) Create(ctx context.Context, blob *models.BlobWithInterests) (*models.BlobWithInterests, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobRepo.Create")
 	defer span.Finish()
 
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "BlobRepo.Create.BeginTxx")
+	}
+	defer tx.Rollback()
+
 	newBlob := &models.BlobWithInterests{}
-	if err := r.db.QueryRowxContext(ctx, createBlobQuery,
-		blob.ID, blob.UserID, blob.Content,
+	if err := tx.QueryRowxContext(ctx, createBlobQuery,
+		blob.ID, blob.UserID, blob.Content, blob.ReblogOfID,
 	).StructScan(newBlob); err != nil {
+		if isUniqueViolation(err, "unique_user_plain_reblog") {
+			return nil, ErrAlreadyReblogged
+		}
 		return nil, errors.Wrap(err, "BlobRepo.Create.StructScan")
 	}
 
-	for _, interest := range blob.Interests {
-		if err := r.createBlobInterest(ctx, newBlob.ID, interest); err != nil {
-			return nil, errors.Wrap(err, "BlobRepo.Create.createBlobInterest")
+	if newBlob.Interests, err = addBlobInterests(ctx, tx, newBlob.ID, blob.Interests, blob.Hashtags); err != nil {
+		return nil, errors.Wrap(err, "BlobRepo.Create.addBlobInterests")
+	}
+
+	if err := replaceBlobMentions(ctx, tx, newBlob.ID, blob.UserID, blob.MentionIDs); err != nil {
+		return nil, errors.Wrap(err, "BlobRepo.Create.replaceBlobMentions")
+	}
+
+	if len(blob.MediaIDs) > 0 {
+		if err := setBlobMedia(ctx, tx, newBlob.ID, blob.UserID, blob.MediaIDs); err != nil {
+			if errors.Is(err, ErrMediaUnavailable) {
+				return nil, err
+			}
+			return nil, errors.Wrap(err, "BlobRepo.Create.setBlobMedia")
 		}
 	}
 
+	if blob.HoldReport != nil {
+		if err := holdContent(ctx, tx, blob.HoldReport); err != nil {
+			return nil, errors.Wrap(err, "BlobRepo.Create.holdContent")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "BlobRepo.Create.Commit")
+	}
 	return newBlob, nil
 }
 
-func (r *BlobRepo) createBlobInterest(ctx context.Context, blobID uuid.UUID, interestID string) error {
-	_, err := r.db.ExecContext(ctx, insertBlobInterest, blobID, interestID)
-	return err
+// Update changes the content of a blob owned by blob.UserID, replaces its
+// interests, hashtag interests, mentions and attachments and holds it when blob.HoldReport is set, all in
+// one transaction. It returns nil when the
+// blob does not exist or belongs to someone else.
+func (r *BlobRepo) Update(ctx context.Context, blob *models.BlobWithInterests) (*models.BlobWithInterests, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobRepo.Update")
+	defer span.Finish()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "BlobRepo.Update.BeginTxx")
+	}
+	defer tx.Rollback()
+
+	updatedBlob := &models.BlobWithInterests{}
+	if err := tx.QueryRowxContext(ctx, updateBlobQuery,
+		blob.Content, blob.ID, blob.UserID,
+	).StructScan(updatedBlob); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "BlobRepo.Update.StructScan")
+	}
+
+	if _, err := tx.ExecContext(ctx, deleteBlobInterestsQuery, updatedBlob.ID); err != nil {
+		return nil, errors.Wrap(err, "BlobRepo.Update.deleteBlobInterests")
+	}
+	if updatedBlob.Interests, err = addBlobInterests(ctx, tx, updatedBlob.ID, blob.Interests, blob.Hashtags); err != nil {
+		return nil, errors.Wrap(err, "BlobRepo.Update.addBlobInterests")
+	}
+
+	if err := replaceBlobMentions(ctx, tx, updatedBlob.ID, blob.UserID, blob.MentionIDs); err != nil {
+		return nil, errors.Wrap(err, "BlobRepo.Update.replaceBlobMentions")
+	}
+
+	// Nil MediaIDs leave the attachments alone; an empty list removes them.
+	if blob.MediaIDs != nil {
+		if err := setBlobMedia(ctx, tx, updatedBlob.ID, blob.UserID, blob.MediaIDs); err != nil {
+			if errors.Is(err, ErrMediaUnavailable) {
+				return nil, err
+			}
+			return nil, errors.Wrap(err, "BlobRepo.Update.setBlobMedia")
+		}
+	}
+
+	if blob.HoldReport != nil {
+		if err := holdContent(ctx, tx, blob.HoldReport); err != nil {
+			return nil, errors.Wrap(err, "BlobRepo.Update.holdContent")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "BlobRepo.Update.Commit")
+	}
+	return updatedBlob, nil
+}
+
+// addBlobInterests links the blob to interestIDs and to the interest behind
+// every hashtag, creating the ones that do not exist yet, inside the
+// transaction that writes the blob. It returns the linked interest IDs.
+func addBlobInterests(ctx context.Context, tx *sqlx.Tx, blobID uuid.UUID, interestIDs, hashtags []string) ([]string, error) {
+	ids := append([]string{}, interestIDs...)
+	for _, name := range hashtags {
+		interestID, err := findOrCreateInterest(ctx, tx, name)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, interestID)
+	}
+
+	seen := make(map[string]bool)
+	linked := []string{}
+	for _, interestID := range ids {
+		if seen[interestID] {
+			continue
+		}
+		seen[interestID] = true
+		if _, err := tx.ExecContext(ctx, insertBlobInterest, blobID, interestID); err != nil {
+			return nil, errors.Wrap(err, "addBlobInterests.insert")
+		}
+		linked = append(linked, interestID)
+	}
+	return linked, nil
+}
+
+func findOrCreateInterest(ctx context.Context, tx *sqlx.Tx, name string) (string, error) {
+	var interestID string
+	err := tx.GetContext(ctx, &interestID, findInterestByNameQuery, name)
+	if err == nil {
+		return interestID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", errors.Wrap(err, "findOrCreateInterest.GetContext")
+	}
+
+	if err := tx.GetContext(ctx, &interestID, insertInterestQuery, uuid.New(), name); err != nil {
+		return "", errors.Wrap(err, "findOrCreateInterest.insert")
+	}
+
+	return interestID, nil
 }
 
 func (r *BlobRepo) ListAllInterests(ctx context.Context) ([]*models.Interest, error) {
@@ -59,127 +196,64 @@ func (r *BlobRepo) ListAllInterests(ctx context.Context) ([]*models.Interest, er
 }
 
 
-func (r *BlobRepo) GetByID(ctx context.Context, blobID uuid.UUID) (*models.BlobWithDetails, error) {
+// GetByID loads a blob with its interests and the first limit comments and
+// likes visible to viewerID. Each list is its own bounded query, so popular
+// blobs cost the same as quiet ones.
+func (r *BlobRepo) GetByID(ctx context.Context, blobID uuid.UUID, viewerID string, limit int) (*models.BlobWithDetails, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobRepo.GetByID")
 	defer span.Finish()
 
-	type Row struct { 
-		BlobID            string     `db:"blob_id"`
-		UserID            string     `db:"blob_user_id"`
-		Content           string     `db:"blob_content"`
-		CreatedAt         time.Time  `db:"blob_created_at"`
-		UpdatedAt         time.Time  `db:"blob_updated_at"`
-		Username          string     `db:"user_username"`
-		AvatarIcon        string     `db:"user_avatar_icon"`
-		UserCreatedAt     time.Time  `db:"user_created_at"`
-		CommentID         *string    `db:"comment_id"`
-		CommentUserID     *string    `db:"comment_user_id"`
-		CommentContent    *string    `db:"comment_content"`
-		CommentCreatedAt  *time.Time `db:"comment_created_at"`
-		CommentUpdatedAt  *time.Time `db:"comment_updated_at"`
-		LikeID            *string    `db:"like_id"`
-		LikeUserID        *string    `db:"like_user_id"`
-		LikeCreatedAt     *time.Time `db:"like_created_at"`
-		InterestID        *string    `db:"interest_id"`
-		InterestName      *string    `db:"interest_name"`
-		InterestDescription *string  `db:"interest_description"`
-		InterestCreatedAt *time.Time `db:"interest_created_at"`
-		InterestUpdatedAt *time.Time `db:"interest_updated_at"`
+	var row struct {
+		models.BlobWithDetails
+		reblogRow
 	}
-
-	var rows []Row
-	if err := r.db.SelectContext(ctx, &rows, getBlobByIDQuery, blobID); err != nil {
+	if err := r.db.GetContext(ctx, &row, getBlobByIDQuery, blobID, viewerID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
-		return nil, errors.Wrap(err, "BlobRepo.GetByID.SelectContext")
+		return nil, errors.Wrap(err, "BlobRepo.GetByID.GetContext")
 	}
 
-	if len(rows) == 0 {
-		return nil, nil
+	blob := &row.BlobWithDetails
+	blob.ReblogOf = row.reblogOf(blob.IsReblog)
+
+	blob.Interests = []models.Interest{}
+	if err := r.db.SelectContext(ctx, &blob.Interests, listBlobInterestsQuery, blobID); err != nil {
+		return nil, errors.Wrap(err, "BlobRepo.GetByID.listInterests")
 	}
 
-	blob := &models.BlobWithDetails{
-		ID:           rows[0].BlobID,
-		UserID:       rows[0].UserID,
-		Content:      rows[0].Content,
-		CreatedAt:    rows[0].CreatedAt,
-		UpdatedAt:    rows[0].UpdatedAt,
-		Username:     rows[0].Username,
-		AvatarIcon:   rows[0].AvatarIcon,
-		UserCreatedAt: rows[0].UserCreatedAt,
-		Comments:     []models.Comment{},
-		Likes:        []models.Like{},
-		Interests:    []models.Interest{},
+	blob.Comments = []models.CommentWithUser{}
+	if err := r.db.SelectContext(ctx, &blob.Comments, searchCommentsbyBlobIDQuery, blobID, viewerID, limit, 0); err != nil {
+		return nil, errors.Wrap(err, "BlobRepo.GetByID.listComments")
 	}
 
-	commentIDs := make(map[string]bool)
-	likeIDs := make(map[string]bool)
-	interestIDs := make(map[string]bool)
-	
-	for _, row := range rows {
-		if row.CommentID != nil {
-			commentID := *row.CommentID
-	
-			if !commentIDs[commentID] {
-				blob.Comments = append(blob.Comments, models.Comment{
-					ID:        uuid.MustParse(commentID),
-					Content:   *row.CommentContent,
-					CreatedAt: *row.CommentCreatedAt,
-					UpdatedAt: *row.CommentUpdatedAt,
-					UserID:    *row.CommentUserID,
-					BlobID:    uuid.MustParse(row.BlobID),
-				})
-				commentIDs[commentID] = true
-			}
-		}
-	
-		if row.LikeID != nil {
-			likeID := *row.LikeID
-			if !likeIDs[likeID] {
-				blob.Likes = append(blob.Likes, models.Like{
-					ID:        uuid.MustParse(likeID),
-					UserID:    *row.LikeUserID,
-					CreatedAt: *row.LikeCreatedAt,
-					BlobID:    uuid.MustParse(row.BlobID),
-				})
-				likeIDs[likeID] = true
-			}
-		}
-	
-		if row.InterestID != nil {
-			interestID := *row.InterestID
-	
-			if !interestIDs[interestID] {
-				blob.Interests = append(blob.Interests, models.Interest{
-					ID:          uuid.MustParse(interestID),
-					Name:        *row.InterestName,
-					Description: *row.InterestDescription,
-					CreatedAt:   *row.InterestCreatedAt,
-					UpdatedAt:   *row.InterestUpdatedAt,
-				})
-				interestIDs[interestID] = true
-			}
-		}
+	blob.Likes = []models.ReactionWithUser{}
+	if err := r.db.SelectContext(ctx, &blob.Likes, listLikesQuery, blobID, viewerID, limit, 0); err != nil {
+		return nil, errors.Wrap(err, "BlobRepo.GetByID.listLikes")
 	}
 
 	return blob, nil
 }
 
 
-func (r *BlobRepo) Delete(ctx context.Context, blobID uuid.UUID) error {
+// Delete removes a blob written by userID and reports whether there was one.
+func (r *BlobRepo) Delete(ctx context.Context, blobID uuid.UUID, userID string) (bool, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobRepo.Delete")
 	defer span.Finish()
 
-	_, err := r.db.ExecContext(ctx, deleteBlobQuery, blobID)
+	result, err := r.db.ExecContext(ctx, deleteBlobQuery, blobID, userID)
+	if err != nil {
+		return false, errors.Wrap(err, "BlobRepo.Delete.ExecContext")
+	}
+	deleted, err := result.RowsAffected()
 	if err != nil {
-		return errors.Wrap(err, "BlobRepo.Delete.ExecContext")
+		return false, errors.Wrap(err, "BlobRepo.Delete.RowsAffected")
 	}
 
-	return nil
+	return deleted > 0, nil
 }
 
-func (r *BlobRepo) ListBlobs(ctx context.Context) ([]models.BlobListWithDetails, error) {
+func (r *BlobRepo) ListBlobs(ctx context.Context, viewerID string) ([]models.BlobListWithDetails, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobRepo.ListBlobs")
 	defer span.Finish()
 
@@ -195,10 +269,15 @@ func (r *BlobRepo) ListBlobs(ctx context.Context) ([]models.BlobListWithDetails,
 		InterestName *string   `db:"interest_name"`
 		LikesCount   int       `db:"likes_count"`
 		CommentsCount int      `db:"comments_count"`
+		ReactionCounts models.ReactionCounts `db:"reaction_counts"`
+		ReblogsCount int       `db:"reblogs_count"`
+		IsReblog     bool      `db:"is_reblog"`
+		reblogRow
+		models.ViewerState
 	}
 
 	var rows []Row
-	if err := r.db.SelectContext(ctx, &rows, listBlobsQuery); err != nil {
+	if err := r.db.SelectContext(ctx, &rows, listBlobsQuery, viewerID); err != nil {
 		return nil, errors.Wrap(err, "BlobRepo.ListBlobs.SelectContext")
 	}
 
@@ -217,6 +296,11 @@ func (r *BlobRepo) ListBlobs(ctx context.Context) ([]models.BlobListWithDetails,
 				UserCreatedAt: row.UserCreatedAt,
 				LikesCount:  row.LikesCount,
 				CommentsCount: row.CommentsCount,
+				ReactionCounts: row.ReactionCounts,
+				ReblogsCount: row.ReblogsCount,
+				IsReblog:    row.IsReblog,
+				ReblogOf:    row.reblogOf(row.IsReblog),
+				ViewerState: row.ViewerState,
 				Interests:   []string{},
 			}
 		}
@@ -233,3 +317,142 @@ func (r *BlobRepo) ListBlobs(ctx context.Context) ([]models.BlobListWithDetails,
 
 	return blobs, nil
 }
+
+// GetAuthor returns the author of a visible blob without loading its
+// details. An empty string means the blob is gone or hidden.
+func (r *BlobRepo) GetAuthor(ctx context.Context, blobID uuid.UUID) (string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobRepo.GetAuthor")
+	defer span.Finish()
+
+	var authorID string
+	if err := r.db.GetContext(ctx, &authorID, getBlobAuthorQuery, blobID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "BlobRepo.GetAuthor.GetContext")
+	}
+	return authorID, nil
+}
+
+// GetReblogTarget returns the blob a reblog of blobID should point at and its
+// author. Reblogging a plain reblog targets its original. Empty strings mean
+// there is nothing visible to reblog.
+func (r *BlobRepo) GetReblogTarget(ctx context.Context, blobID uuid.UUID) (string, string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobRepo.GetReblogTarget")
+	defer span.Finish()
+
+	var targetID, authorID string
+	if err := r.db.QueryRowxContext(ctx, getReblogTargetQuery, blobID).Scan(&targetID, &authorID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", "", nil
+		}
+		return "", "", errors.Wrap(err, "BlobRepo.GetReblogTarget.Scan")
+	}
+	return targetID, authorID, nil
+}
+
+// GetReblogOriginal returns the original of a reblog. It is marked as not
+// available when the original is gone or hidden.
+func (r *BlobRepo) GetReblogOriginal(ctx context.Context, originalID *string) (*models.ReblogOf, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobRepo.GetReblogOriginal")
+	defer span.Finish()
+
+	if originalID == nil {
+		return &models.ReblogOf{}, nil
+	}
+
+	original := &models.ReblogOf{}
+	if err := r.db.GetContext(ctx, original, getReblogOriginalQuery, *originalID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return &models.ReblogOf{}, nil
+		}
+		return nil, errors.Wrap(err, "BlobRepo.GetReblogOriginal.GetContext")
+	}
+	original.Available = true
+	return original, nil
+}
+
+// ListFeed lists the blobs and reblogs of the users viewerID follows, and the
+// viewer's own, newest first.
+func (r *BlobRepo) ListFeed(ctx context.Context, viewerID string, limit, offset int) ([]*models.BlobListWithDetails, int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobRepo.ListFeed")
+	defer span.Finish()
+
+	blobs, total, err := listBlobPage(ctx, r.db, countFollowingFeedQuery, listFollowingFeedQuery, viewerID, viewerID, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "BlobRepo.ListFeed")
+	}
+	return blobs, total, nil
+}
+
+// ListTrending lists the blobs with the most likes and comments first,
+// leaving out authors viewerID muted or blocked.
+func (r *BlobRepo) ListTrending(ctx context.Context, viewerID string, limit, offset int) ([]*models.BlobListWithDetails, int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobRepo.ListTrending")
+	defer span.Finish()
+
+	blobs, total, err := listBlobPage(ctx, r.db, countTrendingBlobsQuery, listTrendingBlobsQuery, viewerID, viewerID, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "BlobRepo.ListTrending")
+	}
+	return blobs, total, nil
+}
+
+// ListViewerStates returns the viewer state of the visible blobs among
+// blobIDs; unknown and hidden IDs are left out.
+func (r *BlobRepo) ListViewerStates(ctx context.Context, blobIDs []string, viewerID string) ([]models.BlobViewerState, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobRepo.ListViewerStates")
+	defer span.Finish()
+
+	states := []models.BlobViewerState{}
+	if err := r.db.SelectContext(ctx, &states, listBlobViewerStatesQuery, pq.Array(blobIDs), viewerID); err != nil {
+		return nil, errors.Wrap(err, "BlobRepo.ListViewerStates.SelectContext")
+	}
+	return states, nil
+}
+
+// reblogRow scans the reblogColumns of the original; they are all NULL when
+// the blob is not a reblog or the original is gone.
+type reblogRow struct {
+	OriginalID         *string    `db:"original_id"`
+	OriginalUserID     *string    `db:"original_user_id"`
+	OriginalUsername   *string    `db:"original_username"`
+	OriginalAvatarIcon *string    `db:"original_avatar_icon"`
+	OriginalContent    *string    `db:"original_content"`
+	OriginalCreatedAt  *time.Time `db:"original_created_at"`
+}
+
+func (r reblogRow) reblogOf(isReblog bool) *models.ReblogOf {
+	if !isReblog {
+		return nil
+	}
+	if r.OriginalID == nil {
+		return &models.ReblogOf{}
+	}
+	return &models.ReblogOf{
+		Available:  true,
+		ID:         *r.OriginalID,
+		UserID:     stringValue(r.OriginalUserID),
+		Username:   stringValue(r.OriginalUsername),
+		AvatarIcon: stringValue(r.OriginalAvatarIcon),
+		Content:    stringValue(r.OriginalContent),
+		CreatedAt:  r.OriginalCreatedAt,
+	}
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func isUniqueViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == constraint
+}
+
+func isForeignKeyViolation(err error, constraint string) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503" && pqErr.Constraint == constraint
+}
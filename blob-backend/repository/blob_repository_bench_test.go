@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/pgtest"
+)
+
+// seedBlob creates a blob with the given number of comments and likes, each
+// from its own user, and returns it with the id of a viewer.
+func seedBlob(tb testing.TB, db *sqlx.DB, comments, likes int) (uuid.UUID, string) {
+	tb.Helper()
+
+	ctx := context.Background()
+	blobID := uuid.New()
+	users := comments
+	if likes > users {
+		users = likes
+	}
+
+	seed := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`INSERT INTO "User" (id, email, username)
+			SELECT 'user-' || n, 'user-' || n || '@example.com', 'user' || n
+			FROM generate_series(0, $1::int) n`, []interface{}{users}},
+		{`INSERT INTO "Blob" (id, user_id, content) VALUES ($1, 'user-0', 'seeded blob')`,
+			[]interface{}{blobID}},
+		{`INSERT INTO "Comment" (id, content, user_id, blob_id)
+			SELECT gen_random_uuid(), 'comment ' || n, 'user-' || n, $1
+			FROM generate_series(1, $2::int) n`, []interface{}{blobID, comments}},
+		{`INSERT INTO "Reaction" (id, user_id, blob_id, type)
+			SELECT gen_random_uuid(), 'user-' || n, $1, 'like'
+			FROM generate_series(1, $2::int) n`, []interface{}{blobID, likes}},
+	}
+	for _, step := range seed {
+		if _, err := db.ExecContext(ctx, step.query, step.args...); err != nil {
+			tb.Fatalf("seed: %v", err)
+		}
+	}
+	return blobID, "user-0"
+}
+
+// BenchmarkGetByID loads the detail of a blob without activity, as a
+// baseline, and of one with 200 comments and 500 likes. Run it against a
+// pgtest database:
+//
+//	BLOB_TEST_POSTGRES_URL=postgres://... go test ./repository -bench . -run '^$'
+func BenchmarkGetByID(b *testing.B) {
+	benchmarks := []struct {
+		name     string
+		comments int
+		likes    int
+	}{
+		{"quiet", 0, 0},
+		{"popular", 200, 500},
+	}
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			db, _ := pgtest.New(b)
+			blobID, viewerID := seedBlob(b, db, bm.comments, bm.likes)
+			repo := NewBlobRepository(db)
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				blob, err := repo.GetByID(ctx, blobID, viewerID, 20)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if blob == nil || blob.LikesCount != bm.likes || blob.CommentsCount != bm.comments {
+					b.Fatalf("unexpected blob detail: %+v", blob)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkGetAuthor is the lookup commenting does instead of GetByID; it
+// should not grow with the activity on the blob.
+func BenchmarkGetAuthor(b *testing.B) {
+	db, _ := pgtest.New(b)
+	blobID, viewerID := seedBlob(b, db, 200, 500)
+	repo := NewBlobRepository(db)
+	ctx := context.Background()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		authorID, err := repo.GetAuthor(ctx, blobID)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if authorID != viewerID {
+			b.Fatalf("author = %q", authorID)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/pgtest"
+)
+
+func exec(tb testing.TB, db *sqlx.DB, query string, args ...interface{}) {
+	tb.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		tb.Fatalf("%s: %v", query, err)
+	}
+}
+
+// addUsers creates a user per name, with the name as id and username.
+func addUsers(tb testing.TB, db *sqlx.DB, names ...string) {
+	tb.Helper()
+	for _, name := range names {
+		exec(tb, db, `INSERT INTO "User" (id, email, username) VALUES ($1, $1 || '@example.com', $1)`, name)
+	}
+}
+
+func addBlob(tb testing.TB, db *sqlx.DB, userID, content string) uuid.UUID {
+	tb.Helper()
+	id := uuid.New()
+	exec(tb, db, `INSERT INTO "Blob" (id, user_id, content) VALUES ($1, $2, $3)`, id, userID, content)
+	return id
+}
+
+// TestListBlobsReadsStoredCounters checks GET /blob shows the same counters
+// as the blob detail, whoever the viewer muted or blocked, while leaving out
+// the blobs of the authors they silenced.
+func TestListBlobsReadsStoredCounters(t *testing.T) {
+	db, _ := pgtest.New(t)
+	repo := NewBlobRepository(db)
+	ctx := context.Background()
+
+	addUsers(t, db, "ana", "bob", "carl", "dan", "eve")
+	blobID := addBlob(t, db, "bob", "popular")
+	addBlob(t, db, "carl", "muted")
+	for _, userID := range []string{"carl", "dan", "eve"} {
+		exec(t, db, `INSERT INTO "Reaction" (id, user_id, blob_id, type) VALUES ($1, $2, $3, 'like')`, uuid.New(), userID, blobID)
+		exec(t, db, `INSERT INTO "Comment" (id, content, user_id, blob_id) VALUES ($1, 'hi', $2, $3)`, uuid.New(), userID, blobID)
+	}
+	exec(t, db, `INSERT INTO "Mute" (user_id, muted_user_id) VALUES ('ana', 'carl')`)
+	exec(t, db, `INSERT INTO "Block" (user_id, blocked_user_id) VALUES ('ana', 'dan')`)
+
+	for _, viewerID := range []string{"ana", "bob"} {
+		blobs, err := repo.ListBlobs(ctx, viewerID)
+		if err != nil {
+			t.Fatalf("ListBlobs(%s): %v", viewerID, err)
+		}
+		var listed *models.BlobListWithDetails
+		for i := range blobs {
+			if blobs[i].ID == blobID.String() {
+				listed = &blobs[i]
+			} else if viewerID == "ana" {
+				t.Errorf("ListBlobs(ana) shows the blob of a muted author: %+v", blobs[i])
+			}
+		}
+		if listed == nil {
+			t.Fatalf("ListBlobs(%s) = %+v, missing the popular blob", viewerID, blobs)
+		}
+
+		detail, err := repo.GetByID(ctx, blobID, viewerID, 10)
+		if err != nil || detail == nil {
+			t.Fatalf("GetByID(%s) = %v, %v", viewerID, detail, err)
+		}
+		if listed.LikesCount != 3 || listed.CommentsCount != 3 || listed.ReactionCounts["like"] != 3 ||
+			listed.LikesCount != detail.LikesCount || listed.CommentsCount != detail.CommentsCount {
+			t.Errorf("ListBlobs(%s): likes = %d, comments = %d, reactions = %v; detail: likes = %d, comments = %d; want 3 each",
+				viewerID, listed.LikesCount, listed.CommentsCount, listed.ReactionCounts, detail.LikesCount, detail.CommentsCount)
+		}
+	}
+}
+
+func TestListFeedLeavesOutMutedAndBlockedAuthors(t *testing.T) {
+	db, _ := pgtest.New(t)
+	repo := NewBlobRepository(db)
+	ctx := context.Background()
+
+	addUsers(t, db, "ana", "bob", "carl", "dan", "eve")
+	for _, followed := range []string{"bob", "carl", "dan"} {
+		exec(t, db, `INSERT INTO "Follow" (user_id, followed_user_id) VALUES ('ana', $1)`, followed)
+	}
+	exec(t, db, `INSERT INTO "Mute" (user_id, muted_user_id) VALUES ('ana', 'carl'), ('ana', 'eve')`)
+	exec(t, db, `INSERT INTO "Block" (user_id, blocked_user_id) VALUES ('ana', 'dan')`)
+
+	visibleID := addBlob(t, db, "bob", "followed")
+	addBlob(t, db, "carl", "followed but muted")
+	addBlob(t, db, "dan", "followed but blocked")
+	mutedOriginalID := addBlob(t, db, "eve", "muted original")
+	exec(t, db, `INSERT INTO "Blob" (id, user_id, content, reblog_of_id, is_reblog) VALUES ($1, 'bob', '', $2, TRUE)`,
+		uuid.New(), mutedOriginalID)
+
+	blobs, total, err := repo.ListFeed(ctx, "ana", 10, 0)
+	if err != nil {
+		t.Fatalf("ListFeed: %v", err)
+	}
+	if total != 1 || len(blobs) != 1 || blobs[0].ID != visibleID.String() {
+		contents := make([]string, len(blobs))
+		for i, blob := range blobs {
+			contents[i] = blob.Content
+		}
+		t.Errorf("ListFeed = %d %q, want only bob's own blob", total, contents)
+	}
+}
+
+func TestCreateWritesHashtagInterestsWithTheBlob(t *testing.T) {
+	db, _ := pgtest.New(t)
+	blobs := NewBlobRepository(db)
+	ctx := context.Background()
+	addUsers(t, db, "ana")
+
+	countInterests := func(name string) int {
+		var count int
+		if err := db.Get(&count, `SELECT COUNT(*) FROM "Interest" WHERE name = $1`, name); err != nil {
+			t.Fatalf("count interests: %v", err)
+		}
+		return count
+	}
+
+	failed := &models.BlobWithInterests{ID: uuid.New(), UserID: "ana", Content: "#rust", Hashtags: []string{"rust"}, MediaIDs: []uuid.UUID{uuid.New()}}
+	if _, err := blobs.Create(ctx, failed); !errors.Is(err, ErrMediaUnavailable) {
+		t.Fatalf("Create = %v, want ErrMediaUnavailable", err)
+	}
+	if count := countInterests("rust"); count != 0 {
+		t.Errorf("a failed create left %d #rust interests", count)
+	}
+
+	created, err := blobs.Create(ctx, &models.BlobWithInterests{ID: uuid.New(), UserID: "ana", Content: "#rust #Rust", Hashtags: []string{"rust", "Rust"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if len(created.Interests) != 1 || countInterests("rust") != 1 {
+		t.Errorf("interests = %v, want one #rust interest", created.Interests)
+	}
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/models"
+	"github.com/lib/pq"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+)
+
+type BookmarkRepo struct {
+	db *sqlx.DB
+}
+
+func NewBookmarkRepository(db *sqlx.DB) BookmarkRepo {
+	return BookmarkRepo{db: db}
+}
+
+// GetAuthorID returns the author of a visible blob, or an empty string when
+// the blob is gone or hidden.
+func (r *BookmarkRepo) GetAuthorID(ctx context.Context, blobID uuid.UUID) (string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BookmarkRepo.GetAuthorID")
+	defer span.Finish()
+
+	var authorID string
+	if err := r.db.GetContext(ctx, &authorID, getBookmarkTargetQuery, blobID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "BookmarkRepo.GetAuthorID.GetContext")
+	}
+	return authorID, nil
+}
+
+// Add bookmarks the blob and reports whether it was not bookmarked before.
+func (r *BookmarkRepo) Add(ctx context.Context, userID string, blobID uuid.UUID, authorID string) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BookmarkRepo.Add")
+	defer span.Finish()
+
+	result, err := r.db.ExecContext(ctx, insertBookmarkQuery, userID, blobID, authorID)
+	if err != nil {
+		return false, errors.Wrap(err, "BookmarkRepo.Add.ExecContext")
+	}
+	added, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "BookmarkRepo.Add.RowsAffected")
+	}
+	return added > 0, nil
+}
+
+// Remove deletes the bookmark and reports whether there was one.
+func (r *BookmarkRepo) Remove(ctx context.Context, userID string, blobID uuid.UUID) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BookmarkRepo.Remove")
+	defer span.Finish()
+
+	result, err := r.db.ExecContext(ctx, deleteBookmarkQuery, userID, blobID)
+	if err != nil {
+		return false, errors.Wrap(err, "BookmarkRepo.Remove.ExecContext")
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "BookmarkRepo.Remove.RowsAffected")
+	}
+	return removed > 0, nil
+}
+
+// bookmarkRow scans the interests array into the bookmark.
+type bookmarkRow struct {
+	models.Bookmark
+	Interests pq.StringArray `db:"interests"`
+}
+
+func (r *BookmarkRepo) List(ctx context.Context, userID string, limit, offset int) ([]*models.Bookmark, int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BookmarkRepo.List")
+	defer span.Finish()
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, countBookmarksQuery, userID); err != nil {
+		return nil, 0, errors.Wrap(err, "BookmarkRepo.List.count")
+	}
+
+	var rows []bookmarkRow
+	if err := r.db.SelectContext(ctx, &rows, listBookmarksQuery, userID, limit, offset); err != nil {
+		return nil, 0, errors.Wrap(err, "BookmarkRepo.List.SelectContext")
+	}
+
+	bookmarks := make([]*models.Bookmark, 0, len(rows))
+	for i := range rows {
+		bookmark := rows[i].Bookmark
+		bookmark.Interests = []string(rows[i].Interests)
+		bookmarks = append(bookmarks, &bookmark)
+	}
+	return bookmarks, total, nil
+}
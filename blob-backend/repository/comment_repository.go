@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"github.com/jmoiron/sqlx"
 	"github.com/joaoleau/blob/models"
 	"github.com/opentracing/opentracing-go"
@@ -11,35 +12,91 @@ import (
 
 type CommentRepo struct {
 	db *sqlx.DB
-	blobRepo *BlobRepo
 }
 
-func NewCommentRepository(db *sqlx.DB, blobRepo *BlobRepo) CommentRepo {
+func NewCommentRepository(db *sqlx.DB) CommentRepo {
 	return CommentRepo{
-		db: db, 
-		blobRepo: blobRepo,
+		db: db,
 	}
 }
 
+// AddComment inserts the comment with the mentions of comment.MentionIDs and,
+// for held comments, the filter report in one transaction.
 func (r *CommentRepo) AddComment(ctx context.Context, comment *models.Comment) (*models.Comment, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "CommentRepo.AddComment")
 	defer span.Finish()
 
-	if _, err := r.blobRepo.GetByID(ctx, comment.BlobID); err != nil {
-		return nil, errors.Wrap(err, "Comment.AddLike.GetByID")
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "CommentRepo.AddComment.BeginTxx")
 	}
+	defer tx.Rollback()
 
 	newComment := &models.Comment{}
-	if err := r.db.QueryRowxContext(ctx, insertCommentQuery,
+	if err := tx.QueryRowxContext(ctx, insertCommentQuery,
 		comment.ID, comment.Content, comment.UserID, comment.BlobID,
 	).StructScan(newComment); err != nil {
+		if isForeignKeyViolation(err, "fk_blob_comment") {
+			return nil, ErrBlobNotFound
+		}
 		return nil, errors.Wrap(err, "CommentRepo.AddComment.StructScan")
 	}
 
+	if err := replaceCommentMentions(ctx, tx, newComment.BlobID, newComment.ID, newComment.UserID, comment.MentionIDs); err != nil {
+		return nil, errors.Wrap(err, "CommentRepo.AddComment.replaceCommentMentions")
+	}
+
+	if comment.HoldReport != nil {
+		if err := holdContent(ctx, tx, comment.HoldReport); err != nil {
+			return nil, errors.Wrap(err, "CommentRepo.AddComment.holdContent")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "CommentRepo.AddComment.Commit")
+	}
 	return newComment, nil
 }
 
 
+// UpdateComment changes a comment owned by comment.UserID and replaces its
+// mentions in one transaction. It returns nil when there is no such comment.
+func (r *CommentRepo) UpdateComment(ctx context.Context, comment *models.Comment) (*models.Comment, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CommentRepo.UpdateComment")
+	defer span.Finish()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "CommentRepo.UpdateComment.BeginTxx")
+	}
+	defer tx.Rollback()
+
+	updatedComment := &models.Comment{}
+	if err := tx.QueryRowxContext(ctx, updateCommentQuery,
+		comment.Content, comment.ID, comment.UserID, comment.BlobID,
+	).StructScan(updatedComment); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "CommentRepo.UpdateComment.StructScan")
+	}
+
+	if err := replaceCommentMentions(ctx, tx, updatedComment.BlobID, updatedComment.ID, updatedComment.UserID, comment.MentionIDs); err != nil {
+		return nil, errors.Wrap(err, "CommentRepo.UpdateComment.replaceCommentMentions")
+	}
+
+	if comment.HoldReport != nil {
+		if err := holdContent(ctx, tx, comment.HoldReport); err != nil {
+			return nil, errors.Wrap(err, "CommentRepo.UpdateComment.holdContent")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "CommentRepo.UpdateComment.Commit")
+	}
+	return updatedComment, nil
+}
+
 func (r *CommentRepo) RemoveComment(ctx context.Context, userID string, commentID uuid.UUID) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "CommentRepo.RemoveComment")
 	defer span.Finish()
@@ -52,13 +109,18 @@ func (r *CommentRepo) RemoveComment(ctx context.Context, userID string, commentI
 }
 
 
-func (r *CommentRepo) ListCommentsByBlobID(ctx context.Context, blobID uuid.UUID) ([]models.CommentWithUser, error) {
+func (r *CommentRepo) ListCommentsByBlobID(ctx context.Context, blobID uuid.UUID, viewerID string, limit, offset int) ([]models.CommentWithUser, int, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "CommentRepo.ListCommentsByBlobID")
 	defer span.Finish()
 
-	var comments []models.CommentWithUser
-	if err := r.db.SelectContext(ctx, &comments, searchCommentsbyBlobIDQuery, blobID); err != nil {
-		return nil, errors.Wrap(err, "CommentRepo.ListCommentsByBlobID.SelectContext")
+	var total int
+	if err := r.db.GetContext(ctx, &total, countCommentsByBlobIDQuery, blobID, viewerID); err != nil {
+		return nil, 0, errors.Wrap(err, "CommentRepo.ListCommentsByBlobID.count")
+	}
+
+	comments := []models.CommentWithUser{}
+	if err := r.db.SelectContext(ctx, &comments, searchCommentsbyBlobIDQuery, blobID, viewerID, limit, offset); err != nil {
+		return nil, 0, errors.Wrap(err, "CommentRepo.ListCommentsByBlobID.SelectContext")
 	}
-	return comments, nil
+	return comments, total, nil
 }
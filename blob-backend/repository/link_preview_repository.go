@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/preview"
+	"github.com/lib/pq"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+)
+
+// LinkPreviewRepo stores unfurled links. The table doubles as the work queue
+// of the preview worker.
+type LinkPreviewRepo struct {
+	db *sqlx.DB
+}
+
+func NewLinkPreviewRepository(db *sqlx.DB) LinkPreviewRepo {
+	return LinkPreviewRepo{db: db}
+}
+
+// Enqueue queues unknown URLs and re-queues previews older than a week.
+func (r *LinkPreviewRepo) Enqueue(ctx context.Context, urls []string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "LinkPreviewRepo.Enqueue")
+	defer span.Finish()
+
+	if len(urls) == 0 {
+		return nil
+	}
+
+	if _, err := r.db.ExecContext(ctx, enqueueLinkPreviewsQuery, pq.Array(urls)); err != nil {
+		return errors.Wrap(err, "LinkPreviewRepo.Enqueue.ExecContext")
+	}
+	return nil
+}
+
+// Claim locks up to limit due URLs for this worker. Claims of a worker that
+// died are picked up again once they time out.
+func (r *LinkPreviewRepo) Claim(ctx context.Context, limit int) ([]models.LinkPreviewJob, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "LinkPreviewRepo.Claim")
+	defer span.Finish()
+
+	jobs := []models.LinkPreviewJob{}
+	if err := r.db.SelectContext(ctx, &jobs, claimLinkPreviewsQuery, limit); err != nil {
+		return nil, errors.Wrap(err, "LinkPreviewRepo.Claim.SelectContext")
+	}
+	return jobs, nil
+}
+
+func (r *LinkPreviewRepo) Complete(ctx context.Context, url string, metadata *preview.Metadata) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "LinkPreviewRepo.Complete")
+	defer span.Finish()
+
+	if _, err := r.db.ExecContext(ctx, completeLinkPreviewQuery,
+		url, metadata.Title, metadata.Description, metadata.ImageURL, metadata.SiteName,
+	); err != nil {
+		return errors.Wrap(err, "LinkPreviewRepo.Complete.ExecContext")
+	}
+	return nil
+}
+
+// Fail records a failed fetch. The URL is retried after retryIn until it has
+// been attempted maxAttempts times.
+func (r *LinkPreviewRepo) Fail(ctx context.Context, url string, maxAttempts int, reason string, retryIn time.Duration) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "LinkPreviewRepo.Fail")
+	defer span.Finish()
+
+	if _, err := r.db.ExecContext(ctx, failLinkPreviewQuery, url, maxAttempts, reason, int(retryIn.Seconds())); err != nil {
+		return errors.Wrap(err, "LinkPreviewRepo.Fail.ExecContext")
+	}
+	return nil
+}
+
+func (r *LinkPreviewRepo) ListReady(ctx context.Context, urls []string) ([]models.LinkPreview, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "LinkPreviewRepo.ListReady")
+	defer span.Finish()
+
+	previews := []models.LinkPreview{}
+	if len(urls) == 0 {
+		return previews, nil
+	}
+
+	if err := r.db.SelectContext(ctx, &previews, listLinkPreviewsQuery, pq.Array(urls)); err != nil {
+		return nil, errors.Wrap(err, "LinkPreviewRepo.ListReady.SelectContext")
+	}
+	return previews, nil
+}
@@ -0,0 +1,9 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/joaoleau/blob/pgtest"
+)
+
+func TestMain(m *testing.M) { pgtest.Main(m) }
@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/models"
+	"github.com/lib/pq"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+)
+
+// ErrMediaUnavailable is returned when an attachment does not exist, belongs
+// to someone else or is already attached to another blob.
+var ErrMediaUnavailable = errors.New("media not found or already attached")
+
+type MediaRepo struct {
+	db *sqlx.DB
+}
+
+func NewMediaRepository(db *sqlx.DB) MediaRepo {
+	return MediaRepo{db: db}
+}
+
+func (r *MediaRepo) Create(ctx context.Context, media *models.Media) (*models.Media, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "MediaRepo.Create")
+	defer span.Finish()
+
+	created := &models.Media{}
+	if err := r.db.QueryRowxContext(ctx, insertMediaQuery,
+		media.ID, media.UserID, media.ContentType, media.Size, media.Width, media.Height,
+		media.StorageKey, media.ThumbnailKey, media.ExpiresAt,
+	).StructScan(created); err != nil {
+		return nil, errors.Wrap(err, "MediaRepo.Create.StructScan")
+	}
+	return created, nil
+}
+
+// CheckAttachable returns ErrMediaUnavailable unless every id can be attached
+// to blobID by userID.
+func (r *MediaRepo) CheckAttachable(ctx context.Context, blobID uuid.UUID, userID string, ids []uuid.UUID) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "MediaRepo.CheckAttachable")
+	defer span.Finish()
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var count int
+	if err := r.db.GetContext(ctx, &count, countAttachableMediaQuery, pq.Array(uuidStrings(ids)), userID, blobID); err != nil {
+		return errors.Wrap(err, "MediaRepo.CheckAttachable.GetContext")
+	}
+	if count != len(uniqueUUIDs(ids)) {
+		return ErrMediaUnavailable
+	}
+	return nil
+}
+
+// setBlobMedia makes ids the attachments of blobID inside the blob write
+// transaction. Attachments that are dropped expire and are removed by the
+// janitor.
+func setBlobMedia(ctx context.Context, tx *sqlx.Tx, blobID uuid.UUID, userID string, ids []uuid.UUID) error {
+	ids = uniqueUUIDs(ids)
+	idArray := pq.Array(uuidStrings(ids))
+
+	if _, err := tx.ExecContext(ctx, detachBlobMediaQuery, blobID, idArray); err != nil {
+		return errors.Wrap(err, "setBlobMedia.detach")
+	}
+
+	if len(ids) > 0 {
+		result, err := tx.ExecContext(ctx, attachBlobMediaQuery, blobID, idArray, userID)
+		if err != nil {
+			return errors.Wrap(err, "setBlobMedia.attach")
+		}
+		attached, err := result.RowsAffected()
+		if err != nil {
+			return errors.Wrap(err, "setBlobMedia.RowsAffected")
+		}
+		if attached != int64(len(ids)) {
+			return ErrMediaUnavailable
+		}
+	}
+	return nil
+}
+
+func (r *MediaRepo) ListByBlobs(ctx context.Context, blobIDs []string) ([]models.Media, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "MediaRepo.ListByBlobs")
+	defer span.Finish()
+
+	media := []models.Media{}
+	if len(blobIDs) == 0 {
+		return media, nil
+	}
+
+	if err := r.db.SelectContext(ctx, &media, listMediaByBlobsQuery, pq.Array(blobIDs)); err != nil {
+		return nil, errors.Wrap(err, "MediaRepo.ListByBlobs.SelectContext")
+	}
+	return media, nil
+}
+
+// DeleteExpired removes up to limit expired or orphaned rows and returns them
+// so the caller can delete the stored files.
+func (r *MediaRepo) DeleteExpired(ctx context.Context, limit int) ([]models.Media, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "MediaRepo.DeleteExpired")
+	defer span.Finish()
+
+	media := []models.Media{}
+	if err := r.db.SelectContext(ctx, &media, deleteExpiredMediaQuery, limit); err != nil {
+		return nil, errors.Wrap(err, "MediaRepo.DeleteExpired.SelectContext")
+	}
+	return media, nil
+}
+
+func uniqueUUIDs(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool)
+	unique := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	values := make([]string, len(ids))
+	for i, id := range ids {
+		values[i] = id.String()
+	}
+	return values
+}
@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/models"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+)
+
+type MentionRepo struct {
+	db *sqlx.DB
+}
+
+func NewMentionRepository(db *sqlx.DB) MentionRepo {
+	return MentionRepo{db: db}
+}
+
+func (r *MentionRepo) ListByMentionedUser(ctx context.Context, userID string, limit, offset int) ([]models.MentionWithAuthor, int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "MentionRepo.ListByMentionedUser")
+	defer span.Finish()
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, countMentionsByUserQuery, userID); err != nil {
+		return nil, 0, errors.Wrap(err, "MentionRepo.ListByMentionedUser.count")
+	}
+
+	mentions := []models.MentionWithAuthor{}
+	if err := r.db.SelectContext(ctx, &mentions, listMentionsByUserQuery, userID, limit, offset); err != nil {
+		return nil, 0, errors.Wrap(err, "MentionRepo.ListByMentionedUser.SelectContext")
+	}
+
+	return mentions, total, nil
+}
+
+// replaceBlobMentions swaps the mentions made in the blob body itself for
+// the given users, inside the transaction that writes the blob. Mentions
+// made in the blob's comments are untouched.
+func replaceBlobMentions(ctx context.Context, tx *sqlx.Tx, blobID uuid.UUID, authorID string, userIDs []string) error {
+	if _, err := tx.ExecContext(ctx, deleteBlobMentionsQuery, blobID); err != nil {
+		return errors.Wrap(err, "replaceBlobMentions.delete")
+	}
+
+	for _, userID := range userIDs {
+		if _, err := tx.ExecContext(ctx, insertMentionQuery, uuid.New(), blobID, nil, authorID, userID); err != nil {
+			return errors.Wrap(err, "replaceBlobMentions.insert")
+		}
+	}
+	return nil
+}
+
+// replaceCommentMentions swaps the mentions made in one comment for the given
+// users, inside the transaction that writes the comment.
+func replaceCommentMentions(ctx context.Context, tx *sqlx.Tx, blobID, commentID uuid.UUID, authorID string, userIDs []string) error {
+	if _, err := tx.ExecContext(ctx, deleteCommentMentionsQuery, commentID); err != nil {
+		return errors.Wrap(err, "replaceCommentMentions.delete")
+	}
+
+	for _, userID := range userIDs {
+		if _, err := tx.ExecContext(ctx, insertMentionQuery, uuid.New(), blobID, commentID, authorID, userID); err != nil {
+			return errors.Wrap(err, "replaceCommentMentions.insert")
+		}
+	}
+	return nil
+}
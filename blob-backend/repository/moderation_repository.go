@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/models"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+)
+
+// ErrAssigneeNotModerator is returned when a report is assigned to someone
+// without the moderator or admin role.
+var ErrAssigneeNotModerator = errors.New("assignee is not a moderator")
+
+type ModerationRepo struct {
+	db *sqlx.DB
+}
+
+func NewModerationRepository(db *sqlx.DB) ModerationRepo {
+	return ModerationRepo{db: db}
+}
+
+// GetReportTarget returns the author and content of a blob, or of one of its
+// comments when commentID is set. A nil report means the target is gone.
+func (r *ModerationRepo) GetReportTarget(ctx context.Context, blobID uuid.UUID, commentID *uuid.UUID) (*models.Report, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationRepo.GetReportTarget")
+	defer span.Finish()
+
+	target := &models.Report{BlobID: &blobID, CommentID: commentID}
+
+	var err error
+	if commentID == nil {
+		err = r.db.QueryRowxContext(ctx, getBlobReportTargetQuery, blobID).Scan(&target.TargetUserID, &target.Content)
+	} else {
+		err = r.db.QueryRowxContext(ctx, getCommentReportTargetQuery, *commentID, blobID).Scan(&target.TargetUserID, &target.Content)
+	}
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "ModerationRepo.GetReportTarget.Scan")
+	}
+
+	return target, nil
+}
+
+func (r *ModerationRepo) HasOpenReport(ctx context.Context, reporterID string, blobID uuid.UUID, commentID *uuid.UUID) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationRepo.HasOpenReport")
+	defer span.Finish()
+
+	var exists bool
+	if err := r.db.GetContext(ctx, &exists, openReportExistsQuery, reporterID, blobID, commentID); err != nil {
+		return false, errors.Wrap(err, "ModerationRepo.HasOpenReport.GetContext")
+	}
+	return exists, nil
+}
+
+func (r *ModerationRepo) CreateReport(ctx context.Context, report *models.Report) (*models.Report, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationRepo.CreateReport")
+	defer span.Finish()
+
+	newReport := &models.Report{}
+	if err := r.db.QueryRowxContext(ctx, insertReportQuery,
+		report.ID, report.ReporterID, report.BlobID, report.CommentID, report.TargetUserID,
+		report.Content, report.Reason, report.Details, report.Source,
+	).StructScan(newReport); err != nil {
+		return nil, errors.Wrap(err, "ModerationRepo.CreateReport.StructScan")
+	}
+
+	return newReport, nil
+}
+
+func (r *ModerationRepo) GetReport(ctx context.Context, reportID uuid.UUID) (*models.Report, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationRepo.GetReport")
+	defer span.Finish()
+
+	report := &models.Report{}
+	if err := r.db.GetContext(ctx, report, getReportQuery, reportID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "ModerationRepo.GetReport.GetContext")
+	}
+	return report, nil
+}
+
+func (r *ModerationRepo) ListReports(ctx context.Context, status string, limit, offset int) ([]*models.Report, int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationRepo.ListReports")
+	defer span.Finish()
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, countReportsQuery, status); err != nil {
+		return nil, 0, errors.Wrap(err, "ModerationRepo.ListReports.count")
+	}
+
+	reports := []*models.Report{}
+	if err := r.db.SelectContext(ctx, &reports, listReportsQuery, status, limit, offset); err != nil {
+		return nil, 0, errors.Wrap(err, "ModerationRepo.ListReports.SelectContext")
+	}
+
+	return reports, total, nil
+}
+
+// AssignReport assigns an open report to assigneeID, who must be a moderator,
+// and logs it. It returns nil when there is no open report with this ID.
+func (r *ModerationRepo) AssignReport(ctx context.Context, reportID uuid.UUID, assigneeID, moderatorID string) (*models.Report, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationRepo.AssignReport")
+	defer span.Finish()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "ModerationRepo.AssignReport.BeginTxx")
+	}
+	defer tx.Rollback()
+
+	var isModerator bool
+	if err := tx.GetContext(ctx, &isModerator, isModeratorQuery, assigneeID); err != nil {
+		return nil, errors.Wrap(err, "ModerationRepo.AssignReport.isModerator")
+	}
+	if !isModerator {
+		return nil, ErrAssigneeNotModerator
+	}
+
+	report := &models.Report{}
+	if err := tx.QueryRowxContext(ctx, assignReportQuery, assigneeID, reportID).StructScan(report); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "ModerationRepo.AssignReport.StructScan")
+	}
+
+	if err := insertAuditLog(ctx, tx, moderatorID, models.ModerationActionAssign, &reportID, "report", reportID.String(), "assigned to "+assigneeID); err != nil {
+		return nil, errors.Wrap(err, "ModerationRepo.AssignReport.insertAuditLog")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "ModerationRepo.AssignReport.Commit")
+	}
+	return report, nil
+}
+
+// ResolveReport applies the moderator's action to the reported content,
+// closes the report and writes the audit log entry in a single transaction.
+func (r *ModerationRepo) ResolveReport(ctx context.Context, reportID uuid.UUID, action, note, moderatorID string) (*models.Report, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationRepo.ResolveReport")
+	defer span.Finish()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "ModerationRepo.ResolveReport.BeginTxx")
+	}
+	defer tx.Rollback()
+
+	status := models.ReportStatusResolved
+	if action == models.ModerationActionDismiss {
+		status = models.ReportStatusDismissed
+	}
+
+	report := &models.Report{}
+	if err := tx.QueryRowxContext(ctx, resolveReportQuery, status, action, note, moderatorID, reportID).StructScan(report); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "ModerationRepo.ResolveReport.StructScan")
+	}
+
+	targetType, targetID := "blob", ""
+	if report.BlobID != nil {
+		targetID = report.BlobID.String()
+	}
+	if report.CommentID != nil {
+		targetType, targetID = "comment", report.CommentID.String()
+	}
+
+	switch action {
+	case models.ModerationActionHide:
+		err = setHidden(ctx, tx, report, true)
+	case models.ModerationActionDismiss:
+		// Only content held by the filter was hidden because of this report.
+		if report.Source == models.ReportSourceFilter {
+			err = setHidden(ctx, tx, report, false)
+		}
+	case models.ModerationActionDelete:
+		if report.CommentID != nil {
+			_, err = tx.ExecContext(ctx, moderatorDeleteCommentQuery, *report.CommentID)
+		} else if report.BlobID != nil {
+			_, err = tx.ExecContext(ctx, deleteBlobQuery, *report.BlobID)
+		}
+	case models.ModerationActionBan:
+		targetType, targetID = "user", report.TargetUserID
+		if err = setHidden(ctx, tx, report, true); err == nil {
+			if _, err = tx.ExecContext(ctx, banUserQuery, report.TargetUserID); err == nil {
+				_, err = tx.ExecContext(ctx, deleteUserSessionsQuery, report.TargetUserID)
+			}
+		}
+	case models.ModerationActionWarn:
+		targetType, targetID = "user", report.TargetUserID
+		_, err = tx.ExecContext(ctx, insertWarningQuery, uuid.New(), report.TargetUserID, reportID, moderatorID, report.Reason, note)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "ModerationRepo.ResolveReport."+action)
+	}
+
+	if err := insertAuditLog(ctx, tx, moderatorID, action, &reportID, targetType, targetID, note); err != nil {
+		return nil, errors.Wrap(err, "ModerationRepo.ResolveReport.insertAuditLog")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "ModerationRepo.ResolveReport.Commit")
+	}
+	return report, nil
+}
+
+func (r *ModerationRepo) ListAuditLog(ctx context.Context, limit, offset int) ([]models.AuditLogEntry, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationRepo.ListAuditLog")
+	defer span.Finish()
+
+	entries := []models.AuditLogEntry{}
+	if err := r.db.SelectContext(ctx, &entries, listAuditLogQuery, limit, offset); err != nil {
+		return nil, errors.Wrap(err, "ModerationRepo.ListAuditLog.SelectContext")
+	}
+	return entries, nil
+}
+
+// ListWarnings returns the warnings given to userID, newest first.
+func (r *ModerationRepo) ListWarnings(ctx context.Context, userID string, limit, offset int) ([]models.Warning, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationRepo.ListWarnings")
+	defer span.Finish()
+
+	warnings := []models.Warning{}
+	if err := r.db.SelectContext(ctx, &warnings, listWarningsByUserQuery, userID, limit, offset); err != nil {
+		return nil, errors.Wrap(err, "ModerationRepo.ListWarnings.SelectContext")
+	}
+	return warnings, nil
+}
+
+// holdContent hides the blob (or comment) of a filter report and files the
+// report, inside the transaction that writes the content, so held content
+// is never visible and never hidden without a report.
+func holdContent(ctx context.Context, tx *sqlx.Tx, report *models.Report) error {
+	if err := setHidden(ctx, tx, report, true); err != nil {
+		return errors.Wrap(err, "holdContent.setHidden")
+	}
+
+	if _, err := tx.ExecContext(ctx, insertReportQuery,
+		report.ID, report.ReporterID, report.BlobID, report.CommentID, report.TargetUserID,
+		report.Content, report.Reason, report.Details, report.Source,
+	); err != nil {
+		return errors.Wrap(err, "holdContent.insertReport")
+	}
+	return nil
+}
+
+func setHidden(ctx context.Context, tx *sqlx.Tx, report *models.Report, hidden bool) error {
+	var err error
+	if report.CommentID != nil {
+		_, err = tx.ExecContext(ctx, setCommentHiddenQuery, hidden, *report.CommentID)
+	} else if report.BlobID != nil {
+		_, err = tx.ExecContext(ctx, setBlobHiddenQuery, hidden, *report.BlobID)
+	}
+	return err
+}
+
+func insertAuditLog(ctx context.Context, tx *sqlx.Tx, moderatorID, action string, reportID *uuid.UUID, targetType, targetID, details string) error {
+	_, err := tx.ExecContext(ctx, insertAuditLogQuery, uuid.New(), moderatorID, action, reportID, targetType, targetID, details)
+	return err
+}
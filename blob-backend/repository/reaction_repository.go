@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/models"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+)
+
+// ErrAlreadyReacted is returned when a user adds a reaction type they already
+// left on the same blob or comment.
+var ErrAlreadyReacted = errors.New("reaction already exists")
+
+type ReactionRepo struct {
+	db *sqlx.DB
+}
+
+func NewReactionRepository(db *sqlx.DB) ReactionRepo {
+	return ReactionRepo{db: db}
+}
+
+// GetTargetAuthor returns the author of a visible blob, or of one of its
+// comments when commentID is set. An empty string means the target is gone.
+func (r *ReactionRepo) GetTargetAuthor(ctx context.Context, blobID uuid.UUID, commentID *uuid.UUID) (string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ReactionRepo.GetTargetAuthor")
+	defer span.Finish()
+
+	var authorID string
+	if err := r.db.GetContext(ctx, &authorID, getReactionTargetQuery, blobID, commentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "ReactionRepo.GetTargetAuthor.GetContext")
+	}
+	return authorID, nil
+}
+
+func (r *ReactionRepo) Add(ctx context.Context, reaction *models.Reaction) (*models.Reaction, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ReactionRepo.Add")
+	defer span.Finish()
+
+	newReaction := &models.Reaction{}
+	if err := r.db.QueryRowxContext(ctx, insertReactionQuery,
+		reaction.ID, reaction.UserID, reaction.BlobID, reaction.CommentID, reaction.Type,
+	).StructScan(newReaction); err != nil {
+		if isUniqueViolation(err, "unique_user_blob_reaction") || isUniqueViolation(err, "unique_user_comment_reaction") {
+			return nil, ErrAlreadyReacted
+		}
+		return nil, errors.Wrap(err, "ReactionRepo.Add.StructScan")
+	}
+
+	return newReaction, nil
+}
+
+// AddLike likes a blob and reports whether the like is new; liking twice is
+// not an error.
+func (r *ReactionRepo) AddLike(ctx context.Context, id uuid.UUID, userID string, blobID uuid.UUID) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ReactionRepo.AddLike")
+	defer span.Finish()
+
+	result, err := r.db.ExecContext(ctx, insertLikeQuery, id, userID, blobID)
+	if err != nil {
+		return false, errors.Wrap(err, "ReactionRepo.AddLike.ExecContext")
+	}
+	created, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "ReactionRepo.AddLike.RowsAffected")
+	}
+	return created > 0, nil
+}
+
+func (r *ReactionRepo) BlobExists(ctx context.Context, blobID uuid.UUID) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ReactionRepo.BlobExists")
+	defer span.Finish()
+
+	var exists bool
+	if err := r.db.GetContext(ctx, &exists, blobExistsQuery, blobID); err != nil {
+		return false, errors.Wrap(err, "ReactionRepo.BlobExists.GetContext")
+	}
+	return exists, nil
+}
+
+func (r *ReactionRepo) GetLikeState(ctx context.Context, blobID uuid.UUID, userID string) (*models.LikeState, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ReactionRepo.GetLikeState")
+	defer span.Finish()
+
+	state := &models.LikeState{BlobID: blobID}
+	if err := r.db.GetContext(ctx, state, getLikeStateQuery, blobID, userID); err != nil {
+		return nil, errors.Wrap(err, "ReactionRepo.GetLikeState.GetContext")
+	}
+	return state, nil
+}
+
+// Remove deletes the user's reaction of the given type and reports whether
+// there was one.
+func (r *ReactionRepo) Remove(ctx context.Context, userID string, blobID uuid.UUID, commentID *uuid.UUID, reactionType string) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ReactionRepo.Remove")
+	defer span.Finish()
+
+	result, err := r.db.ExecContext(ctx, deleteReactionQuery, userID, blobID, commentID, reactionType)
+	if err != nil {
+		return false, errors.Wrap(err, "ReactionRepo.Remove.ExecContext")
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "ReactionRepo.Remove.RowsAffected")
+	}
+	return removed > 0, nil
+}
+
+// ListLikes pages through the likes on a blob, oldest first, leaving out
+// users the viewer muted or blocked.
+func (r *ReactionRepo) ListLikes(ctx context.Context, blobID uuid.UUID, viewerID string, limit, offset int) ([]models.ReactionWithUser, int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ReactionRepo.ListLikes")
+	defer span.Finish()
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, countLikesQuery, blobID, viewerID); err != nil {
+		return nil, 0, errors.Wrap(err, "ReactionRepo.ListLikes.count")
+	}
+
+	likes := []models.ReactionWithUser{}
+	if err := r.db.SelectContext(ctx, &likes, listLikesQuery, blobID, viewerID, limit, offset); err != nil {
+		return nil, 0, errors.Wrap(err, "ReactionRepo.ListLikes.SelectContext")
+	}
+	return likes, total, nil
+}
+
+// List returns the reactions on a blob, or on one of its comments, leaving
+// out users the viewer muted or blocked.
+func (r *ReactionRepo) List(ctx context.Context, blobID uuid.UUID, commentID *uuid.UUID, viewerID string) ([]models.ReactionWithUser, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ReactionRepo.List")
+	defer span.Finish()
+
+	reactions := []models.ReactionWithUser{}
+	if err := r.db.SelectContext(ctx, &reactions, listReactionsQuery, blobID, commentID, viewerID); err != nil {
+		return nil, errors.Wrap(err, "ReactionRepo.List.SelectContext")
+	}
+	return reactions, nil
+}
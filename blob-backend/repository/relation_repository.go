@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/models"
+	"github.com/lib/pq"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+)
+
+// RelationRepo stores the per-user follow, block and mute lists.
+type RelationRepo struct {
+	db *sqlx.DB
+}
+
+func NewRelationRepository(db *sqlx.DB) RelationRepo {
+	return RelationRepo{db: db}
+}
+
+func (r *RelationRepo) Add(ctx context.Context, relation, userID, targetID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "RelationRepo.Add")
+	defer span.Finish()
+
+	insertQuery, _ := relationQueries(relation)
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "RelationRepo.Add.BeginTxx")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, insertQuery, userID, targetID); err != nil {
+		return errors.Wrap(err, "RelationRepo.Add.ExecContext")
+	}
+
+	// Blocking someone also ends any follow between the two users.
+	if relation == models.RelationBlock {
+		if _, err := tx.ExecContext(ctx, deleteFollowsBetweenQuery, userID, targetID); err != nil {
+			return errors.Wrap(err, "RelationRepo.Add.deleteFollows")
+		}
+	}
+
+	return errors.Wrap(tx.Commit(), "RelationRepo.Add.Commit")
+}
+
+func (r *RelationRepo) Remove(ctx context.Context, relation, userID, targetID string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "RelationRepo.Remove")
+	defer span.Finish()
+
+	_, deleteQuery := relationQueries(relation)
+
+	if _, err := r.db.ExecContext(ctx, deleteQuery, userID, targetID); err != nil {
+		return errors.Wrap(err, "RelationRepo.Remove.ExecContext")
+	}
+	return nil
+}
+
+func (r *RelationRepo) List(ctx context.Context, relation, userID string) ([]models.RelatedUser, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "RelationRepo.List")
+	defer span.Finish()
+
+	query := listBlockedUsersQuery
+	if relation == models.RelationMute {
+		query = listMutedUsersQuery
+	}
+
+	users := []models.RelatedUser{}
+	if err := r.db.SelectContext(ctx, &users, query, userID); err != nil {
+		return nil, errors.Wrap(err, "RelationRepo.List.SelectContext")
+	}
+	return users, nil
+}
+
+// IsBlockedBy reports whether ownerID has blocked actorID.
+func (r *RelationRepo) IsBlockedBy(ctx context.Context, actorID, ownerID string) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "RelationRepo.IsBlockedBy")
+	defer span.Finish()
+
+	var blocked bool
+	if err := r.db.GetContext(ctx, &blocked, isBlockedByQuery, ownerID, actorID); err != nil {
+		return false, errors.Wrap(err, "RelationRepo.IsBlockedBy.GetContext")
+	}
+	return blocked, nil
+}
+
+// ListBlockersAmong returns the users in userIDs that have blocked actorID.
+func (r *RelationRepo) ListBlockersAmong(ctx context.Context, actorID string, userIDs []string) ([]string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "RelationRepo.ListBlockersAmong")
+	defer span.Finish()
+
+	blockers := []string{}
+	if len(userIDs) == 0 {
+		return blockers, nil
+	}
+
+	if err := r.db.SelectContext(ctx, &blockers, listBlockersAmongQuery, actorID, pq.Array(userIDs)); err != nil {
+		return nil, errors.Wrap(err, "RelationRepo.ListBlockersAmong.SelectContext")
+	}
+	return blockers, nil
+}
+
+func relationQueries(relation string) (string, string) {
+	switch relation {
+	case models.RelationMute:
+		return insertMuteQuery, deleteMuteQuery
+	case models.RelationFollow:
+		return insertFollowQuery, deleteFollowQuery
+	default:
+		return insertBlockQuery, deleteBlockQuery
+	}
+}
@@ -2,56 +2,59 @@ package repository
 
 const (
 	createBlobQuery = `
-		INSERT INTO "Blob" (id, user_id, content, created_at, updated_at)
-		VALUES ($1, $2, $3, now(), now())
-		RETURNING *`
+		INSERT INTO "Blob" (id, user_id, content, reblog_of_id, is_reblog, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $4 IS NOT NULL, now(), now())
+		RETURNING id, user_id, content, created_at, updated_at, reblog_of_id, is_reblog`
 
 	updateBlobQuery = `
 		UPDATE "Blob"
 		SET content = COALESCE(NULLIF($1, ''), content),
 			updated_at = now()
-		WHERE id = $2
-		RETURNING *`
+		WHERE id = $2 AND user_id = $3
+		RETURNING id, user_id, content, created_at, updated_at, reblog_of_id, is_reblog`
 
+	// getBlobByIDQuery loads a single blob with its counts; the first
+	// comments and likes come from their own bounded list queries.
 	getBlobByIDQuery = `
-	SELECT 
-		b.id AS blob_id, 
-		b.user_id AS blob_user_id, 
-		b.content AS blob_content, 
-		b.created_at AS blob_created_at, 
-		b.updated_at AS blob_updated_at,
-		u.username AS user_username, 
-		u.avatar_icon AS user_avatar_icon, 
+	SELECT
+		b.id,
+		b.user_id,
+		b.content,
+		b.created_at,
+		b.updated_at,
+		u.username,
+		u.avatar_icon,
 		u.created_at AS user_created_at,
-		c.id AS comment_id, 
-		c.content AS comment_content, 
-		c.created_at AS comment_created_at, 
-		c.updated_at AS comment_updated_at, 
-		c.user_id AS comment_user_id, 
-		l.id AS like_id, 
-		l.user_id AS like_user_id, 
-		l.created_at AS like_created_at,
-		i.id AS interest_id,
-		i.name AS interest_name, 
-		i.description AS interest_description, 
-		i.created_at AS interest_created_at,
-		i.updated_at AS interest_updated_at
+		b.likes_count,
+		b.comments_count,` + blobReactionCounts + `,` + reblogColumns + `,` + viewerColumns + `
 	FROM "Blob" b
-	LEFT JOIN "User" u ON b.user_id = u.id
-	LEFT JOIN "Comment" c ON c.blob_id = b.id
-	LEFT JOIN "Like" l ON l.blob_id = b.id
-	LEFT JOIN "_BlobToInterest" bi ON bi.blob_id = b.id
-	LEFT JOIN "Interest" i ON i.id = bi.interest_id
-	WHERE b.id = $1;
-	`
+	CROSS JOIN (SELECT $2::varchar AS id) viewer` + viewerBlockJoin + `
+	JOIN "User" u ON b.user_id = u.id` + reblogJoins + `
+	WHERE b.id = $1 AND b.hidden_at IS NULL`
+
+	listBlobInterestsQuery = `
+		SELECT i.id, i.name, COALESCE(i.description, '') AS description, i.created_at, i.updated_at
+		FROM "_BlobToInterest" bi
+		JOIN "Interest" i ON i.id = bi.interest_id
+		WHERE bi.blob_id = $1
+		ORDER BY i.name`
 
 	deleteBlobQuery = `
 		DELETE FROM "Blob"
-		WHERE id = $1
+		WHERE id = $1 AND user_id = $2
 		RETURNING id`
 
 	listBlobsQuery = `
-		SELECT * FROM listBlobs`
+		SELECT lb.*,` + viewerColumns + `
+		FROM listBlobs lb
+		JOIN "Blob" b ON b.id = lb.id
+		CROSS JOIN (SELECT $1::varchar AS id) viewer` + viewerBlockJoin + `
+		WHERE NOT EXISTS (
+			SELECT 1 FROM "Mute" m WHERE m.user_id = $1 AND m.muted_user_id = lb.user_id
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM "Block" bl WHERE bl.user_id = $1 AND bl.blocked_user_id = lb.user_id
+		)`
 
 	getTotalBlob = `
 		SELECT COUNT(id)
@@ -61,29 +64,171 @@ const (
 		SELECT COUNT(id)
 		FROM "User"`
 
-	listUserByUsernameWithBlobsQuery = `
+	getPublicProfileQuery = `
 		SELECT
 			u.id,
-			u.name,
-			u.email,
-			u.email_verified,
-			u.image,
 			u.username,
-			u.bio,
+			COALESCE(u.name, '') AS name,
+			COALESCE(u.image, '') AS image,
+			COALESCE(u.bio, '') AS bio,
+			COALESCE(u.avatar_icon, 'user') AS avatar_icon,
+			COALESCE(u.avatar_color, 'cyan') AS avatar_color,
+			u.created_at,
+			u.private_profile,
+			u.hide_likes,
+			(SELECT COUNT(*) FROM "Follow" f WHERE f.followed_user_id = u.id) AS followers_count,
+			(SELECT COUNT(*) FROM "Follow" f WHERE f.user_id = u.id) AS following_count,
+			(SELECT COUNT(*) FROM "Blob" b WHERE b.user_id = u.id AND b.hidden_at IS NULL) AS blobs_count,
+			(SELECT COUNT(*) FROM "Reaction" l JOIN "Blob" b ON b.id = l.blob_id
+				WHERE b.user_id = u.id AND b.hidden_at IS NULL
+					AND l.comment_id IS NULL AND l.type = 'like') AS likes_count,
+			EXISTS (SELECT 1 FROM "Follow" f WHERE f.user_id = $2 AND f.followed_user_id = u.id) AS followed_by_me,
+			EXISTS (SELECT 1 FROM "Follow" f WHERE f.user_id = u.id AND f.followed_user_id = $2) AS follows_me,
+			EXISTS (SELECT 1 FROM "Block" bl WHERE bl.user_id = u.id AND bl.blocked_user_id = $2) AS blocked_me
+		FROM "User" u
+		WHERE lower(u.username) = lower($1)
+			AND u.deletion_scheduled_at IS NULL
+			AND u.banned_at IS NULL`
+
+	getRenamedUsernameQuery = `
+		SELECT u.username
+		FROM "UsernameHistory" h
+		JOIN "User" u ON u.id = h.user_id
+		WHERE h.username = lower($1)
+			AND u.deletion_scheduled_at IS NULL
+			AND u.banned_at IS NULL`
+
+	insertUsernameHistoryQuery = `
+		INSERT INTO "UsernameHistory" (username, user_id)
+		VALUES (lower($1), $2)
+		ON CONFLICT (username) DO UPDATE
+		SET user_id = EXCLUDED.user_id,
+			changed_at = now()`
+
+	deleteUsernameHistoryQuery = `
+		DELETE FROM "UsernameHistory"
+		WHERE username = lower($1)`
+
+	profileBlobColumns = `
+		b.id,
+		b.user_id,
+		b.content,
+		b.created_at,
+		b.updated_at,
+		u.username,
+		u.avatar_icon,
+		u.created_at AS user_created_at,
+		b.likes_count,
+		b.comments_count,
+		ARRAY(
+			SELECT i.name FROM "_BlobToInterest" bi
+			JOIN "Interest" i ON i.id = bi.interest_id
+			WHERE bi.blob_id = b.id
+			ORDER BY i.name
+		) AS interests,` + blobReactionCounts + `,` + reblogColumns + `,` + viewerColumns
+
+	// viewerColumns fill models.ViewerState for the user bound as viewer.id.
+	// Queries selecting them join the viewer and viewerBlockJoin.
+	viewerColumns = `
+		EXISTS (SELECT 1 FROM "Reaction" vl WHERE vl.blob_id = b.id AND vl.user_id = viewer.id
+			AND vl.comment_id IS NULL AND vl.type = 'like') AS liked_by_me,
+		EXISTS (SELECT 1 FROM "Comment" vc WHERE vc.blob_id = b.id AND vc.user_id = viewer.id) AS commented_by_me,
+		b.user_id = viewer.id AS is_author,
+		EXISTS (SELECT 1 FROM "Bookmark" vbm WHERE vbm.blob_id = b.id AND vbm.user_id = viewer.id) AS bookmarked,
+		b.user_id = viewer.id AS "permissions.can_edit",
+		b.user_id = viewer.id AS "permissions.can_delete",
+		vbl.user_id IS NULL AS "permissions.can_comment",
+		vbl.user_id IS NULL AS "permissions.can_react",
+		vbl.user_id IS NULL AS "permissions.can_reblog"`
+
+	viewerBlockJoin = `
+		LEFT JOIN "Block" vbl ON vbl.user_id = b.user_id AND vbl.blocked_user_id = viewer.id`
+
+	// profileViewerJoin binds the viewer of the profileBlobColumns page
+	// queries, which always take it as $4.
+	profileViewerJoin = `
+		CROSS JOIN (SELECT $4::varchar AS id) viewer` + viewerBlockJoin
+
+	// blobReactionCounts and commentReactionCounts build the per-type
+	// counts as a JSON object, scanned by models.ReactionCounts.
+	blobReactionCounts = `
+		(SELECT COALESCE(jsonb_object_agg(rc.type, rc.count), '{}')
+			FROM (SELECT type, COUNT(*) AS count FROM "Reaction"
+				WHERE blob_id = b.id AND comment_id IS NULL GROUP BY type) rc) AS reaction_counts`
+
+	commentReactionCounts = `
+		(SELECT COALESCE(jsonb_object_agg(rc.type, rc.count), '{}')
+			FROM (SELECT type, COUNT(*) AS count FROM "Reaction"
+				WHERE comment_id = c.id GROUP BY type) rc) AS reaction_counts`
+
+	// reblogColumns describe the blob a reblog points at; the original_*
+	// columns are NULL when it expired, was deleted or is hidden.
+	reblogColumns = `
+		(SELECT COUNT(*) FROM "Blob" rb WHERE rb.reblog_of_id = b.id AND rb.hidden_at IS NULL) AS reblogs_count,
+		b.is_reblog,
+		o.id AS original_id,
+		o.user_id AS original_user_id,
+		ou.username AS original_username,
+		ou.avatar_icon AS original_avatar_icon,
+		o.content AS original_content,
+		o.created_at AS original_created_at`
+
+	reblogJoins = `
+		LEFT JOIN "Blob" o ON o.id = b.reblog_of_id AND o.hidden_at IS NULL
+		LEFT JOIN "User" ou ON ou.id = o.user_id`
+
+	listBlobsByUserQuery = `
+		SELECT` + profileBlobColumns + `
+		FROM "Blob" b
+		JOIN "User" u ON u.id = b.user_id` + reblogJoins + profileViewerJoin + `
+		WHERE b.user_id = $1 AND b.hidden_at IS NULL
+		ORDER BY b.created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	countBlobsByUserQuery = `
+		SELECT COUNT(id)
+		FROM "Blob"
+		WHERE user_id = $1 AND hidden_at IS NULL`
+
+	listBlobsLikedByUserQuery = `
+		SELECT` + profileBlobColumns + `
+		FROM "Reaction" lk
+		JOIN "Blob" b ON b.id = lk.blob_id
+		JOIN "User" u ON u.id = b.user_id` + reblogJoins + profileViewerJoin + `
+		WHERE lk.user_id = $1 AND lk.comment_id IS NULL AND lk.type = 'like' AND b.hidden_at IS NULL
+		ORDER BY lk.created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	countBlobsLikedByUserQuery = `
+		SELECT COUNT(lk.id)
+		FROM "Reaction" lk
+		JOIN "Blob" b ON b.id = lk.blob_id
+		WHERE lk.user_id = $1 AND lk.comment_id IS NULL AND lk.type = 'like' AND b.hidden_at IS NULL`
+
+	listCommentsByUserQuery = `
+		SELECT
+			c.id,
+			c.content,
+			c.created_at,
+			c.updated_at,
+			c.user_id,
+			COALESCE(u.image, '') AS image,
+			u.username,
 			u.avatar_icon,
 			u.avatar_color,
-			u.created_at,
-			u.updated_at,
-			b.id AS blob_id,
-			b.content AS blob_content,
-			b.created_at AS blob_created_at,
-			b.updated_at AS blob_updated_at
-		FROM
-			"User" u
-		LEFT JOIN
-			"Blob" b ON b.user_id = u.id
-		WHERE u.username = $1
-		`
+			c.blob_id,` + commentReactionCounts + `
+		FROM "Comment" c
+		JOIN "User" u ON u.id = c.user_id
+		JOIN "Blob" b ON b.id = c.blob_id
+		WHERE c.user_id = $1 AND c.hidden_at IS NULL AND b.hidden_at IS NULL
+		ORDER BY c.created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	countCommentsByUserQuery = `
+		SELECT COUNT(c.id)
+		FROM "Comment" c
+		JOIN "Blob" b ON b.id = c.blob_id
+		WHERE c.user_id = $1 AND c.hidden_at IS NULL AND b.hidden_at IS NULL`
 
 	getUserByEmail = `
 		SELECT 
@@ -97,7 +242,10 @@ const (
 			u.avatar_icon,
 			u.avatar_color,
 			u.created_at,
-			u.updated_at
+			u.updated_at,
+			u.deletion_scheduled_at,
+			u.private_profile,
+			u.hide_likes
 		FROM "User" u
 		WHERE u.email = $1
 		`
@@ -114,46 +262,148 @@ const (
 			u.avatar_icon,
 			u.avatar_color,
 			u.created_at,
-			u.updated_at
+			u.updated_at,
+			u.deletion_scheduled_at,
+			u.private_profile,
+			u.hide_likes
 		FROM "User" u
 		WHERE u.id = $1
 		`
 
+	// scheduleUserDeletionQuery dates the deletion with the database clock,
+	// which the purge job compares against, and keeps an earlier request.
+	scheduleUserDeletionQuery = `
+		UPDATE "User"
+		SET deletion_requested_at = COALESCE(deletion_requested_at, now()),
+			deletion_scheduled_at = COALESCE(deletion_scheduled_at, now() + $1 * interval '1 second')
+		WHERE id = $2
+		RETURNING deletion_scheduled_at`
+
+	cancelUserDeletionQuery = `
+		UPDATE "User"
+		SET deletion_requested_at = NULL,
+			deletion_scheduled_at = NULL
+		WHERE id = $1 AND deletion_scheduled_at IS NOT NULL`
+
+	exportUserBlobsQuery = `
+		SELECT id, user_id, content, created_at, updated_at
+		FROM "Blob"
+		WHERE user_id = $1
+		ORDER BY created_at`
+
+	exportUserCommentsQuery = `
+		SELECT id, content, created_at, updated_at, user_id, blob_id
+		FROM "Comment"
+		WHERE user_id = $1
+		ORDER BY created_at`
+
+	exportUserReactionsQuery = `
+		SELECT id, created_at, user_id, blob_id, comment_id, type
+		FROM "Reaction"
+		WHERE user_id = $1
+		ORDER BY created_at`
+
+	exportUserSessionsQuery = `
+		SELECT id, expires, created_at
+		FROM "Session"
+		WHERE user_id = $1
+		ORDER BY created_at`
+
+	// getReactionTargetQuery returns the author of a visible blob, or of a
+	// visible comment of it when $2 is set.
+	getReactionTargetQuery = `
+		SELECT COALESCE(c.user_id, b.user_id)
+		FROM "Blob" b
+		LEFT JOIN "Comment" c ON c.id = $2 AND c.blob_id = b.id AND c.hidden_at IS NULL
+		WHERE b.id = $1 AND b.hidden_at IS NULL
+			AND ($2::varchar IS NULL OR c.id IS NOT NULL)`
+
+	insertReactionQuery = `
+		INSERT INTO "Reaction" (id, user_id, blob_id, comment_id, type)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at, user_id, blob_id, comment_id, type`
+
+	deleteReactionQuery = `
+		DELETE FROM "Reaction"
+		WHERE user_id = $1
+		AND blob_id = $2
+		AND comment_id IS NOT DISTINCT FROM $3
+		AND type = $4`
+
+	// insertLikeQuery is the idempotent form of insertReactionQuery used by
+	// the like routes; the conflict target matches unique_user_blob_reaction.
 	insertLikeQuery = `
-		INSERT INTO "Like" (id, user_id, blob_id)
-		VALUES ($1, $2, $3)
-		RETURNING id;`
+		INSERT INTO "Reaction" (id, user_id, blob_id, type)
+		VALUES ($1, $2, $3, 'like')
+		ON CONFLICT (user_id, blob_id, type) WHERE comment_id IS NULL DO NOTHING`
 
-	deleteLikeQuery = `
-		DELETE FROM "Like"
-		WHERE id = $1
-		AND user_id = $2
-		AND blob_id = $3
-		RETURNING id;
-	`
+	blobExistsQuery = `
+		SELECT EXISTS (
+			SELECT 1 FROM "Blob" WHERE id = $1 AND hidden_at IS NULL
+		)`
 
-	searchLikeQuery = `
-		SELECT id
-		FROM "Like"
-		WHERE user_id = $1 AND blob_id = $2;
-	`
-	searchLikebyBlobIDQuery = `
-		SELECT 
-			l.id, 
-			l.created_at, 
-			l.user_id, 
-			l.blob_id, 
-			u.image, 
-			u.username, 
-			u.avatar_icon, 
-			u.avatar_color
-		FROM 
-			"Like" l
-		JOIN 
-			"User" u ON l.user_id = u.id
-		WHERE 
-			l.blob_id = $1;
-	`
+	getLikeStateQuery = `
+		SELECT
+			COUNT(*) AS likes_count,
+			COALESCE(BOOL_OR(user_id = $2), false) AS liked_by_me
+		FROM "Reaction"
+		WHERE blob_id = $1 AND comment_id IS NULL AND type = 'like'`
+
+	likeVisibleCondition = `
+		r.blob_id = $1 AND r.comment_id IS NULL AND r.type = 'like'
+			AND NOT EXISTS (
+				SELECT 1 FROM "Mute" m WHERE m.user_id = $2 AND m.muted_user_id = r.user_id
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM "Block" bl WHERE bl.user_id = $2 AND bl.blocked_user_id = r.user_id
+			)`
+
+	listLikesQuery = `
+		SELECT
+			r.id,
+			r.created_at,
+			r.user_id,
+			r.blob_id,
+			r.comment_id,
+			r.type,
+			COALESCE(u.image, '') AS image,
+			u.username,
+			COALESCE(u.avatar_icon, 'user') AS avatar_icon,
+			COALESCE(u.avatar_color, 'cyan') AS avatar_color
+		FROM "Reaction" r
+		JOIN "User" u ON r.user_id = u.id
+		WHERE` + likeVisibleCondition + `
+		ORDER BY r.created_at, r.id
+		LIMIT $3 OFFSET $4`
+
+	countLikesQuery = `
+		SELECT COUNT(*)
+		FROM "Reaction" r
+		WHERE` + likeVisibleCondition
+
+	listReactionsQuery = `
+		SELECT
+			r.id,
+			r.created_at,
+			r.user_id,
+			r.blob_id,
+			r.comment_id,
+			r.type,
+			COALESCE(u.image, '') AS image,
+			u.username,
+			COALESCE(u.avatar_icon, 'user') AS avatar_icon,
+			COALESCE(u.avatar_color, 'cyan') AS avatar_color
+		FROM "Reaction" r
+		JOIN "User" u ON r.user_id = u.id
+		WHERE r.blob_id = $1
+			AND r.comment_id IS NOT DISTINCT FROM $2
+			AND NOT EXISTS (
+				SELECT 1 FROM "Mute" m WHERE m.user_id = $3 AND m.muted_user_id = r.user_id
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM "Block" bl WHERE bl.user_id = $3 AND bl.blocked_user_id = r.user_id
+			)
+		ORDER BY r.created_at`
 
 	insertCommentQuery = `
 		INSERT INTO "Comment" (id, content, user_id, blob_id)
@@ -164,8 +414,26 @@ const (
 	insertBlobInterest = `
 		INSERT INTO "_BlobToInterest" (blob_id, interest_id)
 		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`
+
+	deleteBlobInterestsQuery = `
+		DELETE FROM "_BlobToInterest"
+		WHERE blob_id = $1
 	`
 
+	findInterestByNameQuery = `
+		SELECT id
+		FROM "Interest"
+		WHERE lower(name) = lower($1)
+		LIMIT 1`
+
+	insertInterestQuery = `
+		INSERT INTO "Interest" (id, name)
+		VALUES ($1, $2)
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id`
+
 	searchCommentsbyBlobIDQuery = `
 	SELECT 
 		c.id, 
@@ -177,18 +445,514 @@ const (
 		u.username, 
 		u.avatar_icon, 
 		u.avatar_color, 
-		c.blob_id
+		c.blob_id,` + commentReactionCounts + `
 	FROM 
 		"Comment" c
 	JOIN 
 		"User" u ON c.user_id = u.id
 	WHERE 
-		c.blob_id = $1;
+		c.blob_id = $1 AND c.hidden_at IS NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM "Mute" m WHERE m.user_id = $2 AND m.muted_user_id = c.user_id
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM "Block" bl WHERE bl.user_id = $2 AND bl.blocked_user_id = c.user_id
+		)
+	ORDER BY c.created_at, c.id
+	LIMIT $3 OFFSET $4
 	`
 
+	countCommentsByBlobIDQuery = `
+		SELECT COUNT(*)
+		FROM "Comment" c
+		WHERE c.blob_id = $1 AND c.hidden_at IS NULL
+			AND NOT EXISTS (
+				SELECT 1 FROM "Mute" m WHERE m.user_id = $2 AND m.muted_user_id = c.user_id
+			)
+			AND NOT EXISTS (
+				SELECT 1 FROM "Block" bl WHERE bl.user_id = $2 AND bl.blocked_user_id = c.user_id
+			)`
+
+	updateCommentQuery = `
+		UPDATE "Comment"
+		SET content = $1,
+			updated_at = now()
+		WHERE id = $2 AND user_id = $3 AND blob_id = $4
+		RETURNING id, content, created_at, updated_at, user_id, blob_id`
+
+	getUsersByUsernamesQuery = `
+		SELECT id, username
+		FROM "User"
+		WHERE lower(username) = ANY($1)`
+
+	insertMentionQuery = `
+		INSERT INTO "Mention" (id, blob_id, comment_id, author_id, mentioned_user_id)
+		VALUES ($1, $2, $3, $4, $5)`
+
+	deleteBlobMentionsQuery = `
+		DELETE FROM "Mention"
+		WHERE blob_id = $1 AND comment_id IS NULL`
+
+	deleteCommentMentionsQuery = `
+		DELETE FROM "Mention"
+		WHERE comment_id = $1`
+
+	// mentionVisibleCondition leaves out hidden blobs and comments, and the
+	// mentions made by authors the user muted or blocked.
+	mentionVisibleCondition = `
+		m.mentioned_user_id = $1
+		AND b.hidden_at IS NULL
+		AND c.hidden_at IS NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM "Mute" mu WHERE mu.user_id = $1 AND mu.muted_user_id = m.author_id
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM "Block" bl WHERE bl.user_id = $1 AND bl.blocked_user_id = m.author_id
+		)`
+
+	listMentionsByUserQuery = `
+		SELECT
+			m.id,
+			m.blob_id,
+			m.comment_id,
+			m.author_id,
+			u.username AS author_username,
+			u.avatar_icon AS author_avatar_icon,
+			u.avatar_color AS author_avatar_color,
+			COALESCE(c.content, b.content) AS content,
+			m.created_at
+		FROM "Mention" m
+		JOIN "User" u ON u.id = m.author_id
+		JOIN "Blob" b ON b.id = m.blob_id
+		LEFT JOIN "Comment" c ON c.id = m.comment_id
+		WHERE` + mentionVisibleCondition + `
+		ORDER BY m.created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	countMentionsByUserQuery = `
+		SELECT COUNT(*)
+		FROM "Mention" m
+		JOIN "Blob" b ON b.id = m.blob_id
+		LEFT JOIN "Comment" c ON c.id = m.comment_id
+		WHERE` + mentionVisibleCondition
+
+	insertBlockQuery = `
+		INSERT INTO "Block" (user_id, blocked_user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`
+
+	deleteBlockQuery = `
+		DELETE FROM "Block"
+		WHERE user_id = $1 AND blocked_user_id = $2`
+
+	insertMuteQuery = `
+		INSERT INTO "Mute" (user_id, muted_user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`
+
+	deleteMuteQuery = `
+		DELETE FROM "Mute"
+		WHERE user_id = $1 AND muted_user_id = $2`
+
+	insertFollowQuery = `
+		INSERT INTO "Follow" (user_id, followed_user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`
+
+	deleteFollowQuery = `
+		DELETE FROM "Follow"
+		WHERE user_id = $1 AND followed_user_id = $2`
+
+	deleteFollowsBetweenQuery = `
+		DELETE FROM "Follow"
+		WHERE (user_id = $1 AND followed_user_id = $2)
+			OR (user_id = $2 AND followed_user_id = $1)`
+
+	listBlockedUsersQuery = `
+		SELECT u.id, u.username, u.avatar_icon, u.avatar_color, bl.created_at
+		FROM "Block" bl
+		JOIN "User" u ON u.id = bl.blocked_user_id
+		WHERE bl.user_id = $1
+		ORDER BY bl.created_at DESC`
+
+	listMutedUsersQuery = `
+		SELECT u.id, u.username, u.avatar_icon, u.avatar_color, m.created_at
+		FROM "Mute" m
+		JOIN "User" u ON u.id = m.muted_user_id
+		WHERE m.user_id = $1
+		ORDER BY m.created_at DESC`
+
+	isBlockedByQuery = `
+		SELECT EXISTS (
+			SELECT 1 FROM "Block"
+			WHERE user_id = $1 AND blocked_user_id = $2
+		)`
+
+	listBlockersAmongQuery = `
+		SELECT user_id
+		FROM "Block"
+		WHERE blocked_user_id = $1 AND user_id = ANY($2)`
+
+	reportColumns = `
+		id, reporter_id, blob_id, comment_id, target_user_id, content, reason, details,
+		source, status, assignee_id, action, resolution_note, created_at, updated_at, resolved_at`
+
+	getBlobReportTargetQuery = `
+		SELECT user_id, content
+		FROM "Blob"
+		WHERE id = $1`
+
+	getCommentReportTargetQuery = `
+		SELECT user_id, content
+		FROM "Comment"
+		WHERE id = $1 AND blob_id = $2`
+
+	openReportExistsQuery = `
+		SELECT EXISTS (
+			SELECT 1 FROM "Report"
+			WHERE reporter_id = $1
+				AND blob_id = $2
+				AND comment_id IS NOT DISTINCT FROM $3
+				AND status IN ('open', 'assigned')
+		)`
+
+	insertReportQuery = `
+		INSERT INTO "Report" (id, reporter_id, blob_id, comment_id, target_user_id, content, reason, details, source)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING` + reportColumns
+
+	getReportQuery = `
+		SELECT` + reportColumns + `
+		FROM "Report"
+		WHERE id = $1`
+
+	listReportsQuery = `
+		SELECT` + reportColumns + `
+		FROM "Report"
+		WHERE $1 = '' OR status = $1
+		ORDER BY created_at ASC
+		LIMIT $2 OFFSET $3`
+
+	countReportsQuery = `
+		SELECT COUNT(id)
+		FROM "Report"
+		WHERE $1 = '' OR status = $1`
+
+	isModeratorQuery = `
+		SELECT EXISTS (
+			SELECT 1 FROM "User"
+			WHERE id = $1
+				AND role IN ('moderator', 'admin')
+				AND banned_at IS NULL
+		)`
+
+	assignReportQuery = `
+		UPDATE "Report"
+		SET assignee_id = $1,
+			status = 'assigned',
+			updated_at = now()
+		WHERE id = $2 AND status IN ('open', 'assigned')
+		RETURNING` + reportColumns
+
+	resolveReportQuery = `
+		UPDATE "Report"
+		SET status = $1,
+			action = $2,
+			resolution_note = NULLIF($3, ''),
+			assignee_id = COALESCE(assignee_id, $4),
+			resolved_at = now(),
+			updated_at = now()
+		WHERE id = $5 AND status IN ('open', 'assigned')
+		RETURNING` + reportColumns
+
+	setBlobHiddenQuery = `
+		UPDATE "Blob"
+		SET hidden_at = CASE WHEN $1 THEN now() END
+		WHERE id = $2`
+
+	setCommentHiddenQuery = `
+		UPDATE "Comment"
+		SET hidden_at = CASE WHEN $1 THEN now() END
+		WHERE id = $2`
+
+	moderatorDeleteCommentQuery = `
+		DELETE FROM "Comment"
+		WHERE id = $1`
+
+	banUserQuery = `
+		UPDATE "User"
+		SET banned_at = now()
+		WHERE id = $1`
+
+	deleteUserSessionsQuery = `
+		DELETE FROM "Session"
+		WHERE user_id = $1`
+
+	insertAuditLogQuery = `
+		INSERT INTO "ModerationAuditLog" (id, moderator_id, action, report_id, target_type, target_id, details)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	insertWarningQuery = `
+		INSERT INTO "Warning" (id, user_id, report_id, moderator_id, reason, note)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	listWarningsByUserQuery = `
+		SELECT id, report_id, reason, note, created_at
+		FROM "Warning"
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	listAuditLogQuery = `
+		SELECT id, moderator_id, action, report_id, target_type, target_id, details, created_at
+		FROM "ModerationAuditLog"
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2`
+
 	deleteCommentQuery = `
 		DELETE FROM "Comment"
 		WHERE id = $1 AND user_id = $2
 		RETURNING id;
 		`
+
+	mediaColumns = `
+		id, user_id, blob_id, content_type, size, width, height,
+		storage_key, thumbnail_key, created_at, expires_at`
+
+	insertMediaQuery = `
+		INSERT INTO "Media" (id, user_id, content_type, size, width, height, storage_key, thumbnail_key, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING` + mediaColumns
+
+	countAttachableMediaQuery = `
+		SELECT COUNT(id)
+		FROM "Media"
+		WHERE id = ANY($1)
+			AND user_id = $2
+			AND (blob_id = $3 OR (blob_id IS NULL AND expires_at > now()))`
+
+	detachBlobMediaQuery = `
+		UPDATE "Media"
+		SET blob_id = NULL,
+			expires_at = now()
+		WHERE blob_id = $1 AND NOT (id = ANY($2))`
+
+	attachBlobMediaQuery = `
+		UPDATE "Media"
+		SET blob_id = $1,
+			expires_at = NULL
+		WHERE id = ANY($2)
+			AND user_id = $3
+			AND (blob_id = $1 OR (blob_id IS NULL AND expires_at > now()))`
+
+	listMediaByBlobsQuery = `
+		SELECT` + mediaColumns + `
+		FROM "Media"
+		WHERE blob_id = ANY($1)
+		ORDER BY created_at`
+
+	deleteExpiredMediaQuery = `
+		DELETE FROM "Media"
+		WHERE id IN (
+			SELECT id FROM "Media"
+			WHERE expires_at < now() OR user_id IS NULL
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING` + mediaColumns
+
+	enqueueLinkPreviewsQuery = `
+		INSERT INTO "LinkPreview" (url)
+		SELECT unnest($1::text[])
+		ON CONFLICT (url) DO UPDATE
+		SET status = 'pending',
+			attempts = 0,
+			next_attempt_at = now()
+		WHERE "LinkPreview".status IN ('ready', 'failed')
+			AND "LinkPreview".fetched_at < now() - interval '7 days'`
+
+	claimLinkPreviewsQuery = `
+		UPDATE "LinkPreview"
+		SET status = 'fetching',
+			attempts = attempts + 1,
+			locked_until = now() + interval '2 minutes'
+		WHERE url IN (
+			SELECT url FROM "LinkPreview"
+			WHERE (status = 'pending' AND next_attempt_at <= now())
+				OR (status = 'fetching' AND locked_until < now())
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING url, attempts`
+
+	completeLinkPreviewQuery = `
+		UPDATE "LinkPreview"
+		SET status = 'ready',
+			title = $2,
+			description = $3,
+			image_url = $4,
+			site_name = $5,
+			error = '',
+			locked_until = NULL,
+			fetched_at = now()
+		WHERE url = $1`
+
+	failLinkPreviewQuery = `
+		UPDATE "LinkPreview"
+		SET status = CASE WHEN attempts >= $2 THEN 'failed' ELSE 'pending' END,
+			error = $3,
+			next_attempt_at = now() + $4 * interval '1 second',
+			locked_until = NULL,
+			fetched_at = now()
+		WHERE url = $1`
+
+	listLinkPreviewsQuery = `
+		SELECT url, title, description, image_url, site_name
+		FROM "LinkPreview"
+		WHERE url = ANY($1) AND status = 'ready'`
+
+	getBookmarkTargetQuery = `
+		SELECT user_id
+		FROM "Blob"
+		WHERE id = $1 AND hidden_at IS NULL`
+
+	insertBookmarkQuery = `
+		INSERT INTO "Bookmark" (user_id, blob_id, author_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, blob_id) DO NOTHING`
+
+	deleteBookmarkQuery = `
+		DELETE FROM "Bookmark"
+		WHERE user_id = $1 AND blob_id = $2`
+
+	bookmarkVisibleCondition = `
+		bm.user_id = $1 AND (b.id IS NOT NULL OR s.id IS NOT NULL)`
+
+	listBookmarksQuery = `
+		SELECT
+			bm.blob_id,
+			bm.created_at AS bookmarked_at,
+			b.id IS NULL AS expired,
+			bm.author_id AS user_id,
+			u.username,
+			COALESCE(u.avatar_icon, 'user') AS avatar_icon,
+			COALESCE(b.content, s.content) AS content,
+			COALESCE(b.created_at, s.blob_created_at) AS blob_created_at,
+			CASE WHEN b.id IS NULL THEN s.interests ELSE ARRAY(
+				SELECT i.name FROM "_BlobToInterest" bi
+				JOIN "Interest" i ON i.id = bi.interest_id
+				WHERE bi.blob_id = b.id
+				ORDER BY i.name
+			) END AS interests
+		FROM "Bookmark" bm
+		JOIN "User" u ON u.id = bm.author_id
+		LEFT JOIN "Blob" b ON b.id = bm.blob_id AND b.hidden_at IS NULL
+		LEFT JOIN "BlobSnapshot" s ON s.id = bm.blob_id
+		WHERE` + bookmarkVisibleCondition + `
+		ORDER BY bm.created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	countBookmarksQuery = `
+		SELECT COUNT(*)
+		FROM "Bookmark" bm
+		LEFT JOIN "Blob" b ON b.id = bm.blob_id AND b.hidden_at IS NULL
+		LEFT JOIN "BlobSnapshot" s ON s.id = bm.blob_id
+		WHERE` + bookmarkVisibleCondition
+
+	getUserStatsQuery = `
+		SELECT
+			(SELECT COUNT(*) FROM "Blob" b WHERE b.user_id = $1) AS blobs_count,
+			(SELECT COUNT(*) FROM "Reaction" l JOIN "Blob" b ON b.id = l.blob_id
+				WHERE b.user_id = $1 AND l.comment_id IS NULL AND l.type = 'like') AS likes_received,
+			(SELECT COUNT(*) FROM "Comment" c JOIN "Blob" b ON b.id = c.blob_id
+				WHERE b.user_id = $1 AND c.hidden_at IS NULL) AS comments_received,
+			(SELECT COUNT(*) FROM "Blob" rb JOIN "Blob" b ON b.id = rb.reblog_of_id
+				WHERE b.user_id = $1 AND rb.hidden_at IS NULL) AS reblogs_received,
+			(SELECT COUNT(*) FROM "Bookmark" bm WHERE bm.author_id = $1) AS bookmarks_received,
+			(SELECT COUNT(*) FROM "Bookmark" bm WHERE bm.user_id = $1) AS bookmarks_saved`
+
+	// getReblogTargetQuery resolves what a reblog of $1 points at: reblogging
+	// a plain reblog reblogs its original instead.
+	getReblogTargetQuery = `
+		SELECT o.id, o.user_id
+		FROM "Blob" b
+		JOIN "Blob" o ON o.id = CASE WHEN b.is_reblog AND b.content = '' THEN b.reblog_of_id ELSE b.id END
+		WHERE b.id = $1 AND b.hidden_at IS NULL AND o.hidden_at IS NULL`
+
+	getBlobAuthorQuery = `
+		SELECT user_id
+		FROM "Blob"
+		WHERE id = $1 AND hidden_at IS NULL`
+
+	getReblogOriginalQuery = `
+		SELECT
+			o.id,
+			o.user_id,
+			u.username,
+			COALESCE(u.avatar_icon, 'user') AS avatar_icon,
+			o.content,
+			o.created_at
+		FROM "Blob" o
+		JOIN "User" u ON u.id = o.user_id
+		WHERE o.id = $1 AND o.hidden_at IS NULL`
+
+	// feedCondition leaves out the blobs of muted or blocked authors, and
+	// reblogs of their blobs by the people the viewer follows.
+	feedCondition = `
+		b.hidden_at IS NULL
+		AND (b.user_id = $1 OR EXISTS (
+			SELECT 1 FROM "Follow" f WHERE f.user_id = $1 AND f.followed_user_id = b.user_id
+		))
+		AND NOT EXISTS (
+			SELECT 1 FROM "Mute" m WHERE m.user_id = $1 AND m.muted_user_id IN (b.user_id, o.user_id)
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM "Block" bl WHERE bl.user_id = $1 AND bl.blocked_user_id IN (b.user_id, o.user_id)
+		)`
+
+	listFollowingFeedQuery = `
+		SELECT` + profileBlobColumns + `
+		FROM "Blob" b
+		JOIN "User" u ON u.id = b.user_id` + reblogJoins + profileViewerJoin + `
+		WHERE` + feedCondition + `
+		ORDER BY b.created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	countFollowingFeedQuery = `
+		SELECT COUNT(b.id)
+		FROM "Blob" b` + reblogJoins + `
+		WHERE` + feedCondition
+
+	// trendingScore ranks blobs by the stored counters, so trending reads
+	// idx_blob_trending instead of counting likes and comments.
+	trendingScore = `(b.likes_count + 2 * b.comments_count)`
+
+	trendingCondition = `
+		b.hidden_at IS NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM "Mute" m WHERE m.user_id = $1 AND m.muted_user_id = b.user_id
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM "Block" bl WHERE bl.user_id = $1 AND bl.blocked_user_id = b.user_id
+		)`
+
+	listTrendingBlobsQuery = `
+		SELECT` + profileBlobColumns + `
+		FROM "Blob" b
+		JOIN "User" u ON u.id = b.user_id` + reblogJoins + profileViewerJoin + `
+		WHERE` + trendingCondition + `
+		ORDER BY ` + trendingScore + ` DESC, b.created_at DESC
+		LIMIT $2 OFFSET $3`
+
+	countTrendingBlobsQuery = `
+		SELECT COUNT(b.id)
+		FROM "Blob" b
+		WHERE` + trendingCondition
+
+	listBlobViewerStatesQuery = `
+		SELECT b.id,` + viewerColumns + `
+		FROM "Blob" b
+		CROSS JOIN (SELECT $2::varchar AS id) viewer` + viewerBlockJoin + `
+		WHERE b.id = ANY($1) AND b.hidden_at IS NULL`
 )
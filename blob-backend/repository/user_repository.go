@@ -4,15 +4,20 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
-	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
 	"github.com/joaoleau/blob/models"
+	"github.com/lib/pq"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 )
 
+// ErrUsernameTaken is returned when another account already uses the
+// username, in any case.
+var ErrUsernameTaken = errors.New("username already taken")
+
 type UserRepo struct {
 	db *sqlx.DB
 }
@@ -21,69 +26,109 @@ func NewUserRepository(db *sqlx.DB) *UserRepo {
 	return &UserRepo{db: db}
 }
 
-func (r *UserRepo) GetByUsername(ctx context.Context, username string) (*models.UserWithBlobs, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "UserRepo.GetByUsername")
+// GetProfile returns the public profile of the user with the given username,
+// seen from viewerID. A nil profile means no active user has that name.
+func (r *UserRepo) GetProfile(ctx context.Context, username, viewerID string) (*models.PublicProfile, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserRepo.GetProfile")
 	defer span.Finish()
 
-	type Row struct {
-		ID             string    `db:"id"`
-		Name           string    `db:"name"`
-		Email          string    `db:"email"`
-		EmailVerified  time.Time `db:"email_verified"`
-		Image          string    `db:"image"`
-		Username       string    `db:"username"`
-		Bio            string    `db:"bio"`
-		AvatarIcon     string    `db:"avatar_icon"`
-		AvatarColor    string    `db:"avatar_color"`
-		CreatedAt      time.Time `db:"created_at"`
-		UpdatedAt      time.Time `db:"updated_at"`
-		BlobID         *uuid.UUID   `db:"blob_id"`
-		BlobContent    *string   `db:"blob_content"`
-		BlobCreatedAt  *time.Time `db:"blob_created_at"`
-		BlobUpdatedAt  *time.Time `db:"blob_updated_at"`
-	}
-
-	var rows []Row
-
-	if err := r.db.SelectContext(ctx, &rows, listUserByUsernameWithBlobsQuery, username); err != nil {
+	profile := &models.PublicProfile{}
+	if err := r.db.GetContext(ctx, profile, getPublicProfileQuery, username, viewerID); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
 		}
-		return nil, errors.Wrap(err, "UserRepo.GetByUsername.SelectContext")
-	}
-
-	if len(rows) == 0 {
-		return nil, nil
-	}
-
-	userWithBlobs := &models.UserWithBlobs{
-		ID:            rows[0].ID,
-		Name:          rows[0].Name,
-		Email:         rows[0].Email,
-		EmailVerified: rows[0].EmailVerified,
-		Image:         rows[0].Image,
-		Username:      rows[0].Username,
-		Bio:           rows[0].Bio,
-		AvatarIcon:    rows[0].AvatarIcon,
-		AvatarColor:   rows[0].AvatarColor,
-		CreatedAt:     rows[0].CreatedAt,
-		UpdatedAt:     rows[0].UpdatedAt,
-		Blobs:         []models.Blob{},
-	}
-
-	for _, row := range rows {
-		if row.BlobID != nil {
-			blob := models.Blob{
-				ID:        *row.BlobID,
-				Content:   *row.BlobContent,
-				CreatedAt: *row.BlobCreatedAt,
-				UpdatedAt: *row.BlobUpdatedAt,
-			}
-			userWithBlobs.Blobs = append(userWithBlobs.Blobs, blob)
+		return nil, errors.Wrap(err, "UserRepo.GetProfile.GetContext")
+	}
+	return profile, nil
+}
+
+// GetRenamedUsername returns the current username of the user who used to be
+// called username, or an empty string when nobody was.
+func (r *UserRepo) GetRenamedUsername(ctx context.Context, username string) (string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserRepo.GetRenamedUsername")
+	defer span.Finish()
+
+	var current string
+	if err := r.db.GetContext(ctx, &current, getRenamedUsernameQuery, username); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
 		}
+		return "", errors.Wrap(err, "UserRepo.GetRenamedUsername.GetContext")
 	}
+	return current, nil
+}
 
-	return userWithBlobs, nil
+// profileBlobRow scans the interests array and the reblogged original that
+// BlobListWithDetails has no columns for.
+type profileBlobRow struct {
+	models.BlobListWithDetails
+	reblogRow
+	Interests pq.StringArray `db:"interests"`
+}
+
+func (r *UserRepo) ListBlobs(ctx context.Context, userID, viewerID string, limit, offset int) ([]*models.BlobListWithDetails, int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserRepo.ListBlobs")
+	defer span.Finish()
+
+	blobs, total, err := r.listProfileBlobs(ctx, countBlobsByUserQuery, listBlobsByUserQuery, userID, viewerID, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "UserRepo.ListBlobs")
+	}
+	return blobs, total, nil
+}
+
+func (r *UserRepo) ListLikedBlobs(ctx context.Context, userID, viewerID string, limit, offset int) ([]*models.BlobListWithDetails, int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserRepo.ListLikedBlobs")
+	defer span.Finish()
+
+	blobs, total, err := r.listProfileBlobs(ctx, countBlobsLikedByUserQuery, listBlobsLikedByUserQuery, userID, viewerID, limit, offset)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "UserRepo.ListLikedBlobs")
+	}
+	return blobs, total, nil
+}
+
+func (r *UserRepo) listProfileBlobs(ctx context.Context, countQuery, listQuery, userID, viewerID string, limit, offset int) ([]*models.BlobListWithDetails, int, error) {
+	return listBlobPage(ctx, r.db, countQuery, listQuery, userID, viewerID, limit, offset)
+}
+
+// listBlobPage runs a count and a page query that select profileBlobColumns
+// for the given user, as seen by viewerID.
+func listBlobPage(ctx context.Context, db *sqlx.DB, countQuery, listQuery, userID, viewerID string, limit, offset int) ([]*models.BlobListWithDetails, int, error) {
+	var total int
+	if err := db.GetContext(ctx, &total, countQuery, userID); err != nil {
+		return nil, 0, errors.Wrap(err, "count")
+	}
+
+	var rows []profileBlobRow
+	if err := db.SelectContext(ctx, &rows, listQuery, userID, limit, offset, viewerID); err != nil {
+		return nil, 0, errors.Wrap(err, "SelectContext")
+	}
+
+	blobs := make([]*models.BlobListWithDetails, 0, len(rows))
+	for i := range rows {
+		blob := rows[i].BlobListWithDetails
+		blob.Interests = []string(rows[i].Interests)
+		blob.ReblogOf = rows[i].reblogOf(blob.IsReblog)
+		blobs = append(blobs, &blob)
+	}
+	return blobs, total, nil
+}
+
+func (r *UserRepo) ListComments(ctx context.Context, userID string, limit, offset int) ([]models.CommentWithUser, int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserRepo.ListComments")
+	defer span.Finish()
+
+	var total int
+	if err := r.db.GetContext(ctx, &total, countCommentsByUserQuery, userID); err != nil {
+		return nil, 0, errors.Wrap(err, "UserRepo.ListComments.count")
+	}
+
+	comments := []models.CommentWithUser{}
+	if err := r.db.SelectContext(ctx, &comments, listCommentsByUserQuery, userID, limit, offset); err != nil {
+		return nil, 0, errors.Wrap(err, "UserRepo.ListComments.SelectContext")
+	}
+	return comments, total, nil
 }
 
 func (r *UserRepo) GetUserById(ctx context.Context, userID string) (*models.User, error) {
@@ -116,14 +161,116 @@ func (r *UserRepo) GetByEmail(ctx context.Context, email string) (*models.User,
 	return user, nil
 }
 
-func (r *UserRepo) UpdateUser(ctx context.Context, userID string, updatedData models.User) error {
+func (r *UserRepo) GetByUsernames(ctx context.Context, usernames []string) ([]models.User, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserRepo.GetByUsernames")
+	defer span.Finish()
+
+	var users []models.User
+	if len(usernames) == 0 {
+		return users, nil
+	}
+
+	if err := r.db.SelectContext(ctx, &users, getUsersByUsernamesQuery, pq.Array(usernames)); err != nil {
+		return nil, errors.Wrap(err, "UserRepo.GetByUsernames.SelectContext")
+	}
+	return users, nil
+}
+
+// ScheduleDeletion marks the account for deletion once grace has passed,
+// keeping the date of an earlier request, and revokes every session so the
+// user is signed out everywhere. It returns the deletion date.
+func (r *UserRepo) ScheduleDeletion(ctx context.Context, userID string, grace time.Duration) (time.Time, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserRepo.ScheduleDeletion")
+	defer span.Finish()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "UserRepo.ScheduleDeletion.BeginTxx")
+	}
+	defer tx.Rollback()
+
+	var scheduledAt time.Time
+	if err := tx.GetContext(ctx, &scheduledAt, scheduleUserDeletionQuery, grace.Seconds(), userID); err != nil {
+		return time.Time{}, errors.Wrap(err, "UserRepo.ScheduleDeletion.schedule")
+	}
+	if _, err := tx.ExecContext(ctx, deleteUserSessionsQuery, userID); err != nil {
+		return time.Time{}, errors.Wrap(err, "UserRepo.ScheduleDeletion.deleteSessions")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return time.Time{}, errors.Wrap(err, "UserRepo.ScheduleDeletion.Commit")
+	}
+	return scheduledAt, nil
+}
+
+// CancelDeletion reports whether a pending deletion was cancelled.
+func (r *UserRepo) CancelDeletion(ctx context.Context, userID string) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserRepo.CancelDeletion")
+	defer span.Finish()
+
+	result, err := r.db.ExecContext(ctx, cancelUserDeletionQuery, userID)
+	if err != nil {
+		return false, errors.Wrap(err, "UserRepo.CancelDeletion.ExecContext")
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "UserRepo.CancelDeletion.RowsAffected")
+	}
+	return rows > 0, nil
+}
+
+func (r *UserRepo) GetStats(ctx context.Context, userID string) (*models.UserStats, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserRepo.GetStats")
+	defer span.Finish()
+
+	stats := &models.UserStats{}
+	if err := r.db.GetContext(ctx, stats, getUserStatsQuery, userID); err != nil {
+		return nil, errors.Wrap(err, "UserRepo.GetStats.GetContext")
+	}
+	return stats, nil
+}
+
+// Export collects everything stored about the user, except secrets such as
+// passwords and session tokens.
+func (r *UserRepo) Export(ctx context.Context, user *models.User) (*models.UserExport, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserRepo.Export")
+	defer span.Finish()
+
+	export := &models.UserExport{
+		ExportedAt: time.Now().UTC(),
+		Profile:    user,
+		Blobs:      []models.Blob{},
+		Comments:   []models.Comment{},
+		Reactions:  []models.Reaction{},
+		Sessions:   []models.Session{},
+	}
+
+	if err := r.db.SelectContext(ctx, &export.Blobs, exportUserBlobsQuery, user.ID); err != nil {
+		return nil, errors.Wrap(err, "UserRepo.Export.blobs")
+	}
+	if err := r.db.SelectContext(ctx, &export.Comments, exportUserCommentsQuery, user.ID); err != nil {
+		return nil, errors.Wrap(err, "UserRepo.Export.comments")
+	}
+	if err := r.db.SelectContext(ctx, &export.Reactions, exportUserReactionsQuery, user.ID); err != nil {
+		return nil, errors.Wrap(err, "UserRepo.Export.reactions")
+	}
+	if err := r.db.SelectContext(ctx, &export.Sessions, exportUserSessionsQuery, user.ID); err != nil {
+		return nil, errors.Wrap(err, "UserRepo.Export.sessions")
+	}
+
+	return export, nil
+}
+
+// UpdateUser applies the non-empty fields of updatedData to user. A username
+// change also records the old name so profile links keep redirecting to it.
+func (r *UserRepo) UpdateUser(ctx context.Context, user *models.User, updatedData models.User) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "UserRepo.UpdateUser")
 	defer span.Finish()
 
 	query := `UPDATE "User" SET`
 	var args []interface{}
 	argIndex := 1
-	var oldEmail string
 	
 	if updatedData.Name != "" {
 		query += ` name = $` + fmt.Sprintf("%d", argIndex) + `,`
@@ -131,11 +278,15 @@ func (r *UserRepo) UpdateUser(ctx context.Context, userID string, updatedData mo
 		argIndex++
 	}
 	if updatedData.Email != "" {
-		oldEmail = ctx.Value("email").(string)
 		query += ` email = $` + fmt.Sprintf("%d", argIndex) + `,`
 		args = append(args, updatedData.Email)
 		argIndex++
 	}
+	if updatedData.Username != "" {
+		query += ` username = $` + fmt.Sprintf("%d", argIndex) + `,`
+		args = append(args, updatedData.Username)
+		argIndex++
+	}
 	if updatedData.Bio != "" {
 		query += ` bio = $` + fmt.Sprintf("%d", argIndex) + `,`
 		args = append(args, updatedData.Bio)
@@ -156,19 +307,57 @@ func (r *UserRepo) UpdateUser(ctx context.Context, userID string, updatedData mo
 		args = append(args, updatedData.AvatarColor)
 		argIndex++
 	}
+	if updatedData.PrivateProfile != nil {
+		query += ` private_profile = $` + fmt.Sprintf("%d", argIndex) + `,`
+		args = append(args, *updatedData.PrivateProfile)
+		argIndex++
+	}
+	if updatedData.HideLikes != nil {
+		query += ` hide_likes = $` + fmt.Sprintf("%d", argIndex) + `,`
+		args = append(args, *updatedData.HideLikes)
+		argIndex++
+	}
 
-	query = query[:len(query)-1]
+	if len(args) == 0 {
+		return nil
+	}
+
+	query += ` updated_at = now()`
 	query += ` WHERE id = $` + fmt.Sprintf("%d", argIndex)
-	args = append(args, userID)
+	args = append(args, user.ID)
 
-	_, err := r.db.ExecContext(ctx, query, args...)
+	tx, err := r.db.BeginTxx(ctx, nil)
 	if err != nil {
+		return errors.Wrap(err, "UserRepo.UpdateUser.BeginTxx")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		if isUniqueViolation(err, "unique_user_username_lower") || isUniqueViolation(err, "User_username_key") {
+			return ErrUsernameTaken
+		}
 		return errors.Wrap(err, "UserRepo.UpdateUser.ExecContext")
 	}
 
-	if oldEmail != updatedData.Email {
+	if updatedData.Username != "" && updatedData.Username != user.Username {
+		// The new name stops redirecting to whoever used it before.
+		if _, err := tx.ExecContext(ctx, deleteUsernameHistoryQuery, updatedData.Username); err != nil {
+			return errors.Wrap(err, "UserRepo.UpdateUser.deleteUsernameHistory")
+		}
+		if user.Username != "" && !strings.EqualFold(updatedData.Username, user.Username) {
+			if _, err := tx.ExecContext(ctx, insertUsernameHistoryQuery, user.Username, user.ID); err != nil {
+				return errors.Wrap(err, "UserRepo.UpdateUser.insertUsernameHistory")
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "UserRepo.UpdateUser.Commit")
+	}
+
+	if updatedData.Email != "" && updatedData.Email != user.Email {
 		deleteQuery := `DELETE FROM "VerificationToken" WHERE email = $1`
-		_, err := r.db.ExecContext(ctx, deleteQuery, oldEmail)
+		_, err := r.db.ExecContext(ctx, deleteQuery, user.Email)
 		if err != nil {
 			return errors.Wrap(err, "UserRepo.UpdateUser.ExecContext: deleting verification token")
 		}
@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/pgtest"
+)
+
+func TestUpdateUserRefusesUsernameInAnotherCase(t *testing.T) {
+	db, _ := pgtest.New(t)
+	repo := NewUserRepository(db)
+	addUsers(t, db, "ana", "bob")
+
+	bob := &models.User{ID: "bob", Username: "bob"}
+	if err := repo.UpdateUser(context.Background(), bob, models.User{Username: "ANA"}); !errors.Is(err, ErrUsernameTaken) {
+		t.Errorf("UpdateUser = %v, want ErrUsernameTaken", err)
+	}
+	if err := repo.UpdateUser(context.Background(), bob, models.User{Username: "Bobby"}); err != nil {
+		t.Errorf("UpdateUser = %v", err)
+	}
+}
@@ -0,0 +1,239 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/pgtest"
+)
+
+// follow makes each pair of users, follower first, follow one another.
+func follow(tb testing.TB, db *sqlx.DB, pairs ...[2]string) {
+	tb.Helper()
+	for _, pair := range pairs {
+		exec(tb, db, `INSERT INTO "Follow" (user_id, followed_user_id) VALUES ($1, $2)`, pair[0], pair[1])
+	}
+}
+
+func containsBlob(blobs []*models.BlobListWithDetails, id uuid.UUID) bool {
+	for _, blob := range blobs {
+		if blob.ID == id.String() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHeldBlobsAreLeftOut(t *testing.T) {
+	db, _ := pgtest.New(t)
+	blobs := NewBlobRepository(db)
+	users := NewUserRepository(db)
+	ctx := context.Background()
+
+	addUsers(t, db, "ana", "bob")
+	follow(t, db, [2]string{"ana", "bob"})
+	visibleID := addBlob(t, db, "bob", "visible")
+
+	heldID := uuid.New()
+	if _, err := blobs.Create(ctx, &models.BlobWithInterests{
+		ID:      heldID,
+		UserID:  "bob",
+		Content: "held",
+		HoldReport: &models.Report{
+			ID:           uuid.New(),
+			BlobID:       &heldID,
+			TargetUserID: "bob",
+			Content:      "held",
+			Reason:       "spam",
+			Source:       models.ReportSourceFilter,
+		},
+	}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if blob, err := blobs.GetByID(ctx, heldID, "ana", 10); err != nil || blob != nil {
+		t.Errorf("GetByID = %v, %v, want nil", blob, err)
+	}
+	if authorID, err := blobs.GetAuthor(ctx, heldID); err != nil || authorID != "" {
+		t.Errorf("GetAuthor = %q, %v, want none", authorID, err)
+	}
+	if blob, err := blobs.GetByID(ctx, visibleID, "ana", 10); err != nil || blob == nil {
+		t.Errorf("GetByID of the visible blob = %v, %v", blob, err)
+	}
+
+	listed, err := blobs.ListBlobs(ctx, "ana")
+	if err != nil {
+		t.Fatalf("ListBlobs: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != visibleID.String() {
+		t.Errorf("ListBlobs = %+v, want only the visible blob", listed)
+	}
+
+	lists := []struct {
+		name string
+		list func() ([]*models.BlobListWithDetails, int, error)
+	}{
+		{"ListFeed", func() ([]*models.BlobListWithDetails, int, error) { return blobs.ListFeed(ctx, "ana", 10, 0) }},
+		{"ListTrending", func() ([]*models.BlobListWithDetails, int, error) { return blobs.ListTrending(ctx, "ana", 10, 0) }},
+		{"UserRepo.ListBlobs", func() ([]*models.BlobListWithDetails, int, error) { return users.ListBlobs(ctx, "bob", "ana", 10, 0) }},
+	}
+	for _, tt := range lists {
+		listed, total, err := tt.list()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if total != 1 || containsBlob(listed, heldID) || !containsBlob(listed, visibleID) {
+			t.Errorf("%s = %d blobs of %d, want only the visible one", tt.name, len(listed), total)
+		}
+	}
+
+	profile, err := users.GetProfile(ctx, "bob", "ana")
+	if err != nil || profile == nil {
+		t.Fatalf("GetProfile = %v, %v", profile, err)
+	}
+	if profile.BlobsCount != 1 {
+		t.Errorf("blobs_count = %d, want 1", profile.BlobsCount)
+	}
+}
+
+func TestExpiredBlobsArePopped(t *testing.T) {
+	db, _ := pgtest.New(t)
+	blobs := NewBlobRepository(db)
+	bookmarks := NewBookmarkRepository(db)
+	ctx := context.Background()
+
+	addUsers(t, db, "ana", "bob")
+	freshID := addBlob(t, db, "bob", "fresh")
+	expiredID := addBlob(t, db, "bob", "expired")
+	forgottenID := addBlob(t, db, "bob", "forgotten")
+	exec(t, db, `UPDATE "Blob" SET created_at = NOW() - INTERVAL '25 hours' WHERE id IN ($1, $2)`, expiredID, forgottenID)
+	if _, err := bookmarks.Add(ctx, "ana", expiredID, "bob"); err != nil {
+		t.Fatalf("Add bookmark: %v", err)
+	}
+
+	var expired int
+	if err := db.Get(&expired, `SELECT pop_old_blobs()`); err != nil {
+		t.Fatalf("pop_old_blobs: %v", err)
+	}
+	if expired != 2 {
+		t.Errorf("pop_old_blobs = %d, want 2", expired)
+	}
+
+	for _, id := range []uuid.UUID{expiredID, forgottenID} {
+		if blob, err := blobs.GetByID(ctx, id, "ana", 10); err != nil || blob != nil {
+			t.Errorf("GetByID of an expired blob = %v, %v", blob, err)
+		}
+	}
+	if blob, err := blobs.GetByID(ctx, freshID, "ana", 10); err != nil || blob == nil {
+		t.Errorf("GetByID of the fresh blob = %v, %v", blob, err)
+	}
+
+	saved, total, err := bookmarks.List(ctx, "ana", 10, 0)
+	if err != nil {
+		t.Fatalf("List bookmarks: %v", err)
+	}
+	if total != 1 || len(saved) != 1 || saved[0].BlobID != expiredID.String() || !saved[0].Expired || saved[0].Content != "expired" {
+		t.Errorf("bookmarks = %d %+v, want the snapshot of the expired blob", total, saved)
+	}
+}
+
+func TestExpiredMediaIsCollected(t *testing.T) {
+	db, _ := pgtest.New(t)
+	media := NewMediaRepository(db)
+	ctx := context.Background()
+
+	addUsers(t, db, "ana")
+	blobID := addBlob(t, db, "ana", "with a picture")
+	insert := `INSERT INTO "Media" (id, user_id, content_type, size, width, height, storage_key, thumbnail_key, expires_at)
+		VALUES ($1, 'ana', 'image/png', 1, 1, 1, $1, $1, NOW() + $2 * INTERVAL '1 hour')`
+	attached, pending, expired := uuid.New(), uuid.New(), uuid.New()
+	exec(t, db, `INSERT INTO "Media" (id, user_id, blob_id, content_type, size, width, height, storage_key, thumbnail_key)
+		VALUES ($1, 'ana', $2, 'image/png', 1, 1, 1, $1, $1)`, attached, blobID)
+	exec(t, db, insert, pending, 1)
+	exec(t, db, insert, expired, -1)
+
+	collected, err := media.DeleteExpired(ctx, 10)
+	if err != nil {
+		t.Fatalf("DeleteExpired: %v", err)
+	}
+	if len(collected) != 1 || collected[0].ID != expired {
+		t.Fatalf("DeleteExpired = %+v, want only the expired upload", collected)
+	}
+
+	// Deleting the blob expires its attachments.
+	exec(t, db, `DELETE FROM "Blob" WHERE id = $1`, blobID)
+	collected, err = media.DeleteExpired(ctx, 10)
+	if err != nil {
+		t.Fatalf("DeleteExpired: %v", err)
+	}
+	if len(collected) != 1 || collected[0].ID != attached {
+		t.Errorf("DeleteExpired after the blob is gone = %+v, want its attachment", collected)
+	}
+}
+
+func TestPrivateProfileRelations(t *testing.T) {
+	db, _ := pgtest.New(t)
+	users := NewUserRepository(db)
+	ctx := context.Background()
+
+	addUsers(t, db, "ana", "bob", "carl", "dan")
+	exec(t, db, `UPDATE "User" SET private_profile = TRUE WHERE id = 'ana'`)
+	follow(t, db, [2]string{"ana", "bob"}, [2]string{"carl", "ana"})
+	exec(t, db, `INSERT INTO "Block" (user_id, blocked_user_id) VALUES ('ana', 'dan')`)
+
+	tests := []struct {
+		viewerID     string
+		followsMe    bool
+		followedByMe bool
+		blockedMe    bool
+	}{
+		{"bob", true, false, false},
+		{"carl", false, true, false},
+		{"dan", false, false, true},
+		{"", false, false, false},
+	}
+	for _, tt := range tests {
+		profile, err := users.GetProfile(ctx, "ANA", tt.viewerID)
+		if err != nil || profile == nil {
+			t.Fatalf("GetProfile(%q) = %v, %v", tt.viewerID, profile, err)
+		}
+		if !profile.PrivateProfile || profile.FollowsMe != tt.followsMe || profile.FollowedByMe != tt.followedByMe || profile.BlockedMe != tt.blockedMe {
+			t.Errorf("GetProfile(%q) = private %v, follows me %v, followed by me %v, blocked me %v",
+				tt.viewerID, profile.PrivateProfile, profile.FollowsMe, profile.FollowedByMe, profile.BlockedMe)
+		}
+	}
+
+	exec(t, db, `UPDATE "User" SET banned_at = NOW() WHERE id = 'bob'`)
+	exec(t, db, `UPDATE "User" SET deletion_scheduled_at = NOW() WHERE id = 'carl'`)
+	for _, username := range []string{"bob", "carl"} {
+		if profile, err := users.GetProfile(ctx, username, "ana"); err != nil || profile != nil {
+			t.Errorf("GetProfile(%s) = %v, %v, want none", username, profile, err)
+		}
+	}
+}
+
+func TestMentionsLeaveOutMutedAndBlockedAuthors(t *testing.T) {
+	db, _ := pgtest.New(t)
+	mentions := NewMentionRepository(db)
+	ctx := context.Background()
+
+	addUsers(t, db, "ana", "bob", "eve", "max")
+	exec(t, db, `INSERT INTO "Mute" (user_id, muted_user_id) VALUES ('ana', 'eve')`)
+	exec(t, db, `INSERT INTO "Block" (user_id, blocked_user_id) VALUES ('ana', 'max')`)
+	for _, author := range []string{"bob", "eve", "max"} {
+		blobID := addBlob(t, db, author, "hi @ana")
+		exec(t, db, `INSERT INTO "Mention" (id, blob_id, author_id, mentioned_user_id) VALUES ($1, $2, $3, 'ana')`,
+			uuid.New(), blobID, author)
+	}
+
+	listed, total, err := mentions.ListByMentionedUser(ctx, "ana", 10, 0)
+	if err != nil {
+		t.Fatalf("ListByMentionedUser: %v", err)
+	}
+	if total != 1 || len(listed) != 1 || listed[0].AuthorID != "bob" {
+		t.Errorf("ListByMentionedUser = %d %+v, want only bob's mention", total, listed)
+	}
+}
@@ -0,0 +1,259 @@
+// Package response writes the bodies of API responses. Routes under /api/v1
+// answer an envelope with data, meta and error members; the unversioned /api
+// routes keep answering bare bodies for the clients written against them.
+// Both localize their messages from the Accept-Language header and answer
+// empty lists as [] rather than null.
+package response
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaoleau/blob/i18n"
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/validation"
+)
+
+const (
+	LegacyPrefix  = "/api"
+	VersionPrefix = "/api/v1"
+)
+
+// requestIDHeader mirrors middleware.RequestIDHeader, which this package
+// cannot import without a cycle.
+const requestIDHeader = "X-Request-ID"
+
+// Envelope is the body of every /api/v1 response. Exactly one of Data and
+// Error is set.
+type Envelope struct {
+	Data  interface{} `json:"data"`
+	Meta  Meta        `json:"meta"`
+	Error *ErrorBody  `json:"error"`
+}
+
+// Meta carries what describes a response rather than belongs to it.
+type Meta struct {
+	RequestID  string             `json:"request_id,omitempty"`
+	Pagination *models.Pagination `json:"pagination,omitempty"`
+}
+
+// ErrorBody describes a failed request. Fields, Allowed and MaxBytes are set
+// by the errors they apply to.
+type ErrorBody struct {
+	Message  string            `json:"message"`
+	Fields   validation.Errors `json:"fields,omitempty"`
+	Allowed  []string          `json:"allowed,omitempty"`
+	MaxBytes int64             `json:"max_bytes,omitempty"`
+}
+
+// Versioned reports whether the request came in through /api/v1.
+func Versioned(c *gin.Context) bool {
+	path := c.FullPath()
+	if path == "" {
+		path = c.Request.URL.Path
+	}
+	return path == VersionPrefix || strings.HasPrefix(path, VersionPrefix+"/")
+}
+
+// BasePath is the prefix the request was routed under, for building links
+// that stay on the same version.
+func BasePath(c *gin.Context) string {
+	if Versioned(c) {
+		return VersionPrefix
+	}
+	return LegacyPrefix
+}
+
+// T translates message into the language the client asked for.
+func T(c *gin.Context, message string, args ...interface{}) string {
+	return i18n.T(i18n.Language(c.GetHeader("Accept-Language")), message, args...)
+}
+
+// JSON answers data. On /api/v1 a paginated list is answered as its items,
+// with the page moved to the meta.
+func JSON(c *gin.Context, status int, data interface{}) {
+	if !Versioned(c) {
+		c.JSON(status, normalize(data))
+		return
+	}
+
+	envelope := Envelope{Data: data, Meta: meta(c)}
+	if list, ok := data.(models.Paginated); ok {
+		page := list.PageInfo()
+		envelope.Data = list.PageItems()
+		envelope.Meta.Pagination = &page
+	}
+	envelope.Data = normalize(envelope.Data)
+	c.JSON(status, envelope)
+}
+
+// Message answers a confirmation in the client's language: as the message
+// member of a bare body, or as data.message in the envelope.
+func Message(c *gin.Context, status int, message string, extra gin.H) {
+	body := gin.H{"message": T(c, message)}
+	for key, value := range extra {
+		body[key] = value
+	}
+	JSON(c, status, body)
+}
+
+// NoContent answers 204 without a body on either version.
+func NoContent(c *gin.Context) {
+	c.Status(http.StatusNoContent)
+}
+
+// Error answers status with message as the error.
+func Error(c *gin.Context, status int, message string) {
+	Fail(c, status, ErrorBody{Message: message})
+}
+
+// Fail answers status with body as the error, translating its messages.
+func Fail(c *gin.Context, status int, body ErrorBody) {
+	c.JSON(status, failure(c, body))
+}
+
+// Abort is Fail for middleware: it also stops the handler chain.
+func Abort(c *gin.Context, status int, body ErrorBody) {
+	c.AbortWithStatusJSON(status, failure(c, body))
+}
+
+func failure(c *gin.Context, body ErrorBody) interface{} {
+	language := i18n.Language(c.GetHeader("Accept-Language"))
+	body.Message = i18n.T(language, body.Message)
+	body.Fields = body.Fields.Localize(func(format string, args ...interface{}) string {
+		return i18n.T(language, format, args...)
+	})
+
+	if Versioned(c) {
+		return Envelope{Meta: meta(c), Error: &body}
+	}
+
+	legacy := gin.H{"error": body.Message}
+	if body.Fields != nil {
+		legacy["fields"] = body.Fields
+	}
+	if body.Allowed != nil {
+		legacy["allowed"] = body.Allowed
+	}
+	if body.MaxBytes != 0 {
+		legacy["max_bytes"] = body.MaxBytes
+	}
+	return legacy
+}
+
+func meta(c *gin.Context) Meta {
+	return Meta{RequestID: c.Writer.Header().Get(requestIDHeader)}
+}
+
+// normalize returns data with every nil slice and map it holds replaced by
+// an empty one, so lists are never answered as null. Values without nil
+// collections are returned as they are.
+func normalize(data interface{}) interface{} {
+	if data == nil {
+		return nil
+	}
+	value := reflect.ValueOf(data)
+	if !hasNilCollection(value) {
+		return data
+	}
+	return filled(value).Interface()
+}
+
+// hasNilCollection reports whether value holds a nil slice or map, looking
+// through pointers, structs, slices, maps and interfaces. Byte slices are
+// left alone: encoding/json writes them as base64 strings.
+func hasNilCollection(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return false
+		}
+		if value.IsNil() {
+			return true
+		}
+		for i := 0; i < value.Len(); i++ {
+			if hasNilCollection(value.Index(i)) {
+				return true
+			}
+		}
+	case reflect.Map:
+		if value.IsNil() {
+			return true
+		}
+		iter := value.MapRange()
+		for iter.Next() {
+			if hasNilCollection(iter.Value()) {
+				return true
+			}
+		}
+	case reflect.Pointer, reflect.Interface:
+		return !value.IsNil() && hasNilCollection(value.Elem())
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			if encoded(value.Type().Field(i)) && hasNilCollection(value.Field(i)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filled returns a copy of value with its nil slices and maps made empty.
+func filled(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return value
+		}
+		if value.IsNil() {
+			return reflect.MakeSlice(value.Type(), 0, 0)
+		}
+		copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(filled(value.Index(i)))
+		}
+		return copied
+	case reflect.Map:
+		if value.IsNil() {
+			return reflect.MakeMap(value.Type())
+		}
+		copied := reflect.MakeMapWithSize(value.Type(), value.Len())
+		iter := value.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), filled(iter.Value()))
+		}
+		return copied
+	case reflect.Pointer:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.New(value.Type().Elem())
+		copied.Elem().Set(filled(value.Elem()))
+		return copied
+	case reflect.Interface:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(filled(value.Elem()))
+		return copied
+	case reflect.Struct:
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			if encoded(value.Type().Field(i)) {
+				copied.Field(i).Set(filled(value.Field(i)))
+			}
+		}
+		return copied
+	}
+	return value
+}
+
+// encoded reports whether encoding/json writes field.
+func encoded(field reflect.StructField) bool {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	return field.IsExported() && name != "-"
+}
@@ -0,0 +1,235 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/validation"
+)
+
+// serve answers one request to path with handler on a bare engine that sets
+// the request ID header, like the request logger does.
+func serve(path, acceptLanguage string, handler gin.HandlerFunc) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	server.Use(func(c *gin.Context) {
+		c.Header(requestIDHeader, "req-1")
+	})
+	server.GET(path, handler)
+
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, req)
+	return recorder
+}
+
+func assertJSON(t *testing.T, got []byte, want string) {
+	t.Helper()
+	var gotValue, wantValue interface{}
+	if err := json.Unmarshal(got, &gotValue); err != nil {
+		t.Fatalf("invalid JSON %s: %v", got, err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantValue); err != nil {
+		t.Fatalf("invalid expected JSON %s: %v", want, err)
+	}
+	if !reflect.DeepEqual(gotValue, wantValue) {
+		t.Errorf("body = %s\nwant   %s", got, want)
+	}
+}
+
+type item struct {
+	Tags []string `json:"tags"`
+}
+
+type page struct {
+	Items   []item           `json:"items"`
+	Counts  map[string]int   `json:"counts"`
+	Next    *item            `json:"next"`
+	Any     interface{}      `json:"any"`
+	Raw     []byte           `json:"raw"`
+	Skipped []string         `json:"-"`
+	ByName  map[string][]int `json:"by_name"`
+	private []string
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name string
+		data interface{}
+		want string
+	}{
+		{"nil", nil, `null`},
+		{"nil slice", []string(nil), `[]`},
+		{"nil map", map[string]int(nil), `{}`},
+		{"struct fields", page{}, `{"items":[],"counts":{},"next":null,"any":null,"raw":null,"by_name":{}}`},
+		{"pointer to struct", &page{Next: &item{}}, `{"items":[],"counts":{},"next":{"tags":[]},"any":null,"raw":null,"by_name":{}}`},
+		{"slice of structs", []item{{}, {Tags: []string{"go"}}}, `[{"tags":[]},{"tags":["go"]}]`},
+		{"map values", map[string][]int{"a": nil}, `{"a":[]}`},
+		{"interface", gin.H{"list": []item(nil), "item": item{}}, `{"list":[],"item":{"tags":[]}}`},
+		{"nested interface", page{Any: []string(nil), ByName: map[string][]int{"x": nil}}, `{"items":[],"counts":{},"next":null,"any":[],"raw":null,"by_name":{"x":[]}}`},
+		{"bytes stay base64", page{Raw: []byte("hi")}, `{"items":[],"counts":{},"next":null,"any":null,"raw":"aGk=","by_name":{}}`},
+		{"nil bytes stay null", []byte(nil), `null`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, err := json.Marshal(normalize(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertJSON(t, encoded, tt.want)
+		})
+	}
+}
+
+func TestNormalizeLeavesInputAlone(t *testing.T) {
+	original := &page{Next: &item{}, private: nil}
+	normalized := normalize(original).(*page)
+	if original.Items != nil || original.Next.Tags != nil {
+		t.Errorf("normalize changed its input: %+v", original)
+	}
+	if normalized == original || normalized.Next == original.Next {
+		t.Errorf("normalize did not copy the pointers")
+	}
+	if normalized.Skipped != nil || normalized.private != nil {
+		t.Errorf("fields encoding/json skips were filled: %+v", normalized)
+	}
+
+	complete := &item{Tags: []string{}}
+	if normalize(complete) != complete {
+		t.Errorf("a value without nil collections was copied")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	likes := models.LikeList{Pagination: models.Pagination{TotalCount: 0, TotalPages: 0, Page: 1, Size: 10}}
+
+	tests := []struct {
+		name string
+		path string
+		data interface{}
+		want string
+	}{
+		{"legacy list", "/api/blob/likes", likes,
+			`{"total_count":0,"total_pages":0,"page":1,"size":10,"has_more":false,"likes":[]}`},
+		{"v1 list", "/api/v1/blob/likes", likes,
+			`{"data":[],"meta":{"request_id":"req-1","pagination":{"total_count":0,"total_pages":0,"page":1,"size":10,"has_more":false}},"error":null}`},
+		{"legacy object", "/api/item", item{}, `{"tags":[]}`},
+		{"v1 object", "/api/v1/item", item{}, `{"data":{"tags":[]},"meta":{"request_id":"req-1"},"error":null}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(tt.path, "", func(c *gin.Context) { JSON(c, http.StatusOK, tt.data) })
+			if recorder.Code != http.StatusOK {
+				t.Errorf("status = %d", recorder.Code)
+			}
+			assertJSON(t, recorder.Body.Bytes(), tt.want)
+		})
+	}
+}
+
+func TestMessage(t *testing.T) {
+	handler := func(c *gin.Context) {
+		Message(c, http.StatusAccepted, "Blob not found.", gin.H{"id": "b1"})
+	}
+
+	assertJSON(t, serve("/api/thing", "pt-BR", handler).Body.Bytes(), `{"message":"Blob não encontrado.","id":"b1"}`)
+	assertJSON(t, serve("/api/v1/thing", "en", handler).Body.Bytes(),
+		`{"data":{"message":"Blob not found.","id":"b1"},"meta":{"request_id":"req-1"},"error":null}`)
+}
+
+func TestFail(t *testing.T) {
+	fields := validation.Struct(models.CreateCommentRequest{}).(validation.Errors)
+	body := ErrorBody{Message: "Invalid input data.", Fields: fields, Allowed: []string{"like"}, MaxBytes: 5}
+
+	tests := []struct {
+		name           string
+		path           string
+		acceptLanguage string
+		body           ErrorBody
+		want           string
+	}{
+		{"legacy", "/api/x", "", ErrorBody{Message: "Blob not found."}, `{"error":"Blob not found."}`},
+		{"legacy with every member", "/api/x", "en", body,
+			`{"error":"Invalid input data.","fields":[{"field":"content","rule":"content","message":"must not be blank and at most 500 characters"}],"allowed":["like"],"max_bytes":5}`},
+		{"v1", "/api/v1/x", "", ErrorBody{Message: "Blob not found."},
+			`{"data":null,"meta":{"request_id":"req-1"},"error":{"message":"Blob not found."}}`},
+		{"v1 localized fields", "/api/v1/x", "pt-BR,pt;q=0.9", body,
+			`{"data":null,"meta":{"request_id":"req-1"},"error":{"message":"Dados de entrada inválidos.","fields":[{"field":"content","rule":"content","message":"não pode estar em branco e deve ter no máximo 500 caracteres"}],"allowed":["like"],"max_bytes":5}}`},
+		{"legacy localized", "/api/x", "pt-BR", ErrorBody{Message: "Blob not found."}, `{"error":"Blob não encontrado."}`},
+		{"unknown language falls back to English", "/api/x", "fr-FR", ErrorBody{Message: "Blob not found."}, `{"error":"Blob not found."}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := serve(tt.path, tt.acceptLanguage, func(c *gin.Context) { Fail(c, http.StatusBadRequest, tt.body) })
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("status = %d", recorder.Code)
+			}
+			assertJSON(t, recorder.Body.Bytes(), tt.want)
+		})
+	}
+
+	if fields[0].Message != "must not be blank and at most 500 characters" {
+		t.Errorf("localizing changed the caller's fields: %+v", fields)
+	}
+}
+
+func TestAbortStopsTheChain(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	reached := false
+	server.Use(func(c *gin.Context) { Abort(c, http.StatusForbidden, ErrorBody{Message: "Blob not found."}) })
+	server.GET("/api/v1/x", func(c *gin.Context) { reached = true })
+
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/v1/x", nil))
+	if reached || recorder.Code != http.StatusForbidden {
+		t.Errorf("handler reached = %v, status = %d", reached, recorder.Code)
+	}
+	assertJSON(t, recorder.Body.Bytes(), `{"data":null,"meta":{},"error":{"message":"Blob not found."}}`)
+}
+
+func TestBasePath(t *testing.T) {
+	tests := []struct {
+		route, path, want string
+	}{
+		{"/api/v1", "/api/v1", VersionPrefix},
+		{"/api/v1/blob/:id", "/api/v1/blob/1", VersionPrefix},
+		{"/api/blob/:id", "/api/blob/1", LegacyPrefix},
+		{"/api/v1x", "/api/v1x", LegacyPrefix},
+	}
+	for _, tt := range tests {
+		gin.SetMode(gin.TestMode)
+		server := gin.New()
+		var got string
+		server.GET(tt.route, func(c *gin.Context) { got = BasePath(c) })
+		server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if got != tt.want {
+			t.Errorf("BasePath(%s) = %s, want %s", tt.path, got, tt.want)
+		}
+	}
+
+	// Unmatched routes have no full path and fall back to the URL.
+	gin.SetMode(gin.TestMode)
+	server := gin.New()
+	var got string
+	server.NoRoute(func(c *gin.Context) { got = BasePath(c) })
+	server.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/missing", nil))
+	if got != VersionPrefix {
+		t.Errorf("BasePath of an unmatched route = %s", got)
+	}
+}
+
+func TestNoContent(t *testing.T) {
+	recorder := serve("/api/v1/x", "", func(c *gin.Context) { NoContent(c) })
+	if recorder.Code != http.StatusNoContent || recorder.Body.Len() != 0 {
+		t.Errorf("status = %d, body = %q", recorder.Code, recorder.Body.String())
+	}
+}
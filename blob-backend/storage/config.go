@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/joaoleau/blob/internal/config"
+)
+
+// FromConfig builds the storage backend selected by cfg.Backend. For s3 the
+// bucket is created on startup when it is missing.
+func FromConfig(cfg config.Storage) (Storage, error) {
+	switch cfg.Backend {
+	case "", "local":
+		return NewLocalStorage(cfg.Dir, cfg.BaseURL), nil
+	case "s3":
+		s3, err := NewS3Storage(cfg.S3.Endpoint, cfg.S3.Region, cfg.S3.AccessKey, cfg.S3.SecretKey, cfg.S3.Bucket, cfg.S3.PublicURL, cfg.S3.UseSSL)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := s3.EnsureBucket(ctx, cfg.S3.PublicRead); err != nil {
+			return nil, fmt.Errorf("failed to prepare bucket %s: %w", cfg.S3.Bucket, err)
+		}
+		return s3, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStoragePutAndDelete(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := NewLocalStorage(dir, "http://media.test/uploads/")
+
+	if err := store.Put(ctx, "user/a.png", strings.NewReader("first"), 5, "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Put(ctx, "user/a.png", strings.NewReader("second"), 6, "image/png"); err != nil {
+		t.Fatalf("Put again: %v", err)
+	}
+
+	path := filepath.Join(dir, "user", "a.png")
+	data, err := os.ReadFile(path)
+	if err != nil || string(data) != "second" {
+		t.Fatalf("stored = %q, %v, want the replaced content", data, err)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	if len(entries) != 1 {
+		t.Errorf("temporary files left behind: %v", entries)
+	}
+
+	if got := store.URL("user/a.png"); got != "http://media.test/uploads/user/a.png" {
+		t.Errorf("URL = %s", got)
+	}
+
+	if err := store.Delete(ctx, "user/a.png"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file still there: %v", err)
+	}
+	if err := store.Delete(ctx, "user/a.png"); err != nil {
+		t.Errorf("Delete of a missing key = %v", err)
+	}
+}
+
+func TestLocalStorageRefusesEscapingKeys(t *testing.T) {
+	ctx := context.Background()
+	store := NewLocalStorage(t.TempDir(), "/uploads")
+
+	for _, key := range []string{"", "/", "../outside.png", "user/../../outside.png", "/abs.png", "user//a.png", "user/./a.png"} {
+		if err := store.Put(ctx, key, strings.NewReader("x"), 1, "image/png"); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Put(%q) = %v, want ErrInvalidKey", key, err)
+		}
+		if err := store.Delete(ctx, key); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Delete(%q) = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/internal/config"
+)
+
+// The S3 tests run against a MinIO (or any S3-compatible) server when
+// BLOB_TEST_S3_ENDPOINT is set, for example:
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//	BLOB_TEST_S3_ENDPOINT=localhost:9000 go test ./storage
+const (
+	s3EndpointEnv  = "BLOB_TEST_S3_ENDPOINT"
+	s3AccessKeyEnv = "BLOB_TEST_S3_ACCESS_KEY"
+	s3SecretKeyEnv = "BLOB_TEST_S3_SECRET_KEY"
+)
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// newTestS3 returns a storage on a fresh public-read bucket.
+func newTestS3(t *testing.T) *S3Storage {
+	t.Helper()
+	endpoint := os.Getenv(s3EndpointEnv)
+	if endpoint == "" {
+		t.Skipf("%s is not set", s3EndpointEnv)
+	}
+
+	store, err := FromConfig(config.Storage{
+		Backend: "s3",
+		S3: config.S3{
+			Endpoint:   endpoint,
+			AccessKey:  envOr(s3AccessKeyEnv, "minioadmin"),
+			SecretKey:  envOr(s3SecretKeyEnv, "minioadmin"),
+			Bucket:     "blob-test-" + uuid.NewString(),
+			PublicRead: true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("FromConfig: %v", err)
+	}
+	s3 := store.(*S3Storage)
+	t.Cleanup(func() { s3.client.RemoveBucket(context.Background(), s3.bucket) })
+	return s3
+}
+
+func TestS3StoragePutAndDelete(t *testing.T) {
+	ctx := context.Background()
+	store := newTestS3(t)
+	key := "user/" + uuid.NewString() + ".png"
+
+	if err := store.Put(ctx, key, strings.NewReader("pixels"), 6, "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	resp, err := http.Get(store.URL(key))
+	if err != nil {
+		t.Fatalf("GET %s: %v", store.URL(key), err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "pixels" {
+		t.Errorf("GET = %d %q", resp.StatusCode, body)
+	}
+	if contentType := resp.Header.Get("Content-Type"); contentType != "image/png" {
+		t.Errorf("Content-Type = %s", contentType)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("Delete of a missing key = %v", err)
+	}
+
+	resp, err = http.Get(store.URL(key))
+	if err != nil {
+		t.Fatalf("GET after delete: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET after delete = %d, want 404", resp.StatusCode)
+	}
+}
+
+func TestS3StorageURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		useSSL    bool
+		publicURL string
+		want      string
+	}{
+		{"path style", false, "", "http://minio:9000/media/user/a%20b.png"},
+		{"path style over TLS", true, "", "https://minio:9000/media/user/a%20b.png"},
+		{"public URL", false, "https://cdn.example/", "https://cdn.example/user/a%20b.png"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewS3Storage("minio:9000", "", "key", "secret", "media", tt.publicURL, tt.useSSL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := store.URL("user/a b.png"); got != tt.want {
+				t.Errorf("URL = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
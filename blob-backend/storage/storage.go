@@ -0,0 +1,21 @@
+// Package storage keeps uploaded files behind a small interface so the API
+// can write to the local filesystem in development and to any S3-compatible
+// service (AWS, MinIO) in production and tests.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var ErrInvalidKey = errors.New("invalid storage key")
+
+type Storage interface {
+	// Put stores size bytes from r under key, replacing any existing object.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL returns the public address of key.
+	URL(key string) string
+}
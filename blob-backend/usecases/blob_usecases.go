@@ -4,21 +4,40 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/joaoleau/blob/metrics"
 	"github.com/joaoleau/blob/models"
 	"github.com/joaoleau/blob/repository"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 )
 
+// ErrAlreadyReblogged is returned when a user reblogs the same blob twice
+// without a quote.
+var ErrAlreadyReblogged = repository.ErrAlreadyReblogged
+
+// ErrBlobNotFound is returned when commenting on a blob that is gone or
+// hidden.
+var ErrBlobNotFound = repository.ErrBlobNotFound
+
+// BlobDetailListSize is how many comments and likes the blob detail embeds;
+// the rest are paged through their own endpoints.
+const BlobDetailListSize = 20
+
 type BlobUseCase struct {
-	repository  repository.BlobRepo
+	repository  BlobRepository
+	Moderation  *ModerationUseCase
 	UserUseCase *UserUseCase
+	Media       *MediaUseCase
+	Previews    *LinkPreviewUseCase
 }
 
-func NewBlobUseCase(repo repository.BlobRepo, userUseCase *UserUseCase) BlobUseCase {
+func NewBlobUseCase(repo BlobRepository, moderationUseCase *ModerationUseCase, userUseCase *UserUseCase, mediaUseCase *MediaUseCase, previewUseCase *LinkPreviewUseCase) BlobUseCase {
 	return BlobUseCase{
 		repository:  repo,
+		Moderation:  moderationUseCase,
 		UserUseCase: userUseCase,
+		Media:       mediaUseCase,
+		Previews:    previewUseCase,
 	}
 }
 
@@ -39,17 +58,247 @@ func (u *BlobUseCase) RegisterBlob(ctx context.Context, blob *models.BlobWithInt
 		return nil, errors.New("authenticated user not found")
 	}
 	
+	verdict, err := u.Moderation.Screen(blob.Content)
+	if err != nil {
+		return nil, err
+	}
+
 	blob.ID = uuid.New()
 	blob.UserID = user.ID
 
+	if err := u.Media.CheckAttachable(ctx, blob.ID, user.ID, blob.MediaIDs); err != nil {
+		return nil, err
+	}
+
+	entities := ExtractEntities(blob.Content)
+	blob.Hashtags = uniqueEntityTexts(entities, models.EntityHashtag)
+	if blob.MentionIDs, err = u.mentionedUserIDs(ctx, user.ID, entities); err != nil {
+		return nil, err
+	}
+	blob.HoldReport = u.Moderation.HoldReport(blob.ID, nil, user.ID, blob.Content, verdict)
+
 	createdBlob, err := u.repository.Create(ctx, blob)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to create blob")
 	}
+	metrics.BlobsCreated.Inc()
+
+	if createdBlob.Media, err = u.blobMedia(ctx, createdBlob.ID.String()); err != nil {
+		return nil, err
+	}
+	if createdBlob.IsReblog {
+		if createdBlob.ReblogOf, err = u.repository.GetReblogOriginal(ctx, createdBlob.ReblogOfID); err != nil {
+			return nil, errors.Wrap(err, "failed to fetch reblogged blob")
+		}
+	}
+
+	createdBlob.HeldForReview = blob.HoldReport != nil
+
+	u.Previews.Enqueue(ctx, createdBlob.Content)
+	if createdBlob.LinkPreviews, err = u.Previews.ForContent(ctx, createdBlob.Content); err != nil {
+		return nil, err
+	}
 
+	createdBlob.Entities = entities
 	return createdBlob, nil
 }
 
+func (u *BlobUseCase) UpdateBlob(ctx context.Context, blob *models.BlobWithInterests) (*models.BlobWithInterests, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobUseCase.UpdateBlob")
+	defer span.Finish()
+
+	email, ok := ctx.Value("email").(string)
+	if !ok || email == "" {
+		return nil, errors.New("user email not found in context")
+	}
+
+	user, err := u.UserUseCase.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch user by email")
+	}
+	if user == nil {
+		return nil, errors.New("authenticated user not found")
+	}
+
+	verdict, err := u.Moderation.Screen(blob.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	blob.UserID = user.ID
+
+	if err := u.Media.CheckAttachable(ctx, blob.ID, user.ID, blob.MediaIDs); err != nil {
+		return nil, err
+	}
+
+	entities := ExtractEntities(blob.Content)
+	blob.Hashtags = uniqueEntityTexts(entities, models.EntityHashtag)
+	if blob.MentionIDs, err = u.mentionedUserIDs(ctx, user.ID, entities); err != nil {
+		return nil, err
+	}
+	blob.HoldReport = u.Moderation.HoldReport(blob.ID, nil, user.ID, blob.Content, verdict)
+
+	updatedBlob, err := u.repository.Update(ctx, blob)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to update blob")
+	}
+	if updatedBlob == nil {
+		return nil, nil
+	}
+
+	if updatedBlob.Media, err = u.blobMedia(ctx, updatedBlob.ID.String()); err != nil {
+		return nil, err
+	}
+	if updatedBlob.IsReblog {
+		if updatedBlob.ReblogOf, err = u.repository.GetReblogOriginal(ctx, updatedBlob.ReblogOfID); err != nil {
+			return nil, errors.Wrap(err, "failed to fetch reblogged blob")
+		}
+	}
+
+	updatedBlob.HeldForReview = blob.HoldReport != nil
+
+	u.Previews.Enqueue(ctx, updatedBlob.Content)
+	if updatedBlob.LinkPreviews, err = u.Previews.ForContent(ctx, updatedBlob.Content); err != nil {
+		return nil, err
+	}
+
+	updatedBlob.Entities = entities
+	return updatedBlob, nil
+}
+
+// Reblog creates a blob of the current user pointing at blobID, quoting it
+// when content is set. A nil blob means there is nothing visible to reblog.
+func (u *BlobUseCase) Reblog(ctx context.Context, blobID uuid.UUID, content string) (*models.BlobWithInterests, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobUseCase.Reblog")
+	defer span.Finish()
+
+	user, err := u.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	targetID, authorID, err := u.repository.GetReblogTarget(ctx, blobID)
+	if err != nil {
+		return nil, errors.Wrap(err, "BlobUseCase.Reblog.GetReblogTarget")
+	}
+	if targetID == "" {
+		return nil, nil
+	}
+
+	if err := u.UserUseCase.EnsureNotBlocked(ctx, user.ID, authorID); err != nil {
+		return nil, err
+	}
+
+	return u.RegisterBlob(ctx, &models.BlobWithInterests{Content: content, ReblogOfID: &targetID})
+}
+
+// ListFeed pages through the blobs and reblogs of the users the current user
+// follows, including their own.
+func (u *BlobUseCase) ListFeed(ctx context.Context, page, size int) (*models.BlobList, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobUseCase.ListFeed")
+	defer span.Finish()
+
+	viewer, err := u.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, total, err := u.repository.ListFeed(ctx, viewer.ID, size, (page-1)*size)
+	if err != nil {
+		return nil, errors.Wrap(err, "BlobUseCase.ListFeed.ListFeed")
+	}
+
+	return u.UserUseCase.blobPage(ctx, blobs, total, page, size)
+}
+
+func (u *BlobUseCase) ListTrending(ctx context.Context, page, size int) (*models.BlobList, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobUseCase.ListTrending")
+	defer span.Finish()
+
+	viewer, err := u.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, total, err := u.repository.ListTrending(ctx, viewer.ID, size, (page-1)*size)
+	if err != nil {
+		return nil, errors.Wrap(err, "BlobUseCase.ListTrending.ListTrending")
+	}
+
+	return u.UserUseCase.blobPage(ctx, blobs, total, page, size)
+}
+
+// ViewerStates returns liked_by_me, bookmarked and the other viewer fields
+// for each visible blob in blobIDs, in the order they were asked for.
+func (u *BlobUseCase) ViewerStates(ctx context.Context, blobIDs []uuid.UUID) ([]models.BlobViewerState, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobUseCase.ViewerStates")
+	defer span.Finish()
+
+	viewer, err := u.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]string, 0, len(blobIDs))
+	for _, blobID := range blobIDs {
+		ids = append(ids, blobID.String())
+	}
+
+	states, err := u.repository.ListViewerStates(ctx, ids, viewer.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "BlobUseCase.ViewerStates.ListViewerStates")
+	}
+
+	byID := make(map[string]models.BlobViewerState, len(states))
+	for _, state := range states {
+		byID[state.ID] = state
+	}
+
+	ordered := []models.BlobViewerState{}
+	for _, id := range ids {
+		if state, ok := byID[id]; ok {
+			ordered = append(ordered, state)
+			delete(byID, id)
+		}
+	}
+	return ordered, nil
+}
+
+// mentionedUserIDs resolves the @mentions in entities to the users they
+// notify. Unknown usernames, self-mentions and users who blocked the author
+// are left out.
+func (u *BlobUseCase) mentionedUserIDs(ctx context.Context, authorID string, entities []models.ContentEntity) ([]string, error) {
+	users, err := u.UserUseCase.GetUsersByUsernames(ctx, uniqueEntityTexts(entities, models.EntityMention))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve mentioned users")
+	}
+
+	candidateIDs := []string{}
+	for _, mentioned := range users {
+		if mentioned.ID != authorID {
+			candidateIDs = append(candidateIDs, mentioned.ID)
+		}
+	}
+
+	// Users who blocked the author never get notified by their mentions.
+	blockers, err := u.UserUseCase.relationRepo.ListBlockersAmong(ctx, authorID, candidateIDs)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to check mentioned users' block lists")
+	}
+	blockedBy := make(map[string]bool)
+	for _, blocker := range blockers {
+		blockedBy[blocker] = true
+	}
+
+	userIDs := []string{}
+	for _, userID := range candidateIDs {
+		if !blockedBy[userID] {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	return userIDs, nil
+}
+
 func (u *BlobUseCase) ListInterests(ctx context.Context) ([]*models.Interest, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobUseCase.ListInterests")
 	defer span.Finish()
@@ -63,14 +312,25 @@ func (u *BlobUseCase) ListInterests(ctx context.Context) ([]*models.Interest, er
 }
 
 
+// DeleteBlob deletes a blob of the current user. Blobs that are missing or
+// written by someone else give ErrBlobNotFound.
 func (u *BlobUseCase) DeleteBlob(ctx context.Context, blobID uuid.UUID) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobUseCase.DeleteBlob")
 	defer span.Finish()
 
-	if err := u.repository.Delete(ctx, blobID); err != nil {
+	user, err := u.UserUseCase.CurrentUser(ctx)
+	if err != nil {
 		return err
 	}
 
+	deleted, err := u.repository.Delete(ctx, blobID, user.ID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrBlobNotFound
+	}
+
 	return nil
 }
 
@@ -79,10 +339,30 @@ func (u *BlobUseCase) GetBlobByID(ctx context.Context, blobID uuid.UUID) (*model
 	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobUseCase.GetBlobByID")
 	defer span.Finish()
 
-	blobs, err := u.repository.GetByID(ctx, blobID)
+	viewer, err := u.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, err := u.repository.GetByID(ctx, blobID, viewer.ID, BlobDetailListSize)
 	if err != nil {
 		return nil, err
 	}
+	if blobs == nil {
+		return nil, nil
+	}
+
+	blobs.Entities = ExtractEntities(blobs.Content)
+	for i := range blobs.Comments {
+		blobs.Comments[i].Entities = ExtractEntities(blobs.Comments[i].Content)
+	}
+
+	if blobs.Media, err = u.blobMedia(ctx, blobs.ID); err != nil {
+		return nil, err
+	}
+	if blobs.LinkPreviews, err = u.Previews.ForContent(ctx, blobs.Content); err != nil {
+		return nil, err
+	}
 
 	return blobs, nil
 }
@@ -92,20 +372,44 @@ func (u *BlobUseCase) ListBlobs(ctx context.Context) ([]*models.BlobListWithDeta
 	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobUseCase.ListBlobs")
 	defer span.Finish()
 
-	blobs, err := u.repository.ListBlobs(ctx)
+	viewer, err := u.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, err := u.repository.ListBlobs(ctx, viewer.ID)
 	if err != nil {
 		return nil, errors.Wrap(err, "BlobUseCase.ListBlobs.repository.ListBlobs")
 	}
 
-	var blobPointers []*models.BlobListWithDetails
+	blobPointers := make([]*models.BlobListWithDetails, 0, len(blobs))
 	for _, blob := range blobs {
 		blobCopy := blob
+		blobCopy.Entities = ExtractEntities(blobCopy.Content)
 		blobPointers = append(blobPointers, &blobCopy)
 	}
 
 	if len(blobPointers) == 0 {
-		return nil, nil
+		return blobPointers, nil
+	}
+
+	if err := u.Media.AttachToList(ctx, blobPointers); err != nil {
+		return nil, err
+	}
+	if err := u.Previews.AttachToList(ctx, blobPointers); err != nil {
+		return nil, err
 	}
 
 	return blobPointers, nil
 }
+
+func (u *BlobUseCase) blobMedia(ctx context.Context, blobID string) ([]models.Media, error) {
+	byBlob, err := u.Media.ListForBlobs(ctx, []string{blobID})
+	if err != nil {
+		return nil, err
+	}
+	if byBlob[blobID] == nil {
+		return []models.Media{}, nil
+	}
+	return byBlob[blobID], nil
+}
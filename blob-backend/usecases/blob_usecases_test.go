@@ -0,0 +1,203 @@
+package usecases
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/moderation"
+)
+
+func TestRegisterBlobStoresHashtagsAndMentions(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+
+	blob, err := u.blobs.RegisterBlob(as(ana), &models.BlobWithInterests{Content: "hi @bob and @ana, see #Go"})
+	if err != nil {
+		t.Fatalf("RegisterBlob: %v", err)
+	}
+
+	if len(blob.Interests) != 1 {
+		t.Errorf("interests = %v, want the #Go interest", blob.Interests)
+	}
+	if len(blob.Entities) != 3 {
+		t.Errorf("entities = %+v", blob.Entities)
+	}
+	if blob.Media == nil || blob.LinkPreviews == nil {
+		t.Errorf("media = %v, link previews = %v, want empty lists", blob.Media, blob.LinkPreviews)
+	}
+
+	if mentions := u.mentionsOf(t, bob); len(mentions) != 1 || mentions[0].AuthorID != ana.ID {
+		t.Errorf("bob's mentions = %+v", mentions)
+	}
+	if mentions := u.mentionsOf(t, ana); len(mentions) != 0 {
+		t.Errorf("self-mention was stored: %+v", mentions)
+	}
+}
+
+func TestUpdateBlobReplacesInterestsAndMentions(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+	carol := u.store.addUser("carol")
+
+	blob, err := u.blobs.RegisterBlob(as(ana), &models.BlobWithInterests{Content: "hi @bob #go"})
+	if err != nil {
+		t.Fatalf("RegisterBlob: %v", err)
+	}
+
+	updated, err := u.blobs.UpdateBlob(as(ana), &models.BlobWithInterests{ID: blob.ID, Content: "hi @carol #rust"})
+	if err != nil || updated == nil {
+		t.Fatalf("UpdateBlob = %v, %v", updated, err)
+	}
+	if len(updated.Interests) != 1 || updated.Interests[0] == blob.Interests[0] {
+		t.Errorf("interests = %v, want only #rust", updated.Interests)
+	}
+	if mentions := u.mentionsOf(t, bob); len(mentions) != 0 {
+		t.Errorf("bob kept a mention the edit removed: %+v", mentions)
+	}
+	if mentions := u.mentionsOf(t, carol); len(mentions) != 1 || mentions[0].Content != "hi @carol #rust" {
+		t.Errorf("carol's mentions = %+v", mentions)
+	}
+
+	if other, err := u.blobs.UpdateBlob(as(bob), &models.BlobWithInterests{ID: blob.ID, Content: "hijacked @bob"}); err != nil || other != nil {
+		t.Errorf("update by another user = %+v, %v, want nil", other, err)
+	}
+	if mentions := u.mentionsOf(t, bob); len(mentions) != 0 {
+		t.Errorf("rejected update stored mentions: %+v", mentions)
+	}
+}
+
+func TestRegisterBlobSkipsMentionsOfBlockers(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+
+	if err := u.users.AddRelation(as(bob), models.RelationBlock, "ana"); err != nil {
+		t.Fatalf("AddRelation: %v", err)
+	}
+	if _, err := u.blobs.RegisterBlob(as(ana), &models.BlobWithInterests{Content: "hey @bob"}); err != nil {
+		t.Fatalf("RegisterBlob: %v", err)
+	}
+
+	if mentions := u.mentionsOf(t, bob); len(mentions) != 0 {
+		t.Errorf("mention from a blocked user was stored: %+v", mentions)
+	}
+}
+
+func TestRegisterBlobAppliesModerationFilter(t *testing.T) {
+	u := newTestUseCases(t,
+		moderation.WordListRule{Words: []string{"spam"}, Action: moderation.Reject},
+		moderation.WordListRule{Words: []string{"maybe"}, Action: moderation.Hold},
+	)
+	ana := u.store.addUser("ana")
+
+	_, err := u.blobs.RegisterBlob(as(ana), &models.BlobWithInterests{Content: "buy spam"})
+	if !errors.Is(err, moderation.ErrRejected) {
+		t.Fatalf("err = %v, want moderation.ErrRejected", err)
+	}
+	if len(u.store.blobs) != 0 {
+		t.Errorf("rejected blob was stored")
+	}
+
+	blob, err := u.blobs.RegisterBlob(as(ana), &models.BlobWithInterests{Content: "maybe fine"})
+	if err != nil {
+		t.Fatalf("RegisterBlob: %v", err)
+	}
+	if !blob.HeldForReview {
+		t.Errorf("held blob not flagged")
+	}
+	if len(u.store.reports) != 1 || u.store.reports[0].Source != models.ReportSourceFilter {
+		t.Errorf("reports = %+v, want one filter report", u.store.reports)
+	}
+}
+
+func TestReblogTwiceWithoutQuote(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+	original := u.store.addBlob(ana.ID, "original")
+
+	reblog, err := u.blobs.Reblog(as(bob), original.ID, "")
+	if err != nil {
+		t.Fatalf("Reblog: %v", err)
+	}
+	if !reblog.IsReblog || reblog.ReblogOf == nil || reblog.ReblogOf.ID != original.ID.String() {
+		t.Errorf("reblog = %+v", reblog)
+	}
+
+	if _, err := u.blobs.Reblog(as(bob), original.ID, ""); !errors.Is(err, ErrAlreadyReblogged) {
+		t.Errorf("second reblog err = %v, want ErrAlreadyReblogged", err)
+	}
+	if _, err := u.blobs.Reblog(as(bob), original.ID, "with a quote"); err != nil {
+		t.Errorf("quote reblog: %v", err)
+	}
+}
+
+func TestListBlobsReturnsEmptyList(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+
+	blobs, err := u.blobs.ListBlobs(as(ana))
+	if err != nil {
+		t.Fatalf("ListBlobs: %v", err)
+	}
+	if blobs == nil || len(blobs) != 0 {
+		t.Errorf("blobs = %#v, want an empty list", blobs)
+	}
+}
+
+func TestListFeedPaginates(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+	u.store.addUser("carol")
+	for _, content := range []string{"one", "two", "three"} {
+		u.store.addBlob(bob.ID, content)
+	}
+	u.store.addBlob(ana.ID, "mine")
+
+	if err := u.users.AddRelation(as(ana), models.RelationFollow, "bob"); err != nil {
+		t.Fatalf("AddRelation: %v", err)
+	}
+
+	feed, err := u.blobs.ListFeed(as(ana), 2, 3)
+	if err != nil {
+		t.Fatalf("ListFeed: %v", err)
+	}
+	if feed.TotalCount != 4 || feed.TotalPages != 2 || feed.HasMore {
+		t.Errorf("pagination = %+v", feed.Pagination)
+	}
+	if len(feed.Users) != 1 || feed.Users[0].Content != "one" {
+		t.Errorf("page 2 = %+v", feed.Users)
+	}
+	if feed.Users[0].Media == nil || feed.Users[0].LinkPreviews == nil {
+		t.Errorf("listing media or link previews left nil")
+	}
+}
+
+func TestDeleteBlobOnlyByItsAuthor(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+
+	blob, err := u.blobs.RegisterBlob(as(ana), &models.BlobWithInterests{Content: "mine"})
+	if err != nil {
+		t.Fatalf("RegisterBlob: %v", err)
+	}
+
+	if err := u.blobs.DeleteBlob(as(bob), blob.ID); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("DeleteBlob by another user = %v, want ErrBlobNotFound", err)
+	}
+	if u.store.blobs[blob.ID] == nil {
+		t.Fatalf("another user deleted the blob")
+	}
+
+	if err := u.blobs.DeleteBlob(as(ana), blob.ID); err != nil {
+		t.Fatalf("DeleteBlob by the author: %v", err)
+	}
+	if err := u.blobs.DeleteBlob(as(ana), blob.ID); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("DeleteBlob of a missing blob = %v, want ErrBlobNotFound", err)
+	}
+}
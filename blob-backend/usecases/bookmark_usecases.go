@@ -0,0 +1,94 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/models"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+)
+
+type BookmarkUseCase struct {
+	repository  BookmarkRepository
+	UserUseCase *UserUseCase
+}
+
+func NewBookmarkUseCase(repo BookmarkRepository, userUseCase *UserUseCase) *BookmarkUseCase {
+	return &BookmarkUseCase{
+		repository:  repo,
+		UserUseCase: userUseCase,
+	}
+}
+
+// AddBookmark saves the blob for the current user. found is false when the
+// blob does not exist or is hidden; created is false when it was already
+// bookmarked.
+func (b *BookmarkUseCase) AddBookmark(ctx context.Context, blobID uuid.UUID) (found, created bool, err error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BookmarkUseCase.AddBookmark")
+	defer span.Finish()
+
+	user, err := b.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return false, false, err
+	}
+
+	authorID, err := b.repository.GetAuthorID(ctx, blobID)
+	if err != nil {
+		return false, false, errors.Wrap(err, "BookmarkUseCase.AddBookmark.GetAuthorID")
+	}
+	if authorID == "" {
+		return false, false, nil
+	}
+
+	if err := b.UserUseCase.EnsureNotBlocked(ctx, user.ID, authorID); err != nil {
+		return true, false, err
+	}
+
+	created, err = b.repository.Add(ctx, user.ID, blobID, authorID)
+	if err != nil {
+		return true, false, errors.Wrap(err, "BookmarkUseCase.AddBookmark.Add")
+	}
+	return true, created, nil
+}
+
+// RemoveBookmark reports whether the current user had bookmarked the blob.
+// It also works for blobs that already expired.
+func (b *BookmarkUseCase) RemoveBookmark(ctx context.Context, blobID uuid.UUID) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BookmarkUseCase.RemoveBookmark")
+	defer span.Finish()
+
+	user, err := b.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	removed, err := b.repository.Remove(ctx, user.ID, blobID)
+	if err != nil {
+		return false, errors.Wrap(err, "BookmarkUseCase.RemoveBookmark.Remove")
+	}
+	return removed, nil
+}
+
+func (b *BookmarkUseCase) ListBookmarks(ctx context.Context, page, size int) (*models.BookmarkList, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BookmarkUseCase.ListBookmarks")
+	defer span.Finish()
+
+	user, err := b.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bookmarks, total, err := b.repository.List(ctx, user.ID, size, (page-1)*size)
+	if err != nil {
+		return nil, errors.Wrap(err, "BookmarkUseCase.ListBookmarks.List")
+	}
+	for _, bookmark := range bookmarks {
+		bookmark.Entities = ExtractEntities(bookmark.Content)
+	}
+
+	return &models.BookmarkList{
+		Pagination: newPagination(total, page, size),
+		Bookmarks:  bookmarks,
+	}, nil
+}
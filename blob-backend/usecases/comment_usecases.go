@@ -4,18 +4,18 @@ import (
 	"context"
 
 	"github.com/google/uuid"
+	"github.com/joaoleau/blob/metrics"
 	"github.com/joaoleau/blob/models"
-	"github.com/joaoleau/blob/repository"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 )
 
 type CommentUseCase struct {
-	commentRepo   repository.CommentRepo
+	commentRepo   CommentRepository
 	BlobUseCase   *BlobUseCase
 }
 
-func NewCommentUseCase(repo repository.CommentRepo, blobUseCase *BlobUseCase) CommentUseCase {
+func NewCommentUseCase(repo CommentRepository, blobUseCase *BlobUseCase) CommentUseCase {
 	return CommentUseCase{
 		commentRepo:  repo,
 		BlobUseCase: blobUseCase,
@@ -26,9 +26,12 @@ func (c *CommentUseCase) AddComment(ctx context.Context, comment *models.Comment
 	span, ctx := opentracing.StartSpanFromContext(ctx, "CommentUseCase.AddComment")
 	defer span.Finish()
 
-	_, err := c.BlobUseCase.GetBlobByID(ctx, comment.BlobID)
+	authorID, err := c.BlobUseCase.repository.GetAuthor(ctx, comment.BlobID)
 	if err != nil {
-		return nil, errors.Wrap(err, "LikeUseCase.AddLike.GetByID")
+		return nil, errors.Wrap(err, "CommentUseCase.AddComment.GetAuthor")
+	}
+	if authorID == "" {
+		return nil, ErrBlobNotFound
 	}
 
 	email, ok := ctx.Value("email").(string)
@@ -44,17 +47,78 @@ func (c *CommentUseCase) AddComment(ctx context.Context, comment *models.Comment
 		return nil, errors.New("authenticated user not found")
 	}
 
+	if err := c.BlobUseCase.UserUseCase.EnsureNotBlocked(ctx, user.ID, authorID); err != nil {
+		return nil, err
+	}
+
+	verdict, err := c.BlobUseCase.Moderation.Screen(comment.Content)
+	if err != nil {
+		return nil, err
+	}
+
 	comment.ID = uuid.New()
 	comment.UserID = user.ID 
+	entities := ExtractEntities(comment.Content)
+	if comment.MentionIDs, err = c.BlobUseCase.mentionedUserIDs(ctx, user.ID, entities); err != nil {
+		return nil, err
+	}
+	comment.HoldReport = c.BlobUseCase.Moderation.HoldReport(comment.BlobID, &comment.ID, user.ID, comment.Content, verdict)
 	
 	newComment, err := c.commentRepo.AddComment(ctx, comment)
 	if err != nil {
 		return nil, errors.Wrap(err, "CommentUseCase.AddComment.AddCommentRepo")
 	}
+	metrics.CommentsCreated.Inc()
+
+	newComment.HeldForReview = comment.HoldReport != nil
 
+	newComment.Entities = entities
 	return newComment, nil
 }
 
+func (c *CommentUseCase) UpdateComment(ctx context.Context, comment *models.Comment) (*models.Comment, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "CommentUseCase.UpdateComment")
+	defer span.Finish()
+
+	email, ok := ctx.Value("email").(string)
+	if !ok || email == "" {
+		return nil, errors.New("user email not found in context")
+	}
+
+	user, err := c.BlobUseCase.UserUseCase.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch user by email")
+	}
+	if user == nil {
+		return nil, errors.New("authenticated user not found")
+	}
+
+	verdict, err := c.BlobUseCase.Moderation.Screen(comment.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	comment.UserID = user.ID
+	entities := ExtractEntities(comment.Content)
+	if comment.MentionIDs, err = c.BlobUseCase.mentionedUserIDs(ctx, user.ID, entities); err != nil {
+		return nil, err
+	}
+	comment.HoldReport = c.BlobUseCase.Moderation.HoldReport(comment.BlobID, &comment.ID, user.ID, comment.Content, verdict)
+
+	updatedComment, err := c.commentRepo.UpdateComment(ctx, comment)
+	if err != nil {
+		return nil, errors.Wrap(err, "CommentUseCase.UpdateComment.UpdateCommentRepo")
+	}
+	if updatedComment == nil {
+		return nil, nil
+	}
+
+	updatedComment.HeldForReview = comment.HoldReport != nil
+
+	updatedComment.Entities = entities
+	return updatedComment, nil
+}
+
 
 func (c *CommentUseCase) RemoveComment(ctx context.Context, commentID uuid.UUID) error {
 
@@ -79,14 +143,26 @@ func (c *CommentUseCase) RemoveComment(ctx context.Context, commentID uuid.UUID)
 	return nil
 }
 
-func (c *CommentUseCase) ListCommentsByBlobID(ctx context.Context, blobID uuid.UUID) ([]models.CommentWithUser, error) {
+func (c *CommentUseCase) ListCommentsByBlobID(ctx context.Context, blobID uuid.UUID, page, size int) (*models.CommentList, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "CommentUseCase.ListCommentsByBlobID")
 	defer span.Finish()
 
-	comments, err := c.commentRepo.ListCommentsByBlobID(ctx, blobID)
+	viewer, err := c.BlobUseCase.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	comments, total, err := c.commentRepo.ListCommentsByBlobID(ctx, blobID, viewer.ID, size, (page-1)*size)
 	if err != nil {
 		return nil, errors.Wrap(err, "CommentUseCase.ListCommentsByBlobID.ListCommentsByBlobID")
 	}
 
-	return comments, nil
+	for i := range comments {
+		comments[i].Entities = ExtractEntities(comments[i].Content)
+	}
+
+	return &models.CommentList{
+		Pagination: newPagination(total, page, size),
+		Comments:   comments,
+	}, nil
 }
\ No newline at end of file
@@ -0,0 +1,83 @@
+package usecases
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/models"
+)
+
+func TestAddCommentRefusedForBlockedUsers(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+	blob := u.store.addBlob(ana.ID, "no comments from bob")
+
+	if err := u.users.AddRelation(as(ana), models.RelationBlock, "bob"); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+
+	_, err := u.comments.AddComment(as(bob), &models.Comment{BlobID: blob.ID, Content: "hi"})
+	if !errors.Is(err, ErrBlocked) {
+		t.Errorf("AddComment = %v, want ErrBlocked", err)
+	}
+
+	comment, err := u.comments.AddComment(as(ana), &models.Comment{BlobID: blob.ID, Content: "my own"})
+	if err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	list, err := u.comments.ListCommentsByBlobID(as(bob), blob.ID, 1, 10)
+	if err != nil {
+		t.Fatalf("ListCommentsByBlobID: %v", err)
+	}
+	if list.TotalCount != 1 || list.Comments[0].ID != comment.ID {
+		t.Errorf("comments = %+v", list)
+	}
+}
+
+func TestAddCommentOnMissingBlob(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+
+	_, err := u.comments.AddComment(as(ana), &models.Comment{BlobID: uuid.New(), Content: "anyone here?"})
+	if !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("AddComment = %v, want ErrBlobNotFound", err)
+	}
+	if len(u.store.comments) != 0 {
+		t.Errorf("comments = %+v", u.store.comments)
+	}
+}
+
+func TestCommentMentionsAreWrittenWithTheComment(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+	carol := u.store.addUser("carol")
+	blob := u.store.addBlob(ana.ID, "say hi")
+
+	comment, err := u.comments.AddComment(as(ana), &models.Comment{BlobID: blob.ID, Content: "hi @bob"})
+	if err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	if mentions := u.mentionsOf(t, bob); len(mentions) != 1 || mentions[0].AuthorID != ana.ID {
+		t.Errorf("bob's mentions = %+v", mentions)
+	}
+
+	if other, err := u.comments.UpdateComment(as(bob), &models.Comment{ID: comment.ID, BlobID: blob.ID, Content: "hi @carol"}); err != nil || other != nil {
+		t.Errorf("update by another user = %+v, %v, want nil", other, err)
+	}
+	if mentions := u.mentionsOf(t, carol); len(mentions) != 0 {
+		t.Errorf("rejected update stored mentions: %+v", mentions)
+	}
+
+	if _, err := u.comments.UpdateComment(as(ana), &models.Comment{ID: comment.ID, BlobID: blob.ID, Content: "hi @carol"}); err != nil {
+		t.Fatalf("UpdateComment: %v", err)
+	}
+	if mentions := u.mentionsOf(t, bob); len(mentions) != 0 {
+		t.Errorf("bob kept a mention the edit removed: %+v", mentions)
+	}
+	if mentions := u.mentionsOf(t, carol); len(mentions) != 1 {
+		t.Errorf("carol's mentions = %+v", mentions)
+	}
+}
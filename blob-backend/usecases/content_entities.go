@@ -0,0 +1,90 @@
+package usecases
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/joaoleau/blob/models"
+)
+
+const maxEntityLength = 50
+
+// ExtractEntities scans content for #tags and @username mentions.
+// A marker only starts an entity at the beginning of the text or after a
+// character that cannot be part of a word, so emails and URL fragments
+// are left alone. Offsets are rune (code point) indices, not bytes or the
+// UTF-16 units JavaScript strings use; clients slice Array.from(content).
+func ExtractEntities(content string) []models.ContentEntity {
+	runes := []rune(content)
+	entities := []models.ContentEntity{}
+
+	for i := 0; i < len(runes); i++ {
+		var entityType string
+		switch runes[i] {
+		case '#':
+			entityType = models.EntityHashtag
+		case '@':
+			entityType = models.EntityMention
+		default:
+			continue
+		}
+
+		if i > 0 && (isEntityRune(runes[i-1]) || runes[i-1] == '#' || runes[i-1] == '@') {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && end-i-1 < maxEntityLength && isEntityRune(runes[end]) {
+			end++
+		}
+		// Usernames may contain dots, but never end with one ("cc @ana.").
+		for end > i+1 && runes[end-1] == '.' {
+			end--
+		}
+		if end == i+1 {
+			continue
+		}
+
+		text := string(runes[i+1 : end])
+		if entityType == models.EntityHashtag && strings.Contains(text, ".") {
+			text = text[:strings.Index(text, ".")]
+			end = i + 1 + len([]rune(text))
+			if text == "" {
+				continue
+			}
+		}
+
+		entities = append(entities, models.ContentEntity{
+			Type:  entityType,
+			Start: i,
+			End:   end,
+			Text:  text,
+		})
+		i = end - 1
+	}
+
+	return entities
+}
+
+// uniqueEntityTexts returns the distinct, lower-cased texts of the entities
+// of the given type, in order of first appearance.
+func uniqueEntityTexts(entities []models.ContentEntity, entityType string) []string {
+	seen := make(map[string]bool)
+	var texts []string
+	for _, entity := range entities {
+		if entity.Type != entityType {
+			continue
+		}
+		text := strings.ToLower(entity.Text)
+		if seen[text] {
+			continue
+		}
+		seen[text] = true
+		texts = append(texts, text)
+	}
+	return texts
+}
+
+func isEntityRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.'
+}
@@ -0,0 +1,1151 @@
+package usecases
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/moderation"
+	"github.com/joaoleau/blob/preview"
+	"github.com/joaoleau/blob/repository"
+	"github.com/joaoleau/blob/storage"
+)
+
+// fakeStore is the in-memory database behind the fake repositories. It keeps
+// just enough state for the use cases to behave as they do against Postgres;
+// visibility rules the SQL enforces (expired or held blobs, private
+// profiles) are left out.
+type fakeStore struct {
+	users      map[string]*models.User
+	moderators map[string]bool
+	blobs      map[uuid.UUID]*models.BlobWithInterests
+	comments   map[uuid.UUID]*models.Comment
+	reactions  []models.Reaction
+	relations  map[fakeRelation]bool
+	mentions   []fakeMention
+	interests  map[string]*models.Interest
+	bookmarks  map[fakeBookmark]time.Time
+	reports    []*models.Report
+	warnings   map[string][]models.Warning
+	media      map[uuid.UUID]*models.Media
+	previews   map[string]models.LinkPreview
+	queued     map[string]int
+	now        time.Time
+}
+
+type fakeRelation struct {
+	relation, userID, targetID string
+}
+
+type fakeBookmark struct {
+	userID string
+	blobID uuid.UUID
+}
+
+type fakeMention struct {
+	userID  string
+	mention models.MentionWithAuthor
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		users:      make(map[string]*models.User),
+		moderators: make(map[string]bool),
+		blobs:      make(map[uuid.UUID]*models.BlobWithInterests),
+		comments:   make(map[uuid.UUID]*models.Comment),
+		relations:  make(map[fakeRelation]bool),
+		interests:  make(map[string]*models.Interest),
+		bookmarks:  make(map[fakeBookmark]time.Time),
+		warnings:   make(map[string][]models.Warning),
+		media:      make(map[uuid.UUID]*models.Media),
+		previews:   make(map[string]models.LinkPreview),
+		queued:     make(map[string]int),
+		now:        time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// tick returns a strictly increasing time so listings have a stable order.
+func (s *fakeStore) tick() time.Time {
+	s.now = s.now.Add(time.Second)
+	return s.now
+}
+
+func (s *fakeStore) addUser(username string) *models.User {
+	user := &models.User{
+		ID:          uuid.NewString(),
+		Name:        username,
+		Email:       username + "@example.com",
+		Username:    username,
+		AvatarIcon:  "user",
+		AvatarColor: "cyan",
+		CreatedAt:   s.tick(),
+	}
+	s.users[user.ID] = user
+	return user
+}
+
+// addModerator creates a user with the moderator role.
+func (s *fakeStore) addModerator(username string) *models.User {
+	user := s.addUser(username)
+	s.moderators[user.ID] = true
+	return user
+}
+
+func (s *fakeStore) addBlob(userID, content string) *models.BlobWithInterests {
+	now := s.tick()
+	blob := &models.BlobWithInterests{ID: uuid.New(), Content: content, UserID: userID, CreatedAt: now, UpdatedAt: now}
+	s.blobs[blob.ID] = blob
+	return blob
+}
+
+func (s *fakeStore) username(userID string) string {
+	if user := s.users[userID]; user != nil {
+		return user.Username
+	}
+	return ""
+}
+
+func (s *fakeStore) isBlockedBy(actorID, ownerID string) bool {
+	return s.relations[fakeRelation{models.RelationBlock, ownerID, actorID}]
+}
+
+func (s *fakeStore) likesCount(blobID uuid.UUID) int {
+	count := 0
+	for _, reaction := range s.reactions {
+		if reaction.BlobID == blobID && reaction.CommentID == nil && reaction.Type == models.ReactionLike {
+			count++
+		}
+	}
+	return count
+}
+
+func (s *fakeStore) likedBy(blobID uuid.UUID, userID string) bool {
+	for _, reaction := range s.reactions {
+		if reaction.BlobID == blobID && reaction.CommentID == nil && reaction.Type == models.ReactionLike && reaction.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *fakeStore) viewerState(blob *models.BlobWithInterests, viewerID string) models.ViewerState {
+	isAuthor := blob.UserID == viewerID
+	canInteract := !s.isBlockedBy(viewerID, blob.UserID)
+	_, bookmarked := s.bookmarks[fakeBookmark{viewerID, blob.ID}]
+	return models.ViewerState{
+		LikedByMe:  s.likedBy(blob.ID, viewerID),
+		IsAuthor:   isAuthor,
+		Bookmarked: bookmarked,
+		Permissions: models.BlobPermissions{
+			CanEdit:    isAuthor,
+			CanDelete:  isAuthor,
+			CanComment: canInteract,
+			CanReact:   canInteract,
+			CanReblog:  canInteract,
+		},
+	}
+}
+
+func (s *fakeStore) listItem(blob *models.BlobWithInterests, viewerID string) *models.BlobListWithDetails {
+	comments := 0
+	for _, comment := range s.comments {
+		if comment.BlobID == blob.ID {
+			comments++
+		}
+	}
+	return &models.BlobListWithDetails{
+		ID:             blob.ID.String(),
+		UserID:         blob.UserID,
+		Content:        blob.Content,
+		CreatedAt:      blob.CreatedAt,
+		UpdatedAt:      blob.UpdatedAt,
+		Username:       s.username(blob.UserID),
+		LikesCount:     s.likesCount(blob.ID),
+		CommentsCount:  comments,
+		ReactionCounts: models.ReactionCounts{},
+		IsReblog:       blob.IsReblog,
+		Interests:      blob.Interests,
+		ViewerState:    s.viewerState(blob, viewerID),
+	}
+}
+
+// sortedBlobs returns the blobs that match keep, newest first.
+func (s *fakeStore) sortedBlobs(keep func(*models.BlobWithInterests) bool) []*models.BlobWithInterests {
+	blobs := []*models.BlobWithInterests{}
+	for _, blob := range s.blobs {
+		if keep(blob) {
+			blobs = append(blobs, blob)
+		}
+	}
+	sort.Slice(blobs, func(i, j int) bool { return blobs[i].CreatedAt.After(blobs[j].CreatedAt) })
+	return blobs
+}
+
+func (s *fakeStore) blobPage(viewerID string, limit, offset int, keep func(*models.BlobWithInterests) bool) ([]*models.BlobListWithDetails, int) {
+	blobs := s.sortedBlobs(keep)
+	items := []*models.BlobListWithDetails{}
+	for _, blob := range page(blobs, limit, offset) {
+		items = append(items, s.listItem(blob, viewerID))
+	}
+	return items, len(blobs)
+}
+
+func (s *fakeStore) commentWithUser(comment *models.Comment) models.CommentWithUser {
+	return models.CommentWithUser{
+		ID:             comment.ID,
+		Content:        comment.Content,
+		CreatedAt:      comment.CreatedAt,
+		UpdatedAt:      comment.UpdatedAt,
+		UserID:         comment.UserID,
+		Username:       s.username(comment.UserID),
+		BlobID:         comment.BlobID,
+		ReactionCounts: models.ReactionCounts{},
+	}
+}
+
+func (s *fakeStore) sortedComments(keep func(*models.Comment) bool) []models.CommentWithUser {
+	comments := []models.CommentWithUser{}
+	for _, comment := range s.comments {
+		if keep(comment) {
+			comments = append(comments, s.commentWithUser(comment))
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool { return comments[i].CreatedAt.Before(comments[j].CreatedAt) })
+	return comments
+}
+
+func (s *fakeStore) reactionWithUser(reaction models.Reaction) models.ReactionWithUser {
+	return models.ReactionWithUser{Reaction: reaction, Username: s.username(reaction.UserID)}
+}
+
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return items[:0]
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
+
+type fakeBlobRepo struct{ s *fakeStore }
+
+func (r fakeBlobRepo) Create(ctx context.Context, blob *models.BlobWithInterests) (*models.BlobWithInterests, error) {
+	if blob.ReblogOfID != nil {
+		for _, other := range r.s.blobs {
+			if other.UserID == blob.UserID && other.ReblogOfID != nil && *other.ReblogOfID == *blob.ReblogOfID && other.Content == "" && blob.Content == "" {
+				return nil, repository.ErrAlreadyReblogged
+			}
+		}
+	}
+
+	created := *blob
+	created.CreatedAt = r.s.tick()
+	created.UpdatedAt = created.CreatedAt
+	created.IsReblog = blob.ReblogOfID != nil
+	created.Interests = r.s.blobInterests(blob.Interests, blob.Hashtags)
+	r.s.blobs[created.ID] = &created
+	r.s.replaceMentions(created.ID, nil, created.UserID, blob.MentionIDs)
+	if len(blob.MediaIDs) > 0 {
+		r.s.setBlobMedia(created.ID, blob.MediaIDs)
+	}
+	if blob.HoldReport != nil {
+		r.s.fileReport(blob.HoldReport)
+	}
+
+	result := created
+	return &result, nil
+}
+
+func (r fakeBlobRepo) Update(ctx context.Context, blob *models.BlobWithInterests) (*models.BlobWithInterests, error) {
+	existing := r.s.blobs[blob.ID]
+	if existing == nil || existing.UserID != blob.UserID {
+		return nil, nil
+	}
+	existing.Content = blob.Content
+	existing.Interests = r.s.blobInterests(blob.Interests, blob.Hashtags)
+	existing.UpdatedAt = r.s.tick()
+	r.s.replaceMentions(existing.ID, nil, existing.UserID, blob.MentionIDs)
+	if blob.MediaIDs != nil {
+		r.s.setBlobMedia(existing.ID, blob.MediaIDs)
+	}
+	if blob.HoldReport != nil {
+		r.s.fileReport(blob.HoldReport)
+	}
+
+	result := *existing
+	return &result, nil
+}
+
+func (r fakeBlobRepo) Delete(ctx context.Context, blobID uuid.UUID, userID string) (bool, error) {
+	blob := r.s.blobs[blobID]
+	if blob == nil || blob.UserID != userID {
+		return false, nil
+	}
+	delete(r.s.blobs, blobID)
+	return true, nil
+}
+
+func (r fakeBlobRepo) GetByID(ctx context.Context, blobID uuid.UUID, viewerID string, limit int) (*models.BlobWithDetails, error) {
+	blob := r.s.blobs[blobID]
+	if blob == nil {
+		return nil, nil
+	}
+	item := r.s.listItem(blob, viewerID)
+
+	likes := []models.ReactionWithUser{}
+	for _, reaction := range r.s.reactions {
+		if reaction.BlobID == blobID && reaction.CommentID == nil && reaction.Type == models.ReactionLike {
+			likes = append(likes, r.s.reactionWithUser(reaction))
+		}
+	}
+
+	return &models.BlobWithDetails{
+		ID:             item.ID,
+		UserID:         item.UserID,
+		Content:        item.Content,
+		CreatedAt:      item.CreatedAt,
+		UpdatedAt:      item.UpdatedAt,
+		Username:       item.Username,
+		LikesCount:     item.LikesCount,
+		CommentsCount:  item.CommentsCount,
+		ReactionCounts: item.ReactionCounts,
+		IsReblog:       item.IsReblog,
+		Comments:       page(r.s.sortedComments(func(c *models.Comment) bool { return c.BlobID == blobID }), limit, 0),
+		Likes:          page(likes, limit, 0),
+		Interests:      []models.Interest{},
+		ViewerState:    item.ViewerState,
+	}, nil
+}
+
+func (r fakeBlobRepo) ListBlobs(ctx context.Context, viewerID string) ([]models.BlobListWithDetails, error) {
+	blobs := []models.BlobListWithDetails{}
+	for _, blob := range r.s.sortedBlobs(func(*models.BlobWithInterests) bool { return true }) {
+		blobs = append(blobs, *r.s.listItem(blob, viewerID))
+	}
+	return blobs, nil
+}
+
+func (r fakeBlobRepo) ListFeed(ctx context.Context, viewerID string, limit, offset int) ([]*models.BlobListWithDetails, int, error) {
+	items, total := r.s.blobPage(viewerID, limit, offset, func(blob *models.BlobWithInterests) bool {
+		return blob.UserID == viewerID || r.s.relations[fakeRelation{models.RelationFollow, viewerID, blob.UserID}]
+	})
+	return items, total, nil
+}
+
+func (r fakeBlobRepo) ListTrending(ctx context.Context, viewerID string, limit, offset int) ([]*models.BlobListWithDetails, int, error) {
+	blobs := r.s.sortedBlobs(func(*models.BlobWithInterests) bool { return true })
+	sort.SliceStable(blobs, func(i, j int) bool { return r.s.likesCount(blobs[i].ID) > r.s.likesCount(blobs[j].ID) })
+
+	items := []*models.BlobListWithDetails{}
+	for _, blob := range page(blobs, limit, offset) {
+		items = append(items, r.s.listItem(blob, viewerID))
+	}
+	return items, len(blobs), nil
+}
+
+func (r fakeBlobRepo) ListViewerStates(ctx context.Context, blobIDs []string, viewerID string) ([]models.BlobViewerState, error) {
+	states := []models.BlobViewerState{}
+	for _, id := range blobIDs {
+		blobID, err := uuid.Parse(id)
+		if err != nil {
+			continue
+		}
+		if blob := r.s.blobs[blobID]; blob != nil {
+			states = append(states, models.BlobViewerState{ID: id, ViewerState: r.s.viewerState(blob, viewerID)})
+		}
+	}
+	return states, nil
+}
+
+func (r fakeBlobRepo) GetAuthor(ctx context.Context, blobID uuid.UUID) (string, error) {
+	blob := r.s.blobs[blobID]
+	if blob == nil {
+		return "", nil
+	}
+	return blob.UserID, nil
+}
+
+func (r fakeBlobRepo) GetReblogTarget(ctx context.Context, blobID uuid.UUID) (string, string, error) {
+	blob := r.s.blobs[blobID]
+	if blob == nil {
+		return "", "", nil
+	}
+	return blob.ID.String(), blob.UserID, nil
+}
+
+func (r fakeBlobRepo) GetReblogOriginal(ctx context.Context, originalID *string) (*models.ReblogOf, error) {
+	if originalID == nil {
+		return nil, nil
+	}
+	blobID, err := uuid.Parse(*originalID)
+	if err != nil {
+		return nil, err
+	}
+	blob := r.s.blobs[blobID]
+	if blob == nil {
+		return &models.ReblogOf{Available: false}, nil
+	}
+	return &models.ReblogOf{
+		Available: true,
+		ID:        blob.ID.String(),
+		UserID:    blob.UserID,
+		Username:  r.s.username(blob.UserID),
+		Content:   blob.Content,
+		CreatedAt: &blob.CreatedAt,
+	}, nil
+}
+
+// blobInterests links interestIDs and the interests of hashtags, creating
+// missing ones, like the repository does as it writes a blob.
+func (s *fakeStore) blobInterests(interestIDs, hashtags []string) []string {
+	ids := append([]string{}, interestIDs...)
+	for _, name := range hashtags {
+		name = strings.ToLower(name)
+		interest := s.interests[name]
+		if interest == nil {
+			interest = &models.Interest{ID: uuid.New(), Name: name, CreatedAt: s.tick()}
+			s.interests[name] = interest
+		}
+		ids = append(ids, interest.ID.String())
+	}
+
+	seen := make(map[string]bool)
+	linked := []string{}
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			linked = append(linked, id)
+		}
+	}
+	return linked
+}
+
+func (r fakeBlobRepo) ListAllInterests(ctx context.Context) ([]*models.Interest, error) {
+	interests := []*models.Interest{}
+	for _, interest := range r.s.interests {
+		interests = append(interests, interest)
+	}
+	sort.Slice(interests, func(i, j int) bool { return interests[i].Name < interests[j].Name })
+	return interests, nil
+}
+
+type fakeCommentRepo struct{ s *fakeStore }
+
+func (r fakeCommentRepo) AddComment(ctx context.Context, comment *models.Comment) (*models.Comment, error) {
+	if r.s.blobs[comment.BlobID] == nil {
+		return nil, repository.ErrBlobNotFound
+	}
+	created := *comment
+	created.CreatedAt = r.s.tick()
+	created.UpdatedAt = created.CreatedAt
+	r.s.comments[created.ID] = &created
+	r.s.replaceMentions(created.BlobID, &created.ID, created.UserID, comment.MentionIDs)
+	if comment.HoldReport != nil {
+		r.s.fileReport(comment.HoldReport)
+	}
+
+	result := created
+	return &result, nil
+}
+
+func (r fakeCommentRepo) UpdateComment(ctx context.Context, comment *models.Comment) (*models.Comment, error) {
+	existing := r.s.comments[comment.ID]
+	if existing == nil || existing.UserID != comment.UserID {
+		return nil, nil
+	}
+	existing.Content = comment.Content
+	existing.UpdatedAt = r.s.tick()
+	r.s.replaceMentions(existing.BlobID, &existing.ID, existing.UserID, comment.MentionIDs)
+	if comment.HoldReport != nil {
+		r.s.fileReport(comment.HoldReport)
+	}
+
+	result := *existing
+	return &result, nil
+}
+
+func (r fakeCommentRepo) RemoveComment(ctx context.Context, userID string, commentID uuid.UUID) error {
+	if comment := r.s.comments[commentID]; comment != nil && comment.UserID == userID {
+		delete(r.s.comments, commentID)
+	}
+	return nil
+}
+
+func (r fakeCommentRepo) ListCommentsByBlobID(ctx context.Context, blobID uuid.UUID, viewerID string, limit, offset int) ([]models.CommentWithUser, int, error) {
+	comments := r.s.sortedComments(func(c *models.Comment) bool { return c.BlobID == blobID })
+	return page(comments, limit, offset), len(comments), nil
+}
+
+type fakeUserRepo struct{ s *fakeStore }
+
+func (r fakeUserRepo) GetUserById(ctx context.Context, userID string) (*models.User, error) {
+	if user := r.s.users[userID]; user != nil {
+		result := *user
+		return &result, nil
+	}
+	return nil, nil
+}
+
+func (r fakeUserRepo) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	for _, user := range r.s.users {
+		if user.Email == email {
+			result := *user
+			return &result, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r fakeUserRepo) GetByUsernames(ctx context.Context, usernames []string) ([]models.User, error) {
+	users := []models.User{}
+	for _, username := range usernames {
+		for _, user := range r.s.users {
+			if strings.EqualFold(user.Username, username) {
+				users = append(users, *user)
+			}
+		}
+	}
+	return users, nil
+}
+
+func (r fakeUserRepo) GetProfile(ctx context.Context, username, viewerID string) (*models.PublicProfile, error) {
+	users, _ := r.GetByUsernames(ctx, []string{username})
+	if len(users) == 0 {
+		return nil, nil
+	}
+	user := users[0]
+
+	profile := &models.PublicProfile{
+		ID:           user.ID,
+		Username:     user.Username,
+		Name:         user.Name,
+		AvatarIcon:   user.AvatarIcon,
+		AvatarColor:  user.AvatarColor,
+		CreatedAt:    user.CreatedAt,
+		FollowedByMe: r.s.relations[fakeRelation{models.RelationFollow, viewerID, user.ID}],
+		FollowsMe:    r.s.relations[fakeRelation{models.RelationFollow, user.ID, viewerID}],
+		BlockedMe:    r.s.isBlockedBy(viewerID, user.ID),
+	}
+	if user.PrivateProfile != nil {
+		profile.PrivateProfile = *user.PrivateProfile
+	}
+	if user.HideLikes != nil {
+		profile.HideLikes = *user.HideLikes
+	}
+	for _, blob := range r.s.blobs {
+		if blob.UserID == user.ID {
+			profile.BlobsCount++
+		}
+	}
+	return profile, nil
+}
+
+func (r fakeUserRepo) GetRenamedUsername(ctx context.Context, username string) (string, error) {
+	return "", nil
+}
+
+func (r fakeUserRepo) ListBlobs(ctx context.Context, userID, viewerID string, limit, offset int) ([]*models.BlobListWithDetails, int, error) {
+	items, total := r.s.blobPage(viewerID, limit, offset, func(blob *models.BlobWithInterests) bool {
+		return blob.UserID == userID
+	})
+	return items, total, nil
+}
+
+func (r fakeUserRepo) ListLikedBlobs(ctx context.Context, userID, viewerID string, limit, offset int) ([]*models.BlobListWithDetails, int, error) {
+	items, total := r.s.blobPage(viewerID, limit, offset, func(blob *models.BlobWithInterests) bool {
+		return r.s.likedBy(blob.ID, userID)
+	})
+	return items, total, nil
+}
+
+func (r fakeUserRepo) ListComments(ctx context.Context, userID string, limit, offset int) ([]models.CommentWithUser, int, error) {
+	comments := r.s.sortedComments(func(c *models.Comment) bool { return c.UserID == userID })
+	return page(comments, limit, offset), len(comments), nil
+}
+
+func (r fakeUserRepo) GetStats(ctx context.Context, userID string) (*models.UserStats, error) {
+	stats := &models.UserStats{}
+	for _, blob := range r.s.blobs {
+		if blob.UserID == userID {
+			stats.BlobsCount++
+			stats.LikesReceived += r.s.likesCount(blob.ID)
+		}
+	}
+	for key := range r.s.bookmarks {
+		if key.userID == userID {
+			stats.BookmarksSaved++
+		}
+	}
+	return stats, nil
+}
+
+func (r fakeUserRepo) Export(ctx context.Context, user *models.User) (*models.UserExport, error) {
+	export := &models.UserExport{
+		ExportedAt: r.s.tick(),
+		Profile:    user,
+		Blobs:      []models.Blob{},
+		Comments:   []models.Comment{},
+		Reactions:  []models.Reaction{},
+		Sessions:   []models.Session{},
+	}
+	for _, blob := range r.s.sortedBlobs(func(blob *models.BlobWithInterests) bool { return blob.UserID == user.ID }) {
+		export.Blobs = append(export.Blobs, models.Blob{ID: blob.ID, Content: blob.Content, CreatedAt: blob.CreatedAt, UpdatedAt: blob.UpdatedAt, UserID: blob.UserID})
+	}
+	return export, nil
+}
+
+func (r fakeUserRepo) ScheduleDeletion(ctx context.Context, userID string, grace time.Duration) (time.Time, error) {
+	user := r.s.users[userID]
+	if user == nil {
+		return time.Time{}, sql.ErrNoRows
+	}
+	if user.DeletionScheduledAt == nil {
+		scheduledAt := r.s.now.Add(grace)
+		user.DeletionScheduledAt = &scheduledAt
+	}
+	return *user.DeletionScheduledAt, nil
+}
+
+func (r fakeUserRepo) CancelDeletion(ctx context.Context, userID string) (bool, error) {
+	user := r.s.users[userID]
+	if user == nil || user.DeletionScheduledAt == nil {
+		return false, nil
+	}
+	user.DeletionScheduledAt = nil
+	return true, nil
+}
+
+func (r fakeUserRepo) UpdateUser(ctx context.Context, user *models.User, updatedData models.User) error {
+	stored := r.s.users[user.ID]
+	if stored == nil {
+		return nil
+	}
+	for _, field := range []struct{ value, target *string }{
+		{&updatedData.Name, &stored.Name},
+		{&updatedData.Email, &stored.Email},
+		{&updatedData.Username, &stored.Username},
+		{&updatedData.Bio, &stored.Bio},
+		{&updatedData.Image, &stored.Image},
+		{&updatedData.AvatarIcon, &stored.AvatarIcon},
+		{&updatedData.AvatarColor, &stored.AvatarColor},
+	} {
+		if *field.value != "" {
+			*field.target = *field.value
+		}
+	}
+	stored.UpdatedAt = r.s.tick()
+	return nil
+}
+
+type fakeReactionRepo struct{ s *fakeStore }
+
+func (r fakeReactionRepo) GetTargetAuthor(ctx context.Context, blobID uuid.UUID, commentID *uuid.UUID) (string, error) {
+	if commentID != nil {
+		if comment := r.s.comments[*commentID]; comment != nil && comment.BlobID == blobID {
+			return comment.UserID, nil
+		}
+		return "", nil
+	}
+	if blob := r.s.blobs[blobID]; blob != nil {
+		return blob.UserID, nil
+	}
+	return "", nil
+}
+
+func (r fakeReactionRepo) find(userID string, blobID uuid.UUID, commentID *uuid.UUID, reactionType string) int {
+	for i, reaction := range r.s.reactions {
+		if reaction.UserID == userID && reaction.BlobID == blobID && sameComment(reaction.CommentID, commentID) && reaction.Type == reactionType {
+			return i
+		}
+	}
+	return -1
+}
+
+func (r fakeReactionRepo) Add(ctx context.Context, reaction *models.Reaction) (*models.Reaction, error) {
+	if r.find(reaction.UserID, reaction.BlobID, reaction.CommentID, reaction.Type) >= 0 {
+		return nil, repository.ErrAlreadyReacted
+	}
+	created := *reaction
+	created.CreatedAt = r.s.tick()
+	r.s.reactions = append(r.s.reactions, created)
+	return &created, nil
+}
+
+func (r fakeReactionRepo) Remove(ctx context.Context, userID string, blobID uuid.UUID, commentID *uuid.UUID, reactionType string) (bool, error) {
+	i := r.find(userID, blobID, commentID, reactionType)
+	if i < 0 {
+		return false, nil
+	}
+	r.s.reactions = append(r.s.reactions[:i], r.s.reactions[i+1:]...)
+	return true, nil
+}
+
+func (r fakeReactionRepo) List(ctx context.Context, blobID uuid.UUID, commentID *uuid.UUID, viewerID string) ([]models.ReactionWithUser, error) {
+	reactions := []models.ReactionWithUser{}
+	for _, reaction := range r.s.reactions {
+		if reaction.BlobID == blobID && sameComment(reaction.CommentID, commentID) {
+			reactions = append(reactions, r.s.reactionWithUser(reaction))
+		}
+	}
+	return reactions, nil
+}
+
+func (r fakeReactionRepo) AddLike(ctx context.Context, id uuid.UUID, userID string, blobID uuid.UUID) (bool, error) {
+	_, err := r.Add(ctx, &models.Reaction{ID: id, UserID: userID, BlobID: blobID, Type: models.ReactionLike})
+	if err == repository.ErrAlreadyReacted {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r fakeReactionRepo) BlobExists(ctx context.Context, blobID uuid.UUID) (bool, error) {
+	return r.s.blobs[blobID] != nil, nil
+}
+
+func (r fakeReactionRepo) GetLikeState(ctx context.Context, blobID uuid.UUID, userID string) (*models.LikeState, error) {
+	return &models.LikeState{
+		BlobID:     blobID,
+		LikesCount: r.s.likesCount(blobID),
+		LikedByMe:  r.s.likedBy(blobID, userID),
+	}, nil
+}
+
+func (r fakeReactionRepo) ListLikes(ctx context.Context, blobID uuid.UUID, viewerID string, limit, offset int) ([]models.ReactionWithUser, int, error) {
+	likes := []models.ReactionWithUser{}
+	for _, reaction := range r.s.reactions {
+		if reaction.BlobID == blobID && reaction.CommentID == nil && reaction.Type == models.ReactionLike {
+			likes = append(likes, r.s.reactionWithUser(reaction))
+		}
+	}
+	return page(likes, limit, offset), len(likes), nil
+}
+
+func sameComment(a, b *uuid.UUID) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+type fakeBookmarkRepo struct{ s *fakeStore }
+
+func (r fakeBookmarkRepo) GetAuthorID(ctx context.Context, blobID uuid.UUID) (string, error) {
+	if blob := r.s.blobs[blobID]; blob != nil {
+		return blob.UserID, nil
+	}
+	return "", nil
+}
+
+func (r fakeBookmarkRepo) Add(ctx context.Context, userID string, blobID uuid.UUID, authorID string) (bool, error) {
+	key := fakeBookmark{userID, blobID}
+	if _, ok := r.s.bookmarks[key]; ok {
+		return false, nil
+	}
+	r.s.bookmarks[key] = r.s.tick()
+	return true, nil
+}
+
+func (r fakeBookmarkRepo) Remove(ctx context.Context, userID string, blobID uuid.UUID) (bool, error) {
+	key := fakeBookmark{userID, blobID}
+	if _, ok := r.s.bookmarks[key]; !ok {
+		return false, nil
+	}
+	delete(r.s.bookmarks, key)
+	return true, nil
+}
+
+func (r fakeBookmarkRepo) List(ctx context.Context, userID string, limit, offset int) ([]*models.Bookmark, int, error) {
+	bookmarks := []*models.Bookmark{}
+	for key, at := range r.s.bookmarks {
+		if key.userID != userID {
+			continue
+		}
+		bookmark := &models.Bookmark{BlobID: key.blobID.String(), BookmarkedAt: at, Expired: true, Interests: []string{}}
+		if blob := r.s.blobs[key.blobID]; blob != nil {
+			bookmark.UserID = blob.UserID
+			bookmark.Username = r.s.username(blob.UserID)
+			bookmark.Content = blob.Content
+			bookmark.BlobCreatedAt = blob.CreatedAt
+			bookmark.Expired = false
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+	sort.Slice(bookmarks, func(i, j int) bool { return bookmarks[i].BookmarkedAt.After(bookmarks[j].BookmarkedAt) })
+	return page(bookmarks, limit, offset), len(bookmarks), nil
+}
+
+type fakeMentionRepo struct{ s *fakeStore }
+
+func (s *fakeStore) replaceMentions(blobID uuid.UUID, commentID *uuid.UUID, authorID string, userIDs []string) {
+	kept := s.mentions[:0]
+	for _, m := range s.mentions {
+		if m.mention.BlobID != blobID || !sameComment(m.mention.CommentID, commentID) {
+			kept = append(kept, m)
+		}
+	}
+	s.mentions = kept
+
+	content := ""
+	if commentID != nil {
+		if comment := s.comments[*commentID]; comment != nil {
+			content = comment.Content
+		}
+	} else if blob := s.blobs[blobID]; blob != nil {
+		content = blob.Content
+	}
+
+	for _, userID := range userIDs {
+		s.mentions = append(s.mentions, fakeMention{userID: userID, mention: models.MentionWithAuthor{
+			ID:             uuid.New(),
+			BlobID:         blobID,
+			CommentID:      commentID,
+			AuthorID:       authorID,
+			AuthorUsername: s.username(authorID),
+			Content:        content,
+			CreatedAt:      s.tick(),
+		}})
+	}
+}
+
+func (r fakeMentionRepo) ListByMentionedUser(ctx context.Context, userID string, limit, offset int) ([]models.MentionWithAuthor, int, error) {
+	mentions := []models.MentionWithAuthor{}
+	for i := len(r.s.mentions) - 1; i >= 0; i-- {
+		m := r.s.mentions[i]
+		if m.userID == userID {
+			mentions = append(mentions, m.mention)
+		}
+	}
+	return page(mentions, limit, offset), len(mentions), nil
+}
+
+type fakeRelationRepo struct{ s *fakeStore }
+
+func (r fakeRelationRepo) Add(ctx context.Context, relation, userID, targetID string) error {
+	r.s.relations[fakeRelation{relation, userID, targetID}] = true
+	if relation == models.RelationBlock {
+		delete(r.s.relations, fakeRelation{models.RelationFollow, userID, targetID})
+		delete(r.s.relations, fakeRelation{models.RelationFollow, targetID, userID})
+	}
+	return nil
+}
+
+func (r fakeRelationRepo) Remove(ctx context.Context, relation, userID, targetID string) error {
+	delete(r.s.relations, fakeRelation{relation, userID, targetID})
+	return nil
+}
+
+func (r fakeRelationRepo) List(ctx context.Context, relation, userID string) ([]models.RelatedUser, error) {
+	users := []models.RelatedUser{}
+	for key := range r.s.relations {
+		if key.relation == relation && key.userID == userID {
+			if target := r.s.users[key.targetID]; target != nil {
+				users = append(users, models.RelatedUser{ID: target.ID, Username: target.Username, AvatarIcon: target.AvatarIcon, AvatarColor: target.AvatarColor})
+			}
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+func (r fakeRelationRepo) IsBlockedBy(ctx context.Context, actorID, ownerID string) (bool, error) {
+	return r.s.isBlockedBy(actorID, ownerID), nil
+}
+
+func (r fakeRelationRepo) ListBlockersAmong(ctx context.Context, actorID string, userIDs []string) ([]string, error) {
+	blockers := []string{}
+	for _, userID := range userIDs {
+		if r.s.isBlockedBy(actorID, userID) {
+			blockers = append(blockers, userID)
+		}
+	}
+	return blockers, nil
+}
+
+type fakeMediaRepo struct{ s *fakeStore }
+
+func (r fakeMediaRepo) Create(ctx context.Context, item *models.Media) (*models.Media, error) {
+	created := *item
+	created.CreatedAt = r.s.tick()
+	r.s.media[created.ID] = &created
+
+	result := created
+	return &result, nil
+}
+
+func (r fakeMediaRepo) CheckAttachable(ctx context.Context, blobID uuid.UUID, userID string, ids []uuid.UUID) error {
+	for _, id := range ids {
+		item := r.s.media[id]
+		if item == nil || item.UserID == nil || *item.UserID != userID || (item.BlobID != nil && *item.BlobID != blobID) {
+			return repository.ErrMediaUnavailable
+		}
+	}
+	return nil
+}
+
+func (r fakeMediaRepo) ListByBlobs(ctx context.Context, blobIDs []string) ([]models.Media, error) {
+	items := []models.Media{}
+	for _, id := range blobIDs {
+		for _, item := range r.s.media {
+			if item.BlobID != nil && item.BlobID.String() == id {
+				items = append(items, *item)
+			}
+		}
+	}
+	return items, nil
+}
+
+func (r fakeMediaRepo) DeleteExpired(ctx context.Context, limit int) ([]models.Media, error) {
+	expired := []models.Media{}
+	for _, item := range r.s.media {
+		if len(expired) == limit {
+			break
+		}
+		if (item.ExpiresAt != nil && item.ExpiresAt.Before(r.s.now)) || item.UserID == nil {
+			expired = append(expired, *item)
+			delete(r.s.media, item.ID)
+		}
+	}
+	return expired, nil
+}
+
+type fakeLinkPreviewRepo struct{ s *fakeStore }
+
+func (r fakeLinkPreviewRepo) Enqueue(ctx context.Context, urls []string) error {
+	for _, url := range urls {
+		if _, ready := r.s.previews[url]; !ready {
+			r.s.queued[url] = 0
+		}
+	}
+	return nil
+}
+
+func (r fakeLinkPreviewRepo) Claim(ctx context.Context, limit int) ([]models.LinkPreviewJob, error) {
+	jobs := []models.LinkPreviewJob{}
+	for url, attempts := range r.s.queued {
+		if len(jobs) == limit {
+			break
+		}
+		jobs = append(jobs, models.LinkPreviewJob{URL: url, Attempts: attempts + 1})
+		delete(r.s.queued, url)
+	}
+	return jobs, nil
+}
+
+func (r fakeLinkPreviewRepo) Complete(ctx context.Context, url string, metadata *preview.Metadata) error {
+	r.s.previews[url] = models.LinkPreview{
+		URL:         url,
+		Title:       metadata.Title,
+		Description: metadata.Description,
+		ImageURL:    metadata.ImageURL,
+		SiteName:    metadata.SiteName,
+	}
+	return nil
+}
+
+func (r fakeLinkPreviewRepo) Fail(ctx context.Context, url string, maxAttempts int, reason string, retryIn time.Duration) error {
+	return nil
+}
+
+func (r fakeLinkPreviewRepo) ListReady(ctx context.Context, urls []string) ([]models.LinkPreview, error) {
+	previews := []models.LinkPreview{}
+	for _, url := range urls {
+		if p, ok := r.s.previews[url]; ok {
+			previews = append(previews, p)
+		}
+	}
+	return previews, nil
+}
+
+type fakeModerationRepo struct{ s *fakeStore }
+
+func (r fakeModerationRepo) GetReportTarget(ctx context.Context, blobID uuid.UUID, commentID *uuid.UUID) (*models.Report, error) {
+	if commentID != nil {
+		comment := r.s.comments[*commentID]
+		if comment == nil || comment.BlobID != blobID {
+			return nil, nil
+		}
+		return &models.Report{BlobID: &blobID, CommentID: commentID, TargetUserID: comment.UserID, Content: comment.Content}, nil
+	}
+	blob := r.s.blobs[blobID]
+	if blob == nil {
+		return nil, nil
+	}
+	return &models.Report{BlobID: &blobID, TargetUserID: blob.UserID, Content: blob.Content}, nil
+}
+
+func (r fakeModerationRepo) HasOpenReport(ctx context.Context, reporterID string, blobID uuid.UUID, commentID *uuid.UUID) (bool, error) {
+	for _, report := range r.s.reports {
+		if report.ReporterID != nil && *report.ReporterID == reporterID && report.BlobID != nil && *report.BlobID == blobID &&
+			sameComment(report.CommentID, commentID) && (report.Status == models.ReportStatusOpen || report.Status == models.ReportStatusAssigned) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *fakeStore) setBlobMedia(blobID uuid.UUID, ids []uuid.UUID) {
+	kept := make(map[uuid.UUID]bool)
+	for _, id := range ids {
+		kept[id] = true
+	}
+	for _, item := range s.media {
+		if item.BlobID != nil && *item.BlobID == blobID && !kept[item.ID] {
+			detachedAt := s.now
+			item.BlobID = nil
+			item.ExpiresAt = &detachedAt
+		}
+	}
+	for _, id := range ids {
+		if item := s.media[id]; item != nil {
+			attached := blobID
+			item.BlobID = &attached
+			item.ExpiresAt = nil
+		}
+	}
+}
+
+func (s *fakeStore) fileReport(report *models.Report) *models.Report {
+	created := *report
+	created.Status = models.ReportStatusOpen
+	created.CreatedAt = s.tick()
+	created.UpdatedAt = created.CreatedAt
+	s.reports = append(s.reports, &created)
+	return &created
+}
+
+func (r fakeModerationRepo) CreateReport(ctx context.Context, report *models.Report) (*models.Report, error) {
+	result := *r.s.fileReport(report)
+	return &result, nil
+}
+
+func (r fakeModerationRepo) ListReports(ctx context.Context, status string, limit, offset int) ([]*models.Report, int, error) {
+	reports := []*models.Report{}
+	for _, report := range r.s.reports {
+		if status == "" || report.Status == status {
+			reports = append(reports, report)
+		}
+	}
+	return page(reports, limit, offset), len(reports), nil
+}
+
+func (r fakeModerationRepo) find(reportID uuid.UUID) *models.Report {
+	for _, report := range r.s.reports {
+		if report.ID == reportID {
+			return report
+		}
+	}
+	return nil
+}
+
+func (r fakeModerationRepo) AssignReport(ctx context.Context, reportID uuid.UUID, assigneeID, moderatorID string) (*models.Report, error) {
+	if !r.s.moderators[assigneeID] {
+		return nil, repository.ErrAssigneeNotModerator
+	}
+	report := r.find(reportID)
+	if report == nil {
+		return nil, nil
+	}
+	report.AssigneeID = &assigneeID
+	report.Status = models.ReportStatusAssigned
+	return report, nil
+}
+
+func (r fakeModerationRepo) ResolveReport(ctx context.Context, reportID uuid.UUID, action, note, moderatorID string) (*models.Report, error) {
+	report := r.find(reportID)
+	if report == nil {
+		return nil, nil
+	}
+	resolvedAt := r.s.tick()
+	report.Status = models.ReportStatusResolved
+	report.Action = &action
+	report.ResolutionNote = &note
+	report.ResolvedAt = &resolvedAt
+	if action == models.ModerationActionWarn {
+		r.s.warnings[report.TargetUserID] = append([]models.Warning{{
+			ID: uuid.New(), ReportID: &reportID, Reason: report.Reason, Note: note, CreatedAt: resolvedAt,
+		}}, r.s.warnings[report.TargetUserID]...)
+	}
+	return report, nil
+}
+
+func (r fakeModerationRepo) ListWarnings(ctx context.Context, userID string, limit, offset int) ([]models.Warning, error) {
+	return page(append([]models.Warning{}, r.s.warnings[userID]...), limit, offset), nil
+}
+
+func (r fakeModerationRepo) ListAuditLog(ctx context.Context, limit, offset int) ([]models.AuditLogEntry, error) {
+	return []models.AuditLogEntry{}, nil
+}
+
+// testUseCases wires every use case to one fakeStore the way SetupRouters
+// wires them to Postgres.
+type testUseCases struct {
+	store      *fakeStore
+	users      *UserUseCase
+	blobs      *BlobUseCase
+	comments   *CommentUseCase
+	reactions  *ReactionUseCase
+	bookmarks  *BookmarkUseCase
+	moderation *ModerationUseCase
+	media      *MediaUseCase
+	mediaDir   string
+}
+
+func newTestUseCases(t *testing.T, rules ...moderation.Rule) *testUseCases {
+	t.Helper()
+
+	s := newFakeStore()
+	mediaDir := t.TempDir()
+	mediaUseCase := NewMediaUseCase(fakeMediaRepo{s}, storage.NewLocalStorage(mediaDir, "http://media.test"))
+	previewUseCase := NewLinkPreviewUseCase(fakeLinkPreviewRepo{s}, nil)
+	userUseCase := NewUserUseCase(fakeUserRepo{s}, fakeMentionRepo{s}, fakeRelationRepo{s}, mediaUseCase, previewUseCase)
+	moderationUseCase := NewModerationUseCase(fakeModerationRepo{s}, moderation.NewFilter(rules...), userUseCase)
+	blobUseCase := NewBlobUseCase(fakeBlobRepo{s}, moderationUseCase, userUseCase, mediaUseCase, previewUseCase)
+	commentUseCase := NewCommentUseCase(fakeCommentRepo{s}, &blobUseCase)
+
+	return &testUseCases{
+		store:      s,
+		users:      userUseCase,
+		blobs:      &blobUseCase,
+		comments:   &commentUseCase,
+		reactions:  NewReactionUseCase(fakeReactionRepo{s}, userUseCase),
+		bookmarks:  NewBookmarkUseCase(fakeBookmarkRepo{s}, userUseCase),
+		moderation: moderationUseCase,
+		media:      mediaUseCase,
+		mediaDir:   mediaDir,
+	}
+}
+
+// as returns a context authenticated as user, like the auth middleware does.
+func as(user *models.User) context.Context {
+	return context.WithValue(context.Background(), "email", user.Email)
+}
+
+// mentionsOf lists the first page of mentions of user.
+func (u *testUseCases) mentionsOf(t *testing.T, user *models.User) []models.MentionWithAuthor {
+	t.Helper()
+	list, err := u.users.ListMentions(as(user), user.Email, 1, 10)
+	if err != nil {
+		t.Fatalf("ListMentions: %v", err)
+	}
+	return list.Mentions
+}
+
+var (
+	_ BlobRepository        = fakeBlobRepo{}
+	_ CommentRepository     = fakeCommentRepo{}
+	_ UserRepository        = fakeUserRepo{}
+	_ ReactionRepository    = fakeReactionRepo{}
+	_ BookmarkRepository    = fakeBookmarkRepo{}
+	_ MentionRepository     = fakeMentionRepo{}
+	_ RelationRepository    = fakeRelationRepo{}
+	_ MediaRepository       = fakeMediaRepo{}
+	_ LinkPreviewRepository = fakeLinkPreviewRepo{}
+	_ ModerationRepository  = fakeModerationRepo{}
+)
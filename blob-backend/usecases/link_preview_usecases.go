@@ -0,0 +1,159 @@
+package usecases
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/joaoleau/blob/internal/logging"
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/preview"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+)
+
+const (
+	linkPreviewBatch       = 10
+	linkPreviewMaxAttempts = 3
+)
+
+type LinkPreviewUseCase struct {
+	repository LinkPreviewRepository
+	fetcher    *preview.Fetcher
+}
+
+func NewLinkPreviewUseCase(repo LinkPreviewRepository, fetcher *preview.Fetcher) *LinkPreviewUseCase {
+	return &LinkPreviewUseCase{
+		repository: repo,
+		fetcher:    fetcher,
+	}
+}
+
+// Enqueue queues the links in content for the worker. Previews are a nicety,
+// so a failure is only logged and never fails the blob write.
+func (l *LinkPreviewUseCase) Enqueue(ctx context.Context, content string) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "LinkPreviewUseCase.Enqueue")
+	defer span.Finish()
+
+	if err := l.repository.Enqueue(ctx, preview.ExtractURLs(content)); err != nil {
+		logging.FromContext(ctx).WarnContext(ctx, "Failed to enqueue link previews", logging.Err(err))
+	}
+}
+
+// ForContent returns the ready previews of the links in content, in the
+// order the links appear.
+func (l *LinkPreviewUseCase) ForContent(ctx context.Context, content string) ([]models.LinkPreview, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "LinkPreviewUseCase.ForContent")
+	defer span.Finish()
+
+	urls := preview.ExtractURLs(content)
+	byURL, err := l.byURL(ctx, urls)
+	if err != nil {
+		return nil, err
+	}
+	return orderedPreviews(urls, byURL), nil
+}
+
+func (l *LinkPreviewUseCase) AttachToList(ctx context.Context, blobs []*models.BlobListWithDetails) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "LinkPreviewUseCase.AttachToList")
+	defer span.Finish()
+
+	urlsByBlob := make([][]string, len(blobs))
+	var urls []string
+	for i, blob := range blobs {
+		urlsByBlob[i] = preview.ExtractURLs(blob.Content)
+		urls = append(urls, urlsByBlob[i]...)
+	}
+
+	byURL, err := l.byURL(ctx, urls)
+	if err != nil {
+		return err
+	}
+
+	for i, blob := range blobs {
+		blob.LinkPreviews = orderedPreviews(urlsByBlob[i], byURL)
+	}
+	return nil
+}
+
+func (l *LinkPreviewUseCase) byURL(ctx context.Context, urls []string) (map[string]models.LinkPreview, error) {
+	previews, err := l.repository.ListReady(ctx, urls)
+	if err != nil {
+		return nil, errors.Wrap(err, "LinkPreviewUseCase.byURL.ListReady")
+	}
+
+	byURL := make(map[string]models.LinkPreview, len(previews))
+	for _, p := range previews {
+		byURL[p.URL] = p
+	}
+	return byURL, nil
+}
+
+func orderedPreviews(urls []string, byURL map[string]models.LinkPreview) []models.LinkPreview {
+	previews := []models.LinkPreview{}
+	for _, url := range urls {
+		if p, ok := byURL[url]; ok {
+			previews = append(previews, p)
+		}
+	}
+	return previews
+}
+
+// RunWorker fetches queued links every interval until ctx is cancelled.
+func (l *LinkPreviewUseCase) RunWorker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := l.ProcessQueue(ctx); err != nil {
+				slog.ErrorContext(ctx, "Failed to process link previews", logging.Err(err))
+			}
+		}
+	}
+}
+
+// ProcessQueue claims and fetches batches of queued links until the queue has
+// nothing due. Failed fetches are retried with a growing delay and given up
+// after linkPreviewMaxAttempts.
+func (l *LinkPreviewUseCase) ProcessQueue(ctx context.Context) (int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "LinkPreviewUseCase.ProcessQueue")
+	defer span.Finish()
+
+	total := 0
+	for {
+		jobs, err := l.repository.Claim(ctx, linkPreviewBatch)
+		if err != nil {
+			return total, errors.Wrap(err, "LinkPreviewUseCase.ProcessQueue.Claim")
+		}
+		if len(jobs) == 0 {
+			return total, nil
+		}
+
+		for _, job := range jobs {
+			if err := l.process(ctx, job); err != nil {
+				return total, err
+			}
+			total++
+		}
+	}
+}
+
+func (l *LinkPreviewUseCase) process(ctx context.Context, job models.LinkPreviewJob) error {
+	metadata, err := l.fetcher.Fetch(ctx, job.URL)
+	if err != nil {
+		retryIn := time.Duration(job.Attempts*job.Attempts) * time.Minute
+		if err := l.repository.Fail(ctx, job.URL, linkPreviewMaxAttempts, err.Error(), retryIn); err != nil {
+			return errors.Wrap(err, "LinkPreviewUseCase.process.Fail")
+		}
+		return nil
+	}
+
+	if err := l.repository.Complete(ctx, job.URL, metadata); err != nil {
+		return errors.Wrap(err, "LinkPreviewUseCase.process.Complete")
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package usecases
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/internal/logging"
+	"github.com/joaoleau/blob/media"
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/repository"
+	"github.com/joaoleau/blob/storage"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+)
+
+// MediaUploadTTL is how long an upload waits to be attached to a blob before
+// the janitor removes it.
+const MediaUploadTTL = 24 * time.Hour
+
+const mediaJanitorBatch = 100
+
+// ErrMediaUnavailable is returned when an attachment does not exist, belongs
+// to someone else or is already attached to another blob.
+var ErrMediaUnavailable = repository.ErrMediaUnavailable
+
+type MediaUseCase struct {
+	repository MediaRepository
+	storage    storage.Storage
+}
+
+func NewMediaUseCase(repo MediaRepository, store storage.Storage) *MediaUseCase {
+	return &MediaUseCase{
+		repository: repo,
+		storage:    store,
+	}
+}
+
+// Upload re-encodes an image and its thumbnail, stores both and records the
+// upload for userID. It expires unless attached to a blob within MediaUploadTTL.
+func (m *MediaUseCase) Upload(ctx context.Context, userID string, data []byte) (*models.Media, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "MediaUseCase.Upload")
+	defer span.Finish()
+
+	full, thumbnail, err := media.Process(data)
+	if err != nil {
+		return nil, err
+	}
+
+	id := uuid.New()
+	expiresAt := time.Now().Add(MediaUploadTTL)
+	upload := &models.Media{
+		ID:           id,
+		UserID:       &userID,
+		ContentType:  full.ContentType,
+		Size:         int64(len(full.Data)),
+		Width:        full.Width,
+		Height:       full.Height,
+		StorageKey:   fmt.Sprintf("%s/%s%s", userID, id, full.Extension),
+		ThumbnailKey: fmt.Sprintf("%s/%s_thumb%s", userID, id, thumbnail.Extension),
+		ExpiresAt:    &expiresAt,
+	}
+
+	if err := m.storage.Put(ctx, upload.StorageKey, bytes.NewReader(full.Data), int64(len(full.Data)), full.ContentType); err != nil {
+		return nil, errors.Wrap(err, "MediaUseCase.Upload.PutImage")
+	}
+	if err := m.storage.Put(ctx, upload.ThumbnailKey, bytes.NewReader(thumbnail.Data), int64(len(thumbnail.Data)), thumbnail.ContentType); err != nil {
+		m.deleteFiles(ctx, *upload)
+		return nil, errors.Wrap(err, "MediaUseCase.Upload.PutThumbnail")
+	}
+
+	created, err := m.repository.Create(ctx, upload)
+	if err != nil {
+		m.deleteFiles(ctx, *upload)
+		return nil, errors.Wrap(err, "MediaUseCase.Upload.Create")
+	}
+
+	m.withURLs(created)
+	return created, nil
+}
+
+// CheckAttachable fails with repository.ErrMediaUnavailable unless userID
+// can attach every id to blobID. The blob repository attaches them when it
+// writes the blob.
+func (m *MediaUseCase) CheckAttachable(ctx context.Context, blobID uuid.UUID, userID string, ids []uuid.UUID) error {
+	return m.repository.CheckAttachable(ctx, blobID, userID, ids)
+}
+
+// ListForBlobs returns the attachments of each blob, keyed by blob ID.
+func (m *MediaUseCase) ListForBlobs(ctx context.Context, blobIDs []string) (map[string][]models.Media, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "MediaUseCase.ListForBlobs")
+	defer span.Finish()
+
+	items, err := m.repository.ListByBlobs(ctx, blobIDs)
+	if err != nil {
+		return nil, errors.Wrap(err, "MediaUseCase.ListForBlobs.ListByBlobs")
+	}
+
+	byBlob := make(map[string][]models.Media)
+	for i := range items {
+		m.withURLs(&items[i])
+		blobID := items[i].BlobID.String()
+		byBlob[blobID] = append(byBlob[blobID], items[i])
+	}
+	return byBlob, nil
+}
+
+// AttachToList fills in the Media of every blob in a listing.
+func (m *MediaUseCase) AttachToList(ctx context.Context, blobs []*models.BlobListWithDetails) error {
+	blobIDs := make([]string, len(blobs))
+	for i, blob := range blobs {
+		blobIDs[i] = blob.ID
+	}
+
+	byBlob, err := m.ListForBlobs(ctx, blobIDs)
+	if err != nil {
+		return err
+	}
+
+	for _, blob := range blobs {
+		blob.Media = byBlob[blob.ID]
+		if blob.Media == nil {
+			blob.Media = []models.Media{}
+		}
+	}
+	return nil
+}
+
+// RunJanitor deletes expired and orphaned uploads every interval until ctx
+// is cancelled.
+func (m *MediaUseCase) RunJanitor(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := m.DeleteExpired(ctx)
+			if err != nil {
+				slog.ErrorContext(ctx, "Failed to delete expired media", logging.Err(err))
+			} else if deleted > 0 {
+				slog.InfoContext(ctx, "Deleted expired media files", slog.Int("count", deleted))
+			}
+		}
+	}
+}
+
+func (m *MediaUseCase) DeleteExpired(ctx context.Context) (int, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "MediaUseCase.DeleteExpired")
+	defer span.Finish()
+
+	total := 0
+	for {
+		expired, err := m.repository.DeleteExpired(ctx, mediaJanitorBatch)
+		if err != nil {
+			return total, errors.Wrap(err, "MediaUseCase.DeleteExpired.DeleteExpired")
+		}
+		for _, item := range expired {
+			m.deleteFiles(ctx, item)
+		}
+		total += len(expired)
+		if len(expired) < mediaJanitorBatch {
+			return total, nil
+		}
+	}
+}
+
+func (m *MediaUseCase) deleteFiles(ctx context.Context, item models.Media) {
+	for _, key := range []string{item.StorageKey, item.ThumbnailKey} {
+		if err := m.storage.Delete(ctx, key); err != nil {
+			logging.FromContext(ctx).WarnContext(ctx, "Failed to delete media file", slog.String("key", key), logging.Err(err))
+		}
+	}
+}
+
+func (m *MediaUseCase) withURLs(item *models.Media) {
+	item.URL = m.storage.URL(item.StorageKey)
+	item.ThumbnailURL = m.storage.URL(item.ThumbnailKey)
+}
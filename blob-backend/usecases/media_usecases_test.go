@@ -0,0 +1,93 @@
+package usecases
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/models"
+)
+
+func pngUpload(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 40, 30))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func (u *testUseCases) upload(t *testing.T, user *models.User) *models.Media {
+	t.Helper()
+	item, err := u.media.Upload(as(user), user.ID, pngUpload(t))
+	if err != nil {
+		t.Fatalf("Upload: %v", err)
+	}
+	return item
+}
+
+func (u *testUseCases) stored(item *models.Media) bool {
+	for _, key := range []string{item.StorageKey, item.ThumbnailKey} {
+		if _, err := os.Stat(filepath.Join(u.mediaDir, filepath.FromSlash(key))); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMediaJanitorKeepsAttachedUploads(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	attached := u.upload(t, ana)
+	abandoned := u.upload(t, ana)
+
+	blob, err := u.blobs.RegisterBlob(as(ana), &models.BlobWithInterests{Content: "with a picture", MediaIDs: []uuid.UUID{attached.ID}})
+	if err != nil {
+		t.Fatalf("RegisterBlob: %v", err)
+	}
+	if len(blob.Media) != 1 || blob.Media[0].ID != attached.ID {
+		t.Fatalf("media = %+v", blob.Media)
+	}
+
+	u.store.now = time.Now().Add(MediaUploadTTL + time.Minute)
+	deleted, err := u.media.DeleteExpired(as(ana))
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpired = %d, %v, want the abandoned upload", deleted, err)
+	}
+	if !u.stored(attached) || u.stored(abandoned) {
+		t.Errorf("attached stored = %v, abandoned stored = %v", u.stored(attached), u.stored(abandoned))
+	}
+
+	// Dropping the attachment on edit hands it to the janitor.
+	if _, err := u.blobs.UpdateBlob(as(ana), &models.BlobWithInterests{ID: blob.ID, Content: "no picture", MediaIDs: []uuid.UUID{}}); err != nil {
+		t.Fatalf("UpdateBlob: %v", err)
+	}
+	u.store.tick()
+	if deleted, err := u.media.DeleteExpired(as(ana)); err != nil || deleted != 1 {
+		t.Fatalf("DeleteExpired = %d, %v, want the detached upload", deleted, err)
+	}
+	if u.stored(attached) {
+		t.Errorf("detached upload is still stored")
+	}
+}
+
+func TestRegisterBlobRefusesSomeoneElsesMedia(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+	upload := u.upload(t, bob)
+
+	_, err := u.blobs.RegisterBlob(as(ana), &models.BlobWithInterests{Content: "not mine", MediaIDs: []uuid.UUID{upload.ID}})
+	if !errors.Is(err, ErrMediaUnavailable) {
+		t.Errorf("RegisterBlob = %v, want ErrMediaUnavailable", err)
+	}
+	if len(u.store.blobs) != 0 {
+		t.Errorf("blob was stored without its media: %+v", u.store.blobs)
+	}
+}
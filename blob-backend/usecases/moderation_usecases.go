@@ -0,0 +1,211 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/moderation"
+	"github.com/joaoleau/blob/repository"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInvalidReportReason  = errors.New("invalid report reason")
+	ErrInvalidAction        = errors.New("invalid moderation action")
+	ErrAlreadyReported      = errors.New("content already reported")
+	ErrAssigneeNotModerator = repository.ErrAssigneeNotModerator
+)
+
+type ModerationUseCase struct {
+	repository  ModerationRepository
+	filter      *moderation.Filter
+	UserUseCase *UserUseCase
+}
+
+func NewModerationUseCase(repo ModerationRepository, filter *moderation.Filter, userUseCase *UserUseCase) *ModerationUseCase {
+	return &ModerationUseCase{
+		repository:  repo,
+		filter:      filter,
+		UserUseCase: userUseCase,
+	}
+}
+
+// Screen runs the content filter. Rejections come back as an error wrapping
+// moderation.ErrRejected; held content is reported with Hold so the caller
+// can attach a HoldReport to it.
+func (m *ModerationUseCase) Screen(content string) (moderation.Verdict, error) {
+	verdict := m.filter.Check(content)
+	if verdict.Action == moderation.Reject {
+		return verdict, errors.Wrap(moderation.ErrRejected, verdict.Reason)
+	}
+	return verdict, nil
+}
+
+// HoldReport builds the filter report that holds freshly created or edited
+// content for review, or returns nil when the verdict does not hold it. The
+// repositories file it in the transaction that writes the content.
+func (m *ModerationUseCase) HoldReport(blobID uuid.UUID, commentID *uuid.UUID, authorID, content string, verdict moderation.Verdict) *models.Report {
+	if verdict.Action != moderation.Hold {
+		return nil
+	}
+	return &models.Report{
+		ID:           uuid.New(),
+		BlobID:       &blobID,
+		CommentID:    commentID,
+		TargetUserID: authorID,
+		Content:      content,
+		Reason:       verdict.Rule,
+		Details:      verdict.Reason,
+		Source:       models.ReportSourceFilter,
+	}
+}
+
+// ReportContent files a user report against a blob, or against one of its
+// comments when commentID is set. A nil report means the target was not found.
+func (m *ModerationUseCase) ReportContent(ctx context.Context, blobID uuid.UUID, commentID *uuid.UUID, request models.ReportRequest) (*models.Report, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationUseCase.ReportContent")
+	defer span.Finish()
+
+	if !isReportReason(request.Reason) {
+		return nil, ErrInvalidReportReason
+	}
+
+	user, err := m.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := m.repository.GetReportTarget(ctx, blobID, commentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "ModerationUseCase.ReportContent.GetReportTarget")
+	}
+	if target == nil {
+		return nil, nil
+	}
+
+	reported, err := m.repository.HasOpenReport(ctx, user.ID, blobID, commentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "ModerationUseCase.ReportContent.HasOpenReport")
+	}
+	if reported {
+		return nil, ErrAlreadyReported
+	}
+
+	target.ID = uuid.New()
+	target.ReporterID = &user.ID
+	target.Reason = request.Reason
+	target.Details = request.Details
+	target.Source = models.ReportSourceUser
+
+	report, err := m.repository.CreateReport(ctx, target)
+	if err != nil {
+		return nil, errors.Wrap(err, "ModerationUseCase.ReportContent.CreateReport")
+	}
+	return report, nil
+}
+
+func (m *ModerationUseCase) ListReports(ctx context.Context, status string, page, size int) (*models.ReportList, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationUseCase.ListReports")
+	defer span.Finish()
+
+	reports, total, err := m.repository.ListReports(ctx, status, size, (page-1)*size)
+	if err != nil {
+		return nil, errors.Wrap(err, "ModerationUseCase.ListReports.ListReports")
+	}
+
+	return &models.ReportList{
+		Pagination: newPagination(total, page, size),
+		Reports:    reports,
+	}, nil
+}
+
+// AssignReport assigns a report to the given moderator, or to the caller
+// when assigneeID is empty. Assignees without the moderator role are refused
+// with ErrAssigneeNotModerator.
+func (m *ModerationUseCase) AssignReport(ctx context.Context, reportID uuid.UUID, assigneeID string) (*models.Report, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationUseCase.AssignReport")
+	defer span.Finish()
+
+	moderator, err := m.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if assigneeID == "" {
+		assigneeID = moderator.ID
+	}
+
+	report, err := m.repository.AssignReport(ctx, reportID, assigneeID, moderator.ID)
+	if errors.Is(err, ErrAssigneeNotModerator) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "ModerationUseCase.AssignReport.AssignReport")
+	}
+	return report, nil
+}
+
+func (m *ModerationUseCase) ResolveReport(ctx context.Context, reportID uuid.UUID, request models.ResolveReportRequest) (*models.Report, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationUseCase.ResolveReport")
+	defer span.Finish()
+
+	switch request.Action {
+	case models.ModerationActionHide, models.ModerationActionDelete, models.ModerationActionWarn,
+		models.ModerationActionBan, models.ModerationActionDismiss:
+	default:
+		return nil, ErrInvalidAction
+	}
+
+	moderator, err := m.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := m.repository.ResolveReport(ctx, reportID, request.Action, request.Note, moderator.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "ModerationUseCase.ResolveReport.ResolveReport")
+	}
+	return report, nil
+}
+
+func (m *ModerationUseCase) ListAuditLog(ctx context.Context, page, size int) ([]models.AuditLogEntry, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationUseCase.ListAuditLog")
+	defer span.Finish()
+
+	entries, err := m.repository.ListAuditLog(ctx, size, (page-1)*size)
+	if err != nil {
+		return nil, errors.Wrap(err, "ModerationUseCase.ListAuditLog.ListAuditLog")
+	}
+	return entries, nil
+}
+
+// ListWarnings returns the warnings moderators gave the caller.
+func (m *ModerationUseCase) ListWarnings(ctx context.Context, page, size int) ([]models.Warning, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ModerationUseCase.ListWarnings")
+	defer span.Finish()
+
+	user, err := m.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings, err := m.repository.ListWarnings(ctx, user.ID, size, (page-1)*size)
+	if err != nil {
+		return nil, errors.Wrap(err, "ModerationUseCase.ListWarnings.ListWarnings")
+	}
+	return warnings, nil
+}
+
+func (m *ModerationUseCase) currentUser(ctx context.Context) (*models.User, error) {
+	return m.UserUseCase.CurrentUser(ctx)
+}
+
+func isReportReason(reason string) bool {
+	for _, allowed := range models.ReportReasons {
+		if reason == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package usecases
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/moderation"
+)
+
+func TestWarnResolutionNotifiesTheAuthor(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+	moderator := u.store.addUser("mod")
+	blob := u.store.addBlob(ana.ID, "rude words")
+
+	report, err := u.moderation.ReportContent(as(bob), blob.ID, nil, models.ReportRequest{Reason: "harassment"})
+	if err != nil || report == nil {
+		t.Fatalf("ReportContent = %+v, %v", report, err)
+	}
+	if _, err := u.moderation.ResolveReport(as(moderator), report.ID, models.ResolveReportRequest{Action: models.ModerationActionWarn, Note: "keep it civil"}); err != nil {
+		t.Fatalf("ResolveReport: %v", err)
+	}
+
+	warnings, err := u.moderation.ListWarnings(as(ana), 1, 10)
+	if err != nil {
+		t.Fatalf("ListWarnings: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].Reason != "harassment" || warnings[0].Note != "keep it civil" || *warnings[0].ReportID != report.ID {
+		t.Errorf("ana's warnings = %+v", warnings)
+	}
+	if warnings, _ := u.moderation.ListWarnings(as(bob), 1, 10); len(warnings) != 0 {
+		t.Errorf("the reporter got warned: %+v", warnings)
+	}
+}
+
+func TestHeldCommentIsReportedWithIt(t *testing.T) {
+	u := newTestUseCases(t, moderation.WordListRule{Words: []string{"maybe"}, Action: moderation.Hold})
+	ana := u.store.addUser("ana")
+	blob := u.store.addBlob(ana.ID, "hello")
+
+	comment, err := u.comments.AddComment(as(ana), &models.Comment{BlobID: blob.ID, Content: "maybe spam"})
+	if err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	if !comment.HeldForReview {
+		t.Errorf("held comment not flagged")
+	}
+	if len(u.store.reports) != 1 || u.store.reports[0].CommentID == nil || *u.store.reports[0].CommentID != comment.ID {
+		t.Errorf("reports = %+v, want one for the comment", u.store.reports)
+	}
+
+	if comment, err = u.comments.AddComment(as(ana), &models.Comment{BlobID: blob.ID, Content: "fine"}); err != nil || comment.HeldForReview {
+		t.Errorf("AddComment = %+v, %v, want a visible comment", comment, err)
+	}
+	if len(u.store.reports) != 1 {
+		t.Errorf("a clean comment was reported")
+	}
+}
+
+func TestAssignReportOnlyToModerators(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+	moderator := u.store.addModerator("mod")
+	blob := u.store.addBlob(ana.ID, "rude words")
+
+	report, err := u.moderation.ReportContent(as(bob), blob.ID, nil, models.ReportRequest{Reason: "harassment"})
+	if err != nil || report == nil {
+		t.Fatalf("ReportContent = %+v, %v", report, err)
+	}
+
+	if _, err := u.moderation.AssignReport(as(moderator), report.ID, bob.ID); !errors.Is(err, ErrAssigneeNotModerator) {
+		t.Errorf("AssignReport to a regular user = %v, want ErrAssigneeNotModerator", err)
+	}
+	assigned, err := u.moderation.AssignReport(as(moderator), report.ID, "")
+	if err != nil || assigned == nil || *assigned.AssigneeID != moderator.ID {
+		t.Errorf("AssignReport to the caller = %+v, %v", assigned, err)
+	}
+}
@@ -0,0 +1,23 @@
+package usecases
+
+import "github.com/joaoleau/blob/models"
+
+// pageCount returns how many pages of the given size hold total items.
+func pageCount(total, size int) int {
+	if size < 1 {
+		return 0
+	}
+	return (total + size - 1) / size
+}
+
+// newPagination describes the page-th page of size items out of total.
+func newPagination(total, page, size int) models.Pagination {
+	totalPages := pageCount(total, size)
+	return models.Pagination{
+		TotalCount: total,
+		TotalPages: totalPages,
+		Page:       page,
+		Size:       size,
+		HasMore:    page < totalPages,
+	}
+}
@@ -0,0 +1,229 @@
+package usecases
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/metrics"
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/repository"
+	"github.com/opentracing/opentracing-go"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrInvalidReaction = errors.New("invalid reaction type")
+	ErrAlreadyReacted  = repository.ErrAlreadyReacted
+)
+
+type ReactionUseCase struct {
+	repository  ReactionRepository
+	UserUseCase *UserUseCase
+}
+
+func NewReactionUseCase(repo ReactionRepository, userUseCase *UserUseCase) *ReactionUseCase {
+	return &ReactionUseCase{
+		repository:  repo,
+		UserUseCase: userUseCase,
+	}
+}
+
+// React adds the current user's reaction to a blob, or to one of its comments
+// when commentID is set. A nil reaction means the target was not found.
+func (r *ReactionUseCase) React(ctx context.Context, blobID uuid.UUID, commentID *uuid.UUID, reactionType string) (*models.Reaction, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ReactionUseCase.React")
+	defer span.Finish()
+
+	if !isReactionType(reactionType) {
+		return nil, ErrInvalidReaction
+	}
+
+	user, err := r.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	authorID, err := r.repository.GetTargetAuthor(ctx, blobID, commentID)
+	if err != nil {
+		return nil, errors.Wrap(err, "ReactionUseCase.React.GetTargetAuthor")
+	}
+	if authorID == "" {
+		return nil, nil
+	}
+
+	if err := r.UserUseCase.EnsureNotBlocked(ctx, user.ID, authorID); err != nil {
+		return nil, err
+	}
+
+	reaction, err := r.repository.Add(ctx, &models.Reaction{
+		ID:        uuid.New(),
+		UserID:    user.ID,
+		BlobID:    blobID,
+		CommentID: commentID,
+		Type:      reactionType,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "ReactionUseCase.React.Add")
+	}
+	metrics.Reactions.WithLabelValues(reactionType).Inc()
+	return reaction, nil
+}
+
+// Unreact removes the current user's reaction and reports whether there was one.
+func (r *ReactionUseCase) Unreact(ctx context.Context, blobID uuid.UUID, commentID *uuid.UUID, reactionType string) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ReactionUseCase.Unreact")
+	defer span.Finish()
+
+	if !isReactionType(reactionType) {
+		return false, ErrInvalidReaction
+	}
+
+	user, err := r.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	removed, err := r.repository.Remove(ctx, user.ID, blobID, commentID, reactionType)
+	if err != nil {
+		return false, errors.Wrap(err, "ReactionUseCase.Unreact.Remove")
+	}
+	return removed, nil
+}
+
+// Like is the idempotent like used by the like routes. A nil state means the
+// blob was not found; created is false when the user had already liked it.
+func (r *ReactionUseCase) Like(ctx context.Context, blobID uuid.UUID) (state *models.LikeState, created bool, err error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ReactionUseCase.Like")
+	defer span.Finish()
+
+	user, err := r.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	authorID, err := r.repository.GetTargetAuthor(ctx, blobID, nil)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "ReactionUseCase.Like.GetTargetAuthor")
+	}
+	if authorID == "" {
+		return nil, false, nil
+	}
+
+	if err := r.UserUseCase.EnsureNotBlocked(ctx, user.ID, authorID); err != nil {
+		return nil, false, err
+	}
+
+	created, err = r.repository.AddLike(ctx, uuid.New(), user.ID, blobID)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "ReactionUseCase.Like.AddLike")
+	}
+	if created {
+		metrics.Reactions.WithLabelValues(models.ReactionLike).Inc()
+	}
+
+	state, err = r.repository.GetLikeState(ctx, blobID, user.ID)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "ReactionUseCase.Like.GetLikeState")
+	}
+	return state, created, nil
+}
+
+// Unlike removes the current user's like, if any. A nil state means the blob
+// was not found.
+func (r *ReactionUseCase) Unlike(ctx context.Context, blobID uuid.UUID) (*models.LikeState, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ReactionUseCase.Unlike")
+	defer span.Finish()
+
+	user, err := r.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := r.repository.Remove(ctx, user.ID, blobID, nil, models.ReactionLike)
+	if err != nil {
+		return nil, errors.Wrap(err, "ReactionUseCase.Unlike.Remove")
+	}
+	if !removed {
+		exists, err := r.repository.BlobExists(ctx, blobID)
+		if err != nil {
+			return nil, errors.Wrap(err, "ReactionUseCase.Unlike.BlobExists")
+		}
+		if !exists {
+			return nil, nil
+		}
+	}
+
+	state, err := r.repository.GetLikeState(ctx, blobID, user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "ReactionUseCase.Unlike.GetLikeState")
+	}
+	return state, nil
+}
+
+func (r *ReactionUseCase) ListLikes(ctx context.Context, blobID uuid.UUID, page, size int) (*models.LikeList, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ReactionUseCase.ListLikes")
+	defer span.Finish()
+
+	viewer, err := r.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	likes, total, err := r.repository.ListLikes(ctx, blobID, viewer.ID, size, (page-1)*size)
+	if err != nil {
+		return nil, errors.Wrap(err, "ReactionUseCase.ListLikes.ListLikes")
+	}
+
+	return &models.LikeList{
+		Pagination: newPagination(total, page, size),
+		Likes:      likes,
+	}, nil
+}
+
+// ListReactions groups the reactions on a target by type, in the order of
+// models.ReactionTypes. Types nobody used are left out.
+func (r *ReactionUseCase) ListReactions(ctx context.Context, blobID uuid.UUID, commentID *uuid.UUID) ([]models.ReactionGroup, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "ReactionUseCase.ListReactions")
+	defer span.Finish()
+
+	viewer, err := r.UserUseCase.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reactions, err := r.repository.List(ctx, blobID, commentID, viewer.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "ReactionUseCase.ListReactions.List")
+	}
+
+	byType := make(map[string]*models.ReactionGroup)
+	for _, reaction := range reactions {
+		group := byType[reaction.Type]
+		if group == nil {
+			group = &models.ReactionGroup{Type: reaction.Type, Users: []models.ReactionWithUser{}}
+			byType[reaction.Type] = group
+		}
+		group.Count++
+		group.Users = append(group.Users, reaction)
+		if reaction.UserID == viewer.ID {
+			group.ReactedByMe = true
+		}
+	}
+
+	groups := []models.ReactionGroup{}
+	for _, reactionType := range models.ReactionTypes {
+		if group := byType[reactionType]; group != nil {
+			groups = append(groups, *group)
+		}
+	}
+	return groups, nil
+}
+
+func isReactionType(reactionType string) bool {
+	for _, allowed := range models.ReactionTypes {
+		if reactionType == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package usecases
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/models"
+)
+
+func TestLikeIsIdempotent(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+	blob := u.store.addBlob(ana.ID, "like me")
+
+	state, created, err := u.reactions.Like(as(bob), blob.ID)
+	if err != nil || !created {
+		t.Fatalf("Like = %v, %v", created, err)
+	}
+	if state.LikesCount != 1 || !state.LikedByMe {
+		t.Errorf("state = %+v", state)
+	}
+
+	state, created, err = u.reactions.Like(as(bob), blob.ID)
+	if err != nil || created {
+		t.Errorf("second Like = %v, %v, want not created", created, err)
+	}
+	if state.LikesCount != 1 {
+		t.Errorf("likes after second like = %d", state.LikesCount)
+	}
+
+	state, err = u.reactions.Unlike(as(bob), blob.ID)
+	if err != nil {
+		t.Fatalf("Unlike: %v", err)
+	}
+	if state.LikesCount != 0 || state.LikedByMe {
+		t.Errorf("state after unlike = %+v", state)
+	}
+
+	if state, _, err := u.reactions.Like(as(bob), uuid.New()); err != nil || state != nil {
+		t.Errorf("like of a missing blob = %+v, %v, want nil state", state, err)
+	}
+}
+
+func TestReactChecksTypeAndBlocks(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+	blob := u.store.addBlob(ana.ID, "react to me")
+
+	if _, err := u.reactions.React(as(bob), blob.ID, nil, "meh"); !errors.Is(err, ErrInvalidReaction) {
+		t.Errorf("React(meh) = %v, want ErrInvalidReaction", err)
+	}
+	if _, err := u.reactions.React(as(bob), blob.ID, nil, "fire"); err != nil {
+		t.Fatalf("React: %v", err)
+	}
+	if _, err := u.reactions.React(as(bob), blob.ID, nil, "fire"); !errors.Is(err, ErrAlreadyReacted) {
+		t.Errorf("second React = %v, want ErrAlreadyReacted", err)
+	}
+
+	groups, err := u.reactions.ListReactions(as(ana), blob.ID, nil)
+	if err != nil {
+		t.Fatalf("ListReactions: %v", err)
+	}
+	if len(groups) != 1 || groups[0].Type != "fire" || groups[0].Count != 1 || groups[0].ReactedByMe {
+		t.Errorf("groups = %+v", groups)
+	}
+
+	if err := u.users.AddRelation(as(ana), models.RelationBlock, "bob"); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+	if _, err := u.reactions.React(as(bob), blob.ID, nil, "love"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("React after block = %v, want ErrBlocked", err)
+	}
+}
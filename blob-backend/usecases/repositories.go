@@ -0,0 +1,123 @@
+package usecases
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/models"
+	"github.com/joaoleau/blob/preview"
+	"github.com/joaoleau/blob/repository"
+)
+
+// The use cases depend on these interfaces rather than on the Postgres
+// repositories so unit tests can run them against in-memory fakes. Each one
+// lists the methods its use case calls; the Postgres types in the repository
+// package implement them all.
+
+type BlobRepository interface {
+	Create(ctx context.Context, blob *models.BlobWithInterests) (*models.BlobWithInterests, error)
+	Update(ctx context.Context, blob *models.BlobWithInterests) (*models.BlobWithInterests, error)
+	Delete(ctx context.Context, blobID uuid.UUID, userID string) (bool, error)
+	GetByID(ctx context.Context, blobID uuid.UUID, viewerID string, limit int) (*models.BlobWithDetails, error)
+	GetAuthor(ctx context.Context, blobID uuid.UUID) (string, error)
+	ListBlobs(ctx context.Context, viewerID string) ([]models.BlobListWithDetails, error)
+	ListFeed(ctx context.Context, viewerID string, limit, offset int) ([]*models.BlobListWithDetails, int, error)
+	ListTrending(ctx context.Context, viewerID string, limit, offset int) ([]*models.BlobListWithDetails, int, error)
+	ListViewerStates(ctx context.Context, blobIDs []string, viewerID string) ([]models.BlobViewerState, error)
+	GetReblogTarget(ctx context.Context, blobID uuid.UUID) (string, string, error)
+	GetReblogOriginal(ctx context.Context, originalID *string) (*models.ReblogOf, error)
+	ListAllInterests(ctx context.Context) ([]*models.Interest, error)
+}
+
+type CommentRepository interface {
+	AddComment(ctx context.Context, comment *models.Comment) (*models.Comment, error)
+	UpdateComment(ctx context.Context, comment *models.Comment) (*models.Comment, error)
+	RemoveComment(ctx context.Context, userID string, commentID uuid.UUID) error
+	ListCommentsByBlobID(ctx context.Context, blobID uuid.UUID, viewerID string, limit, offset int) ([]models.CommentWithUser, int, error)
+}
+
+type UserRepository interface {
+	GetUserById(ctx context.Context, userID string) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByUsernames(ctx context.Context, usernames []string) ([]models.User, error)
+	GetProfile(ctx context.Context, username, viewerID string) (*models.PublicProfile, error)
+	GetRenamedUsername(ctx context.Context, username string) (string, error)
+	ListBlobs(ctx context.Context, userID, viewerID string, limit, offset int) ([]*models.BlobListWithDetails, int, error)
+	ListLikedBlobs(ctx context.Context, userID, viewerID string, limit, offset int) ([]*models.BlobListWithDetails, int, error)
+	ListComments(ctx context.Context, userID string, limit, offset int) ([]models.CommentWithUser, int, error)
+	GetStats(ctx context.Context, userID string) (*models.UserStats, error)
+	Export(ctx context.Context, user *models.User) (*models.UserExport, error)
+	ScheduleDeletion(ctx context.Context, userID string, grace time.Duration) (time.Time, error)
+	CancelDeletion(ctx context.Context, userID string) (bool, error)
+	UpdateUser(ctx context.Context, user *models.User, updatedData models.User) error
+}
+
+type ReactionRepository interface {
+	GetTargetAuthor(ctx context.Context, blobID uuid.UUID, commentID *uuid.UUID) (string, error)
+	Add(ctx context.Context, reaction *models.Reaction) (*models.Reaction, error)
+	Remove(ctx context.Context, userID string, blobID uuid.UUID, commentID *uuid.UUID, reactionType string) (bool, error)
+	List(ctx context.Context, blobID uuid.UUID, commentID *uuid.UUID, viewerID string) ([]models.ReactionWithUser, error)
+	AddLike(ctx context.Context, id uuid.UUID, userID string, blobID uuid.UUID) (bool, error)
+	BlobExists(ctx context.Context, blobID uuid.UUID) (bool, error)
+	GetLikeState(ctx context.Context, blobID uuid.UUID, userID string) (*models.LikeState, error)
+	ListLikes(ctx context.Context, blobID uuid.UUID, viewerID string, limit, offset int) ([]models.ReactionWithUser, int, error)
+}
+
+type BookmarkRepository interface {
+	GetAuthorID(ctx context.Context, blobID uuid.UUID) (string, error)
+	Add(ctx context.Context, userID string, blobID uuid.UUID, authorID string) (bool, error)
+	Remove(ctx context.Context, userID string, blobID uuid.UUID) (bool, error)
+	List(ctx context.Context, userID string, limit, offset int) ([]*models.Bookmark, int, error)
+}
+
+type MentionRepository interface {
+	ListByMentionedUser(ctx context.Context, userID string, limit, offset int) ([]models.MentionWithAuthor, int, error)
+}
+
+type RelationRepository interface {
+	Add(ctx context.Context, relation, userID, targetID string) error
+	Remove(ctx context.Context, relation, userID, targetID string) error
+	List(ctx context.Context, relation, userID string) ([]models.RelatedUser, error)
+	IsBlockedBy(ctx context.Context, actorID, ownerID string) (bool, error)
+	ListBlockersAmong(ctx context.Context, actorID string, userIDs []string) ([]string, error)
+}
+
+type MediaRepository interface {
+	Create(ctx context.Context, media *models.Media) (*models.Media, error)
+	CheckAttachable(ctx context.Context, blobID uuid.UUID, userID string, ids []uuid.UUID) error
+	ListByBlobs(ctx context.Context, blobIDs []string) ([]models.Media, error)
+	DeleteExpired(ctx context.Context, limit int) ([]models.Media, error)
+}
+
+type LinkPreviewRepository interface {
+	Enqueue(ctx context.Context, urls []string) error
+	Claim(ctx context.Context, limit int) ([]models.LinkPreviewJob, error)
+	Complete(ctx context.Context, url string, metadata *preview.Metadata) error
+	Fail(ctx context.Context, url string, maxAttempts int, reason string, retryIn time.Duration) error
+	ListReady(ctx context.Context, urls []string) ([]models.LinkPreview, error)
+}
+
+type ModerationRepository interface {
+	GetReportTarget(ctx context.Context, blobID uuid.UUID, commentID *uuid.UUID) (*models.Report, error)
+	HasOpenReport(ctx context.Context, reporterID string, blobID uuid.UUID, commentID *uuid.UUID) (bool, error)
+	CreateReport(ctx context.Context, report *models.Report) (*models.Report, error)
+	ListReports(ctx context.Context, status string, limit, offset int) ([]*models.Report, int, error)
+	AssignReport(ctx context.Context, reportID uuid.UUID, assigneeID, moderatorID string) (*models.Report, error)
+	ResolveReport(ctx context.Context, reportID uuid.UUID, action, note, moderatorID string) (*models.Report, error)
+	ListAuditLog(ctx context.Context, limit, offset int) ([]models.AuditLogEntry, error)
+	ListWarnings(ctx context.Context, userID string, limit, offset int) ([]models.Warning, error)
+}
+
+var (
+	_ BlobRepository        = (*repository.BlobRepo)(nil)
+	_ CommentRepository     = (*repository.CommentRepo)(nil)
+	_ UserRepository        = (*repository.UserRepo)(nil)
+	_ ReactionRepository    = (*repository.ReactionRepo)(nil)
+	_ BookmarkRepository    = (*repository.BookmarkRepo)(nil)
+	_ MentionRepository     = (*repository.MentionRepo)(nil)
+	_ RelationRepository    = (*repository.RelationRepo)(nil)
+	_ MediaRepository       = (*repository.MediaRepo)(nil)
+	_ LinkPreviewRepository = (*repository.LinkPreviewRepo)(nil)
+	_ ModerationRepository  = (*repository.ModerationRepo)(nil)
+)
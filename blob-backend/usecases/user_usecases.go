@@ -2,23 +2,77 @@ package usecases
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/joaoleau/blob/models"
 	"github.com/joaoleau/blob/repository"
+	"github.com/joaoleau/blob/validation"
 	"github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 )
 
+// AccountDeletionGracePeriod is how long a deleted account can still be
+// restored before the pop cronjob removes it for good.
+const AccountDeletionGracePeriod = 30 * 24 * time.Hour
+
+var (
+	ErrUserNotFound    = errors.New("user not found")
+	ErrSelfRelation    = errors.New("cannot follow, block or mute yourself")
+	ErrBlocked         = errors.New("blocked by the content author")
+	ErrPrivateProfile  = errors.New("profile is private")
+	ErrLikesHidden     = errors.New("likes are hidden")
+	ErrInvalidUsername = errors.New("invalid username")
+	ErrUsernameTaken   = repository.ErrUsernameTaken
+)
+
+// reservedUsernames would be shadowed by the static /user/... routes.
+var reservedUsernames = map[string]bool{
+	"mentions":  true,
+	"blocks":    true,
+	"mutes":     true,
+	"export":    true,
+	"deletion":  true,
+	"bookmarks": true,
+	"stats":     true,
+}
+
 type UserUseCase struct {
-	repository *repository.UserRepo
+	repository   UserRepository
+	mentionRepo  MentionRepository
+	relationRepo RelationRepository
+	Media        *MediaUseCase
+	Previews     *LinkPreviewUseCase
 }
 
-func NewUserUseCase(repo *repository.UserRepo) *UserUseCase {
+func NewUserUseCase(repo UserRepository, mentionRepo MentionRepository, relationRepo RelationRepository, mediaUseCase *MediaUseCase, previewUseCase *LinkPreviewUseCase) *UserUseCase {
 	return &UserUseCase{
-		repository: repo,
+		repository:   repo,
+		mentionRepo:  mentionRepo,
+		relationRepo: relationRepo,
+		Media:        mediaUseCase,
+		Previews:     previewUseCase,
 	}
 }
 
+// CurrentUser resolves the authenticated user from the email the auth
+// middleware stored in the context.
+func (u *UserUseCase) CurrentUser(ctx context.Context) (*models.User, error) {
+	email, ok := ctx.Value("email").(string)
+	if !ok || email == "" {
+		return nil, errors.New("user email not found in context")
+	}
+
+	user, err := u.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch user by email")
+	}
+	if user == nil {
+		return nil, errors.New("authenticated user not found")
+	}
+	return user, nil
+}
+
 func (u *UserUseCase) GetUserById(ctx context.Context, id string) (*models.User, error) {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.GetUserById")
 	defer span.Finish()
@@ -31,16 +85,143 @@ func (u *UserUseCase) GetUserById(ctx context.Context, id string) (*models.User,
 	return user, nil
 }
 
-func (u *UserUseCase) GetUserByUsername(ctx context.Context, username string) (*models.UserWithBlobs, error) {
-	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.GetUserByUsername")
+// GetProfile returns the public profile of username as seen by the caller.
+func (u *UserUseCase) GetProfile(ctx context.Context, username string) (*models.PublicProfile, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.GetProfile")
+	defer span.Finish()
+
+	viewer, err := u.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	profile, err := u.repository.GetProfile(ctx, username, viewer.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserUseCase.GetProfile.GetProfile")
+	}
+	if profile == nil {
+		return nil, ErrUserNotFound
+	}
+	profile.IsMe = profile.ID == viewer.ID
+
+	return profile, nil
+}
+
+// RenamedUsername returns the current name of a user who changed away from
+// username, or an empty string.
+func (u *UserUseCase) RenamedUsername(ctx context.Context, username string) (string, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.RenamedUsername")
+	defer span.Finish()
+
+	current, err := u.repository.GetRenamedUsername(ctx, username)
+	if err != nil {
+		return "", errors.Wrap(err, "UserUseCase.RenamedUsername.GetRenamedUsername")
+	}
+	return current, nil
+}
+
+func (u *UserUseCase) ListUserBlobs(ctx context.Context, username string, page, size int) (*models.BlobList, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.ListUserBlobs")
+	defer span.Finish()
+
+	profile, err := u.visibleProfile(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+
+	viewer, err := u.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, total, err := u.repository.ListBlobs(ctx, profile.ID, viewer.ID, size, (page-1)*size)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserUseCase.ListUserBlobs.ListBlobs")
+	}
+	return u.blobPage(ctx, blobs, total, page, size)
+}
+
+// ListUserLikes lists the blobs username liked. Users who hide their likes
+// only see this list for themselves.
+func (u *UserUseCase) ListUserLikes(ctx context.Context, username string, page, size int) (*models.BlobList, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.ListUserLikes")
+	defer span.Finish()
+
+	profile, err := u.visibleProfile(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if profile.HideLikes && !profile.IsMe {
+		return nil, ErrLikesHidden
+	}
+
+	viewer, err := u.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	blobs, total, err := u.repository.ListLikedBlobs(ctx, profile.ID, viewer.ID, size, (page-1)*size)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserUseCase.ListUserLikes.ListLikedBlobs")
+	}
+	return u.blobPage(ctx, blobs, total, page, size)
+}
+
+func (u *UserUseCase) ListUserComments(ctx context.Context, username string, page, size int) (*models.CommentList, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.ListUserComments")
 	defer span.Finish()
 
-	userWithBlobs, err := u.repository.GetByUsername(ctx, username)
+	profile, err := u.visibleProfile(ctx, username)
 	if err != nil {
 		return nil, err
 	}
 
-	return userWithBlobs, nil
+	comments, total, err := u.repository.ListComments(ctx, profile.ID, size, (page-1)*size)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserUseCase.ListUserComments.ListComments")
+	}
+	for i := range comments {
+		comments[i].Entities = ExtractEntities(comments[i].Content)
+	}
+
+	return &models.CommentList{
+		Pagination: newPagination(total, page, size),
+		Comments:   comments,
+	}, nil
+}
+
+// visibleProfile loads a profile whose blobs, comments and likes the caller
+// may see. A private profile is only open to its owner and to the users the
+// owner follows.
+func (u *UserUseCase) visibleProfile(ctx context.Context, username string) (*models.PublicProfile, error) {
+	profile, err := u.GetProfile(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	if profile.BlockedMe {
+		return nil, ErrBlocked
+	}
+	if profile.PrivateProfile && !profile.IsMe && !profile.FollowsMe {
+		return nil, ErrPrivateProfile
+	}
+	return profile, nil
+}
+
+func (u *UserUseCase) blobPage(ctx context.Context, blobs []*models.BlobListWithDetails, total, page, size int) (*models.BlobList, error) {
+	for _, blob := range blobs {
+		blob.Entities = ExtractEntities(blob.Content)
+	}
+	if err := u.Media.AttachToList(ctx, blobs); err != nil {
+		return nil, err
+	}
+	if err := u.Previews.AttachToList(ctx, blobs); err != nil {
+		return nil, err
+	}
+
+	return &models.BlobList{
+		Pagination: newPagination(total, page, size),
+		Users:      blobs,
+	}, nil
 }
 
 func (u *UserUseCase) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
@@ -56,6 +237,203 @@ func (u *UserUseCase) GetUserByEmail(ctx context.Context, email string) (*models
 	return user, nil
 }
 
+func (u *UserUseCase) GetUsersByUsernames(ctx context.Context, usernames []string) ([]models.User, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.GetUsersByUsernames")
+	defer span.Finish()
+
+	users, err := u.repository.GetByUsernames(ctx, usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (u *UserUseCase) ListMentions(ctx context.Context, email string, page, size int) (*models.MentionList, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.ListMentions")
+	defer span.Finish()
+
+	user, err := u.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserUseCase.ListMentions.GetUserByEmail")
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	mentions, total, err := u.mentionRepo.ListByMentionedUser(ctx, user.ID, size, (page-1)*size)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserUseCase.ListMentions.ListByMentionedUser")
+	}
+
+	for i := range mentions {
+		mentions[i].Entities = ExtractEntities(mentions[i].Content)
+	}
+
+	return &models.MentionList{
+		Pagination: newPagination(total, page, size),
+		Mentions:   mentions,
+	}, nil
+}
+
+// AddRelation follows, blocks or mutes the user with the given username.
+func (u *UserUseCase) AddRelation(ctx context.Context, relation, username string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.AddRelation")
+	defer span.Finish()
+
+	user, target, err := u.relationUsers(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	if relation == models.RelationFollow {
+		if err := u.EnsureNotBlocked(ctx, user.ID, target.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := u.relationRepo.Add(ctx, relation, user.ID, target.ID); err != nil {
+		return errors.Wrap(err, "UserUseCase.AddRelation.Add")
+	}
+	return nil
+}
+
+func (u *UserUseCase) RemoveRelation(ctx context.Context, relation, username string) error {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.RemoveRelation")
+	defer span.Finish()
+
+	user, target, err := u.relationUsers(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	if err := u.relationRepo.Remove(ctx, relation, user.ID, target.ID); err != nil {
+		return errors.Wrap(err, "UserUseCase.RemoveRelation.Remove")
+	}
+	return nil
+}
+
+func (u *UserUseCase) ListRelations(ctx context.Context, relation string) ([]models.RelatedUser, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.ListRelations")
+	defer span.Finish()
+
+	user, err := u.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := u.relationRepo.List(ctx, relation, user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserUseCase.ListRelations.List")
+	}
+	return users, nil
+}
+
+// EnsureNotBlocked returns ErrBlocked when ownerID has blocked actorID.
+func (u *UserUseCase) EnsureNotBlocked(ctx context.Context, actorID, ownerID string) error {
+	if actorID == ownerID {
+		return nil
+	}
+
+	blocked, err := u.relationRepo.IsBlockedBy(ctx, actorID, ownerID)
+	if err != nil {
+		return errors.Wrap(err, "UserUseCase.EnsureNotBlocked.IsBlockedBy")
+	}
+	if blocked {
+		return ErrBlocked
+	}
+	return nil
+}
+
+func (u *UserUseCase) relationUsers(ctx context.Context, username string) (*models.User, *models.User, error) {
+	user, err := u.CurrentUser(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	targets, err := u.repository.GetByUsernames(ctx, []string{strings.ToLower(username)})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "UserUseCase.relationUsers.GetByUsernames")
+	}
+	if len(targets) == 0 {
+		return nil, nil, ErrUserNotFound
+	}
+	if targets[0].ID == user.ID {
+		return nil, nil, ErrSelfRelation
+	}
+	return user, &targets[0], nil
+}
+
+// Stats returns the current user's private counters, including how often
+// their blobs were bookmarked, expired ones too.
+func (u *UserUseCase) Stats(ctx context.Context) (*models.UserStats, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.Stats")
+	defer span.Finish()
+
+	user, err := u.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := u.repository.GetStats(ctx, user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserUseCase.Stats.GetStats")
+	}
+	return stats, nil
+}
+
+func (u *UserUseCase) ExportData(ctx context.Context) (*models.UserExport, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.ExportData")
+	defer span.Finish()
+
+	user, err := u.CurrentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	export, err := u.repository.Export(ctx, user)
+	if err != nil {
+		return nil, errors.Wrap(err, "UserUseCase.ExportData.Export")
+	}
+	return export, nil
+}
+
+// RequestDeletion schedules the current account for deletion after the grace
+// period and signs it out of every session.
+func (u *UserUseCase) RequestDeletion(ctx context.Context) (time.Time, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.RequestDeletion")
+	defer span.Finish()
+
+	user, err := u.CurrentUser(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	scheduledAt, err := u.repository.ScheduleDeletion(ctx, user.ID, AccountDeletionGracePeriod)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "UserUseCase.RequestDeletion.ScheduleDeletion")
+	}
+	return scheduledAt, nil
+}
+
+func (u *UserUseCase) CancelDeletion(ctx context.Context) (bool, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.CancelDeletion")
+	defer span.Finish()
+
+	user, err := u.CurrentUser(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	cancelled, err := u.repository.CancelDeletion(ctx, user.ID)
+	if err != nil {
+		return false, errors.Wrap(err, "UserUseCase.CancelDeletion.CancelDeletion")
+	}
+	return cancelled, nil
+}
+
+// UpdateUser applies the profile changes, checking that a new username is
+// well-formed and not used by anyone else.
 func (u *UserUseCase) UpdateUser(ctx context.Context, email string, userData models.User) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "UserUseCase.UpdateUser")
 	defer span.Finish()
@@ -68,10 +446,33 @@ func (u *UserUseCase) UpdateUser(ctx context.Context, email string, userData mod
 		return errors.New("user not found")
 	}
 
-	err = u.repository.UpdateUser(ctx, user.ID, userData)
+	if userData.Username != "" && userData.Username != user.Username {
+		if err := u.checkUsernameAvailable(ctx, user.ID, userData.Username); err != nil {
+			return err
+		}
+	}
+
+	err = u.repository.UpdateUser(ctx, user, userData)
 	if err != nil {
 		return errors.Wrap(err, "UserUseCase.UpdateUser.UpdateUser")
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+func (u *UserUseCase) checkUsernameAvailable(ctx context.Context, userID, username string) error {
+	if !validation.IsUsername(username) || reservedUsernames[strings.ToLower(username)] {
+		return ErrInvalidUsername
+	}
+
+	users, err := u.repository.GetByUsernames(ctx, []string{strings.ToLower(username)})
+	if err != nil {
+		return errors.Wrap(err, "UserUseCase.checkUsernameAvailable.GetByUsernames")
+	}
+	for _, other := range users {
+		if other.ID != userID {
+			return ErrUsernameTaken
+		}
+	}
+	return nil
+}
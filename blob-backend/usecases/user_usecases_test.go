@@ -0,0 +1,121 @@
+package usecases
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/joaoleau/blob/models"
+)
+
+func TestUpdateUserChecksUsername(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	u.store.addUser("bob")
+
+	tests := []struct {
+		username string
+		want     error
+	}{
+		{"Bob", ErrUsernameTaken},
+		{"mentions", ErrInvalidUsername},
+		{"no spaces", ErrInvalidUsername},
+		{"ana", nil},
+		{"ana_2", nil},
+	}
+	for _, tt := range tests {
+		err := u.users.UpdateUser(as(ana), ana.Email, models.User{Username: tt.username})
+		if !errors.Is(err, tt.want) {
+			t.Errorf("UpdateUser(%q) = %v, want %v", tt.username, err, tt.want)
+		}
+	}
+
+	if got := u.store.users[ana.ID].Username; got != "ana_2" {
+		t.Errorf("username = %q, want ana_2", got)
+	}
+}
+
+func TestRelationsRejectSelfAndUnknownUsers(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+
+	if err := u.users.AddRelation(as(ana), models.RelationFollow, "ana"); !errors.Is(err, ErrSelfRelation) {
+		t.Errorf("follow self = %v, want ErrSelfRelation", err)
+	}
+	if err := u.users.AddRelation(as(ana), models.RelationFollow, "nobody"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("follow unknown = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestBlockedUserCannotFollowOrSeeBlobs(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+
+	if err := u.users.AddRelation(as(ana), models.RelationFollow, "bob"); err != nil {
+		t.Fatalf("follow: %v", err)
+	}
+	if err := u.users.AddRelation(as(bob), models.RelationBlock, "ana"); err != nil {
+		t.Fatalf("block: %v", err)
+	}
+
+	if following, _ := u.users.ListRelations(as(ana), models.RelationFollow); len(following) != 0 {
+		t.Errorf("block kept the follow: %+v", following)
+	}
+	if err := u.users.AddRelation(as(ana), models.RelationFollow, "bob"); !errors.Is(err, ErrBlocked) {
+		t.Errorf("follow after block = %v, want ErrBlocked", err)
+	}
+	if _, err := u.users.ListUserBlobs(as(ana), "bob", 1, 10); !errors.Is(err, ErrBlocked) {
+		t.Errorf("ListUserBlobs = %v, want ErrBlocked", err)
+	}
+}
+
+func TestRequestDeletionKeepsScheduledDate(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+
+	first, err := u.users.RequestDeletion(as(ana))
+	if err != nil {
+		t.Fatalf("RequestDeletion: %v", err)
+	}
+	second, err := u.users.RequestDeletion(as(ana))
+	if err != nil {
+		t.Fatalf("RequestDeletion: %v", err)
+	}
+	if !second.Equal(first) {
+		t.Errorf("second request moved the date from %v to %v", first, second)
+	}
+	if want := u.store.now.Add(AccountDeletionGracePeriod); !first.Equal(want) {
+		t.Errorf("scheduled for %v, want %v", first, want)
+	}
+
+	cancelled, err := u.users.CancelDeletion(as(ana))
+	if err != nil || !cancelled {
+		t.Errorf("CancelDeletion = %v, %v", cancelled, err)
+	}
+	if cancelled, _ := u.users.CancelDeletion(as(ana)); cancelled {
+		t.Errorf("nothing left to cancel, got cancelled")
+	}
+}
+
+func TestListMentionsIsPaginated(t *testing.T) {
+	u := newTestUseCases(t)
+	ana := u.store.addUser("ana")
+	bob := u.store.addUser("bob")
+
+	for _, content := range []string{"one @bob", "two @bob", "three @bob"} {
+		if _, err := u.blobs.RegisterBlob(as(ana), &models.BlobWithInterests{Content: content}); err != nil {
+			t.Fatalf("RegisterBlob: %v", err)
+		}
+	}
+
+	list, err := u.users.ListMentions(as(bob), bob.Email, 2, 2)
+	if err != nil {
+		t.Fatalf("ListMentions: %v", err)
+	}
+	if list.TotalCount != 3 || list.TotalPages != 2 || list.HasMore {
+		t.Errorf("pagination = %+v", list.Pagination)
+	}
+	if len(list.Mentions) != 1 || list.Mentions[0].Content != "one @bob" {
+		t.Errorf("second page = %+v, want the oldest mention", list.Mentions)
+	}
+}
@@ -0,0 +1,215 @@
+// Package validation runs the `validate` struct tags of request DTOs and
+// turns failures into field-level errors the handlers can return as-is.
+package validation
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/joaoleau/blob/models"
+)
+
+const maxInterestLength = 100
+
+// AvatarIcons and AvatarColors are the palettes the clients can render.
+var (
+	AvatarIcons = []string{
+		"user", "cat", "dog", "bird", "fish", "rabbit", "squirrel", "turtle",
+		"ghost", "bot", "rocket", "star", "heart", "flower", "leaf", "sun",
+		"moon", "cloud", "zap", "flame", "coffee", "music", "gamepad", "camera",
+	}
+	AvatarColors = []string{
+		"slate", "gray", "red", "orange", "amber", "yellow", "lime", "green",
+		"emerald", "teal", "cyan", "sky", "blue", "indigo", "violet", "purple",
+		"fuchsia", "pink", "rose",
+	}
+)
+
+// UsernamePattern allows 3 to 50 letters, digits, dots and underscores, not
+// starting or ending with a dot so mentions can be told apart from sentences.
+const UsernamePattern = `^[A-Za-z0-9_][A-Za-z0-9_.]{1,48}[A-Za-z0-9_]$`
+
+var usernamePattern = regexp.MustCompile(UsernamePattern)
+
+// FieldError describes one invalid field, named as in the JSON body.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+
+	format string
+	args   []interface{}
+}
+
+// Errors lists every invalid field of a request.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	fields := make([]string, len(e))
+	for i, field := range e {
+		fields[i] = field.Field + ": " + field.Message
+	}
+	return "invalid input: " + strings.Join(fields, "; ")
+}
+
+var validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New(validator.WithRequiredStructEnabled())
+
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	v.RegisterValidation("content", validateContent)
+	v.RegisterValidation("interests", validateInterests)
+	v.RegisterValidation("username", func(fl validator.FieldLevel) bool {
+		return IsUsername(fl.Field().String())
+	})
+	v.RegisterValidation("avatar_icon", func(fl validator.FieldLevel) bool {
+		return contains(AvatarIcons, fl.Field().String())
+	})
+	v.RegisterValidation("avatar_color", func(fl validator.FieldLevel) bool {
+		return contains(AvatarColors, fl.Field().String())
+	})
+	v.RegisterValidation("reaction", func(fl validator.FieldLevel) bool {
+		return contains(models.ReactionTypes, fl.Field().String())
+	})
+
+	return v
+}
+
+// Struct validates a request DTO. The error is nil or an Errors value.
+func Struct(request interface{}) error {
+	err := validate.Struct(request)
+	if err == nil {
+		return nil
+	}
+
+	validationErrors, ok := err.(validator.ValidationErrors)
+	if !ok {
+		return err
+	}
+
+	fields := make(Errors, 0, len(validationErrors))
+	for _, fieldErr := range validationErrors {
+		format, args := message(fieldErr)
+		fields = append(fields, FieldError{
+			Field:   fieldErr.Field(),
+			Rule:    fieldErr.Tag(),
+			Message: fmt.Sprintf(format, args...),
+			format:  format,
+			args:    args,
+		})
+	}
+	return fields
+}
+
+// Localize returns the errors with their messages rendered by translate,
+// which gets the English format of each message and its arguments.
+func (e Errors) Localize(translate func(format string, args ...interface{}) string) Errors {
+	if e == nil {
+		return nil
+	}
+	localized := make(Errors, len(e))
+	for i, field := range e {
+		localized[i] = field
+		if field.format != "" {
+			localized[i].Message = translate(field.format, field.args...)
+		}
+	}
+	return localized
+}
+
+// IsUsername reports whether name is a well-formed username.
+func IsUsername(name string) bool {
+	return usernamePattern.MatchString(name)
+}
+
+// validateContent requires non-blank text of at most param characters.
+func validateContent(fl validator.FieldLevel) bool {
+	content := fl.Field().String()
+	if strings.TrimSpace(content) == "" {
+		return false
+	}
+	max, err := strconv.Atoi(fl.Param())
+	return err != nil || utf8.RuneCountInString(content) <= max
+}
+
+// validateInterests allows at most param distinct, non-blank interests that
+// fit the Interest.name column.
+func validateInterests(fl validator.FieldLevel) bool {
+	interests, ok := fl.Field().Interface().([]string)
+	if !ok {
+		return false
+	}
+	if max, err := strconv.Atoi(fl.Param()); err == nil && len(interests) > max {
+		return false
+	}
+
+	seen := make(map[string]bool)
+	for _, interest := range interests {
+		name := strings.ToLower(strings.TrimSpace(interest))
+		if name == "" || utf8.RuneCountInString(name) > maxInterestLength || seen[name] {
+			return false
+		}
+		seen[name] = true
+	}
+	return true
+}
+
+// message returns the English format of the message for fieldErr and its
+// arguments, kept apart so Localize can translate the format.
+func message(fieldErr validator.FieldError) (string, []interface{}) {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required", nil
+	case "max":
+		if fieldErr.Kind() == reflect.Slice {
+			return "must have at most %s items", []interface{}{fieldErr.Param()}
+		}
+		return "must be at most %s characters", []interface{}{fieldErr.Param()}
+	case "email":
+		return "must be a valid email address", nil
+	case "url":
+		return "must be a valid URL", nil
+	case "uuid":
+		return "must be a valid UUID", nil
+	case "oneof":
+		return "must be one of: %s", []interface{}{strings.ReplaceAll(fieldErr.Param(), " ", ", ")}
+	case "content":
+		return "must not be blank and at most %s characters", []interface{}{fieldErr.Param()}
+	case "interests":
+		return "must be at most %s distinct, non-blank interests of up to %d characters", []interface{}{fieldErr.Param(), maxInterestLength}
+	case "username":
+		return "must be 3 to 50 letters, digits, dots or underscores and cannot start or end with a dot", nil
+	case "avatar_icon":
+		return "must be one of: %s", []interface{}{strings.Join(AvatarIcons, ", ")}
+	case "avatar_color":
+		return "must be one of: %s", []interface{}{strings.Join(AvatarColors, ", ")}
+	case "reaction":
+		return "must be one of: %s", []interface{}{strings.Join(models.ReactionTypes, ", ")}
+	}
+	return "is invalid", nil
+}
+
+func contains(values []string, value string) bool {
+	for _, allowed := range values {
+		if value == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/joaoleau/blob/models"
+)
+
+// rules lists the field and rule of every error, in order.
+func rules(err error) []string {
+	var fields Errors
+	if !errors.As(err, &fields) {
+		return nil
+	}
+	out := make([]string, len(fields))
+	for i, field := range fields {
+		out[i] = field.Field + ":" + field.Rule
+	}
+	return out
+}
+
+func TestCustomRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		request interface{}
+		want    []string
+	}{
+		{"content", models.CreateCommentRequest{Content: "hello"}, nil},
+		{"content at the limit in runes", models.CreateCommentRequest{Content: strings.Repeat("é", 500)}, nil},
+		{"content over the limit", models.CreateCommentRequest{Content: strings.Repeat("a", 501)}, []string{"content:content"}},
+		{"blank content", models.CreateCommentRequest{Content: " \n\t "}, []string{"content:content"}},
+		{"empty content", models.CreateCommentRequest{}, []string{"content:content"}},
+		{"optional content left out", models.UpdateBlobRequest{}, nil},
+
+		{"interests", models.CreateBlobRequest{Content: "x", Interests: []string{"go", "rust"}}, nil},
+		{"too many interests", models.CreateBlobRequest{Content: "x", Interests: []string{"a", "b", "c", "d", "e", "f"}}, []string{"interests:interests"}},
+		{"duplicate interests ignore case and spaces", models.CreateBlobRequest{Content: "x", Interests: []string{"Go", " go "}}, []string{"interests:interests"}},
+		{"blank interest", models.CreateBlobRequest{Content: "x", Interests: []string{"go", " "}}, []string{"interests:interests"}},
+		{"long interest", models.CreateBlobRequest{Content: "x", Interests: []string{strings.Repeat("i", maxInterestLength+1)}}, []string{"interests:interests"}},
+
+		{"username", models.UpdateUserRequest{Username: "ana.maria_1"}, nil},
+		{"short username", models.UpdateUserRequest{Username: "an"}, []string{"username:username"}},
+		{"username with a leading dot", models.UpdateUserRequest{Username: ".ana"}, []string{"username:username"}},
+		{"username with a trailing dot", models.UpdateUserRequest{Username: "ana."}, []string{"username:username"}},
+		{"username with a dash", models.UpdateUserRequest{Username: "ana-maria"}, []string{"username:username"}},
+		{"long username", models.UpdateUserRequest{Username: strings.Repeat("a", 51)}, []string{"username:username"}},
+
+		{"avatar icon", models.UpdateUserRequest{AvatarIcon: "cat"}, nil},
+		{"unknown avatar icon", models.UpdateUserRequest{AvatarIcon: "dragon"}, []string{"avatar_icon:avatar_icon"}},
+		{"avatar color", models.UpdateUserRequest{AvatarColor: "teal"}, nil},
+		{"unknown avatar color", models.UpdateUserRequest{AvatarColor: "beige"}, []string{"avatar_color:avatar_color"}},
+
+		{"reaction", models.ReactionRequest{Type: models.ReactionLike}, nil},
+		{"unknown reaction", models.ReactionRequest{Type: "meh"}, []string{"type:reaction"}},
+
+		{"assignee", models.AssignReportRequest{AssigneeID: uuid.NewString()}, nil},
+		{"no assignee", models.AssignReportRequest{}, nil},
+		{"assignee not a uuid", models.AssignReportRequest{AssigneeID: "moderator"}, []string{"assignee_id:uuid"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rules(Struct(tt.request))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("errors = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructListsEveryInvalidField(t *testing.T) {
+	err := Struct(models.UpdateUserRequest{
+		Name:        strings.Repeat("n", 101),
+		Email:       "not-an-email",
+		Username:    "a",
+		Image:       "not a url",
+		AvatarIcon:  "dragon",
+		AvatarColor: "beige",
+	})
+
+	want := []string{"name:max", "email:email", "username:username", "image:url", "avatar_icon:avatar_icon", "avatar_color:avatar_color"}
+	if got := rules(err); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("errors = %v, want %v", got, want)
+	}
+
+	message := err.Error()
+	for _, part := range []string{"name: must be at most 100 characters", "email: must be a valid email address", "avatar_color: must be one of: slate"} {
+		if !strings.Contains(message, part) {
+			t.Errorf("Error() = %q, missing %q", message, part)
+		}
+	}
+}
+
+func TestMessages(t *testing.T) {
+	tests := []struct {
+		request interface{}
+		want    string
+	}{
+		{models.BlobBatchRequest{}, "is required"},
+		{models.BlobBatchRequest{IDs: make([]uuid.UUID, 101)}, "must have at most 100 items"},
+		{models.ReportRequest{Reason: "spam", Details: strings.Repeat("d", 1001)}, "must be at most 1000 characters"},
+		{models.CreateBlobRequest{}, "must not be blank and at most 1000 characters"},
+		{models.CreateBlobRequest{Content: "x", Interests: []string{"a", "a"}}, "must be at most 5 distinct, non-blank interests of up to 100 characters"},
+		{models.AssignReportRequest{AssigneeID: "x"}, "must be a valid UUID"},
+	}
+	for _, tt := range tests {
+		var fields Errors
+		if !errors.As(Struct(tt.request), &fields) || len(fields) != 1 {
+			t.Errorf("%T: errors = %v, want one", tt.request, fields)
+			continue
+		}
+		if fields[0].Message != tt.want {
+			t.Errorf("%T: message = %q, want %q", tt.request, fields[0].Message, tt.want)
+		}
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	err := Struct(models.UpdateUserRequest{Bio: strings.Repeat("b", 501), AvatarIcon: "dragon"})
+	var fields Errors
+	if !errors.As(err, &fields) {
+		t.Fatalf("Struct = %v", err)
+	}
+
+	localized := fields.Localize(func(format string, args ...interface{}) string {
+		return "[" + fmt.Sprintf(format, args...) + "]"
+	})
+	if len(localized) != 2 || localized[0].Message != "[must be at most 500 characters]" || localized[0].Field != "bio" || localized[0].Rule != "max" {
+		t.Errorf("localized = %+v", localized)
+	}
+	if !strings.HasPrefix(fields[0].Message, "must be") {
+		t.Errorf("Localize changed the original errors: %+v", fields)
+	}
+	if Errors(nil).Localize(fmt.Sprintf) != nil {
+		t.Errorf("Localize of nil errors is not nil")
+	}
+}
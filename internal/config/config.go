@@ -0,0 +1,419 @@
+// Package config holds the settings shared by the API, the migration runner
+// and the pop cronjob. Values are layered: defaults, then an optional YAML or
+// TOML file, then environment variables, then command-line flags.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	Database     Database     `cfg:"database"`
+	HTTP         HTTP         `cfg:"http"`
+	CORS         CORS         `cfg:"cors"`
+	Security     Security     `cfg:"security"`
+	Auth         Auth         `cfg:"auth"`
+	RateLimit    RateLimit    `cfg:"rate_limit"`
+	Features     Features     `cfg:"features"`
+	Storage      Storage      `cfg:"storage"`
+	Media        Media        `cfg:"media"`
+	LinkPreviews LinkPreviews `cfg:"link_previews"`
+	Moderation   Moderation   `cfg:"moderation"`
+	Metrics      Metrics      `cfg:"metrics"`
+	Telemetry    Telemetry    `cfg:"telemetry"`
+	Logging      Logging      `cfg:"logging"`
+}
+
+type Database struct {
+	Host string `cfg:"host" env:"DB_HOST" flag:"db-host"`
+	Port int    `cfg:"port" env:"DB_PORT" flag:"db-port"`
+	User string `cfg:"user" env:"DB_USER" flag:"db-user"`
+	// Password has no flag so it never shows up in process listings; use
+	// DB_PASSWD_FILE to mount it as a secret.
+	Password       string        `cfg:"password" env:"DB_PASSWD"`
+	Name           string        `cfg:"name" env:"DB_DATABASE" flag:"db-name"`
+	SSLMode        string        `cfg:"sslmode" env:"DB_SSLMODE" flag:"db-sslmode"`
+	SSLRootCert    string        `cfg:"sslrootcert" env:"DB_SSLROOTCERT"`
+	SSLCert        string        `cfg:"sslcert" env:"DB_SSLCERT"`
+	SSLKey         string        `cfg:"sslkey" env:"DB_SSLKEY"`
+	ConnectTimeout time.Duration `cfg:"connect_timeout" env:"DB_CONNECT_TIMEOUT"`
+	// ConnectRetry is how long the binaries keep retrying the first
+	// connection while Postgres starts up.
+	ConnectRetry time.Duration `cfg:"connect_retry" env:"DB_CONNECT_RETRY"`
+	// StatementTimeout aborts any statement running longer; zero disables it.
+	StatementTimeout time.Duration `cfg:"statement_timeout" env:"DB_STATEMENT_TIMEOUT"`
+	MaxOpenConns     int           `cfg:"max_open_conns" env:"DB_MAX_OPEN_CONNS" flag:"db-max-open-conns"`
+	MaxIdleConns     int           `cfg:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" flag:"db-max-idle-conns"`
+	ConnMaxLifetime  time.Duration `cfg:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime  time.Duration `cfg:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME"`
+}
+
+// MaxBodyBytes caps request bodies other than media uploads, which have
+// their own limit. ShutdownTimeout is how long in-flight requests get to
+// finish after SIGTERM. TrustedProxies lists the IPs or CIDRs whose
+// X-Forwarded-For and X-Real-IP headers are believed; with none the client
+// IP is always the peer address.
+type HTTP struct {
+	Port              int           `cfg:"port" env:"PORT" flag:"port"`
+	ReadTimeout       time.Duration `cfg:"read_timeout" env:"HTTP_READ_TIMEOUT"`
+	ReadHeaderTimeout time.Duration `cfg:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT"`
+	WriteTimeout      time.Duration `cfg:"write_timeout" env:"HTTP_WRITE_TIMEOUT"`
+	IdleTimeout       time.Duration `cfg:"idle_timeout" env:"HTTP_IDLE_TIMEOUT"`
+	MaxHeaderBytes    int           `cfg:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES"`
+	MaxBodyBytes      int           `cfg:"max_body_bytes" env:"HTTP_MAX_BODY_BYTES"`
+	ShutdownTimeout   time.Duration `cfg:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT"`
+	TLSCertFile       string        `cfg:"tls_cert_file" env:"HTTP_TLS_CERT_FILE" flag:"tls-cert"`
+	TLSKeyFile        string        `cfg:"tls_key_file" env:"HTTP_TLS_KEY_FILE" flag:"tls-key"`
+	TrustedProxies    []string      `cfg:"trusted_proxies" env:"HTTP_TRUSTED_PROXIES"`
+}
+
+// CORS lists the browser origins allowed to call the API. With no origins
+// cross-origin requests get no CORS headers and browsers block them.
+type CORS struct {
+	AllowedOrigins   []string      `cfg:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	AllowedMethods   []string      `cfg:"allowed_methods" env:"CORS_ALLOWED_METHODS"`
+	AllowedHeaders   []string      `cfg:"allowed_headers" env:"CORS_ALLOWED_HEADERS"`
+	AllowCredentials bool          `cfg:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS"`
+	MaxAge           time.Duration `cfg:"max_age" env:"CORS_MAX_AGE"`
+}
+
+// Security sets the response headers sent on every request. A zero
+// HSTSMaxAge leaves Strict-Transport-Security out, for plain HTTP setups.
+type Security struct {
+	HSTSMaxAge time.Duration `cfg:"hsts_max_age" env:"SECURITY_HSTS_MAX_AGE"`
+}
+
+// Auth configures how sessions are read. Bearer tokens always work; setting
+// SessionCookie also accepts the session token from that cookie, and CSRF
+// then requires a double-submit token on unsafe requests that use it.
+type Auth struct {
+	SessionCookie string `cfg:"session_cookie" env:"AUTH_SESSION_COOKIE"`
+	CSRF          bool   `cfg:"csrf" env:"AUTH_CSRF"`
+	CSRFCookie    string `cfg:"csrf_cookie" env:"AUTH_CSRF_COOKIE"`
+	CSRFHeader    string `cfg:"csrf_header" env:"AUTH_CSRF_HEADER"`
+}
+
+// RateLimit applies per client IP: RequestsPerMinute refill the bucket and
+// Burst is its size.
+type RateLimit struct {
+	Enabled           bool `cfg:"enabled" env:"RATE_LIMIT_ENABLED" flag:"rate-limit"`
+	RequestsPerMinute int  `cfg:"requests_per_minute" env:"RATE_LIMIT_REQUESTS_PER_MINUTE"`
+	Burst             int  `cfg:"burst" env:"RATE_LIMIT_BURST"`
+}
+
+type Features struct {
+	LinkPreviews bool `cfg:"link_previews" env:"FEATURE_LINK_PREVIEWS"`
+	MediaUploads bool `cfg:"media_uploads" env:"FEATURE_MEDIA_UPLOADS"`
+}
+
+// Storage selects where uploaded media lives: "local" keeps files in Dir,
+// served under BaseURL, and "s3" uses the bucket described by S3.
+type Storage struct {
+	Backend string `cfg:"backend" env:"STORAGE_BACKEND" flag:"storage-backend"`
+	Dir     string `cfg:"dir" env:"MEDIA_DIR"`
+	BaseURL string `cfg:"base_url" env:"MEDIA_BASE_URL"`
+	S3      S3     `cfg:"s3"`
+}
+
+// S3 describes an S3-compatible bucket. It is created on startup when it is
+// missing; with PublicRead it is made readable by anyone. The keys have no
+// flags; use S3_ACCESS_KEY_FILE and S3_SECRET_KEY_FILE to mount them.
+type S3 struct {
+	Endpoint   string `cfg:"endpoint" env:"S3_ENDPOINT"`
+	Region     string `cfg:"region" env:"S3_REGION"`
+	AccessKey  string `cfg:"access_key" env:"S3_ACCESS_KEY"`
+	SecretKey  string `cfg:"secret_key" env:"S3_SECRET_KEY"`
+	Bucket     string `cfg:"bucket" env:"S3_BUCKET"`
+	UseSSL     bool   `cfg:"use_ssl" env:"S3_USE_SSL"`
+	PublicURL  string `cfg:"public_url" env:"S3_PUBLIC_URL"`
+	PublicRead bool   `cfg:"public_read" env:"S3_PUBLIC_READ"`
+}
+
+type Media struct {
+	MaxUploadBytes int `cfg:"max_upload_bytes" env:"MEDIA_MAX_BYTES"`
+}
+
+// LinkPreviews tunes the link preview fetcher. AllowPrivate turns off the
+// private-network protection and is only meant for local setups.
+type LinkPreviews struct {
+	Timeout      time.Duration `cfg:"timeout" env:"LINK_PREVIEW_TIMEOUT"`
+	MaxBytes     int           `cfg:"max_bytes" env:"LINK_PREVIEW_MAX_BYTES"`
+	AllowPrivate bool          `cfg:"allow_private" env:"LINK_PREVIEW_ALLOW_PRIVATE"`
+}
+
+// Moderation holds the rules of the content filter. Empty settings add no rule,
+// so the defaults allow everything. A negative MaxLinks disables the link
+// limit, whose LinkAction is "hold" or "reject".
+type Moderation struct {
+	RejectWords   []string `cfg:"reject_words" env:"MODERATION_REJECT_WORDS"`
+	HoldWords     []string `cfg:"hold_words" env:"MODERATION_HOLD_WORDS"`
+	RejectPattern string   `cfg:"reject_pattern" env:"MODERATION_REJECT_PATTERN"`
+	HoldPattern   string   `cfg:"hold_pattern" env:"MODERATION_HOLD_PATTERN"`
+	MaxLinks      int      `cfg:"max_links" env:"MODERATION_MAX_LINKS"`
+	LinkAction    string   `cfg:"link_action" env:"MODERATION_LINK_ACTION"`
+}
+
+// Metrics configures the batch jobs, which exit before Prometheus could
+// scrape them and push their results to a Pushgateway instead.
+type Metrics struct {
+	PushgatewayURL string `cfg:"pushgateway_url" env:"PUSHGATEWAY_URL" flag:"pushgateway-url"`
+}
+
+// Telemetry configures tracing. Spans go to an OpenTelemetry collector over
+// OTLP/HTTP, to a JSON-lines file for local runs ("-" is stdout), or both;
+// with neither set, spans are dropped.
+type Telemetry struct {
+	OTLPEndpoint string  `cfg:"otlp_endpoint" env:"OTEL_EXPORTER_OTLP_ENDPOINT" flag:"otlp-endpoint"`
+	TraceFile    string  `cfg:"trace_file" env:"TRACE_FILE" flag:"trace-file"`
+	SampleRatio  float64 `cfg:"sample_ratio" env:"TRACE_SAMPLE_RATIO"`
+}
+
+// Logging sets the minimum level written: debug, info, warn or error.
+type Logging struct {
+	Level string `cfg:"level" env:"LOG_LEVEL" flag:"log-level"`
+}
+
+// Default returns the settings used when nothing overrides them. They match
+// what the binaries did before the configuration was centralised.
+func Default() Config {
+	return Config{
+		Database: Database{
+			Host:             "localhost",
+			Port:             5432,
+			User:             "postgres",
+			Name:             "blob",
+			SSLMode:          "disable",
+			ConnectTimeout:   10 * time.Second,
+			ConnectRetry:     time.Minute,
+			StatementTimeout: 30 * time.Second,
+			MaxOpenConns:     25,
+			MaxIdleConns:     25,
+			ConnMaxLifetime:  30 * time.Minute,
+			ConnMaxIdleTime:  5 * time.Minute,
+		},
+		HTTP: HTTP{
+			Port:              80,
+			ReadTimeout:       15 * time.Second,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    1 << 20,
+			MaxBodyBytes:      1 << 20,
+			ShutdownTimeout:   25 * time.Second,
+		},
+		CORS: CORS{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "X-Request-ID", "X-CSRF-Token"},
+			MaxAge:         12 * time.Hour,
+		},
+		Security: Security{
+			HSTSMaxAge: 365 * 24 * time.Hour,
+		},
+		Auth: Auth{
+			CSRFCookie: "csrf_token",
+			CSRFHeader: "X-CSRF-Token",
+		},
+		RateLimit: RateLimit{
+			RequestsPerMinute: 300,
+			Burst:             60,
+		},
+		Features: Features{
+			LinkPreviews: true,
+			MediaUploads: true,
+		},
+		Storage: Storage{
+			Backend: "local",
+			Dir:     "uploads",
+			BaseURL: "/uploads",
+			S3: S3{
+				UseSSL: true,
+			},
+		},
+		Media: Media{
+			MaxUploadBytes: 5 << 20,
+		},
+		LinkPreviews: LinkPreviews{
+			Timeout:  5 * time.Second,
+			MaxBytes: 512 << 10,
+		},
+		Moderation: Moderation{
+			MaxLinks:   -1,
+			LinkAction: "hold",
+		},
+		Telemetry: Telemetry{
+			SampleRatio: 1,
+		},
+		Logging: Logging{
+			Level: "info",
+		},
+	}
+}
+
+var (
+	sslModes        = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	storageBackends = []string{"local", "s3"}
+	filterActions   = []string{"hold", "reject"}
+)
+
+// Validate reports every invalid setting at once so a bad deployment fails
+// at startup with the full list.
+func (c *Config) Validate() error {
+	var problems []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Errorf(format, args...))
+		}
+	}
+
+	db := c.Database
+	check(db.Host != "", "database.host is required")
+	check(db.Port > 0 && db.Port < 65536, "database.port %d is out of range", db.Port)
+	check(db.User != "", "database.user is required")
+	check(db.Name != "", "database.name is required")
+	check(contains(sslModes, db.SSLMode), "database.sslmode %q must be one of: %s", db.SSLMode, strings.Join(sslModes, ", "))
+	check((db.SSLCert == "") == (db.SSLKey == ""), "database.sslcert and database.sslkey must be set together")
+	check(db.ConnectTimeout >= 0, "database.connect_timeout must not be negative")
+	check(db.ConnectRetry >= 0, "database.connect_retry must not be negative")
+	check(db.StatementTimeout >= 0, "database.statement_timeout must not be negative")
+	check(db.MaxOpenConns >= 0, "database.max_open_conns must not be negative")
+	check(db.MaxIdleConns >= 0, "database.max_idle_conns must not be negative")
+	check(db.MaxOpenConns == 0 || db.MaxIdleConns <= db.MaxOpenConns, "database.max_idle_conns must not exceed database.max_open_conns")
+	check(db.ConnMaxLifetime >= 0, "database.conn_max_lifetime must not be negative")
+	check(db.ConnMaxIdleTime >= 0, "database.conn_max_idle_time must not be negative")
+
+	h := c.HTTP
+	check(h.Port > 0 && h.Port < 65536, "http.port %d is out of range", h.Port)
+	check(h.ReadTimeout >= 0 && h.ReadHeaderTimeout >= 0 && h.WriteTimeout >= 0 && h.IdleTimeout >= 0 && h.ShutdownTimeout >= 0, "http timeouts must not be negative")
+	check(h.MaxHeaderBytes > 0, "http.max_header_bytes must be positive")
+	check(h.MaxBodyBytes > 0, "http.max_body_bytes must be positive")
+	check((h.TLSCertFile == "") == (h.TLSKeyFile == ""), "http.tls_cert_file and http.tls_key_file must be set together")
+	for _, proxy := range h.TrustedProxies {
+		check(validProxy(proxy), "http.trusted_proxies: %q is not an IP address or CIDR", proxy)
+	}
+
+	for _, origin := range c.CORS.AllowedOrigins {
+		check(validOrigin(origin), "cors.allowed_origins: %q is not \"*\" or a scheme://host origin", origin)
+	}
+	check(!(c.CORS.AllowCredentials && contains(c.CORS.AllowedOrigins, "*")), "cors.allow_credentials cannot be combined with the \"*\" origin")
+	check(c.CORS.MaxAge >= 0, "cors.max_age must not be negative")
+	check(c.Security.HSTSMaxAge >= 0, "security.hsts_max_age must not be negative")
+	check(!c.Auth.CSRF || c.Auth.SessionCookie != "", "auth.csrf requires auth.session_cookie")
+	check(!c.Auth.CSRF || (c.Auth.CSRFCookie != "" && c.Auth.CSRFHeader != ""), "auth.csrf_cookie and auth.csrf_header are required with auth.csrf")
+
+	st := c.Storage
+	check(contains(storageBackends, st.Backend), "storage.backend %q must be one of: %s", st.Backend, strings.Join(storageBackends, ", "))
+	check(st.Backend != "local" || st.Dir != "", "storage.dir is required for the local backend")
+	check(st.Backend != "s3" || (st.S3.Endpoint != "" && st.S3.Bucket != ""), "storage.s3.endpoint and storage.s3.bucket are required for the s3 backend")
+	check(st.Backend != "s3" || (st.S3.AccessKey == "") == (st.S3.SecretKey == ""), "storage.s3.access_key and storage.s3.secret_key must be set together")
+	check(c.Media.MaxUploadBytes > 0, "media.max_upload_bytes must be positive")
+	check(c.LinkPreviews.Timeout > 0, "link_previews.timeout must be positive")
+	check(c.LinkPreviews.MaxBytes > 0, "link_previews.max_bytes must be positive")
+
+	_, err := regexp.Compile(c.Moderation.RejectPattern)
+	check(err == nil, "moderation.reject_pattern: %v", err)
+	_, err = regexp.Compile(c.Moderation.HoldPattern)
+	check(err == nil, "moderation.hold_pattern: %v", err)
+	check(contains(filterActions, strings.ToLower(c.Moderation.LinkAction)), "moderation.link_action %q must be one of: %s", c.Moderation.LinkAction, strings.Join(filterActions, ", "))
+
+	if c.Metrics.PushgatewayURL != "" {
+		parsed, err := url.Parse(c.Metrics.PushgatewayURL)
+		check(err == nil && parsed.Scheme != "" && parsed.Host != "", "metrics.pushgateway_url %q is not a valid URL", c.Metrics.PushgatewayURL)
+	}
+
+	if c.Telemetry.OTLPEndpoint != "" {
+		parsed, err := url.Parse(c.Telemetry.OTLPEndpoint)
+		check(err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "", "telemetry.otlp_endpoint %q must be an http(s) URL", c.Telemetry.OTLPEndpoint)
+	}
+	check(c.Telemetry.SampleRatio >= 0 && c.Telemetry.SampleRatio <= 1, "telemetry.sample_ratio must be between 0 and 1")
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Logging.Level)) == nil, "logging.level %q must be one of: debug, info, warn, error", c.Logging.Level)
+
+	if c.RateLimit.Enabled {
+		check(c.RateLimit.RequestsPerMinute > 0, "rate_limit.requests_per_minute must be positive")
+		check(c.RateLimit.Burst > 0, "rate_limit.burst must be positive")
+	}
+
+	return errors.Join(problems...)
+}
+
+// DSN is the lib/pq connection string for the configured database.
+func (d Database) DSN() string {
+	return d.DSNFor(d.Name)
+}
+
+// DSNFor connects to another database on the same server, as the migration
+// runner does to create the application database.
+func (d Database) DSNFor(name string) string {
+	params := []struct{ key, value string }{
+		{"host", d.Host},
+		{"port", fmt.Sprint(d.Port)},
+		{"user", d.User},
+		{"password", d.Password},
+		{"dbname", name},
+		{"sslmode", d.SSLMode},
+		{"sslrootcert", d.SSLRootCert},
+		{"sslcert", d.SSLCert},
+		{"sslkey", d.SSLKey},
+	}
+	if d.ConnectTimeout > 0 {
+		params = append(params, struct{ key, value string }{"connect_timeout", fmt.Sprint(int(math.Ceil(d.ConnectTimeout.Seconds())))})
+	}
+	// lib/pq sends unknown keys as run-time parameters, so this sets the
+	// timeout on every connection of the pool.
+	if d.StatementTimeout > 0 {
+		params = append(params, struct{ key, value string }{"statement_timeout", fmt.Sprint(d.StatementTimeout.Milliseconds())})
+	}
+
+	var parts []string
+	for _, param := range params {
+		if param.value == "" {
+			continue
+		}
+		parts = append(parts, param.key+"="+quoteDSNValue(param.value))
+	}
+	return strings.Join(parts, " ")
+}
+
+// quoteDSNValue quotes values the way lib/pq expects in key=value strings.
+func quoteDSNValue(value string) string {
+	if !strings.ContainsAny(value, ` '\`) {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
+
+func validOrigin(origin string) bool {
+	if origin == "*" {
+		return true
+	}
+	parsed, err := url.Parse(origin)
+	return err == nil && parsed.Scheme != "" && parsed.Host != "" && (parsed.Path == "" || parsed.Path == "/")
+}
+
+func validProxy(proxy string) bool {
+	if net.ParseIP(proxy) != nil {
+		return true
+	}
+	_, _, err := net.ParseCIDR(proxy)
+	return err == nil
+}
+
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
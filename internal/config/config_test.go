@@ -0,0 +1,85 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		want   []string
+	}{
+		{"defaults", func(cfg *Config) {}, nil},
+		{"database", func(cfg *Config) {
+			cfg.Database.Host = ""
+			cfg.Database.Port = 70000
+			cfg.Database.SSLMode = "maybe"
+			cfg.Database.MaxIdleConns = 50
+		}, []string{"database.host is required", "database.port 70000 is out of range", `database.sslmode "maybe"`, "database.max_idle_conns must not exceed"}},
+		{"trusted proxies", func(cfg *Config) {
+			cfg.HTTP.TrustedProxies = []string{"10.0.0.1", "10.0.0.0/8", "::1", "proxy.local"}
+		}, []string{`http.trusted_proxies: "proxy.local"`}},
+		{"cors", func(cfg *Config) {
+			cfg.CORS.AllowedOrigins = []string{"*", "example.com"}
+			cfg.CORS.AllowCredentials = true
+		}, []string{`cors.allowed_origins: "example.com"`, "cors.allow_credentials cannot be combined"}},
+		{"csrf without cookie sessions", func(cfg *Config) {
+			cfg.Auth.CSRF = true
+		}, []string{"auth.csrf requires auth.session_cookie"}},
+		{"unknown storage backend", func(cfg *Config) {
+			cfg.Storage.Backend = "ftp"
+		}, []string{`storage.backend "ftp"`}},
+		{"incomplete s3", func(cfg *Config) {
+			cfg.Storage.Backend = "s3"
+			cfg.Storage.S3.Endpoint = "minio:9000"
+			cfg.Storage.S3.AccessKey = "minio"
+		}, []string{"storage.s3.endpoint and storage.s3.bucket are required", "storage.s3.access_key and storage.s3.secret_key must be set together"}},
+		{"complete s3", func(cfg *Config) {
+			cfg.Storage.Backend = "s3"
+			cfg.Storage.Dir = ""
+			cfg.Storage.S3.Endpoint = "minio:9000"
+			cfg.Storage.S3.Bucket = "media"
+		}, nil},
+		{"media and link previews", func(cfg *Config) {
+			cfg.Media.MaxUploadBytes = 0
+			cfg.LinkPreviews.Timeout = 0
+			cfg.LinkPreviews.MaxBytes = -1
+		}, []string{"media.max_upload_bytes must be positive", "link_previews.timeout must be positive", "link_previews.max_bytes must be positive"}},
+		{"moderation", func(cfg *Config) {
+			cfg.Moderation.HoldPattern = "("
+			cfg.Moderation.LinkAction = "ban"
+		}, []string{"moderation.hold_pattern", `moderation.link_action "ban"`}},
+		{"rate limit", func(cfg *Config) {
+			cfg.RateLimit.Enabled = true
+			cfg.RateLimit.Burst = 0
+		}, []string{"rate_limit.burst must be positive"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(&cfg)
+
+			err := cfg.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate passed, want %q", tt.want)
+			}
+			problems := strings.Split(err.Error(), "\n")
+			if len(problems) != len(tt.want) {
+				t.Errorf("got %d problems, want %d:\n%v", len(problems), len(tt.want), err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error does not mention %q:\n%v", want, err)
+				}
+			}
+		})
+	}
+}
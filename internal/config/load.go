@@ -0,0 +1,240 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration of the binary called name from its
+// command-line arguments, without the program name. The optional file comes
+// from -config or BLOB_CONFIG_FILE; a .toml extension selects TOML and
+// anything else is read as YAML. Flags win over environment variables,
+// which win over the file.
+func Load(name string, args []string) (*Config, error) {
+	cfg := Default()
+	fields := collectFields(reflect.ValueOf(&cfg).Elem(), "")
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("BLOB_CONFIG_FILE"), "path to a YAML or TOML configuration file")
+
+	type override struct {
+		field field
+		value string
+	}
+	var overrides []override
+	for _, f := range fields {
+		if f.flag == "" {
+			continue
+		}
+		f := f
+		flags.Func(f.flag, "overrides "+f.key, func(value string) error {
+			overrides = append(overrides, override{field: f, value: value})
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadFile(*configFile, fields); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range fields {
+		if f.env == "" {
+			continue
+		}
+		value, ok, err := LookupEnv(f.env)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if err := f.set(value); err != nil {
+			return nil, fmt.Errorf("%s: %w", f.env, err)
+		}
+	}
+
+	for _, o := range overrides {
+		if err := o.field.set(o.value); err != nil {
+			return nil, fmt.Errorf("-%s: %w", o.field.flag, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return &cfg, nil
+}
+
+// LookupEnv reads key from the environment, or from the file named by
+// key_FILE so secrets can be mounted instead of passed as variables. Setting
+// both is an error.
+func LookupEnv(key string) (string, bool, error) {
+	value, inline := os.LookupEnv(key)
+	path, fromFile := os.LookupEnv(key + "_FILE")
+	switch {
+	case inline && fromFile:
+		return "", false, fmt.Errorf("both %s and %s_FILE are set", key, key)
+	case fromFile:
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("%s_FILE: %w", key, err)
+		}
+		return strings.TrimRight(string(content), "\r\n"), true, nil
+	case inline && value != "":
+		return value, true, nil
+	}
+	return "", false, nil
+}
+
+type field struct {
+	key   string
+	env   string
+	flag  string
+	value reflect.Value
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// collectFields walks the cfg tags of v; nested structs become sections whose
+// keys are joined with dots, like "database.host".
+func collectFields(v reflect.Value, prefix string) []field {
+	var fields []field
+	for i := 0; i < v.NumField(); i++ {
+		structField := v.Type().Field(i)
+		key := prefix + structField.Tag.Get("cfg")
+		if structField.Type.Kind() == reflect.Struct {
+			fields = append(fields, collectFields(v.Field(i), key+".")...)
+			continue
+		}
+		fields = append(fields, field{
+			key:   key,
+			env:   structField.Tag.Get("env"),
+			flag:  structField.Tag.Get("flag"),
+			value: v.Field(i),
+		})
+	}
+	return fields
+}
+
+func (f field) set(raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch {
+	case f.value.Type() == durationType:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		f.value.SetInt(int64(d))
+	case f.value.Kind() == reflect.String:
+		f.value.SetString(raw)
+	case f.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		f.value.SetInt(int64(n))
+	case f.value.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", raw)
+		}
+		f.value.SetFloat(n)
+	case f.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		f.value.SetBool(b)
+	case f.value.Kind() == reflect.Slice:
+		items := []string{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		f.value.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", f.value.Type())
+	}
+	return nil
+}
+
+func loadFile(path string, fields []field) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+
+	document := map[string]interface{}{}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		err = toml.Unmarshal(content, &document)
+	} else {
+		err = yaml.Unmarshal(content, &document)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	values := map[string]interface{}{}
+	flatten(document, "", values)
+
+	byKey := make(map[string]field, len(fields))
+	for _, f := range fields {
+		byKey[f.key] = f
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		f, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("config file %s: unknown setting %q", path, key)
+		}
+		if err := f.set(fileValue(values[key])); err != nil {
+			return fmt.Errorf("config file %s: %s: %w", path, key, err)
+		}
+	}
+	return nil
+}
+
+func flatten(section map[string]interface{}, prefix string, into map[string]interface{}) {
+	for key, value := range section {
+		if nested, ok := value.(map[string]interface{}); ok {
+			flatten(nested, prefix+key+".", into)
+			continue
+		}
+		into[prefix+key] = value
+	}
+}
+
+// fileValue turns a decoded YAML or TOML value back into the text form the
+// environment uses, so both go through field.set.
+func fileValue(value interface{}) string {
+	if list, ok := value.([]interface{}); ok {
+		items := make([]string, 0, len(list))
+		for _, item := range list {
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, ",")
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// cleanEnv hides every setting of the environment running the tests; they
+// come back when the test ends.
+func cleanEnv(t *testing.T) {
+	t.Helper()
+	cfg := Default()
+	for _, f := range collectFields(reflect.ValueOf(&cfg).Elem(), "") {
+		if f.env != "" {
+			unsetEnv(t, f.env)
+			unsetEnv(t, f.env+"_FILE")
+		}
+	}
+	unsetEnv(t, "BLOB_CONFIG_FILE")
+}
+
+func unsetEnv(t *testing.T, key string) {
+	t.Setenv(key, "")
+	os.Unsetenv(key)
+}
+
+func TestLoadLayering(t *testing.T) {
+	yamlFile := writeFile(t, "blob.yaml", "database:\n  host: file-host\n  port: 6543\nhttp:\n  read_timeout: 3s\ncors:\n  allowed_origins: [\"https://a.example\", \"https://b.example\"]\n")
+	tomlFile := writeFile(t, "blob.toml", "[database]\nhost = \"toml-host\"\n")
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		args  []string
+		check func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Database.Host != "localhost" || cfg.Database.Port != 5432 {
+					t.Errorf("database = %s:%d", cfg.Database.Host, cfg.Database.Port)
+				}
+			},
+		},
+		{
+			name: "yaml file over defaults",
+			args: []string{"-config", yamlFile},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Database.Host != "file-host" || cfg.Database.Port != 6543 || cfg.HTTP.ReadTimeout != 3*time.Second {
+					t.Errorf("database = %s:%d, read timeout = %v", cfg.Database.Host, cfg.Database.Port, cfg.HTTP.ReadTimeout)
+				}
+				if strings.Join(cfg.CORS.AllowedOrigins, " ") != "https://a.example https://b.example" {
+					t.Errorf("origins = %v", cfg.CORS.AllowedOrigins)
+				}
+			},
+		},
+		{
+			name: "toml file from the environment",
+			env:  map[string]string{"BLOB_CONFIG_FILE": tomlFile},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Database.Host != "toml-host" {
+					t.Errorf("host = %s", cfg.Database.Host)
+				}
+			},
+		},
+		{
+			name: "env over file",
+			env:  map[string]string{"DB_HOST": "env-host", "STORAGE_BACKEND": "s3", "S3_ENDPOINT": "minio:9000", "S3_BUCKET": "media"},
+			args: []string{"-config", yamlFile},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Database.Host != "env-host" || cfg.Database.Port != 6543 {
+					t.Errorf("database = %s:%d", cfg.Database.Host, cfg.Database.Port)
+				}
+				if cfg.Storage.Backend != "s3" || cfg.Storage.S3.Endpoint != "minio:9000" {
+					t.Errorf("storage = %+v", cfg.Storage)
+				}
+			},
+		},
+		{
+			name: "flag over env",
+			env:  map[string]string{"DB_HOST": "env-host", "DB_PORT": "7000"},
+			args: []string{"-config", yamlFile, "-db-host", "flag-host"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Database.Host != "flag-host" || cfg.Database.Port != 7000 {
+					t.Errorf("database = %s:%d", cfg.Database.Host, cfg.Database.Port)
+				}
+			},
+		},
+		{
+			name: "empty env keeps the lower layer",
+			env:  map[string]string{"DB_HOST": ""},
+			args: []string{"-config", yamlFile},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Database.Host != "file-host" {
+					t.Errorf("host = %s", cfg.Database.Host)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleanEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := Load("test", tt.args)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		{"unknown file setting", nil, []string{"-config", writeFile(t, "bad.yaml", "database:\n  hots: x\n")}, `unknown setting "database.hots"`},
+		{"bad env number", map[string]string{"DB_PORT": "five"}, nil, `DB_PORT: invalid number "five"`},
+		{"bad flag number", nil, []string{"-db-port", "five"}, `-db-port: invalid number "five"`},
+		{"invalid result", map[string]string{"MEDIA_MAX_BYTES": "0"}, nil, "media.max_upload_bytes must be positive"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cleanEnv(t)
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := Load("test", tt.args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupEnvFile(t *testing.T) {
+	secret := writeFile(t, "secret", "s3cr3t\n")
+
+	tests := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantOK  bool
+		wantErr bool
+	}{
+		{"unset", nil, "", false, false},
+		{"inline", map[string]string{"S3_SECRET_KEY": "inline"}, "inline", true, false},
+		{"from file, newline trimmed", map[string]string{"S3_SECRET_KEY_FILE": secret}, "s3cr3t", true, false},
+		{"both set", map[string]string{"S3_SECRET_KEY": "inline", "S3_SECRET_KEY_FILE": secret}, "", false, true},
+		{"missing file", map[string]string{"S3_SECRET_KEY_FILE": filepath.Join(t.TempDir(), "nope")}, "", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unsetEnv(t, "S3_SECRET_KEY")
+			unsetEnv(t, "S3_SECRET_KEY_FILE")
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			value, ok, err := LookupEnv("S3_SECRET_KEY")
+			if (err != nil) != tt.wantErr || value != tt.want || ok != tt.wantOK {
+				t.Errorf("LookupEnv = %q, %v, %v", value, ok, err)
+			}
+		})
+	}
+}
+
+func TestLoadReadsSecretFiles(t *testing.T) {
+	cleanEnv(t)
+	t.Setenv("STORAGE_BACKEND", "s3")
+	t.Setenv("S3_ENDPOINT", "minio:9000")
+	t.Setenv("S3_BUCKET", "media")
+	t.Setenv("S3_ACCESS_KEY", "minio")
+	t.Setenv("S3_SECRET_KEY_FILE", writeFile(t, "s3-secret", "minio123\n"))
+	t.Setenv("DB_PASSWD_FILE", writeFile(t, "db-password", "hunter2"))
+
+	cfg, err := Load("test", nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Storage.S3.SecretKey != "minio123" || cfg.Database.Password != "hunter2" {
+		t.Errorf("secret key = %q, password = %q", cfg.Storage.S3.SecretKey, cfg.Database.Password)
+	}
+}
@@ -0,0 +1,75 @@
+// Package database opens the Postgres connection pool shared by the API, the
+// migration runner and the pop cronjob.
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/joaoleau/blob/internal/config"
+	"github.com/joaoleau/blob/internal/logging"
+	"github.com/lib/pq"
+)
+
+const (
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 10 * time.Second
+	checkTimeout   = 2 * time.Second
+)
+
+// Connect opens a traced pool on database dbname of the server described by
+// cfg, applies the pool limits and waits until Postgres answers. Failed
+// attempts are retried with exponential backoff for up to cfg.ConnectRetry,
+// since Postgres may still be starting when the binaries come up.
+func Connect(ctx context.Context, cfg config.Database, dbname string) (*sqlx.DB, error) {
+	connector, err := pq.NewConnector(cfg.DSNFor(dbname))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	db := sqlx.NewDb(sql.OpenDB(tracedConnector{connector}), "postgres")
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	deadline := time.Now().Add(cfg.ConnectRetry)
+	backoff := initialBackoff
+	for attempt := 1; ; attempt++ {
+		err = Check(ctx, db)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil || time.Now().Add(backoff).After(deadline) {
+			db.Close()
+			return nil, fmt.Errorf("failed to connect to database %s after %d attempts: %w", dbname, attempt, err)
+		}
+
+		slog.WarnContext(ctx, "Database is not ready, retrying",
+			slog.String("database", dbname), slog.Int("attempt", attempt), slog.Duration("retry_in", backoff), logging.Err(err))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			db.Close()
+			return nil, fmt.Errorf("failed to connect to database %s: %w", dbname, ctx.Err())
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+
+	slog.InfoContext(ctx, "Connected to database", slog.String("database", dbname))
+	return db, nil
+}
+
+// Check is the health probe: it runs a trivial query with a short timeout so
+// a stuck pool fails fast instead of hanging the caller.
+func Check(ctx context.Context, db *sqlx.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	var one int
+	return db.QueryRowContext(ctx, "SELECT 1").Scan(&one)
+}
@@ -0,0 +1,20 @@
+package database
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SchemaVersion is the schema this build expects. The migration runner
+// records it once every migration ran, and the API only reports ready when
+// the database has reached it. Bump it with every new migration.
+const SchemaVersion = 3
+
+// AppliedSchemaVersion returns the newest version recorded by the runner, or
+// 0 when the runner has not completed on this database yet.
+func AppliedSchemaVersion(ctx context.Context, db *sqlx.DB) (int, error) {
+	var version int
+	err := db.GetContext(ctx, &version, `SELECT COALESCE(MAX(version), 0) FROM "SchemaVersion"`)
+	return version, err
+}
@@ -0,0 +1,108 @@
+// Package logging configures the JSON slog logger shared by the binaries and
+// carries the per-request logger through contexts.
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/joaoleau/blob/internal/config"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are matched as substrings of lower-cased attribute keys.
+var sensitiveKeys = []string{"password", "passwd", "secret", "token", "authorization", "cookie", "email"}
+
+var (
+	emailPattern  = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	bearerPattern = regexp.MustCompile(`(?i)(bearer\s+)[A-Za-z0-9._~+/=-]+`)
+)
+
+// Setup makes a JSON logger at cfg.Level the slog default. Output of the
+// standard log package goes through it too.
+func Setup(cfg config.Logging) *slog.Logger {
+	var level slog.Level
+	// Validate already rejected unknown levels.
+	_ = level.UnmarshalText([]byte(cfg.Level))
+
+	logger := New(os.Stderr, level)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// New returns a JSON logger writing to w that redacts sensitive attributes:
+// keys naming secrets, tokens or emails lose their value, and email
+// addresses or bearer tokens inside any string are masked.
+func New(w io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redact,
+	}))
+}
+
+func redact(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+	if attr.Value.Kind() == slog.KindString {
+		return slog.String(attr.Key, RedactString(attr.Value.String()))
+	}
+	return attr
+}
+
+// RedactString masks email addresses and bearer tokens in s.
+func RedactString(s string) string {
+	s = emailPattern.ReplaceAllString(s, redacted)
+	return bearerPattern.ReplaceAllString(s, "${1}"+redacted)
+}
+
+// Err describes err for a log entry: its message and, for errors wrapped with
+// github.com/pkg/errors or fmt.Errorf, the message each layer added, from the
+// outermost wrap down to the root cause.
+func Err(err error) slog.Attr {
+	if err == nil {
+		return slog.Attr{}
+	}
+
+	var chain []string
+	for current := err; current != nil; current = errors.Unwrap(current) {
+		message := current.Error()
+		if cause := errors.Unwrap(current); cause != nil {
+			message = strings.TrimSuffix(message, ": "+cause.Error())
+			if message == current.Error() || message == "" {
+				// Layers that only add a stack trace repeat the cause.
+				continue
+			}
+		}
+		chain = append(chain, RedactString(message))
+	}
+
+	return slog.Group("error",
+		slog.String("message", err.Error()),
+		slog.Any("chain", chain),
+	)
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a copy of ctx carrying logger.
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger stored in ctx, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
@@ -0,0 +1,253 @@
+package telemetry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// bridgeTracer implements opentracing.Tracer on top of an OpenTelemetry
+// tracer, so the opentracing.StartSpanFromContext calls spread through the
+// code base produce OpenTelemetry spans under their existing names.
+type bridgeTracer struct {
+	tracer trace.Tracer
+}
+
+// NewBridge returns an opentracing.Tracer whose spans are created by tracer.
+func NewBridge(tracer trace.Tracer) opentracing.Tracer {
+	return &bridgeTracer{tracer: tracer}
+}
+
+func (t *bridgeTracer) StartSpan(operationName string, opts ...opentracing.StartSpanOption) opentracing.Span {
+	options := opentracing.StartSpanOptions{}
+	for _, opt := range opts {
+		opt.Apply(&options)
+	}
+
+	ctx := context.Background()
+	for _, ref := range options.References {
+		if parent, ok := ref.ReferencedContext.(spanContext); ok {
+			ctx = trace.ContextWithSpanContext(ctx, parent.otel)
+			break
+		}
+	}
+
+	var startOptions []trace.SpanStartOption
+	if !options.StartTime.IsZero() {
+		startOptions = append(startOptions, trace.WithTimestamp(options.StartTime))
+	}
+	for key, value := range options.Tags {
+		startOptions = append(startOptions, trace.WithAttributes(toAttribute(key, value)))
+	}
+
+	_, span := t.tracer.Start(ctx, operationName, startOptions...)
+	return &bridgeSpan{span: span, tracer: t}
+}
+
+func (t *bridgeTracer) Inject(sc opentracing.SpanContext, format interface{}, carrier interface{}) error {
+	c, ok := sc.(spanContext)
+	if !ok {
+		return opentracing.ErrInvalidSpanContext
+	}
+	if format != opentracing.HTTPHeaders && format != opentracing.TextMap {
+		return opentracing.ErrUnsupportedFormat
+	}
+	writer, ok := carrier.(opentracing.TextMapWriter)
+	if !ok {
+		return opentracing.ErrInvalidCarrier
+	}
+
+	headers := propagation.HeaderCarrier{}
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpanContext(context.Background(), c.otel), headers)
+	for key := range headers {
+		writer.Set(key, http.Header(headers).Get(key))
+	}
+	return nil
+}
+
+func (t *bridgeTracer) Extract(format interface{}, carrier interface{}) (opentracing.SpanContext, error) {
+	if format != opentracing.HTTPHeaders && format != opentracing.TextMap {
+		return nil, opentracing.ErrUnsupportedFormat
+	}
+	reader, ok := carrier.(opentracing.TextMapReader)
+	if !ok {
+		return nil, opentracing.ErrInvalidCarrier
+	}
+
+	headers := http.Header{}
+	if err := reader.ForeachKey(func(key, value string) error {
+		headers.Add(key, value)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	ctx := otel.GetTextMapPropagator().Extract(context.Background(), propagation.HeaderCarrier(headers))
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil, opentracing.ErrSpanContextNotFound
+	}
+	return spanContext{otel: sc}, nil
+}
+
+// ContextWithSpanHook also stores the OpenTelemetry span in ctx, so code
+// instrumented with OpenTelemetry directly, like the SQL driver, nests its
+// spans under the opentracing span that is current.
+func (t *bridgeTracer) ContextWithSpanHook(ctx context.Context, span opentracing.Span) context.Context {
+	if s, ok := span.(*bridgeSpan); ok {
+		return trace.ContextWithSpan(ctx, s.span)
+	}
+	return ctx
+}
+
+// ContextWithOpenTracingSpan exposes the OpenTelemetry span of ctx as the
+// current opentracing span, so spans started with opentracing continue the
+// trace an OpenTelemetry instrumentation (like the HTTP middleware) began.
+func ContextWithOpenTracingSpan(ctx context.Context) context.Context {
+	span := trace.SpanFromContext(ctx)
+	if !span.SpanContext().IsValid() {
+		return ctx
+	}
+	bridge := &bridgeTracer{tracer: otel.Tracer(InstrumentationName)}
+	return opentracing.ContextWithSpan(ctx, &bridgeSpan{span: span, tracer: bridge})
+}
+
+type spanContext struct {
+	otel trace.SpanContext
+}
+
+// ForeachBaggageItem does nothing: baggage is propagated by OpenTelemetry.
+func (c spanContext) ForeachBaggageItem(handler func(k, v string) bool) {}
+
+type bridgeSpan struct {
+	span   trace.Span
+	tracer *bridgeTracer
+}
+
+func (s *bridgeSpan) Finish() {
+	s.span.End()
+}
+
+func (s *bridgeSpan) FinishWithOptions(opts opentracing.FinishOptions) {
+	for _, record := range opts.LogRecords {
+		s.addEvent(record.Timestamp, record.Fields)
+	}
+
+	var endOptions []trace.SpanEndOption
+	if !opts.FinishTime.IsZero() {
+		endOptions = append(endOptions, trace.WithTimestamp(opts.FinishTime))
+	}
+	s.span.End(endOptions...)
+}
+
+func (s *bridgeSpan) Context() opentracing.SpanContext {
+	return spanContext{otel: s.span.SpanContext()}
+}
+
+func (s *bridgeSpan) SetOperationName(operationName string) opentracing.Span {
+	s.span.SetName(operationName)
+	return s
+}
+
+// SetTag maps the opentracing "error" tag to the span status; every other
+// tag becomes an attribute.
+func (s *bridgeSpan) SetTag(key string, value interface{}) opentracing.Span {
+	if key == string(ext.Error) {
+		if failed, ok := value.(bool); ok && failed {
+			s.span.SetStatus(codes.Error, "")
+		}
+		return s
+	}
+	s.span.SetAttributes(toAttribute(key, value))
+	return s
+}
+
+func (s *bridgeSpan) LogFields(fields ...log.Field) {
+	s.addEvent(time.Time{}, fields)
+}
+
+func (s *bridgeSpan) LogKV(alternatingKeyValues ...interface{}) {
+	fields, err := log.InterleavedKVToFields(alternatingKeyValues...)
+	if err != nil {
+		return
+	}
+	s.LogFields(fields...)
+}
+
+func (s *bridgeSpan) SetBaggageItem(restrictedKey, value string) opentracing.Span {
+	return s
+}
+
+func (s *bridgeSpan) BaggageItem(restrictedKey string) string {
+	return ""
+}
+
+func (s *bridgeSpan) Tracer() opentracing.Tracer {
+	return s.tracer
+}
+
+func (s *bridgeSpan) LogEvent(event string) {
+	s.span.AddEvent(event)
+}
+
+func (s *bridgeSpan) LogEventWithPayload(event string, payload interface{}) {
+	s.span.AddEvent(event, trace.WithAttributes(toAttribute("payload", payload)))
+}
+
+func (s *bridgeSpan) Log(data opentracing.LogData) {
+	s.span.AddEvent(data.Event, trace.WithTimestamp(data.Timestamp), trace.WithAttributes(toAttribute("payload", data.Payload)))
+}
+
+// addEvent records opentracing log fields as one event, named after the
+// "event" field when there is one. Logged errors also mark the span failed.
+func (s *bridgeSpan) addEvent(timestamp time.Time, fields []log.Field) {
+	name := "log"
+	attributes := make([]attribute.KeyValue, 0, len(fields))
+	for _, field := range fields {
+		switch field.Key() {
+		case "event":
+			name = fmt.Sprint(field.Value())
+			continue
+		case "error", "error.object":
+			if err, ok := field.Value().(error); ok {
+				s.span.RecordError(err)
+				s.span.SetStatus(codes.Error, err.Error())
+				continue
+			}
+		}
+		attributes = append(attributes, toAttribute(field.Key(), field.Value()))
+	}
+
+	options := []trace.EventOption{trace.WithAttributes(attributes...)}
+	if !timestamp.IsZero() {
+		options = append(options, trace.WithTimestamp(timestamp))
+	}
+	s.span.AddEvent(name, options...)
+}
+
+func toAttribute(key string, value interface{}) attribute.KeyValue {
+	switch v := value.(type) {
+	case string:
+		return attribute.String(key, v)
+	case bool:
+		return attribute.Bool(key, v)
+	case int:
+		return attribute.Int(key, v)
+	case int64:
+		return attribute.Int64(key, v)
+	case float64:
+		return attribute.Float64(key, v)
+	default:
+		return attribute.String(key, fmt.Sprint(v))
+	}
+}
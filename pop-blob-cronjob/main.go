@@ -1,70 +1,121 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"time"
 	"log"
 	"context"
 	"github.com/joho/godotenv"
+	"github.com/joaoleau/blob/internal/config"
+	"github.com/joaoleau/blob/internal/database"
+	"github.com/joaoleau/blob/internal/logging"
+	"github.com/joaoleau/blob/internal/telemetry"
 	"github.com/opentracing/opentracing-go"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 )
 
-func runningQueries(db *sqlx.DB, ctx context.Context) {
+// runningQueries runs every step and reports whether all of them succeeded.
+func runningQueries(db *sqlx.DB, ctx context.Context) bool {
+	popped := popOldBlobs(db, ctx)
+	purged := purgeDeletedUsers(db, ctx)
+	reconciled := reconcileCounters(db, ctx)
+	return popped && purged && reconciled
+}
+
+// popOldBlobs expires the blobs older than a day.
+func popOldBlobs(db *sqlx.DB, ctx context.Context) bool {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobSchemas.DeleteOldBlobs")
 	defer span.Finish()
 
-	query := "SELECT pop_old_blobs();"
-
-	if _, err := db.ExecContext(ctx, query); err != nil {
+	var expired int
+	if err := db.GetContext(ctx, &expired, "SELECT pop_old_blobs();"); err != nil {
 		log.Println("Failed to execute pop_old_blobs function:", err)
-		return
+		return false
 	}
- 
-	log.Println("Old blobs deleted successfully!")
+	blobsExpired.Set(float64(expired))
+
+	log.Printf("Deleted %d old blobs.", expired)
+	return true
 }
 
-func ConnectDB(dbname string) (*sqlx.DB, error) {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables.")
+// reconcileCounters repairs the likes and comments counters stored on blobs
+// when they drifted from the rows they count.
+func reconcileCounters(db *sqlx.DB, ctx context.Context) bool {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobSchemas.ReconcileCounters")
+	defer span.Finish()
+
+	var repaired int
+	if err := db.GetContext(ctx, &repaired, "SELECT reconcile_blob_counters();"); err != nil {
+		log.Println("Failed to execute reconcile_blob_counters function:", err)
+		return false
 	}
+	countersReconciled.Set(float64(repaired))
 
-	var (
-		host     = os.Getenv("DB_HOST")
-		port     = os.Getenv("DB_PORT")
-		user     = os.Getenv("DB_USER")
-		password = os.Getenv("DB_PASSWD")
-	)
-	
-	if dbname == "" {
-		dbname = os.Getenv("DB_DATABASE")
+	log.Printf("Reconciled counters of %d blobs.", repaired)
+	return true
+}
+
+// purgeDeletedUsers removes the accounts whose deletion grace period is over.
+func purgeDeletedUsers(db *sqlx.DB, ctx context.Context) bool {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobSchemas.PurgeDeletedUsers")
+	defer span.Finish()
+
+	var purged int
+	if err := db.GetContext(ctx, &purged, "SELECT purge_deleted_users();"); err != nil {
+		log.Println("Failed to execute purge_deleted_users function:", err)
+		return false
 	}
+	usersPurged.Set(float64(purged))
 
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
+	log.Printf("Purged %d deleted accounts.", purged)
+	return true
+}
 
-	db, err := sqlx.Open("postgres", psqlInfo)
+// run deletes the expired blobs, purges deleted accounts and reconciles the
+// counters, then pushes the run's metrics. It returns instead of exiting so
+// main can flush the traces of a failed run before the process ends.
+func run(ctx context.Context, cfg *config.Config) error {
+	started := time.Now()
+	dbConnection, err := database.Connect(ctx, cfg.Database, cfg.Database.Name)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return fmt.Errorf("failed to connect to database: %w", err)
 	}
-	
-	err = db.Ping()
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	defer dbConnection.Close()
+
+	succeeded := runningQueries(dbConnection, ctx)
+	if succeeded {
+		lastSuccess.SetToCurrentTime()
 	}
+	pushMetrics(cfg.Metrics.PushgatewayURL, started)
 
-	fmt.Println("Connected to: " + dbname)
-	return db, nil
+	if !succeeded {
+		return errors.New("one or more steps failed")
+	}
+	return nil
 }
 
 func main() { 
-	dbConnection, err := ConnectDB("")
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using system environment variables.")
+	}
+
+	cfg, err := config.Load("blob-pop", os.Args[1:])
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Failed to load configuration: %v", err)
 	}
-	defer dbConnection.Close()
-	ctx := context.Background()
-	
-	runningQueries(dbConnection, ctx)
-}
\ No newline at end of file
+	logging.Setup(cfg.Logging)
+
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg.Telemetry, "blob-pop")
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	err = run(context.Background(), cfg)
+	shutdownTracing(context.Background())
+	if err != nil {
+		log.Fatalf("Run failed: %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// The cronjob exits right after a run, so its results are pushed to a
+// Pushgateway as gauges describing the last run instead of being scraped.
+var (
+	registry = prometheus.NewRegistry()
+
+	blobsExpired       = newGauge("blobs_expired", "Blobs deleted by the last run.")
+	usersPurged        = newGauge("users_purged", "Deleted accounts purged by the last run.")
+	countersReconciled = newGauge("counters_reconciled", "Blobs whose counters the last run repaired.")
+	runDuration        = newGauge("pop_duration_seconds", "Duration of the last run.")
+	lastSuccess        = newGauge("pop_last_success_timestamp_seconds", "Unix time of the last run that completed every step.")
+)
+
+func newGauge(name, help string) prometheus.Gauge {
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Namespace: "blob", Name: name, Help: help})
+	registry.MustRegister(gauge)
+	return gauge
+}
+
+// pushMetrics sends the gauges of this run to the Pushgateway at url; it is a
+// no-op when no Pushgateway is configured.
+func pushMetrics(url string, started time.Time) {
+	if url == "" {
+		return
+	}
+
+	runDuration.Set(time.Since(started).Seconds())
+	if err := push.New(url, "blob_pop").Gatherer(registry).Push(); err != nil {
+		log.Println("Failed to push metrics:", err)
+	}
+}
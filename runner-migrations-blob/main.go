@@ -6,14 +6,16 @@ import (
 	"log"
 	"context"
 	"github.com/joho/godotenv"
+	"github.com/joaoleau/blob/internal/config"
+	"github.com/joaoleau/blob/internal/database"
+	"github.com/joaoleau/blob/internal/logging"
+	"github.com/joaoleau/blob/internal/telemetry"
 	"github.com/opentracing/opentracing-go"
 	"github.com/jmoiron/sqlx"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 const (
-	createDatabaseQuery = `CREATE DATABASE blob;`
-
 	createUserTableQuery = `
 	CREATE TABLE IF NOT EXISTS "User" (
 		id VARCHAR(255) PRIMARY KEY,
@@ -61,18 +63,6 @@ const (
 		CONSTRAINT fk_blob_comment FOREIGN KEY (blob_id) REFERENCES "Blob" (id) ON DELETE CASCADE
 	);`
 
-	createLikeTableQuery = `
-	CREATE TABLE IF NOT EXISTS "Like" (
-		id VARCHAR(255) PRIMARY KEY,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		user_id VARCHAR(255) NOT NULL,
-		blob_id VARCHAR(255) NOT NULL,
-		CONSTRAINT fk_user_like FOREIGN KEY (user_id) REFERENCES "User" (id) ON DELETE CASCADE,
-		CONSTRAINT fk_blob_like FOREIGN KEY (blob_id) REFERENCES "Blob" (id) ON DELETE CASCADE,
-		CONSTRAINT unique_user_blob_like UNIQUE (user_id, blob_id)
-	);
-	`
-
 	createBlobInterestTableQuery = `
 	CREATE TABLE IF NOT EXISTS "_BlobToInterest" (
 		blob_id VARCHAR(255) NOT NULL,
@@ -100,14 +90,431 @@ const (
 		CONSTRAINT fk_user_session FOREIGN KEY (user_id) REFERENCES "User" (id) ON DELETE CASCADE
 	);`
 
+	createMentionTableQuery = `
+	CREATE TABLE IF NOT EXISTS "Mention" (
+		id VARCHAR(255) PRIMARY KEY,
+		blob_id VARCHAR(255) NOT NULL,
+		comment_id VARCHAR(255),
+		author_id VARCHAR(255) NOT NULL,
+		mentioned_user_id VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT fk_blob_mention FOREIGN KEY (blob_id) REFERENCES "Blob" (id) ON DELETE CASCADE,
+		CONSTRAINT fk_comment_mention FOREIGN KEY (comment_id) REFERENCES "Comment" (id) ON DELETE CASCADE,
+		CONSTRAINT fk_author_mention FOREIGN KEY (author_id) REFERENCES "User" (id) ON DELETE CASCADE,
+		CONSTRAINT fk_mentioned_user_mention FOREIGN KEY (mentioned_user_id) REFERENCES "User" (id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_mention_mentioned_user ON "Mention" (mentioned_user_id, created_at DESC);`
+
+	alterModerationColumnsQuery = `
+	ALTER TABLE "User" ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user';
+	ALTER TABLE "User" ADD COLUMN IF NOT EXISTS banned_at TIMESTAMP;
+	ALTER TABLE "Blob" ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMP;
+	ALTER TABLE "Comment" ADD COLUMN IF NOT EXISTS hidden_at TIMESTAMP;`
+
+	createReportTableQuery = `
+	CREATE TABLE IF NOT EXISTS "Report" (
+		id VARCHAR(255) PRIMARY KEY,
+		reporter_id VARCHAR(255),
+		blob_id VARCHAR(255),
+		comment_id VARCHAR(255),
+		target_user_id VARCHAR(255) NOT NULL,
+		content TEXT NOT NULL DEFAULT '',
+		reason VARCHAR(50) NOT NULL,
+		details TEXT NOT NULL DEFAULT '',
+		source VARCHAR(20) NOT NULL DEFAULT 'user',
+		status VARCHAR(20) NOT NULL DEFAULT 'open',
+		assignee_id VARCHAR(255),
+		action VARCHAR(20),
+		resolution_note TEXT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		resolved_at TIMESTAMP,
+		CONSTRAINT fk_reporter_report FOREIGN KEY (reporter_id) REFERENCES "User" (id) ON DELETE SET NULL,
+		CONSTRAINT fk_blob_report FOREIGN KEY (blob_id) REFERENCES "Blob" (id) ON DELETE SET NULL,
+		CONSTRAINT fk_comment_report FOREIGN KEY (comment_id) REFERENCES "Comment" (id) ON DELETE SET NULL,
+		CONSTRAINT fk_target_user_report FOREIGN KEY (target_user_id) REFERENCES "User" (id) ON DELETE CASCADE,
+		CONSTRAINT fk_assignee_report FOREIGN KEY (assignee_id) REFERENCES "User" (id) ON DELETE SET NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_report_status ON "Report" (status, created_at);`
+
+	createModerationAuditLogTableQuery = `
+	CREATE TABLE IF NOT EXISTS "ModerationAuditLog" (
+		id VARCHAR(255) PRIMARY KEY,
+		moderator_id VARCHAR(255),
+		action VARCHAR(20) NOT NULL,
+		report_id VARCHAR(255),
+		target_type VARCHAR(20) NOT NULL,
+		target_id VARCHAR(255) NOT NULL,
+		details TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT fk_moderator_audit FOREIGN KEY (moderator_id) REFERENCES "User" (id) ON DELETE SET NULL,
+		CONSTRAINT fk_report_audit FOREIGN KEY (report_id) REFERENCES "Report" (id) ON DELETE SET NULL
+	);`
+
+	createWarningTableQuery = `
+	CREATE TABLE IF NOT EXISTS "Warning" (
+		id VARCHAR(255) PRIMARY KEY,
+		user_id VARCHAR(255) NOT NULL,
+		report_id VARCHAR(255),
+		moderator_id VARCHAR(255),
+		reason VARCHAR(50) NOT NULL DEFAULT '',
+		note TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT fk_user_warning FOREIGN KEY (user_id) REFERENCES "User" (id) ON DELETE CASCADE,
+		CONSTRAINT fk_report_warning FOREIGN KEY (report_id) REFERENCES "Report" (id) ON DELETE SET NULL,
+		CONSTRAINT fk_moderator_warning FOREIGN KEY (moderator_id) REFERENCES "User" (id) ON DELETE SET NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_warning_user ON "Warning" (user_id, created_at DESC);`
+
+	createBlockTableQuery = `
+	CREATE TABLE IF NOT EXISTS "Block" (
+		user_id VARCHAR(255) NOT NULL,
+		blocked_user_id VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT fk_user_block FOREIGN KEY (user_id) REFERENCES "User" (id) ON DELETE CASCADE,
+		CONSTRAINT fk_blocked_user_block FOREIGN KEY (blocked_user_id) REFERENCES "User" (id) ON DELETE CASCADE,
+		PRIMARY KEY (user_id, blocked_user_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_block_blocked_user ON "Block" (blocked_user_id);`
+
+	createMuteTableQuery = `
+	CREATE TABLE IF NOT EXISTS "Mute" (
+		user_id VARCHAR(255) NOT NULL,
+		muted_user_id VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT fk_user_mute FOREIGN KEY (user_id) REFERENCES "User" (id) ON DELETE CASCADE,
+		CONSTRAINT fk_muted_user_mute FOREIGN KEY (muted_user_id) REFERENCES "User" (id) ON DELETE CASCADE,
+		PRIMARY KEY (user_id, muted_user_id)
+	);`
+
+	alterUserDeletionColumnsQuery = `
+	ALTER TABLE "User" ADD COLUMN IF NOT EXISTS deletion_requested_at TIMESTAMP;
+	ALTER TABLE "User" ADD COLUMN IF NOT EXISTS deletion_scheduled_at TIMESTAMP;
+	CREATE INDEX IF NOT EXISTS idx_user_deletion_scheduled ON "User" (deletion_scheduled_at)
+		WHERE deletion_scheduled_at IS NOT NULL;`
+
+	alterUserPrivacyColumnsQuery = `
+	ALTER TABLE "User" ADD COLUMN IF NOT EXISTS private_profile BOOLEAN NOT NULL DEFAULT FALSE;
+	ALTER TABLE "User" ADD COLUMN IF NOT EXISTS hide_likes BOOLEAN NOT NULL DEFAULT FALSE;`
+
+	createFollowTableQuery = `
+	CREATE TABLE IF NOT EXISTS "Follow" (
+		user_id VARCHAR(255) NOT NULL,
+		followed_user_id VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT fk_user_follow FOREIGN KEY (user_id) REFERENCES "User" (id) ON DELETE CASCADE,
+		CONSTRAINT fk_followed_user_follow FOREIGN KEY (followed_user_id) REFERENCES "User" (id) ON DELETE CASCADE,
+		PRIMARY KEY (user_id, followed_user_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_follow_followed_user ON "Follow" (followed_user_id);`
+
+	createUsernameHistoryTableQuery = `
+	CREATE TABLE IF NOT EXISTS "UsernameHistory" (
+		username VARCHAR(50) PRIMARY KEY,
+		user_id VARCHAR(255) NOT NULL,
+		changed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT fk_user_username_history FOREIGN KEY (user_id) REFERENCES "User" (id) ON DELETE CASCADE
+	);`
+
+	// Usernames are unique regardless of case. The migration stops and lists
+	// the accounts whose usernames clash, so they can be renamed by hand
+	// before the index is built.
+	createUsernameLowerIndexQuery = `
+	DO $$
+	DECLARE
+		clashes TEXT;
+	BEGIN
+		SELECT string_agg(u.username, ', ' ORDER BY lower(u.username), u.username) INTO clashes
+		FROM "User" u
+		WHERE EXISTS (
+			SELECT 1 FROM "User" o
+			WHERE o.id <> u.id AND lower(o.username) = lower(u.username)
+		);
+		IF clashes IS NOT NULL THEN
+			RAISE EXCEPTION 'usernames differ only in case, rename them before migrating: %', clashes;
+		END IF;
+	END $$;
+	CREATE UNIQUE INDEX IF NOT EXISTS unique_user_username_lower ON "User" (lower(username));`
+
+	createMediaTableQuery = `
+	CREATE TABLE IF NOT EXISTS "Media" (
+		id VARCHAR(255) PRIMARY KEY,
+		user_id VARCHAR(255),
+		blob_id VARCHAR(255),
+		content_type VARCHAR(50) NOT NULL,
+		size BIGINT NOT NULL,
+		width INTEGER NOT NULL,
+		height INTEGER NOT NULL,
+		storage_key VARCHAR(255) NOT NULL,
+		thumbnail_key VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		expires_at TIMESTAMP,
+		CONSTRAINT fk_user_media FOREIGN KEY (user_id) REFERENCES "User" (id) ON DELETE SET NULL,
+		CONSTRAINT fk_blob_media FOREIGN KEY (blob_id) REFERENCES "Blob" (id) ON DELETE SET NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_media_blob ON "Media" (blob_id);
+	CREATE INDEX IF NOT EXISTS idx_media_expires ON "Media" (expires_at) WHERE expires_at IS NOT NULL;`
+
+	createLinkPreviewTableQuery = `
+	CREATE TABLE IF NOT EXISTS "LinkPreview" (
+		url TEXT PRIMARY KEY,
+		status VARCHAR(20) NOT NULL DEFAULT 'pending',
+		title TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		image_url TEXT NOT NULL DEFAULT '',
+		site_name VARCHAR(255) NOT NULL DEFAULT '',
+		error TEXT NOT NULL DEFAULT '',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		locked_until TIMESTAMP,
+		fetched_at TIMESTAMP,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);
+	CREATE INDEX IF NOT EXISTS idx_link_preview_queue ON "LinkPreview" (next_attempt_at)
+		WHERE status IN ('pending', 'fetching');`
+
+	createBookmarkTableQuery = `
+	CREATE TABLE IF NOT EXISTS "Bookmark" (
+		user_id VARCHAR(255) NOT NULL,
+		blob_id VARCHAR(255) NOT NULL,
+		author_id VARCHAR(255) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT fk_user_bookmark FOREIGN KEY (user_id) REFERENCES "User" (id) ON DELETE CASCADE,
+		CONSTRAINT fk_author_bookmark FOREIGN KEY (author_id) REFERENCES "User" (id) ON DELETE CASCADE,
+		PRIMARY KEY (user_id, blob_id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_bookmark_blob ON "Bookmark" (blob_id);
+	CREATE INDEX IF NOT EXISTS idx_bookmark_author ON "Bookmark" (author_id);
+
+	CREATE TABLE IF NOT EXISTS "BlobSnapshot" (
+		id VARCHAR(255) PRIMARY KEY,
+		user_id VARCHAR(255) NOT NULL,
+		content TEXT NOT NULL,
+		interests TEXT[] NOT NULL DEFAULT '{}',
+		blob_created_at TIMESTAMP NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT fk_user_blob_snapshot FOREIGN KEY (user_id) REFERENCES "User" (id) ON DELETE CASCADE
+	);`
+
+	// A reblog keeps is_reblog when its original expires or is deleted, so
+	// clients can show a "no longer available" placeholder in its place.
+	alterBlobReblogColumnsQuery = `
+	ALTER TABLE "Blob" ADD COLUMN IF NOT EXISTS reblog_of_id VARCHAR(255)
+		CONSTRAINT fk_reblog_blob REFERENCES "Blob" (id) ON DELETE SET NULL;
+	ALTER TABLE "Blob" ADD COLUMN IF NOT EXISTS is_reblog BOOLEAN NOT NULL DEFAULT FALSE;
+	CREATE INDEX IF NOT EXISTS idx_blob_reblog_of ON "Blob" (reblog_of_id) WHERE reblog_of_id IS NOT NULL;
+	CREATE UNIQUE INDEX IF NOT EXISTS unique_user_plain_reblog ON "Blob" (user_id, reblog_of_id)
+		WHERE is_reblog AND content = '';`
+
+	// Reactions replace the old "Like" table: its rows become "like"
+	// reactions on the first run and the table is dropped.
+	createReactionTableQuery = `
+	CREATE TABLE IF NOT EXISTS "Reaction" (
+		id VARCHAR(255) PRIMARY KEY,
+		user_id VARCHAR(255) NOT NULL,
+		blob_id VARCHAR(255) NOT NULL,
+		comment_id VARCHAR(255),
+		type VARCHAR(20) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		CONSTRAINT fk_user_reaction FOREIGN KEY (user_id) REFERENCES "User" (id) ON DELETE CASCADE,
+		CONSTRAINT fk_blob_reaction FOREIGN KEY (blob_id) REFERENCES "Blob" (id) ON DELETE CASCADE,
+		CONSTRAINT fk_comment_reaction FOREIGN KEY (comment_id) REFERENCES "Comment" (id) ON DELETE CASCADE
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS unique_user_blob_reaction ON "Reaction" (user_id, blob_id, type)
+		WHERE comment_id IS NULL;
+	CREATE UNIQUE INDEX IF NOT EXISTS unique_user_comment_reaction ON "Reaction" (user_id, comment_id, type)
+		WHERE comment_id IS NOT NULL;
+	CREATE INDEX IF NOT EXISTS idx_reaction_blob ON "Reaction" (blob_id);
+	CREATE INDEX IF NOT EXISTS idx_reaction_comment ON "Reaction" (comment_id) WHERE comment_id IS NOT NULL;
+
+	DO $$
+	BEGIN
+		IF to_regclass('"Like"') IS NOT NULL THEN
+			INSERT INTO "Reaction" (id, user_id, blob_id, type, created_at)
+			SELECT id, user_id, blob_id, 'like', created_at FROM "Like"
+			ON CONFLICT DO NOTHING;
+			DROP TABLE "Like" CASCADE;
+		END IF;
+	END
+	$$;`
+
+	// likes_count and comments_count are kept up to date by the triggers in
+	// blobCounterTriggers and repaired by reconcile_blob_counters.
+	alterBlobCounterColumnsQuery = `
+	ALTER TABLE "Blob" ADD COLUMN IF NOT EXISTS likes_count INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE "Blob" ADD COLUMN IF NOT EXISTS comments_count INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX IF NOT EXISTS idx_blob_trending ON "Blob" ((likes_count + 2 * comments_count) DESC, created_at DESC)
+		WHERE hidden_at IS NULL;`
+
+	// SchemaVersion records the schema versions the runner completed; the API
+	// reports ready only once the version it was built for is here.
+	createSchemaVersionTableQuery = `
+	CREATE TABLE IF NOT EXISTS "SchemaVersion" (
+		version INTEGER PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+	);`
+
+	recordSchemaVersionQuery = `INSERT INTO "SchemaVersion" (version) VALUES ($1) ON CONFLICT (version) DO NOTHING;`
+
+	blobCounterTriggers = `
+	CREATE OR REPLACE FUNCTION count_blob_likes()
+	RETURNS trigger AS $$
+	BEGIN
+		IF TG_OP = 'INSERT' AND NEW.comment_id IS NULL AND NEW.type = 'like' THEN
+			UPDATE "Blob" SET likes_count = likes_count + 1 WHERE id = NEW.blob_id;
+		ELSIF TG_OP = 'DELETE' AND OLD.comment_id IS NULL AND OLD.type = 'like' THEN
+			UPDATE "Blob" SET likes_count = GREATEST(likes_count - 1, 0) WHERE id = OLD.blob_id;
+		END IF;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS reaction_count_likes ON "Reaction";
+	CREATE TRIGGER reaction_count_likes
+		AFTER INSERT OR DELETE ON "Reaction"
+		FOR EACH ROW EXECUTE FUNCTION count_blob_likes();
+
+	-- Only visible comments count, so hiding and restoring one moves the
+	-- counter as well.
+	CREATE OR REPLACE FUNCTION count_blob_comments()
+	RETURNS trigger AS $$
+	DECLARE
+		old_visible integer := 0;
+		new_visible integer := 0;
+	BEGIN
+		IF TG_OP <> 'INSERT' AND OLD.hidden_at IS NULL THEN
+			old_visible := 1;
+		END IF;
+		IF TG_OP <> 'DELETE' AND NEW.hidden_at IS NULL THEN
+			new_visible := 1;
+		END IF;
+
+		IF TG_OP = 'DELETE' THEN
+			UPDATE "Blob" SET comments_count = GREATEST(comments_count - old_visible, 0) WHERE id = OLD.blob_id;
+		ELSIF new_visible <> old_visible THEN
+			UPDATE "Blob" SET comments_count = GREATEST(comments_count + new_visible - old_visible, 0) WHERE id = NEW.blob_id;
+		END IF;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS comment_count_comments ON "Comment";
+	CREATE TRIGGER comment_count_comments
+		AFTER INSERT OR DELETE OR UPDATE OF hidden_at ON "Comment"
+		FOR EACH ROW EXECUTE FUNCTION count_blob_comments();`
+
+	// reconcile_blob_counters repairs counters that drifted, e.g. after rows
+	// were changed with the triggers disabled, and returns how many blobs it
+	// fixed. The migration runs it once to fill the new columns; the pop
+	// cronjob runs it on every pass.
+	reconcileBlobCounters = `
+	CREATE OR REPLACE FUNCTION reconcile_blob_counters()
+	RETURNS integer AS $$
+	DECLARE
+		repaired integer;
+	BEGIN
+		UPDATE "Blob" b
+		SET likes_count = actual.likes_count,
+			comments_count = actual.comments_count
+		FROM (
+			SELECT
+				b.id,
+				(SELECT COUNT(*) FROM "Reaction" r
+					WHERE r.blob_id = b.id AND r.comment_id IS NULL AND r.type = 'like') AS likes_count,
+				(SELECT COUNT(*) FROM "Comment" c WHERE c.blob_id = b.id AND c.hidden_at IS NULL) AS comments_count
+			FROM "Blob" b
+		) actual
+		WHERE b.id = actual.id
+			AND (b.likes_count <> actual.likes_count OR b.comments_count <> actual.comments_count);
+		GET DIAGNOSTICS repaired = ROW_COUNT;
+		RETURN repaired;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	SELECT reconcile_blob_counters();`
+
+	purgeDeletedUsers = `
+	CREATE OR REPLACE FUNCTION purge_deleted_users()
+	RETURNS integer AS $$
+	DECLARE
+		purged integer;
+	BEGIN
+		IF to_regclass('"VerificationToken"') IS NOT NULL THEN
+			DELETE FROM "VerificationToken"
+			WHERE email IN (
+				SELECT email FROM "User"
+				WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at < NOW()
+			);
+		END IF;
+
+		DELETE FROM "User"
+		WHERE deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at < NOW();
+		GET DIAGNOSTICS purged = ROW_COUNT;
+		RETURN purged;
+	END;
+	$$ LANGUAGE plpgsql;`
+
+	expireBlobMedia = `
+	CREATE OR REPLACE FUNCTION expire_blob_media()
+	RETURNS trigger AS $$
+	BEGIN
+		UPDATE "Media" SET expires_at = NOW() WHERE blob_id = OLD.id;
+		RETURN OLD;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS blob_expire_media ON "Blob";
+	CREATE TRIGGER blob_expire_media
+		BEFORE DELETE ON "Blob"
+		FOR EACH ROW EXECUTE FUNCTION expire_blob_media();`
+
+	// Bookmarked blobs outlive the 24 hours as a snapshot shared by everyone
+	// who saved them. Bookmarks of blobs removed by their author or by a
+	// moderator are not snapshotted and are dropped here instead, together
+	// with snapshots nobody bookmarks anymore.
+	// pop_old_blobs returns how many blobs expired, for the cronjob metrics.
 	popBlobs = `
-	CREATE OR REPLACE FUNCTION pop_old_blobs()
-	RETURNS void AS $$
+	DROP FUNCTION IF EXISTS pop_old_blobs();
+	CREATE FUNCTION pop_old_blobs()
+	RETURNS integer AS $$
+	DECLARE
+		expired integer;
+	BEGIN
+		INSERT INTO "BlobSnapshot" (id, user_id, content, interests, blob_created_at)
+		SELECT
+			b.id,
+			b.user_id,
+			b.content,
+			ARRAY(
+				SELECT i.name FROM "_BlobToInterest" bi
+				JOIN "Interest" i ON i.id = bi.interest_id
+				WHERE bi.blob_id = b.id
+				ORDER BY i.name
+			),
+			b.created_at
+		FROM "Blob" b
+		WHERE b.created_at < NOW() - INTERVAL '24 hours'
+			AND b.hidden_at IS NULL
+			AND EXISTS (SELECT 1 FROM "Bookmark" bm WHERE bm.blob_id = b.id)
+		ON CONFLICT (id) DO NOTHING;
+
 		DELETE FROM "Blob"
 		WHERE created_at < NOW() - INTERVAL '24 hours';
-	$$ LANGUAGE sql;`
+		GET DIAGNOSTICS expired = ROW_COUNT;
+
+		DELETE FROM "Bookmark" bm
+		WHERE NOT EXISTS (SELECT 1 FROM "Blob" b WHERE b.id = bm.blob_id)
+			AND NOT EXISTS (SELECT 1 FROM "BlobSnapshot" s WHERE s.id = bm.blob_id);
+
+		DELETE FROM "BlobSnapshot" s
+		WHERE NOT EXISTS (SELECT 1 FROM "Bookmark" bm WHERE bm.blob_id = s.id);
+
+		RETURN expired;
+	END;
+	$$ LANGUAGE plpgsql;`
 
 	createViewListBlob = `
+		DROP VIEW IF EXISTS listBlobs;
 		CREATE VIEW listBlobs AS
 		SELECT
 			b.id,
@@ -119,20 +526,36 @@ const (
 			u.avatar_icon,
 			u.created_at AS user_created_at,
 			i.name AS interest_name,
-			(SELECT COUNT(*) FROM "Like" l WHERE l.blob_id = b.id) AS likes_count,
-			(SELECT COUNT(*) FROM "Comment" c WHERE c.blob_id = b.id) AS comments_count
+			b.likes_count,
+			(SELECT COALESCE(jsonb_object_agg(rc.type, rc.count), '{}')
+				FROM (SELECT type, COUNT(*) AS count FROM "Reaction"
+					WHERE blob_id = b.id AND comment_id IS NULL GROUP BY type) rc) AS reaction_counts,
+			b.comments_count,
+			(SELECT COUNT(*) FROM "Blob" rb WHERE rb.reblog_of_id = b.id AND rb.hidden_at IS NULL) AS reblogs_count,
+			b.is_reblog,
+			o.id AS original_id,
+			o.user_id AS original_user_id,
+			ou.username AS original_username,
+			ou.avatar_icon AS original_avatar_icon,
+			o.content AS original_content,
+			o.created_at AS original_created_at
 		FROM "Blob" b
 		LEFT JOIN "_BlobToInterest" bi ON bi.blob_id = b.id
 		LEFT JOIN "Interest" i ON bi.interest_id = i.id
 		LEFT JOIN "User" u ON b.user_id = u.id
+		LEFT JOIN "Blob" o ON o.id = b.reblog_of_id AND o.hidden_at IS NULL
+		LEFT JOIN "User" ou ON ou.id = o.user_id
+		WHERE b.hidden_at IS NULL
 		ORDER BY b.created_at DESC`
 )
 
-func runningQueries(db *sqlx.DB, ctx context.Context) {
+// runningQueries creates the database if needed and applies the schema,
+// stopping at the first statement that fails.
+func runningQueries(db *sqlx.DB, ctx context.Context, cfg config.Database) error {
 	span, ctx := opentracing.StartSpanFromContext(ctx, "BlobSchemas.Create")
 	defer span.Finish()
 
-	if _, err := db.ExecContext(ctx, createDatabaseQuery); err != nil {
+	if _, err := db.ExecContext(ctx, "CREATE DATABASE "+pq.QuoteIdentifier(cfg.Name)); err != nil {
 		log.Println("Database already exists, skipping creation:", err)
 	} else {
 		log.Println("Database created successfully.")
@@ -140,82 +563,111 @@ func runningQueries(db *sqlx.DB, ctx context.Context) {
 
 	log.Println("Database created successfully (or already exists).")
 
-	defer db.Close()
-
-	dbConnection, err := ConnectDB("")
+	dbConnection, err := database.Connect(ctx, cfg, cfg.Name)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return fmt.Errorf("failed to connect to database: %w", err)
 	}
+	defer dbConnection.Close()
 
 	queries := []string{
 		createUserTableQuery,
 		createInterestTableQuery,
 		createBlobTableQuery,
 		createCommentTableQuery,
-		createLikeTableQuery,
 		createBlobInterestTableQuery,
 		createSessionTableQuery,
+		createMentionTableQuery,
+		alterModerationColumnsQuery,
+		createReportTableQuery,
+		createModerationAuditLogTableQuery,
+		createWarningTableQuery,
+		createBlockTableQuery,
+		createMuteTableQuery,
+		alterUserDeletionColumnsQuery,
+		alterUserPrivacyColumnsQuery,
+		createFollowTableQuery,
+		createUsernameHistoryTableQuery,
+		createUsernameLowerIndexQuery,
+		createMediaTableQuery,
+		createLinkPreviewTableQuery,
+		createBookmarkTableQuery,
+		alterBlobReblogColumnsQuery,
+		createReactionTableQuery,
+		alterBlobCounterColumnsQuery,
+		createSchemaVersionTableQuery,
 	}
 
 	for _, query := range queries {
 		if _, err := dbConnection.ExecContext(ctx, query); err != nil {
-			log.Fatalf("Failed to execute query: %v", err)
+			return fmt.Errorf("failed to execute query: %w", err)
 		}
 	}
 
 	log.Println("All tables created successfully.")
 
 	if _, err := dbConnection.ExecContext(ctx, popBlobs); err != nil {
-		log.Fatalf("Failed to create delete_old_blobs function: %v", err)
+		return fmt.Errorf("failed to create delete_old_blobs function: %w", err)
+	}
+	if _, err := dbConnection.ExecContext(ctx, expireBlobMedia); err != nil {
+		return fmt.Errorf("failed to create expire_blob_media trigger: %w", err)
+	}
+	if _, err := dbConnection.ExecContext(ctx, purgeDeletedUsers); err != nil {
+		return fmt.Errorf("failed to create purge_deleted_users function: %w", err)
+	}
+	if _, err := dbConnection.ExecContext(ctx, blobCounterTriggers); err != nil {
+		return fmt.Errorf("failed to create blob counter triggers: %w", err)
+	}
+	if _, err := dbConnection.ExecContext(ctx, reconcileBlobCounters); err != nil {
+		return fmt.Errorf("failed to create reconcile_blob_counters function: %w", err)
 	}
 	if _, err := dbConnection.ExecContext(ctx, createViewListBlob); err != nil {
-		log.Fatalf("Failed to create createViewListBlob view: %v", err)
+		return fmt.Errorf("failed to create createViewListBlob view: %w", err)
+	}
+
+	if _, err := dbConnection.ExecContext(ctx, recordSchemaVersionQuery, database.SchemaVersion); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
 	}
  
 	log.Println("Database, schema, and function created successfully.")
+	return nil
 }
 
 
-func ConnectDB(dbname string) (*sqlx.DB, error) {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment variables.")
-	}
+// run applies the schema. It returns instead of exiting so main can flush the
+// traces of a failed run before the process ends.
+func run(ctx context.Context, cfg *config.Config) error {
+	// Building indexes and backfilling counters can take longer than any
+	// API query is allowed to.
+	cfg.Database.StatementTimeout = 0
 
-	var (
-		host     = os.Getenv("DB_HOST")
-		port     = os.Getenv("DB_PORT")
-		user     = os.Getenv("DB_USER")
-		password = os.Getenv("DB_PASSWD")
-	)
-	
-	if dbname == "" {
-		dbname = os.Getenv("DB_DATABASE")
+	dbConnection, err := database.Connect(ctx, cfg.Database, "postgres")
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
 	}
+	defer dbConnection.Close()
 
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
+	return runningQueries(dbConnection, ctx, cfg.Database)
+}
 
-	db, err := sqlx.Open("postgres", psqlInfo)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+func main() { 
+	if err := godotenv.Load(); err != nil {
+		log.Println("No .env file found, using system environment variables.")
 	}
-	
-	err = db.Ping()
+
+	cfg, err := config.Load("blob-migrations", os.Args[1:])
 	if err != nil {
-		return nil, fmt.Errorf("failed to connect to database: %w", err)
+		log.Fatalf("Failed to load configuration: %v", err)
 	}
+	logging.Setup(cfg.Logging)
 
-	fmt.Println("Connected to " + dbname)
-	return db, nil
-}
+	shutdownTracing, err := telemetry.Setup(context.Background(), cfg.Telemetry, "blob-migrations")
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
 
-func main() { 
-	dbConnection, err := ConnectDB("postgres")
+	err = run(context.Background(), cfg)
+	shutdownTracing(context.Background())
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		log.Fatalf("Migrations failed: %v", err)
 	}
-	defer dbConnection.Close()
-	ctx := context.Background()
-	 
-	runningQueries(dbConnection, ctx)
 }